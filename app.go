@@ -3,32 +3,153 @@ package main
 import (
 	"context"
 	"fmt"
+	"localflix-server/src/appdata"
 	"localflix-server/src/db"
+	"localflix-server/src/logging"
 	"localflix-server/src/models"
 	"localflix-server/src/services"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
 // App struct
 type App struct {
-	ctx             context.Context
-	FoldersService  services.FoldersService
-	CategoryService services.CategoriesService
-	StreamService   services.StreamService
+	ctx                     context.Context
+	FoldersService          services.FoldersService
+	CategoryService         services.CategoriesService
+	StreamService           services.StreamService
+	ApiKeysService          services.ApiKeysService
+	UsersService            services.UsersService
+	SettingsService         services.SettingsService
+	CertificateService      services.CertificateService
+	RateLimitService        *services.RateLimitService
+	AuditService            services.AuditService
+	BackupService           services.BackupService
+	ScanService             services.ScanService
+	SeriesService           services.SeriesService
+	MetadataService         services.MetadataService
+	SearchService           services.SearchService
+	MediaItemsService       services.MediaItemsService
+	TagsService             services.TagsService
+	CollectionsService      services.CollectionsService
+	SmartCollectionsService services.SmartCollectionsService
+	WatchProgressService    services.WatchProgressService
+	MediaToolkit            services.MediaToolkit
+	Logging                 *logging.Logging
+	logger                  *slog.Logger
+	consoleLogLevel         slog.Level
+	directoryPicker         DirectoryPicker
+	dirs                    *appdata.Dirs
 }
 
 // NewApp creates a new App application struct
-func NewApp() *App {
-	return &App{}
+func NewApp(dirs *appdata.Dirs) *App {
+	return &App{
+		directoryPicker: wailsDirectoryPicker{},
+		dirs:            dirs,
+	}
 }
 
 // startup is called when the app starts. The context is saved
 // so we can call the runtime methods
 func (a *App) startup(ctx context.Context) {
+	if err := a.init(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "error starting app: %v\n", err)
+		panic(err)
+	}
+}
+
+// init opens the database and creates the services, it's shared by the
+// desktop app, the headless server and the command line
+func (a *App) init(ctx context.Context) error {
 	a.ctx = ctx
-	appDatabase := db.NewAppDatabase()
-	a.FoldersService = *services.NewFoldersService(a.ctx, appDatabase.Db)
-	a.CategoryService = *services.NewCategoriesService(a.ctx, appDatabase.Db)
-	a.StreamService = *services.NewStreamService(a.FoldersService, *services.NewVideoFileService(), a.CategoryService)
+	var err error
+	a.Logging, err = logging.New(a.dirs.Logs(), os.Stderr, a.consoleLogLevel)
+	if err != nil {
+		return err
+	}
+	a.logger = a.Logging.Logger(models.LogSubsystemApp)
+	slog.SetDefault(a.logger)
+
+	appDatabase, err := db.OpenAppDatabase(a.dirs.Database(), a.Logging.Logger(models.LogSubsystemDb))
+	if err != nil {
+		return err
+	}
+	// The levels are only known once the database is open, migrations are
+	// logged at the default level
+	a.SettingsService = *services.NewSettingsService(a.ctx, appDatabase.Db, a.logger)
+	logSettings, err := a.SettingsService.GetLogSettings()
+	if err == nil {
+		err = a.Logging.Apply(*logSettings)
+	}
+	if err != nil {
+		a.logger.Error("loading log settings", "err", err)
+	}
+
+	libraryLogger := a.Logging.Logger(models.LogSubsystemLibrary)
+	a.FoldersService = *services.NewFoldersService(a.ctx, appDatabase.Db, a.dirs, libraryLogger)
+	a.CategoryService = *services.NewCategoriesService(a.ctx, appDatabase.Db, a.dirs, libraryLogger)
+	a.ApiKeysService = *services.NewApiKeysService(a.ctx, appDatabase.Db, a.Logging.Logger(models.LogSubsystemAuth))
+	a.UsersService = *services.NewUsersService(a.ctx, appDatabase.Db, a.Logging.Logger(models.LogSubsystemAuth))
+	a.AuditService = *services.NewAuditService(a.ctx, appDatabase.Db, a.logger)
+	if err := a.AuditService.PruneAuditEvents(); err != nil {
+		a.logger.Error("pruning audit events", "err", err)
+	}
+	a.BackupService = *services.NewBackupService(a.ctx, appDatabase.Db, a.dirs, libraryLogger)
+	a.MediaToolkit = a.newMediaToolkit()
+	a.ScanService = *services.NewScanService(a.ctx, appDatabase.Db, a.MediaToolkit, libraryLogger)
+	a.SeriesService = *services.NewSeriesService(a.ctx, appDatabase.Db, libraryLogger)
+	a.MetadataService = *services.NewMetadataService(a.ctx, appDatabase.Db, a.newMetadataProviders(), a.dirs, libraryLogger)
+	a.MediaItemsService = *services.NewMediaItemsService(a.ctx, appDatabase.Db, libraryLogger)
+	a.TagsService = *services.NewTagsService(a.ctx, appDatabase.Db, libraryLogger)
+	a.CollectionsService = *services.NewCollectionsService(a.ctx, appDatabase.Db, libraryLogger)
+	a.SmartCollectionsService = *services.NewSmartCollectionsService(a.ctx, appDatabase.Db, libraryLogger)
+	a.WatchProgressService = *services.NewWatchProgressService(a.ctx, appDatabase.Db, libraryLogger)
+	a.SearchService = *services.NewSearchService(a.ctx, appDatabase.Db, libraryLogger)
+	if err := a.SearchService.EnsureIndex(); err != nil {
+		a.logger.Error("preparing search index", "err", err)
+	}
+	a.CertificateService = *services.NewCertificateService(a.dirs.TLS(), a.logger)
+	rateLimitSettings, err := a.SettingsService.GetRateLimitSettings()
+	if err != nil {
+		a.logger.Error("loading rate limit settings", "err", err)
+		rateLimitSettings = &models.RateLimitSettings{}
+	}
+	a.RateLimitService = services.NewRateLimitService(*rateLimitSettings)
+	a.StreamService = *services.NewStreamService(a.FoldersService, a.MediaToolkit, a.CategoryService, a.ApiKeysService, a.SettingsService, a.CertificateService, a.RateLimitService, a.AuditService, a.ScanService, a.SeriesService, a.MetadataService, a.SearchService, a.MediaItemsService, a.TagsService, a.CollectionsService, a.SmartCollectionsService, a.WatchProgressService, a.dirs, a.Logging.Logger(models.LogSubsystemHttp))
+	return nil
+}
+
+// newMediaToolkit finds ffmpeg. Without it the app still runs, features
+// needing it fail with the reason.
+func (a *App) newMediaToolkit() services.MediaToolkit {
+	toolkit, err := services.NewFFmpegToolkit(a.Logging.Logger(models.LogSubsystemMedia))
+	if err != nil {
+		a.logger.Info("ffmpeg not found", "err", err)
+		return services.NewUnavailableMediaToolkit(err)
+	}
+
+	return toolkit
+}
+
+// newMetadataProviders registers the metadata providers. A fixture file set
+// in the environment is added for trying matching offline.
+func (a *App) newMetadataProviders() *services.MetadataProviderRegistry {
+	providers := services.NewMetadataProviderRegistry()
+	if fixturesPath := os.Getenv(services.EnvMetadataFixtures); fixturesPath != "" {
+		provider, err := services.NewFixtureMetadataProvider(fixturesPath)
+		if err == nil {
+			err = providers.Register(provider)
+		}
+		if err != nil {
+			a.logger.Warn("loading metadata fixtures", "path", fixturesPath, "err", err)
+		}
+	}
+
+	return providers
 }
 
 // Greet returns a greeting for the given name
@@ -36,47 +157,375 @@ func (a *App) Greet(name string) string {
 	return fmt.Sprintf("Hello %s, It's show time!", name)
 }
 
-func (a *App) CreateFolderSource(categoryId int) {
-	folderPath := a.FoldersService.SelectFolderSource()
-	a.FoldersService.CreateFolder(folderPath, categoryId)
+// CreateFolderSource asks for a folder and adds it to the category. It
+// returns nil without an error when the dialog was canceled.
+func (a *App) CreateFolderSource(categoryId int) (*models.Folder, error) {
+	folderPath, err := a.directoryPicker.PickDirectory(a.ctx, "Select a source folder")
+	if err != nil {
+		a.logger.Error("selecting folder", "err", err)
+		return nil, err
+	}
+	if folderPath == "" {
+		return nil, nil
+	}
+
+	return a.FoldersService.CreateFolder(folderPath, categoryId)
 }
 
 func (a *App) DeleteFolder(id int) error {
 	return a.FoldersService.DeleteFolder(id)
 }
 
-func (a *App) ListFolders() []models.Folder {
-	return a.FoldersService.ListFolders()
+func (a *App) ListFolders(options models.ListOptions) (*models.FolderPage, error) {
+	return a.FoldersService.ListFoldersPage(options)
+}
+
+func (a *App) ListFolderByCategory(categoryId int, options models.ListOptions) (*models.FolderPage, error) {
+	return a.FoldersService.ListFolderByCategoryPage(categoryId, options)
 }
 
-func (a *App) ListFolderByCategory(categoryId int) []models.Folder {
-	return a.FoldersService.ListFolderByCategory(categoryId)
+func (a *App) ListCategories(options models.ListOptions) (*models.CategoryPage, error) {
+	return a.CategoryService.ListCategoriesPage(options)
 }
 
-func (a *App) ListCategories() []models.Category {
-	return a.CategoryService.ListCategories()
+// ListMediaItems pages the scanned videos of the folder
+func (a *App) ListMediaItems(folderId int, options models.ListOptions) (*models.MediaItemPage, error) {
+	return a.MediaItemsService.ListMediaItems(folderId, options)
 }
 
 func (a *App) CreateCategory(name string) (*models.Category, error) {
-	categoryExists, err := a.CategoryService.GetCategoryByName(name)
+	return a.CategoryService.CreateCategory(name)
+}
+
+func (a *App) GetCategory(id int) (*models.Category, error) {
+	return a.CategoryService.GetCategory(id)
+}
+
+func (a *App) DeleteCategory(id int) error {
+	return a.CategoryService.DeleteCategory(id)
+}
+
+func (a *App) ListApiKeys() []models.ApiKey {
+	return a.ApiKeysService.ListApiKeys()
+}
+
+func (a *App) CreateApiKey(name string, scopes []string) (*models.ApiKey, error) {
+	return a.ApiKeysService.CreateApiKey(name, scopes)
+}
+
+func (a *App) RevokeApiKey(id int) error {
+	return a.ApiKeysService.RevokeApiKey(id)
+}
+
+// CreateUserApiKey adds a key for another device of the user
+func (a *App) CreateUserApiKey(userId int, name string, scopes []string) (*models.ApiKey, error) {
+	return a.ApiKeysService.CreateUserApiKey(userId, name, scopes)
+}
+
+func (a *App) ListUsers() ([]models.User, error) {
+	return a.UsersService.ListUsers()
+}
+
+// CreateUser creates the user and returns its first api key, the only time
+// the plain text key is available
+func (a *App) CreateUser(name string, scopes []string) (*models.ApiKey, error) {
+	_, apiKey, err := a.UsersService.CreateUser(name, scopes)
+	return apiKey, err
+}
+
+// DeleteUser deletes the user together with its api keys
+func (a *App) DeleteUser(id int) error {
+	return a.UsersService.DeleteUser(id)
+}
+
+func (a *App) GetCorsSettings() (*models.CorsSettings, error) {
+	return a.SettingsService.GetCorsSettings()
+}
+
+// UpdateCorsSettings saves the CORS policy, it's applied the next time the
+// server starts
+func (a *App) UpdateCorsSettings(settings models.CorsSettings) (*models.CorsSettings, error) {
+	return a.SettingsService.UpdateCorsSettings(settings)
+}
+
+func (a *App) GetTlsSettings() (*models.TlsSettings, error) {
+	return a.SettingsService.GetTlsSettings()
+}
+
+// UpdateTlsSettings saves the HTTPS configuration, it's applied the next time
+// the server starts
+func (a *App) UpdateTlsSettings(settings models.TlsSettings) (*models.TlsSettings, error) {
+	return a.SettingsService.UpdateTlsSettings(settings)
+}
+
+func (a *App) RegenerateCertificate() error {
+	return a.CertificateService.RegenerateSelfSignedCertificate()
+}
+
+func (a *App) GetRateLimitSettings() (*models.RateLimitSettings, error) {
+	return a.SettingsService.GetRateLimitSettings()
+}
+
+// UpdateRateLimitSettings saves the limits and applies them right away, even
+// to streams already playing
+func (a *App) UpdateRateLimitSettings(settings models.RateLimitSettings) (*models.RateLimitSettings, error) {
+	result, err := a.SettingsService.UpdateRateLimitSettings(settings)
 	if err != nil {
 		return nil, err
 	}
 
-	if categoryExists != nil {
-		fmt.Printf("Category already exists: %v\n", categoryExists)
-		return nil, fmt.Errorf("category already exists")
+	a.RateLimitService.Apply(*result)
+	return result, nil
+}
+
+func (a *App) ListAuditEvents(filter models.AuditFilter) ([]models.AuditEvent, error) {
+	return a.AuditService.ListAuditEvents(filter)
+}
+
+func (a *App) ScanLibrary() ([]models.ScanResult, error) {
+	return a.ScanService.ScanLibrary()
+}
+
+func (a *App) ScanFolder(folderId int) (*models.ScanResult, error) {
+	return a.ScanService.ScanFolder(folderId)
+}
+
+// GetMediaItemDetails returns the media item with its metadata, artwork is
+// given as paths on this machine
+func (a *App) GetMediaItemDetails(id int) (*models.MediaItemDetails, error) {
+	return a.MetadataService.GetMediaItemDetails(id)
+}
+
+// ListMediaItemVersions lists the versions of the media item found in any
+// folder, itself included
+func (a *App) ListMediaItemVersions(id int) ([]models.MediaItem, error) {
+	return a.MediaItemsService.ListVersions(id)
+}
+
+// ListMediaItemParts lists the parts of a video split in several files, or
+// the media item alone
+func (a *App) ListMediaItemParts(id int) ([]models.MediaItem, error) {
+	return a.MediaItemsService.ListParts(id)
+}
+
+func (a *App) ListMetadataProviders() []string {
+	return a.MetadataService.ListMetadataProviders()
+}
+
+func (a *App) GetMetadataSettings() (*models.MetadataSettings, error) {
+	return a.SettingsService.GetMetadataSettings()
+}
+
+func (a *App) UpdateMetadataSettings(settings models.MetadataSettings) (*models.MetadataSettings, error) {
+	return a.SettingsService.UpdateMetadataSettings(settings)
+}
+
+func (a *App) GetScanSettings() (*models.ScanSettings, error) {
+	return a.SettingsService.GetScanSettings()
+}
+
+func (a *App) UpdateScanSettings(settings models.ScanSettings) (*models.ScanSettings, error) {
+	return a.SettingsService.UpdateScanSettings(settings)
+}
+
+// SearchMetadataMatches lists the candidates for the media item, an empty
+// title searches for the one parsed from its file name
+func (a *App) SearchMetadataMatches(mediaItemId int, provider string, query models.MetadataQuery) ([]models.MetadataMatch, error) {
+	return a.MetadataService.SearchMatches(mediaItemId, provider, query)
+}
+
+// FixMetadataMatch sets the media item's metadata to the chosen match for good
+func (a *App) FixMetadataMatch(mediaItemId int, provider string, id string) (*models.MediaItemDetails, error) {
+	return a.MetadataService.FixMatch(mediaItemId, provider, id)
+}
+
+func (a *App) MatchFolderMetadata(folderId int) ([]models.MatchResult, error) {
+	return a.MetadataService.MatchFolder(folderId)
+}
+
+// Search finds media items by title, file name, plot, cast and subtitles,
+// best matches first
+func (a *App) Search(filter models.SearchFilter) ([]models.SearchResult, error) {
+	return a.SearchService.Search(filter)
+}
+
+func (a *App) ListSeries(options models.ListOptions) (*models.SeriesPage, error) {
+	return a.SeriesService.ListSeries(options)
+}
+
+func (a *App) ListSeasons(seriesId int, options models.ListOptions) (*models.SeasonPage, error) {
+	return a.SeriesService.ListSeasons(seriesId, options)
+}
+
+func (a *App) ListEpisodes(seasonId int, options models.ListOptions) (*models.EpisodePage, error) {
+	return a.SeriesService.ListEpisodes(seasonId, options)
+}
+
+// ListTags pages the tags of the kind, "tag" or "genre", or of every kind
+// when kind is empty
+func (a *App) ListTags(kind string, options models.ListOptions) (*models.TagPage, error) {
+	return a.TagsService.ListTags(kind, options)
+}
+
+func (a *App) CreateTag(name string) (*models.Tag, error) {
+	return a.TagsService.CreateTag(name)
+}
+
+func (a *App) RenameTag(id int, name string) (*models.Tag, error) {
+	return a.TagsService.RenameTag(id, name)
+}
+
+func (a *App) DeleteTag(id int) error {
+	return a.TagsService.DeleteTag(id)
+}
+
+// SetMediaItemTags replaces the tags set by hand on the media item, its
+// genres follow the metadata
+func (a *App) SetMediaItemTags(mediaItemId int, tags []string) ([]models.Tag, error) {
+	return a.TagsService.SetMediaItemTags(mediaItemId, tags)
+}
+
+func (a *App) ListCollections(options models.ListOptions) (*models.CollectionPage, error) {
+	return a.CollectionsService.ListCollections(options)
+}
+
+func (a *App) GetCollection(id int) (*models.Collection, error) {
+	return a.CollectionsService.GetCollection(id)
+}
+
+func (a *App) CreateCollection(name string, description string) (*models.Collection, error) {
+	return a.CollectionsService.CreateCollection(name, description)
+}
+
+func (a *App) UpdateCollection(id int, name string, description string) (*models.Collection, error) {
+	return a.CollectionsService.UpdateCollection(id, name, description)
+}
+
+func (a *App) DeleteCollection(id int) error {
+	return a.CollectionsService.DeleteCollection(id)
+}
+
+// ListCollectionItems pages the media items of the collection, in the
+// collection's order unless sorted otherwise
+func (a *App) ListCollectionItems(id int, options models.ListOptions) (*models.MediaItemPage, error) {
+	return a.CollectionsService.ListCollectionItems(id, options)
+}
+
+// SetCollectionItems replaces the media items of the collection, in the
+// order given
+func (a *App) SetCollectionItems(id int, mediaItemIds []int) (*models.Collection, error) {
+	return a.CollectionsService.SetCollectionItems(id, mediaItemIds)
+}
+
+// AddCollectionItem inserts the media item at position, or at the end when
+// position is negative
+func (a *App) AddCollectionItem(id int, mediaItemId int, position int) (*models.Collection, error) {
+	return a.CollectionsService.AddCollectionItem(id, mediaItemId, position)
+}
+
+func (a *App) RemoveCollectionItem(id int, mediaItemId int) (*models.Collection, error) {
+	return a.CollectionsService.RemoveCollectionItem(id, mediaItemId)
+}
+
+func (a *App) ListSmartCollections(options models.ListOptions) (*models.SmartCollectionPage, error) {
+	return a.SmartCollectionsService.ListSmartCollections(options)
+}
+
+func (a *App) GetSmartCollection(id int) (*models.SmartCollection, error) {
+	return a.SmartCollectionsService.GetSmartCollection(id)
+}
+
+func (a *App) CreateSmartCollection(collection models.SmartCollection) (*models.SmartCollection, error) {
+	return a.SmartCollectionsService.CreateSmartCollection(collection)
+}
+
+// UpdateSmartCollection replaces the name, rules and sort of the smart
+// collection
+func (a *App) UpdateSmartCollection(id int, collection models.SmartCollection) (*models.SmartCollection, error) {
+	return a.SmartCollectionsService.UpdateSmartCollection(id, collection)
+}
+
+func (a *App) DeleteSmartCollection(id int) error {
+	return a.SmartCollectionsService.DeleteSmartCollection(id)
+}
+
+// ListSmartCollectionItems evaluates the rules of the smart collection and
+// pages the media items matching them
+func (a *App) ListSmartCollectionItems(id int, options models.ListOptions) (*models.MediaItemPage, error) {
+	return a.SmartCollectionsService.ListSmartCollectionItems(id, options)
+}
+
+// PreviewSmartRules pages the media items matching rules not saved yet
+func (a *App) PreviewSmartRules(rules models.SmartRule, options models.ListOptions) (*models.MediaItemPage, error) {
+	return a.SmartCollectionsService.PreviewSmartRules(rules, options)
+}
+
+// BackupDatabase asks where to save and writes a copy of the database there.
+// It returns the chosen path, or "" when the dialog was canceled.
+func (a *App) BackupDatabase() (string, error) {
+	path, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:           "Back up database",
+		DefaultFilename: fmt.Sprintf("localflix-%s.db", time.Now().Format("2006-01-02")),
+	})
+	if err != nil || path == "" {
+		return "", err
 	}
 
-	return a.CategoryService.CreateCategory(name)
+	return path, a.BackupService.BackupDatabase(path)
 }
 
-func (a *App) GetCategory(id int) (*models.Category, error) {
-	return a.CategoryService.GetCategory(id)
+// ExportLibrary asks where to save and writes the library JSON export there.
+// It returns the chosen path, or "" when the dialog was canceled.
+func (a *App) ExportLibrary() (string, error) {
+	path, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:           "Export library",
+		DefaultFilename: fmt.Sprintf("localflix-%s.json", time.Now().Format("2006-01-02")),
+		Filters:         []runtime.FileFilter{{DisplayName: "JSON", Pattern: "*.json"}},
+	})
+	if err != nil || path == "" {
+		return "", err
+	}
+
+	return path, a.BackupService.ExportLibraryToFile(path)
 }
 
-func (a *App) DeleteCategory(id int) error {
-	return a.CategoryService.DeleteCategory(id)
+// ImportLibrary asks for a library JSON export and merges it, rewriting
+// folder paths with remaps. It returns nil when the dialog was canceled.
+func (a *App) ImportLibrary(remaps []models.PathRemap) (*models.ImportResult, error) {
+	path, err := runtime.OpenFileDialog(a.ctx, runtime.OpenDialogOptions{
+		Title:   "Import library",
+		Filters: []runtime.FileFilter{{DisplayName: "JSON", Pattern: "*.json"}},
+	})
+	if err != nil || path == "" {
+		return nil, err
+	}
+
+	return a.BackupService.ImportLibraryFromFile(path, remaps)
+}
+
+// GetMediaToolkitStatus reports whether ffmpeg was found and which version
+func (a *App) GetMediaToolkitStatus() models.MediaToolkitStatus {
+	return a.MediaToolkit.Status()
+}
+
+func (a *App) GetLogSettings() (*models.LogSettings, error) {
+	return a.SettingsService.GetLogSettings()
+}
+
+// UpdateLogSettings saves the log levels and applies them right away
+func (a *App) UpdateLogSettings(settings models.LogSettings) (*models.LogSettings, error) {
+	result, err := a.SettingsService.UpdateLogSettings(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, a.Logging.Apply(*result)
+}
+
+// ListLogEntries returns the newest log entries matching the filter, for the
+// log viewer
+func (a *App) ListLogEntries(filter models.LogFilter) ([]models.LogEntry, error) {
+	return a.Logging.ListEntries(filter)
 }
 
 func (a *App) StartServer() {
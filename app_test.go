@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"localflix-server/src/appdata"
+	"localflix-server/src/models"
+	"testing"
+)
+
+// fakeDirectoryPicker returns path as if the user had picked it
+type fakeDirectoryPicker struct {
+	path string
+}
+
+func (f fakeDirectoryPicker) PickDirectory(ctx context.Context, title string) (string, error) {
+	return f.path, nil
+}
+
+func newTestApp(t *testing.T, picker DirectoryPicker) *App {
+	t.Helper()
+
+	app := NewApp(&appdata.Dirs{Root: t.TempDir()})
+	app.directoryPicker = picker
+	if err := app.init(context.Background()); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+
+	return app
+}
+
+func TestCreateFolderSource(t *testing.T) {
+	dir := t.TempDir()
+	app := newTestApp(t, fakeDirectoryPicker{path: dir})
+	category, err := app.CreateCategory("Movies")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	folder, err := app.CreateFolderSource(category.ID)
+	if err != nil {
+		t.Fatalf("CreateFolderSource: %v", err)
+	}
+	if folder == nil || folder.Path != dir {
+		t.Errorf("CreateFolderSource = %+v, want the picked folder %s", folder, dir)
+	}
+}
+
+func TestCreateFolderSourceCanceled(t *testing.T) {
+	app := newTestApp(t, fakeDirectoryPicker{})
+	category, err := app.CreateCategory("Movies")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	folder, err := app.CreateFolderSource(category.ID)
+	if folder != nil || err != nil {
+		t.Errorf("CreateFolderSource = %+v, %v, want nil for a canceled dialog", folder, err)
+	}
+	if page, err := app.ListFolders(models.ListOptions{}); err != nil || page.Total != 0 {
+		t.Errorf("got folders %+v, %v, want none", page, err)
+	}
+}
@@ -0,0 +1,640 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"localflix-server/src/appdata"
+	"localflix-server/src/models"
+	"localflix-server/src/services"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+const usage = `Usage: localflix-server [--data-dir DIR] [command]
+
+Without a command the desktop app starts.
+
+Commands:
+  serve                              run the streaming server without the
+                                     desktop app, manage the library through
+                                     the /admin API
+  category add NAME                  create a category
+  category list                      list categories
+  category rm ID|NAME                delete a category with its folders
+  folder add PATH --category ID|NAME register a folder in a category
+  folder list                        list folders
+  folder rm ID                       unregister a folder
+  scan [--folder ID]                 scan the library, or only one folder
+  user add NAME [--scope SCOPE]      create a user with a first api key,
+                                     what each user watched is kept apart
+  user list                          list users
+  user rm ID|NAME                    delete a user and revoke its api keys
+  apikey add NAME [--scope SCOPE]    create an api key for a client, the
+         [--user ID|NAME]            scope can be repeated (library:read,
+                                     stream, admin). Without a user the key
+                                     is shared, like a TV in the living room
+  apikey list                        list api keys
+  apikey rm ID                       revoke an api key
+  settings get [GROUP]               print settings as JSON
+  settings set GROUP JSON            replace a settings group
+  settings set GROUP.FIELD VALUE     change a single setting
+  db backup FILE                     write a copy of the database to FILE
+  db export FILE                     export the library as JSON to FILE
+  db import FILE [--remap OLD=NEW]   import a JSON export, rewriting folder
+                                     paths starting with OLD to start with NEW
+
+Settings changes are applied the next time the server starts.
+`
+
+// runCommand runs a command line subcommand instead of the desktop app and
+// returns the process exit code. Results go to out, errors to errOut.
+func runCommand(dirs *appdata.Dirs, args []string, out io.Writer, errOut io.Writer) int {
+	if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		fmt.Fprint(out, usage)
+		return 0
+	}
+
+	commands := map[string]func(*App, []string, io.Writer) error{
+		"category": runCategoryCommand,
+		"folder":   runFolderCommand,
+		"scan":     runScanCommand,
+		"user":     runUserCommand,
+		"apikey":   runApiKeyCommand,
+		"settings": runSettingsCommand,
+		"db":       runDbCommand,
+	}
+
+	var err error
+	if args[0] == "serve" {
+		err = runServe(dirs, out)
+	} else if command, ok := commands[args[0]]; ok {
+		app := NewApp(dirs)
+		// Commands print their own results, the console only needs problems
+		app.consoleLogLevel = slog.LevelWarn
+		err = app.init(context.Background())
+		if err == nil {
+			err = command(app, args[1:], out)
+		}
+	} else {
+		err = fmt.Errorf("unknown command %q", args[0])
+	}
+
+	if err != nil {
+		fmt.Fprintf(errOut, "Error: %v\n\n", err)
+		fmt.Fprint(errOut, usage)
+		return 1
+	}
+
+	return 0
+}
+
+// runServe starts the services and the streaming server without Wails and
+// blocks until SIGINT or SIGTERM
+func runServe(dirs *appdata.Dirs, out io.Writer) error {
+	app := NewApp(dirs)
+	if err := app.init(context.Background()); err != nil {
+		return err
+	}
+
+	// Without the desktop app there is no other way to get the first key
+	if len(app.ListApiKeys()) == 0 {
+		apiKey, err := app.CreateApiKey("admin", []string{models.ScopeAdmin})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Created admin API key, it won't be shown again: %s\n", apiKey.Key)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		fmt.Fprintln(out, "Shutting down")
+		app.StopServer()
+	}()
+
+	return app.StreamService.Serve()
+}
+
+func runCategoryCommand(app *App, args []string, out io.Writer) error {
+	subcommand, positional, err := parseSubcommand("category", args, nil)
+	if err != nil {
+		return err
+	}
+
+	switch subcommand {
+	case "add":
+		if len(positional) != 1 {
+			return fmt.Errorf("category add needs a NAME")
+		}
+		category, err := app.CreateCategory(positional[0])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Created category %d %s\n", category.ID, category.Name)
+	case "list":
+		table := newTable(out, "ID", "NAME", "FOLDERS")
+		for _, category := range app.CategoryService.ListCategories() {
+			table.row(category.ID, category.Name, len(app.FoldersService.ListFolderByCategory(category.ID)))
+		}
+		table.flush()
+	case "rm":
+		if len(positional) != 1 {
+			return fmt.Errorf("category rm needs an ID or NAME")
+		}
+		category, err := findCategory(app, positional[0])
+		if err != nil {
+			return err
+		}
+		if err := app.DeleteCategory(category.ID); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Deleted category %d %s\n", category.ID, category.Name)
+	default:
+		return fmt.Errorf("unknown category subcommand %q", subcommand)
+	}
+
+	return nil
+}
+
+func runFolderCommand(app *App, args []string, out io.Writer) error {
+	var categoryRef string
+	subcommand, positional, err := parseSubcommand("folder", args, func(flags *flag.FlagSet) {
+		flags.StringVar(&categoryRef, "category", "", "category ID or name")
+	})
+	if err != nil {
+		return err
+	}
+
+	switch subcommand {
+	case "add":
+		if len(positional) != 1 || categoryRef == "" {
+			return fmt.Errorf("folder add needs a PATH and --category")
+		}
+		category, err := findCategory(app, categoryRef)
+		if err != nil {
+			return err
+		}
+		folder, err := app.FoldersService.CreateFolder(positional[0], category.ID)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Added folder %d %s to %s\n", folder.ID, folder.Path, category.Name)
+	case "list":
+		categoryNames := map[int]string{}
+		for _, category := range app.CategoryService.ListCategories() {
+			categoryNames[category.ID] = category.Name
+		}
+		table := newTable(out, "ID", "CATEGORY", "PATH")
+		for _, folder := range app.FoldersService.ListFolders() {
+			table.row(folder.ID, categoryNames[folder.CategoryID], folder.Path)
+		}
+		table.flush()
+	case "rm":
+		if len(positional) != 1 {
+			return fmt.Errorf("folder rm needs an ID")
+		}
+		folderId, err := strconv.Atoi(positional[0])
+		if err != nil {
+			return fmt.Errorf("invalid folder ID %q", positional[0])
+		}
+		if _, err := app.FoldersService.GetFolderById(folderId); err != nil {
+			return fmt.Errorf("folder %d not found", folderId)
+		}
+		if err := app.DeleteFolder(folderId); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Removed folder %d\n", folderId)
+	default:
+		return fmt.Errorf("unknown folder subcommand %q", subcommand)
+	}
+
+	return nil
+}
+
+func runScanCommand(app *App, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("scan", flag.ContinueOnError)
+	folderId := flags.Int("folder", 0, "only scan the folder with this ID")
+	positional, err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		return fmt.Errorf("scan takes no arguments")
+	}
+
+	var results []models.ScanResult
+	if *folderId != 0 {
+		result, err := app.ScanFolder(*folderId)
+		if err != nil {
+			return err
+		}
+		results = append(results, *result)
+	} else {
+		results, err = app.ScanLibrary()
+		if err != nil {
+			return err
+		}
+	}
+
+	table := newTable(out, "FOLDER", "ADDED", "UPDATED", "REMOVED", "UNCHANGED", "EPISODES", "ERRORS")
+	for _, result := range results {
+		table.row(result.FolderID, result.Added, result.Updated, result.Removed, result.Unchanged, result.Episodes, len(result.Errors))
+	}
+	table.flush()
+	for _, result := range results {
+		for _, scanErr := range result.Errors {
+			fmt.Fprintf(out, "Warning: folder %d: %s\n", result.FolderID, scanErr)
+		}
+	}
+
+	return nil
+}
+
+func runUserCommand(app *App, args []string, out io.Writer) error {
+	var scopes stringsFlag
+	subcommand, positional, err := parseSubcommand("user", args, func(flags *flag.FlagSet) {
+		flags.Var(&scopes, "scope", "scope to grant the first api key, can be repeated")
+	})
+	if err != nil {
+		return err
+	}
+
+	switch subcommand {
+	case "add":
+		if len(positional) != 1 {
+			return fmt.Errorf("user add needs a NAME")
+		}
+		if len(scopes) == 0 {
+			scopes = stringsFlag{models.ScopeLibraryRead, models.ScopeStream}
+		}
+		user, apiKey, err := app.UsersService.CreateUser(positional[0], scopes)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Created user %d %s with api key %d (%s), it won't be shown again:\n%s\n", user.ID, user.Name, apiKey.ID, strings.Join(apiKey.Scopes, ","), apiKey.Key)
+	case "list":
+		users, err := app.ListUsers()
+		if err != nil {
+			return err
+		}
+		table := newTable(out, "ID", "NAME", "API KEYS")
+		for _, user := range users {
+			table.row(user.ID, user.Name, user.ApiKeys)
+		}
+		table.flush()
+	case "rm":
+		if len(positional) != 1 {
+			return fmt.Errorf("user rm needs an ID or NAME")
+		}
+		user, err := findUser(app, positional[0])
+		if err != nil {
+			return err
+		}
+		if err := app.DeleteUser(user.ID); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Deleted user %d %s and its %d api keys\n", user.ID, user.Name, user.ApiKeys)
+	default:
+		return fmt.Errorf("unknown user subcommand %q", subcommand)
+	}
+
+	return nil
+}
+
+func runApiKeyCommand(app *App, args []string, out io.Writer) error {
+	var scopes stringsFlag
+	var userRef string
+	subcommand, positional, err := parseSubcommand("apikey", args, func(flags *flag.FlagSet) {
+		flags.Var(&scopes, "scope", "scope to grant, can be repeated")
+		flags.StringVar(&userRef, "user", "", "user ID or name the key belongs to")
+	})
+	if err != nil {
+		return err
+	}
+
+	switch subcommand {
+	case "add":
+		if len(positional) != 1 {
+			return fmt.Errorf("apikey add needs a NAME")
+		}
+		if len(scopes) == 0 {
+			scopes = stringsFlag{models.ScopeLibraryRead, models.ScopeStream}
+		}
+		var userId int
+		if userRef != "" {
+			user, err := findUser(app, userRef)
+			if err != nil {
+				return err
+			}
+			userId = user.ID
+		}
+		apiKey, err := app.CreateUserApiKey(userId, positional[0], scopes)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Created api key %d %s (%s), it won't be shown again:\n%s\n", apiKey.ID, apiKey.Name, strings.Join(apiKey.Scopes, ","), apiKey.Key)
+	case "list":
+		users, err := app.ListUsers()
+		if err != nil {
+			return err
+		}
+		userNames := map[int]string{0: "-"}
+		for _, user := range users {
+			userNames[user.ID] = user.Name
+		}
+		table := newTable(out, "ID", "NAME", "USER", "PREFIX", "SCOPES", "LAST USED")
+		for _, apiKey := range app.ListApiKeys() {
+			lastUsed := "never"
+			if apiKey.LastUsedAt != nil {
+				lastUsed = apiKey.LastUsedAt.Local().Format(time.DateTime)
+			}
+			table.row(apiKey.ID, apiKey.Name, userNames[apiKey.UserID], apiKey.Prefix, strings.Join(apiKey.Scopes, ","), lastUsed)
+		}
+		table.flush()
+	case "rm":
+		if len(positional) != 1 {
+			return fmt.Errorf("apikey rm needs an ID")
+		}
+		id, err := strconv.Atoi(positional[0])
+		if err != nil {
+			return fmt.Errorf("invalid api key ID %q", positional[0])
+		}
+		if err := app.RevokeApiKey(id); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Revoked api key %d\n", id)
+	default:
+		return fmt.Errorf("unknown apikey subcommand %q", subcommand)
+	}
+
+	return nil
+}
+
+func runSettingsCommand(app *App, args []string, out io.Writer) error {
+	subcommand, positional, err := parseSubcommand("settings", args, nil)
+	if err != nil {
+		return err
+	}
+
+	switch subcommand {
+	case "get":
+		groups := services.SettingsGroups
+		if len(positional) > 0 {
+			groups = positional
+		}
+		all := map[string]any{}
+		for _, group := range groups {
+			settings, err := app.SettingsService.GetSettingsGroup(group)
+			if err != nil {
+				return err
+			}
+			all[group] = settings
+		}
+		return printJSON(out, all)
+	case "set":
+		if len(positional) != 2 {
+			return fmt.Errorf("settings set needs a KEY and a VALUE")
+		}
+		group, field, isField := strings.Cut(positional[0], ".")
+		data := []byte(positional[1])
+		if isField {
+			data, err = setSettingsField(app, group, field, positional[1])
+			if err != nil {
+				return err
+			}
+		}
+		settings, err := app.SettingsService.UpdateSettingsGroup(group, data)
+		if err != nil {
+			return err
+		}
+		return printJSON(out, map[string]any{group: settings})
+	default:
+		return fmt.Errorf("unknown settings subcommand %q", subcommand)
+	}
+}
+
+// setSettingsField returns the group's settings as JSON with field replaced
+// by value. Values that aren't valid JSON are used as strings, and comma
+// separated values fill list fields.
+func setSettingsField(app *App, group string, field string, value string) ([]byte, error) {
+	settings, err := app.SettingsService.GetSettingsGroup(group)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	current, ok := fields[field]
+	if !ok {
+		return nil, fmt.Errorf("unknown %s setting %q", group, field)
+	}
+
+	var parsed any
+	if err := json.Unmarshal([]byte(value), &parsed); err != nil {
+		parsed = value
+	}
+	if _, isList := current.([]any); isList {
+		if text, isText := parsed.(string); isText {
+			parsed = strings.Split(text, ",")
+		}
+	}
+	fields[field] = parsed
+
+	return json.Marshal(fields)
+}
+
+func runDbCommand(app *App, args []string, out io.Writer) error {
+	var remaps remapFlag
+	subcommand, positional, err := parseSubcommand("db", args, func(flags *flag.FlagSet) {
+		flags.Var(&remaps, "remap", "rewrite folder paths, OLD=NEW, can be repeated")
+	})
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("db %s needs exactly one FILE argument", subcommand)
+	}
+	file := positional[0]
+
+	switch subcommand {
+	case "backup":
+		if err := app.BackupService.BackupDatabase(file); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Database backed up to %s\n", file)
+	case "export":
+		if err := app.BackupService.ExportLibraryToFile(file); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Library exported to %s\n", file)
+	case "import":
+		result, err := app.BackupService.ImportLibraryFromFile(file, remaps)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Imported %d categories, %d folders (%d already registered), %d settings, %d users, %d api keys and %d watch progress entries\n",
+			result.CategoriesCreated, result.FoldersCreated, result.FoldersSkipped, result.SettingsImported, result.UsersCreated, result.ApiKeysImported, result.ProgressImported)
+		for _, warning := range result.Warnings {
+			fmt.Fprintf(out, "Warning: %s\n", warning)
+		}
+	default:
+		return fmt.Errorf("unknown db subcommand %q", subcommand)
+	}
+
+	return nil
+}
+
+// findCategory looks a category up by ID, or by name when ref isn't a number
+func findCategory(app *App, ref string) (*models.Category, error) {
+	if id, err := strconv.Atoi(ref); err == nil {
+		category, err := app.GetCategory(id)
+		if err != nil {
+			return nil, fmt.Errorf("category %d not found", id)
+		}
+		return category, nil
+	}
+
+	category, err := app.CategoryService.GetCategoryByName(ref)
+	if err != nil {
+		return nil, err
+	}
+	if category == nil {
+		return nil, fmt.Errorf("category %q not found", ref)
+	}
+	return category, nil
+}
+
+// findUser looks a user up by ID, or by name when ref isn't a number
+func findUser(app *App, ref string) (*models.User, error) {
+	var user *models.User
+	var err error
+	if id, convErr := strconv.Atoi(ref); convErr == nil {
+		user, err = app.UsersService.GetUser(id)
+	} else {
+		user, err = app.UsersService.GetUserByName(ref)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, fmt.Errorf("user %q not found", ref)
+	}
+	return user, nil
+}
+
+// parseSubcommand splits args into the subcommand and its positional
+// arguments, defining flags with define when it's not nil
+func parseSubcommand(command string, args []string, define func(*flag.FlagSet)) (string, []string, error) {
+	if len(args) == 0 {
+		return "", nil, fmt.Errorf("missing %s subcommand", command)
+	}
+
+	flags := flag.NewFlagSet(command+" "+args[0], flag.ContinueOnError)
+	if define != nil {
+		define(flags)
+	}
+	positional, err := parseFlags(flags, args[1:])
+	if err != nil {
+		return "", nil, err
+	}
+
+	return args[0], positional, nil
+}
+
+// parseFlags parses flags placed anywhere among the positional arguments,
+// the flag package alone stops at the first positional one
+func parseFlags(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		if flags.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+}
+
+func printJSON(out io.Writer, value any) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(out, string(data))
+	return nil
+}
+
+type table struct {
+	writer *tabwriter.Writer
+}
+
+func newTable(out io.Writer, headers ...any) *table {
+	t := &table{writer: tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)}
+	t.row(headers...)
+	return t
+}
+
+func (t *table) row(values ...any) {
+	cells := make([]string, len(values))
+	for i, value := range values {
+		cells[i] = fmt.Sprint(value)
+	}
+	fmt.Fprintln(t.writer, strings.Join(cells, "\t"))
+}
+
+func (t *table) flush() {
+	t.writer.Flush()
+}
+
+// stringsFlag collects a repeated string flag
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// remapFlag collects repeated --remap OLD=NEW flags
+type remapFlag []models.PathRemap
+
+func (r *remapFlag) String() string {
+	var values []string
+	for _, remap := range *r {
+		values = append(values, remap.From+"="+remap.To)
+	}
+	return strings.Join(values, ",")
+}
+
+func (r *remapFlag) Set(value string) error {
+	remap, err := services.ParsePathRemap(value)
+	if err != nil {
+		return err
+	}
+
+	*r = append(*r, remap)
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"localflix-server/src/appdata"
+	"strings"
+	"testing"
+)
+
+func TestRunCommand(t *testing.T) {
+	dirs := &appdata.Dirs{Root: t.TempDir()}
+	folder := t.TempDir()
+
+	// The steps share the data dir, each one sees what the previous did
+	steps := []struct {
+		args     string
+		wantCode int
+		want     string
+	}{
+		{"help", 0, "Usage: localflix-server"},
+		{"nope", 1, `unknown command "nope"`},
+		{"category add Movies", 0, "Created category 1 Movies"},
+		{"category add Movies", 1, "already exists"},
+		{"category list", 0, "Movies"},
+		{"folder add " + folder + " --category Movies", 0, "Added folder 1 " + folder + " to Movies"},
+		{"folder add " + folder + " --category Shows", 1, `category "Shows" not found`},
+		{"folder list", 0, folder},
+		{"scan", 0, "FOLDER"},
+		{"user add Alice", 0, "Created user 1 Alice with api key 1 (library:read,stream)"},
+		{"user add alice", 1, "already exists"},
+		{"user add Bob --scope everything", 1, "unknown api key scope"},
+		{"apikey add phone --user Alice --scope stream", 0, "Created api key 2 phone (stream)"},
+		{"apikey add tv", 0, "Created api key 3 tv"},
+		{"apikey add tablet --user Carol", 1, `user "Carol" not found`},
+		{"user list", 0, "Alice"},
+		{"apikey list", 0, "phone"},
+		{"user rm Alice", 0, "Deleted user 1 Alice and its 2 api keys"},
+		{"user rm Alice", 1, `user "Alice" not found`},
+		{"settings set rate_limit.requests_per_minute 60", 0, `"requests_per_minute": 60`},
+		{"settings set rate_limit.nope 1", 1, `unknown rate_limit setting "nope"`},
+		{"db backup", 1, "needs exactly one FILE"},
+		{"folder rm 1", 0, "Removed folder 1"},
+		{"category rm Movies", 0, "Deleted category 1 Movies"},
+	}
+	for _, step := range steps {
+		var out, errOut bytes.Buffer
+		code := runCommand(dirs, strings.Fields(step.args), &out, &errOut)
+		if code != step.wantCode {
+			t.Errorf("%s exited with %d, want %d\n%s%s", step.args, code, step.wantCode, out.String(), errOut.String())
+			continue
+		}
+		output := out.String()
+		if code != 0 {
+			output = errOut.String()
+		}
+		if !strings.Contains(output, step.want) {
+			t.Errorf("%s printed %q, want it to contain %q", step.args, output, step.want)
+		}
+	}
+}
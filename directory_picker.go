@@ -0,0 +1,23 @@
+package main
+
+import (
+	"context"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// DirectoryPicker asks the user for a directory. It returns "" without an
+// error when the user cancels.
+type DirectoryPicker interface {
+	PickDirectory(ctx context.Context, title string) (string, error)
+}
+
+// wailsDirectoryPicker opens the native dialog of the desktop app
+type wailsDirectoryPicker struct{}
+
+func (wailsDirectoryPicker) PickDirectory(ctx context.Context, title string) (string, error) {
+	return runtime.OpenDirectoryDialog(ctx, runtime.OpenDialogOptions{
+		CanCreateDirectories: true,
+		Title:                title,
+	})
+}
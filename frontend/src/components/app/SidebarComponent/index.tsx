@@ -7,9 +7,9 @@ import { models } from "../../../../wailsjs/go/models";
 export const SideBarComponent: FC = () => {
     const [categories, setCategories] = useState<models.Category[]>([]);
     const fetchCategories = async () => {
-        const result = await ListCategories();
+        const result = await ListCategories(new models.ListOptions());
         console.log(result)
-        setCategories(result);
+        setCategories(result.items);
     }
 
     useEffect(() => {
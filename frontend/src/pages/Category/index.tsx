@@ -16,9 +16,9 @@ export const CategoryPage: FC = () => {
     }
 
     const fetchFoldersByCategory = async (id: number) => {
-        const result = await ListFolderByCategory(id)
+        const result = await ListFolderByCategory(id, new models.ListOptions())
         console.log(result)
-        setFolders(result)
+        setFolders(result.items)
     }
 
     useEffect(() => {
@@ -10,8 +10,8 @@ export const HomePage : FC = () => {
   const [categories, setCategories] = useState<models.Category[]>([]);
 
   const fetchCategories = async () => {
-    const result = await ListCategories();
-    setCategories(result);
+    const result = await ListCategories(new models.ListOptions());
+    setCategories(result.items);
   }
 
     useEffect(() => {
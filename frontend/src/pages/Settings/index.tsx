@@ -16,9 +16,9 @@ export const SettingsPage : FC = () => {
     const { toast } = useToast()
     
     const fetchCategories = async () => {
-        const result = await ListCategories();
+        const result = await ListCategories(new models.ListOptions());
         console.log(result)
-        setCategories(result);
+        setCategories(result.items);
     }
 
     useEffect(() => {
@@ -2,24 +2,146 @@
 // This file is automatically generated. DO NOT EDIT
 import {models} from '../models';
 
+export function AddCollectionItem(arg1:number,arg2:number,arg3:number):Promise<models.Collection>;
+
+export function BackupDatabase():Promise<string>;
+
+export function CreateApiKey(arg1:string,arg2:Array<string>):Promise<models.ApiKey>;
+
 export function CreateCategory(arg1:string):Promise<models.Category>;
 
-export function CreateFolderSource(arg1:number):Promise<void>;
+export function CreateCollection(arg1:string,arg2:string):Promise<models.Collection>;
+
+export function CreateFolderSource(arg1:number):Promise<models.Folder>;
+
+export function CreateSmartCollection(arg1:models.SmartCollection):Promise<models.SmartCollection>;
+
+export function CreateTag(arg1:string):Promise<models.Tag>;
+
+export function CreateUser(arg1:string,arg2:Array<string>):Promise<models.ApiKey>;
+
+export function CreateUserApiKey(arg1:number,arg2:string,arg3:Array<string>):Promise<models.ApiKey>;
 
 export function DeleteCategory(arg1:number):Promise<void>;
 
+export function DeleteCollection(arg1:number):Promise<void>;
+
 export function DeleteFolder(arg1:number):Promise<void>;
 
+export function DeleteSmartCollection(arg1:number):Promise<void>;
+
+export function DeleteTag(arg1:number):Promise<void>;
+
+export function DeleteUser(arg1:number):Promise<void>;
+
+export function ExportLibrary():Promise<string>;
+
+export function FixMetadataMatch(arg1:number,arg2:string,arg3:string):Promise<models.MediaItemDetails>;
+
 export function GetCategory(arg1:number):Promise<models.Category>;
 
+export function GetCollection(arg1:number):Promise<models.Collection>;
+
+export function GetCorsSettings():Promise<models.CorsSettings>;
+
+export function GetLogSettings():Promise<models.LogSettings>;
+
+export function GetMediaItemDetails(arg1:number):Promise<models.MediaItemDetails>;
+
+export function GetMediaToolkitStatus():Promise<models.MediaToolkitStatus>;
+
+export function GetMetadataSettings():Promise<models.MetadataSettings>;
+
+export function GetRateLimitSettings():Promise<models.RateLimitSettings>;
+
+export function GetScanSettings():Promise<models.ScanSettings>;
+
+export function GetSmartCollection(arg1:number):Promise<models.SmartCollection>;
+
+export function GetTlsSettings():Promise<models.TlsSettings>;
+
 export function Greet(arg1:string):Promise<string>;
 
-export function ListCategories():Promise<Array<models.Category>>;
+export function ImportLibrary(arg1:Array<models.PathRemap>):Promise<models.ImportResult>;
+
+export function ListApiKeys():Promise<Array<models.ApiKey>>;
+
+export function ListAuditEvents(arg1:models.AuditFilter):Promise<Array<models.AuditEvent>>;
+
+export function ListCategories(arg1:models.ListOptions):Promise<models.CategoryPage>;
+
+export function ListCollectionItems(arg1:number,arg2:models.ListOptions):Promise<models.MediaItemPage>;
+
+export function ListCollections(arg1:models.ListOptions):Promise<models.CollectionPage>;
+
+export function ListEpisodes(arg1:number,arg2:models.ListOptions):Promise<models.EpisodePage>;
+
+export function ListFolderByCategory(arg1:number,arg2:models.ListOptions):Promise<models.FolderPage>;
 
-export function ListFolderByCategory(arg1:number):Promise<Array<models.Folder>>;
+export function ListFolders(arg1:models.ListOptions):Promise<models.FolderPage>;
 
-export function ListFolders():Promise<Array<models.Folder>>;
+export function ListLogEntries(arg1:models.LogFilter):Promise<Array<models.LogEntry>>;
+
+export function ListMediaItemParts(arg1:number):Promise<Array<models.MediaItem>>;
+
+export function ListMediaItemVersions(arg1:number):Promise<Array<models.MediaItem>>;
+
+export function ListMediaItems(arg1:number,arg2:models.ListOptions):Promise<models.MediaItemPage>;
+
+export function ListMetadataProviders():Promise<Array<string>>;
+
+export function ListSeasons(arg1:number,arg2:models.ListOptions):Promise<models.SeasonPage>;
+
+export function ListSeries(arg1:models.ListOptions):Promise<models.SeriesPage>;
+
+export function ListSmartCollectionItems(arg1:number,arg2:models.ListOptions):Promise<models.MediaItemPage>;
+
+export function ListSmartCollections(arg1:models.ListOptions):Promise<models.SmartCollectionPage>;
+
+export function ListTags(arg1:string,arg2:models.ListOptions):Promise<models.TagPage>;
+
+export function ListUsers():Promise<Array<models.User>>;
+
+export function MatchFolderMetadata(arg1:number):Promise<Array<models.MatchResult>>;
+
+export function PreviewSmartRules(arg1:models.SmartRule,arg2:models.ListOptions):Promise<models.MediaItemPage>;
+
+export function RegenerateCertificate():Promise<void>;
+
+export function RemoveCollectionItem(arg1:number,arg2:number):Promise<models.Collection>;
+
+export function RenameTag(arg1:number,arg2:string):Promise<models.Tag>;
+
+export function RevokeApiKey(arg1:number):Promise<void>;
+
+export function ScanFolder(arg1:number):Promise<models.ScanResult>;
+
+export function ScanLibrary():Promise<Array<models.ScanResult>>;
+
+export function Search(arg1:models.SearchFilter):Promise<Array<models.SearchResult>>;
+
+export function SearchMetadataMatches(arg1:number,arg2:string,arg3:models.MetadataQuery):Promise<Array<models.MetadataMatch>>;
+
+export function SetCollectionItems(arg1:number,arg2:Array<number>):Promise<models.Collection>;
+
+export function SetMediaItemTags(arg1:number,arg2:Array<string>):Promise<Array<models.Tag>>;
 
 export function StartServer():Promise<void>;
 
 export function StopServer():Promise<void>;
+
+export function UpdateCollection(arg1:number,arg2:string,arg3:string):Promise<models.Collection>;
+
+export function UpdateCorsSettings(arg1:models.CorsSettings):Promise<models.CorsSettings>;
+
+export function UpdateLogSettings(arg1:models.LogSettings):Promise<models.LogSettings>;
+
+export function UpdateMetadataSettings(arg1:models.MetadataSettings):Promise<models.MetadataSettings>;
+
+export function UpdateRateLimitSettings(arg1:models.RateLimitSettings):Promise<models.RateLimitSettings>;
+
+export function UpdateScanSettings(arg1:models.ScanSettings):Promise<models.ScanSettings>;
+
+export function UpdateSmartCollection(arg1:number,arg2:models.SmartCollection):Promise<models.SmartCollection>;
+
+export function UpdateTlsSettings(arg1:models.TlsSettings):Promise<models.TlsSettings>;
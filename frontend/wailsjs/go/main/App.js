@@ -2,40 +2,252 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 
+export function AddCollectionItem(arg1, arg2, arg3) {
+  return window['go']['main']['App']['AddCollectionItem'](arg1, arg2, arg3);
+}
+
+export function BackupDatabase() {
+  return window['go']['main']['App']['BackupDatabase']();
+}
+
+export function CreateApiKey(arg1, arg2) {
+  return window['go']['main']['App']['CreateApiKey'](arg1, arg2);
+}
+
 export function CreateCategory(arg1) {
   return window['go']['main']['App']['CreateCategory'](arg1);
 }
 
+export function CreateCollection(arg1, arg2) {
+  return window['go']['main']['App']['CreateCollection'](arg1, arg2);
+}
+
 export function CreateFolderSource(arg1) {
   return window['go']['main']['App']['CreateFolderSource'](arg1);
 }
 
+export function CreateSmartCollection(arg1) {
+  return window['go']['main']['App']['CreateSmartCollection'](arg1);
+}
+
+export function CreateTag(arg1) {
+  return window['go']['main']['App']['CreateTag'](arg1);
+}
+
+export function CreateUser(arg1, arg2) {
+  return window['go']['main']['App']['CreateUser'](arg1, arg2);
+}
+
+export function CreateUserApiKey(arg1, arg2, arg3) {
+  return window['go']['main']['App']['CreateUserApiKey'](arg1, arg2, arg3);
+}
+
 export function DeleteCategory(arg1) {
   return window['go']['main']['App']['DeleteCategory'](arg1);
 }
 
+export function DeleteCollection(arg1) {
+  return window['go']['main']['App']['DeleteCollection'](arg1);
+}
+
 export function DeleteFolder(arg1) {
   return window['go']['main']['App']['DeleteFolder'](arg1);
 }
 
+export function DeleteSmartCollection(arg1) {
+  return window['go']['main']['App']['DeleteSmartCollection'](arg1);
+}
+
+export function DeleteTag(arg1) {
+  return window['go']['main']['App']['DeleteTag'](arg1);
+}
+
+export function DeleteUser(arg1) {
+  return window['go']['main']['App']['DeleteUser'](arg1);
+}
+
+export function ExportLibrary() {
+  return window['go']['main']['App']['ExportLibrary']();
+}
+
+export function FixMetadataMatch(arg1, arg2, arg3) {
+  return window['go']['main']['App']['FixMetadataMatch'](arg1, arg2, arg3);
+}
+
 export function GetCategory(arg1) {
   return window['go']['main']['App']['GetCategory'](arg1);
 }
 
+export function GetCollection(arg1) {
+  return window['go']['main']['App']['GetCollection'](arg1);
+}
+
+export function GetCorsSettings() {
+  return window['go']['main']['App']['GetCorsSettings']();
+}
+
+export function GetLogSettings() {
+  return window['go']['main']['App']['GetLogSettings']();
+}
+
+export function GetMediaItemDetails(arg1) {
+  return window['go']['main']['App']['GetMediaItemDetails'](arg1);
+}
+
+export function GetMediaToolkitStatus() {
+  return window['go']['main']['App']['GetMediaToolkitStatus']();
+}
+
+export function GetMetadataSettings() {
+  return window['go']['main']['App']['GetMetadataSettings']();
+}
+
+export function GetRateLimitSettings() {
+  return window['go']['main']['App']['GetRateLimitSettings']();
+}
+
+export function GetScanSettings() {
+  return window['go']['main']['App']['GetScanSettings']();
+}
+
+export function GetSmartCollection(arg1) {
+  return window['go']['main']['App']['GetSmartCollection'](arg1);
+}
+
+export function GetTlsSettings() {
+  return window['go']['main']['App']['GetTlsSettings']();
+}
+
 export function Greet(arg1) {
   return window['go']['main']['App']['Greet'](arg1);
 }
 
-export function ListCategories() {
-  return window['go']['main']['App']['ListCategories']();
+export function ImportLibrary(arg1) {
+  return window['go']['main']['App']['ImportLibrary'](arg1);
+}
+
+export function ListApiKeys() {
+  return window['go']['main']['App']['ListApiKeys']();
+}
+
+export function ListAuditEvents(arg1) {
+  return window['go']['main']['App']['ListAuditEvents'](arg1);
+}
+
+export function ListCategories(arg1) {
+  return window['go']['main']['App']['ListCategories'](arg1);
+}
+
+export function ListCollectionItems(arg1, arg2) {
+  return window['go']['main']['App']['ListCollectionItems'](arg1, arg2);
+}
+
+export function ListCollections(arg1) {
+  return window['go']['main']['App']['ListCollections'](arg1);
+}
+
+export function ListEpisodes(arg1, arg2) {
+  return window['go']['main']['App']['ListEpisodes'](arg1, arg2);
+}
+
+export function ListFolderByCategory(arg1, arg2) {
+  return window['go']['main']['App']['ListFolderByCategory'](arg1, arg2);
+}
+
+export function ListFolders(arg1) {
+  return window['go']['main']['App']['ListFolders'](arg1);
+}
+
+export function ListLogEntries(arg1) {
+  return window['go']['main']['App']['ListLogEntries'](arg1);
+}
+
+export function ListMediaItemParts(arg1) {
+  return window['go']['main']['App']['ListMediaItemParts'](arg1);
+}
+
+export function ListMediaItemVersions(arg1) {
+  return window['go']['main']['App']['ListMediaItemVersions'](arg1);
+}
+
+export function ListMediaItems(arg1, arg2) {
+  return window['go']['main']['App']['ListMediaItems'](arg1, arg2);
+}
+
+export function ListMetadataProviders() {
+  return window['go']['main']['App']['ListMetadataProviders']();
+}
+
+export function ListSeasons(arg1, arg2) {
+  return window['go']['main']['App']['ListSeasons'](arg1, arg2);
+}
+
+export function ListSeries(arg1) {
+  return window['go']['main']['App']['ListSeries'](arg1);
+}
+
+export function ListSmartCollectionItems(arg1, arg2) {
+  return window['go']['main']['App']['ListSmartCollectionItems'](arg1, arg2);
+}
+
+export function ListSmartCollections(arg1) {
+  return window['go']['main']['App']['ListSmartCollections'](arg1);
+}
+
+export function ListTags(arg1, arg2) {
+  return window['go']['main']['App']['ListTags'](arg1, arg2);
+}
+
+export function ListUsers() {
+  return window['go']['main']['App']['ListUsers']();
+}
+
+export function MatchFolderMetadata(arg1) {
+  return window['go']['main']['App']['MatchFolderMetadata'](arg1);
+}
+
+export function PreviewSmartRules(arg1, arg2) {
+  return window['go']['main']['App']['PreviewSmartRules'](arg1, arg2);
+}
+
+export function RegenerateCertificate() {
+  return window['go']['main']['App']['RegenerateCertificate']();
 }
 
-export function ListFolderByCategory(arg1) {
-  return window['go']['main']['App']['ListFolderByCategory'](arg1);
+export function RemoveCollectionItem(arg1, arg2) {
+  return window['go']['main']['App']['RemoveCollectionItem'](arg1, arg2);
 }
 
-export function ListFolders() {
-  return window['go']['main']['App']['ListFolders']();
+export function RenameTag(arg1, arg2) {
+  return window['go']['main']['App']['RenameTag'](arg1, arg2);
+}
+
+export function RevokeApiKey(arg1) {
+  return window['go']['main']['App']['RevokeApiKey'](arg1);
+}
+
+export function ScanFolder(arg1) {
+  return window['go']['main']['App']['ScanFolder'](arg1);
+}
+
+export function ScanLibrary() {
+  return window['go']['main']['App']['ScanLibrary']();
+}
+
+export function Search(arg1) {
+  return window['go']['main']['App']['Search'](arg1);
+}
+
+export function SearchMetadataMatches(arg1, arg2, arg3) {
+  return window['go']['main']['App']['SearchMetadataMatches'](arg1, arg2, arg3);
+}
+
+export function SetCollectionItems(arg1, arg2) {
+  return window['go']['main']['App']['SetCollectionItems'](arg1, arg2);
+}
+
+export function SetMediaItemTags(arg1, arg2) {
+  return window['go']['main']['App']['SetMediaItemTags'](arg1, arg2);
 }
 
 export function StartServer() {
@@ -45,3 +257,35 @@ export function StartServer() {
 export function StopServer() {
   return window['go']['main']['App']['StopServer']();
 }
+
+export function UpdateCollection(arg1, arg2, arg3) {
+  return window['go']['main']['App']['UpdateCollection'](arg1, arg2, arg3);
+}
+
+export function UpdateCorsSettings(arg1) {
+  return window['go']['main']['App']['UpdateCorsSettings'](arg1);
+}
+
+export function UpdateLogSettings(arg1) {
+  return window['go']['main']['App']['UpdateLogSettings'](arg1);
+}
+
+export function UpdateMetadataSettings(arg1) {
+  return window['go']['main']['App']['UpdateMetadataSettings'](arg1);
+}
+
+export function UpdateRateLimitSettings(arg1) {
+  return window['go']['main']['App']['UpdateRateLimitSettings'](arg1);
+}
+
+export function UpdateScanSettings(arg1) {
+  return window['go']['main']['App']['UpdateScanSettings'](arg1);
+}
+
+export function UpdateSmartCollection(arg1, arg2) {
+  return window['go']['main']['App']['UpdateSmartCollection'](arg1, arg2);
+}
+
+export function UpdateTlsSettings(arg1) {
+  return window['go']['main']['App']['UpdateTlsSettings'](arg1);
+}
@@ -1,8 +1,162 @@
 export namespace models {
 	
+	export class ApiKey {
+	    id: number;
+	    name: string;
+	    prefix: string;
+	    scopes: string[];
+	    // Go type: time
+	    created_at: any;
+	    // Go type: time
+	    last_used_at: any;
+	    user_id: number;
+	    key?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ApiKey(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.name = source["name"];
+	        this.prefix = source["prefix"];
+	        this.scopes = source["scopes"];
+	        this.created_at = this.convertValues(source["created_at"], null);
+	        this.last_used_at = this.convertValues(source["last_used_at"], null);
+	        this.user_id = source["user_id"];
+	        this.key = source["key"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class AuditEvent {
+	    id: number;
+	    // Go type: time
+	    created_at: any;
+	    action: string;
+	    actor: string;
+	    ip: string;
+	    target_type: string;
+	    target_id: number;
+	    details: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new AuditEvent(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.created_at = this.convertValues(source["created_at"], null);
+	        this.action = source["action"];
+	        this.actor = source["actor"];
+	        this.ip = source["ip"];
+	        this.target_type = source["target_type"];
+	        this.target_id = source["target_id"];
+	        this.details = source["details"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class AuditFilter {
+	    action: string;
+	    actor: string;
+	    target_type: string;
+	    target_id: number;
+	    // Go type: time
+	    since?: any;
+	    // Go type: time
+	    until?: any;
+	    limit: number;
+	    offset: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new AuditFilter(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.action = source["action"];
+	        this.actor = source["actor"];
+	        this.target_type = source["target_type"];
+	        this.target_id = source["target_id"];
+	        this.since = this.convertValues(source["since"], null);
+	        this.until = this.convertValues(source["until"], null);
+	        this.limit = source["limit"];
+	        this.offset = source["offset"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class CastMember {
+	    name: string;
+	    role?: string;
+	    order: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new CastMember(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.role = source["role"];
+	        this.order = source["order"];
+	    }
+	}
 	export class Category {
 	    ID: number;
 	    Name: string;
+	    SmartCollectionID?: number;
 	
 	    static createFrom(source: any = {}) {
 	        return new Category(source);
@@ -12,12 +166,217 @@ export namespace models {
 	        if ('string' === typeof source) source = JSON.parse(source);
 	        this.ID = source["ID"];
 	        this.Name = source["Name"];
+	        this.SmartCollectionID = source["SmartCollectionID"];
+	    }
+	}
+	export class CategoryPage {
+	    items: Category[];
+	    total: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new CategoryPage(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.items = this.convertValues(source["items"], Category);
+	        this.total = source["total"];
 	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class Collection {
+	    id: number;
+	    name: string;
+	    description: string;
+	    item_count: number;
+	    // Go type: time
+	    created_at: any;
+	    // Go type: time
+	    updated_at: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new Collection(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.name = source["name"];
+	        this.description = source["description"];
+	        this.item_count = source["item_count"];
+	        this.created_at = this.convertValues(source["created_at"], null);
+	        this.updated_at = this.convertValues(source["updated_at"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class CollectionPage {
+	    items: Collection[];
+	    total: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new CollectionPage(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.items = this.convertValues(source["items"], Collection);
+	        this.total = source["total"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class CorsSettings {
+	    allow_origins: string[];
+	    allow_methods: string[];
+	    allow_credentials: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new CorsSettings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.allow_origins = source["allow_origins"];
+	        this.allow_methods = source["allow_methods"];
+	        this.allow_credentials = source["allow_credentials"];
+	    }
+	}
+	export class Episode {
+	    id: number;
+	    season_id: number;
+	    media_item_id: number;
+	    number: number;
+	    end_number?: number;
+	    absolute_number?: number;
+	    air_date?: string;
+	    title?: string;
+	    media_item: MediaItem;
+	    url?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Episode(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.season_id = source["season_id"];
+	        this.media_item_id = source["media_item_id"];
+	        this.number = source["number"];
+	        this.end_number = source["end_number"];
+	        this.absolute_number = source["absolute_number"];
+	        this.air_date = source["air_date"];
+	        this.title = source["title"];
+	        this.media_item = this.convertValues(source["media_item"], MediaItem);
+	        this.url = source["url"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class EpisodePage {
+	    items: Episode[];
+	    total: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new EpisodePage(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.items = this.convertValues(source["items"], Episode);
+	        this.total = source["total"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
 	}
 	export class Folder {
 	    id: number;
 	    path: string;
 	    category_id: number;
+	    // Go type: time
+	    last_scanned_at?: any;
 	
 	    static createFrom(source: any = {}) {
 	        return new Folder(source);
@@ -28,7 +387,997 @@ export namespace models {
 	        this.id = source["id"];
 	        this.path = source["path"];
 	        this.category_id = source["category_id"];
+	        this.last_scanned_at = this.convertValues(source["last_scanned_at"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class FolderPage {
+	    items: Folder[];
+	    total: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new FolderPage(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.items = this.convertValues(source["items"], Folder);
+	        this.total = source["total"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ImportResult {
+	    categories_created: number;
+	    folders_created: number;
+	    folders_skipped: number;
+	    settings_imported: number;
+	    users_created: number;
+	    api_keys_imported: number;
+	    progress_imported: number;
+	    warnings: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new ImportResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.categories_created = source["categories_created"];
+	        this.folders_created = source["folders_created"];
+	        this.folders_skipped = source["folders_skipped"];
+	        this.settings_imported = source["settings_imported"];
+	        this.users_created = source["users_created"];
+	        this.api_keys_imported = source["api_keys_imported"];
+	        this.progress_imported = source["progress_imported"];
+	        this.warnings = source["warnings"];
+	    }
+	}
+	export class ListOptions {
+	    offset: number;
+	    limit: number;
+	    sort: string;
+	    filters: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new ListOptions(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.offset = source["offset"];
+	        this.limit = source["limit"];
+	        this.sort = source["sort"];
+	        this.filters = source["filters"];
+	    }
+	}
+	export class LogEntry {
+	    // Go type: time
+	    time: any;
+	    level: string;
+	    subsystem: string;
+	    message: string;
+	    request_id: string;
+	    attrs: {[key: string]: any};
+	
+	    static createFrom(source: any = {}) {
+	        return new LogEntry(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.time = this.convertValues(source["time"], null);
+	        this.level = source["level"];
+	        this.subsystem = source["subsystem"];
+	        this.message = source["message"];
+	        this.request_id = source["request_id"];
+	        this.attrs = source["attrs"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class LogFilter {
+	    min_level: string;
+	    subsystem: string;
+	    request_id: string;
+	    search: string;
+	    limit: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new LogFilter(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.min_level = source["min_level"];
+	        this.subsystem = source["subsystem"];
+	        this.request_id = source["request_id"];
+	        this.search = source["search"];
+	        this.limit = source["limit"];
+	    }
+	}
+	export class LogSettings {
+	    level: string;
+	    subsystems: {[key: string]: string};
+	
+	    static createFrom(source: any = {}) {
+	        return new LogSettings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.level = source["level"];
+	        this.subsystems = source["subsystems"];
+	    }
+	}
+	export class MatchResult {
+	    media_item_id: number;
+	    matched: boolean;
+	    match?: MetadataMatch;
+	    reason?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new MatchResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.media_item_id = source["media_item_id"];
+	        this.matched = source["matched"];
+	        this.match = this.convertValues(source["match"], MetadataMatch);
+	        this.reason = source["reason"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class MediaItem {
+	    id: number;
+	    folder_id: number;
+	    rel_path: string;
+	    name: string;
+	    size: number;
+	    // Go type: time
+	    modified_at: any;
+	    duration: number;
+	    // Go type: time
+	    added_at: any;
+	    // Go type: time
+	    scanned_at: any;
+	    sort_title: string;
+	    release: ReleaseInfo;
+	    version_group?: number;
+	    content_hash?: string;
+	    stack_id?: number;
+	    part?: number;
+	    // Go type: time
+	    last_watched_at?: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new MediaItem(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.folder_id = source["folder_id"];
+	        this.rel_path = source["rel_path"];
+	        this.name = source["name"];
+	        this.size = source["size"];
+	        this.modified_at = this.convertValues(source["modified_at"], null);
+	        this.duration = source["duration"];
+	        this.added_at = this.convertValues(source["added_at"], null);
+	        this.scanned_at = this.convertValues(source["scanned_at"], null);
+	        this.sort_title = source["sort_title"];
+	        this.release = this.convertValues(source["release"], ReleaseInfo);
+	        this.version_group = source["version_group"];
+	        this.content_hash = source["content_hash"];
+	        this.stack_id = source["stack_id"];
+	        this.part = source["part"];
+	        this.last_watched_at = this.convertValues(source["last_watched_at"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class MediaItemDetails {
+	    media_item: MediaItem;
+	    metadata: Metadata;
+	    tags: Tag[];
+	    artwork: {[key: string]: string};
+	
+	    static createFrom(source: any = {}) {
+	        return new MediaItemDetails(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.media_item = this.convertValues(source["media_item"], MediaItem);
+	        this.metadata = this.convertValues(source["metadata"], Metadata);
+	        this.tags = this.convertValues(source["tags"], Tag);
+	        this.artwork = source["artwork"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class MediaItemPage {
+	    items: MediaItem[];
+	    total: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new MediaItemPage(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.items = this.convertValues(source["items"], MediaItem);
+	        this.total = source["total"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class MediaToolkitStatus {
+	    available: boolean;
+	    ffmpeg_path: string;
+	    ffprobe_path: string;
+	    version: string;
+	    error: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new MediaToolkitStatus(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.available = source["available"];
+	        this.ffmpeg_path = source["ffmpeg_path"];
+	        this.ffprobe_path = source["ffprobe_path"];
+	        this.version = source["version"];
+	        this.error = source["error"];
+	    }
+	}
+	export class Metadata {
+	    media_item_id: number;
+	    source: string;
+	    title: string;
+	    original_title?: string;
+	    plot?: string;
+	    year?: number;
+	    rating?: number;
+	    genres: string[];
+	    cast: CastMember[];
+	    external_ids: {[key: string]: string};
+	    confidence?: number;
+	    locked: boolean;
+	    // Go type: time
+	    updated_at: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new Metadata(source);
 	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.media_item_id = source["media_item_id"];
+	        this.source = source["source"];
+	        this.title = source["title"];
+	        this.original_title = source["original_title"];
+	        this.plot = source["plot"];
+	        this.year = source["year"];
+	        this.rating = source["rating"];
+	        this.genres = source["genres"];
+	        this.cast = this.convertValues(source["cast"], CastMember);
+	        this.external_ids = source["external_ids"];
+	        this.confidence = source["confidence"];
+	        this.locked = source["locked"];
+	        this.updated_at = this.convertValues(source["updated_at"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class MetadataMatch {
+	    provider: string;
+	    id: string;
+	    title: string;
+	    year?: number;
+	    overview?: string;
+	    external_ids?: {[key: string]: string};
+	    confidence: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new MetadataMatch(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.provider = source["provider"];
+	        this.id = source["id"];
+	        this.title = source["title"];
+	        this.year = source["year"];
+	        this.overview = source["overview"];
+	        this.external_ids = source["external_ids"];
+	        this.confidence = source["confidence"];
+	    }
+	}
+	export class MetadataQuery {
+	    title: string;
+	    year?: number;
+	    external_ids?: {[key: string]: string};
+	
+	    static createFrom(source: any = {}) {
+	        return new MetadataQuery(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.title = source["title"];
+	        this.year = source["year"];
+	        this.external_ids = source["external_ids"];
+	    }
+	}
+	export class MetadataSettings {
+	    provider: string;
+	    min_confidence: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new MetadataSettings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.provider = source["provider"];
+	        this.min_confidence = source["min_confidence"];
+	    }
+	}
+	export class PathRemap {
+	    from: string;
+	    to: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new PathRemap(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.from = source["from"];
+	        this.to = source["to"];
+	    }
+	}
+	export class RateLimitSettings {
+	    requests_per_minute: number;
+	    max_bandwidth_mbps: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new RateLimitSettings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.requests_per_minute = source["requests_per_minute"];
+	        this.max_bandwidth_mbps = source["max_bandwidth_mbps"];
+	    }
+	}
+	export class ReleaseInfo {
+	    title: string;
+	    year?: number;
+	    resolution?: string;
+	    source?: string;
+	    codec?: string;
+	    edition?: string;
+	    release_group?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ReleaseInfo(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.title = source["title"];
+	        this.year = source["year"];
+	        this.resolution = source["resolution"];
+	        this.source = source["source"];
+	        this.codec = source["codec"];
+	        this.edition = source["edition"];
+	        this.release_group = source["release_group"];
+	    }
+	}
+	export class ScanResult {
+	    folder_id: number;
+	    added: number;
+	    updated: number;
+	    removed: number;
+	    unchanged: number;
+	    stacks: number;
+	    episodes: number;
+	    versions: number;
+	    errors: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new ScanResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.folder_id = source["folder_id"];
+	        this.added = source["added"];
+	        this.updated = source["updated"];
+	        this.removed = source["removed"];
+	        this.unchanged = source["unchanged"];
+	        this.stacks = source["stacks"];
+	        this.episodes = source["episodes"];
+	        this.versions = source["versions"];
+	        this.errors = source["errors"];
+	    }
+	}
+	export class ScanSettings {
+	    hash_content: boolean;
+	    rescan_minutes: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new ScanSettings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.hash_content = source["hash_content"];
+	        this.rescan_minutes = source["rescan_minutes"];
+	    }
+	}
+	export class SearchFilter {
+	    q: string;
+	    category_id: number;
+	    year: number;
+	    genre: string;
+	    watched?: boolean;
+	    limit: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new SearchFilter(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.q = source["q"];
+	        this.category_id = source["category_id"];
+	        this.year = source["year"];
+	        this.genre = source["genre"];
+	        this.watched = source["watched"];
+	        this.limit = source["limit"];
+	    }
+	}
+	export class SearchResult {
+	    media_item: MediaItem;
+	    title: string;
+	    year?: number;
+	    genres: string[];
+	    series?: string;
+	    watched: boolean;
+	    score: number;
+	    url?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new SearchResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.media_item = this.convertValues(source["media_item"], MediaItem);
+	        this.title = source["title"];
+	        this.year = source["year"];
+	        this.genres = source["genres"];
+	        this.series = source["series"];
+	        this.watched = source["watched"];
+	        this.score = source["score"];
+	        this.url = source["url"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class Season {
+	    id: number;
+	    series_id: number;
+	    number: number;
+	    episode_count: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Season(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.series_id = source["series_id"];
+	        this.number = source["number"];
+	        this.episode_count = source["episode_count"];
+	    }
+	}
+	export class SeasonPage {
+	    items: Season[];
+	    total: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new SeasonPage(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.items = this.convertValues(source["items"], Season);
+	        this.total = source["total"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class Series {
+	    id: number;
+	    title: string;
+	    year?: number;
+	    season_count: number;
+	    episode_count: number;
+	    // Go type: time
+	    created_at: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new Series(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.title = source["title"];
+	        this.year = source["year"];
+	        this.season_count = source["season_count"];
+	        this.episode_count = source["episode_count"];
+	        this.created_at = this.convertValues(source["created_at"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class SeriesPage {
+	    items: Series[];
+	    total: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new SeriesPage(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.items = this.convertValues(source["items"], Series);
+	        this.total = source["total"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class SmartCollection {
+	    id: number;
+	    name: string;
+	    rules: SmartRule;
+	    sort: string;
+	    // Go type: time
+	    created_at: any;
+	    // Go type: time
+	    updated_at: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new SmartCollection(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.name = source["name"];
+	        this.rules = this.convertValues(source["rules"], SmartRule);
+	        this.sort = source["sort"];
+	        this.created_at = this.convertValues(source["created_at"], null);
+	        this.updated_at = this.convertValues(source["updated_at"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class SmartCollectionPage {
+	    items: SmartCollection[];
+	    total: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new SmartCollectionPage(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.items = this.convertValues(source["items"], SmartCollection);
+	        this.total = source["total"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class SmartRule {
+	    match?: string;
+	    rules?: SmartRule[];
+	    field?: string;
+	    op?: string;
+	    value: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new SmartRule(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.match = source["match"];
+	        this.rules = this.convertValues(source["rules"], SmartRule);
+	        this.field = source["field"];
+	        this.op = source["op"];
+	        this.value = source["value"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class Tag {
+	    id: number;
+	    name: string;
+	    kind: string;
+	    item_count: number;
+	    // Go type: time
+	    created_at: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new Tag(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.name = source["name"];
+	        this.kind = source["kind"];
+	        this.item_count = source["item_count"];
+	        this.created_at = this.convertValues(source["created_at"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class TagPage {
+	    items: Tag[];
+	    total: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new TagPage(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.items = this.convertValues(source["items"], Tag);
+	        this.total = source["total"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class TlsSettings {
+	    enabled: boolean;
+	    cert_file: string;
+	    key_file: string;
+	    redirect_http: boolean;
+	    redirect_port: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new TlsSettings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.enabled = source["enabled"];
+	        this.cert_file = source["cert_file"];
+	        this.key_file = source["key_file"];
+	        this.redirect_http = source["redirect_http"];
+	        this.redirect_port = source["redirect_port"];
+	    }
+	}
+	export class User {
+	    id: number;
+	    name: string;
+	    api_keys: number;
+	    // Go type: time
+	    created_at: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new User(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.name = source["name"];
+	        this.api_keys = source["api_keys"];
+	        this.created_at = this.convertValues(source["created_at"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
 	}
 
 }
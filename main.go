@@ -2,6 +2,11 @@ package main
 
 import (
 	"embed"
+	"flag"
+	"fmt"
+	"localflix-server/src/appdata"
+	"log/slog"
+	"os"
 
 	"github.com/wailsapp/wails/v2"
 	"github.com/wailsapp/wails/v2/pkg/options"
@@ -12,11 +17,24 @@ import (
 var assets embed.FS
 
 func main() {
+	dataDir := flag.String("data-dir", "", "directory for the database and caches (default $"+appdata.EnvDataDir+" or the platform data dir)")
+	flag.Parse()
+
+	dirs, err := prepareDataDir(*dataDir)
+	if err != nil {
+		println("Error:", err.Error())
+		os.Exit(1)
+	}
+
+	if flag.NArg() > 0 {
+		os.Exit(runCommand(dirs, flag.Args(), os.Stdout, os.Stderr))
+	}
+
 	// Create an instance of the app structure
-	app := NewApp()
+	app := NewApp(dirs)
 
 	// Create application with options
-	err := wails.Run(&options.App{
+	err = wails.Run(&options.App{
 		Title:  "LocalFlix Server",
 		Width:  1024,
 		Height: 768,
@@ -34,3 +52,28 @@ func main() {
 		println("Error:", err.Error())
 	}
 }
+
+// prepareDataDir resolves the data directory, creates it and moves over data
+// left in the working directory by older versions.
+func prepareDataDir(flagValue string) (*appdata.Dirs, error) {
+	dirs, err := appdata.Resolve(flagValue)
+	if err != nil {
+		return nil, err
+	}
+
+	workDir, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	// The log files live in the data dir, which may not exist yet
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	if err := dirs.MigrateLegacy(workDir, logger); err != nil {
+		return nil, err
+	}
+	if err := dirs.Ensure(); err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(os.Stderr, "Using data dir %s\n", dirs.Root)
+	return dirs, nil
+}
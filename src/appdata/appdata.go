@@ -0,0 +1,222 @@
+package appdata
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+)
+
+// EnvDataDir overrides the data directory, the --data-dir flag wins over it
+const EnvDataDir = "LOCALFLIX_DATA_DIR"
+
+const appName = "localflix"
+
+// Dirs resolves where LocalFlix keeps its persistent state. Everything lives
+// under a single root so it can be moved or backed up as a whole.
+type Dirs struct {
+	Root string
+}
+
+// Resolve picks the data directory from the flag value, the environment or
+// the platform default, in that order.
+func Resolve(flagValue string) (*Dirs, error) {
+	root := flagValue
+	if root == "" {
+		root = os.Getenv(EnvDataDir)
+	}
+	if root == "" {
+		defaultRoot, err := defaultRoot()
+		if err != nil {
+			return nil, err
+		}
+		root = defaultRoot
+	}
+
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("resolving data dir: %w", err)
+	}
+
+	return &Dirs{Root: root}, nil
+}
+
+// defaultRoot follows the XDG base directory spec on Linux and the usual
+// application data locations on macOS and Windows.
+func defaultRoot() (string, error) {
+	switch runtime.GOOS {
+	case "windows":
+		if appData := os.Getenv("APPDATA"); appData != "" {
+			return filepath.Join(appData, "LocalFlix"), nil
+		}
+	case "darwin":
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("resolving home dir: %w", err)
+		}
+		return filepath.Join(home, "Library", "Application Support", "LocalFlix"), nil
+	}
+
+	if dataHome := os.Getenv("XDG_DATA_HOME"); dataHome != "" {
+		return filepath.Join(dataHome, appName), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("resolving home dir: %w", err)
+	}
+	return filepath.Join(home, ".local", "share", appName), nil
+}
+
+func (d *Dirs) Database() string {
+	return filepath.Join(d.Root, "localflix.db")
+}
+
+func (d *Dirs) Subtitles() string {
+	return filepath.Join(d.Root, "subtitles")
+}
+
+func (d *Dirs) Thumbnails() string {
+	return filepath.Join(d.Root, "thumbnails")
+}
+
+func (d *Dirs) Artwork() string {
+	return filepath.Join(d.Root, "artwork")
+}
+
+func (d *Dirs) TLS() string {
+	return filepath.Join(d.Root, "tls")
+}
+
+func (d *Dirs) Logs() string {
+	return filepath.Join(d.Root, "logs")
+}
+
+// FolderSubtitles is where the subtitles extracted from a folder's videos go
+func (d *Dirs) FolderSubtitles(folderId int) string {
+	return filepath.Join(d.Subtitles(), strconv.Itoa(folderId))
+}
+
+// FolderThumbnails is where the thumbnails of a folder's videos go
+func (d *Dirs) FolderThumbnails(folderId int) string {
+	return filepath.Join(d.Thumbnails(), strconv.Itoa(folderId))
+}
+
+// FolderArtwork is where the artwork downloaded for a folder's videos goes
+func (d *Dirs) FolderArtwork(folderId int) string {
+	return filepath.Join(d.Artwork(), strconv.Itoa(folderId))
+}
+
+// Ensure creates the root and the cache directories.
+func (d *Dirs) Ensure() error {
+	for _, dir := range []string{d.Root, d.Subtitles(), d.Thumbnails(), d.Artwork(), d.Logs()} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("creating %s: %w", dir, err)
+		}
+	}
+
+	return nil
+}
+
+// MigrateLegacy moves state older versions kept relative to the working
+// directory (.file:locked.db and ./tmp) into the data directory. Anything
+// that already exists in the data directory is left alone, so it's safe to
+// run on every start.
+func (d *Dirs) MigrateLegacy(workDir string, logger *slog.Logger) error {
+	moves := []struct {
+		from string
+		to   string
+	}{
+		{filepath.Join(workDir, ".file:locked.db"), d.Database()},
+		{filepath.Join(workDir, "tmp", "subtitles"), d.Subtitles()},
+		{filepath.Join(workDir, "tmp", "thumbnails"), d.Thumbnails()},
+		{filepath.Join(workDir, "tmp", "tls"), d.TLS()},
+	}
+
+	for _, move := range moves {
+		if filepath.Clean(move.from) == filepath.Clean(move.to) {
+			continue
+		}
+		if _, err := os.Stat(move.from); err != nil {
+			continue
+		}
+		if !isMissingOrEmpty(move.to) {
+			logger.Warn("not migrating legacy data, destination already exists", "from", move.from, "to", move.to)
+			continue
+		}
+
+		logger.Info("migrating legacy data", "from", move.from, "to", move.to)
+		if err := moveAll(move.from, move.to); err != nil {
+			return fmt.Errorf("migrating %s: %w", move.from, err)
+		}
+	}
+
+	return nil
+}
+
+func isMissingOrEmpty(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return os.IsNotExist(err)
+	}
+	if !info.IsDir() {
+		return false
+	}
+
+	entries, err := os.ReadDir(path)
+	return err == nil && len(entries) == 0
+}
+
+// moveAll renames from to to, falling back to copy and delete when they are
+// on different filesystems.
+func moveAll(from string, to string) error {
+	if err := os.MkdirAll(filepath.Dir(to), 0o755); err != nil {
+		return err
+	}
+	os.Remove(to)
+	if err := os.Rename(from, to); err == nil {
+		return nil
+	}
+
+	err := filepath.Walk(from, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(from, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(to, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, info.Mode().Perm())
+		}
+		return copyFile(path, target, info.Mode().Perm())
+	})
+	if err != nil {
+		return err
+	}
+
+	return os.RemoveAll(from)
+}
+
+func copyFile(from string, to string, perm os.FileMode) error {
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(to, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+
+	return dst.Close()
+}
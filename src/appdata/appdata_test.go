@@ -0,0 +1,87 @@
+package appdata
+
+import (
+	"localflix-server/src/logging"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, path string, content string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(content)
+}
+
+func TestMigrateLegacy(t *testing.T) {
+	workDir := t.TempDir()
+	writeFile(t, filepath.Join(workDir, ".file:locked.db"), "database")
+	writeFile(t, filepath.Join(workDir, "tmp", "subtitles", "1", "movie.vtt"), "subtitles")
+	writeFile(t, filepath.Join(workDir, "tmp", "tls", "cert.pem"), "cert")
+	dirs := &Dirs{Root: filepath.Join(t.TempDir(), "data")}
+	// Ensure runs after the migration, empty directories are migrated into
+	if err := os.MkdirAll(dirs.TLS(), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := dirs.MigrateLegacy(workDir, logging.Discard()); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readFile(t, dirs.Database()); got != "database" {
+		t.Errorf("got database %q", got)
+	}
+	if got := readFile(t, filepath.Join(dirs.FolderSubtitles(1), "movie.vtt")); got != "subtitles" {
+		t.Errorf("got subtitles %q", got)
+	}
+	if got := readFile(t, filepath.Join(dirs.TLS(), "cert.pem")); got != "cert" {
+		t.Errorf("got cert %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(workDir, ".file:locked.db")); !os.IsNotExist(err) {
+		t.Error("legacy database was left behind")
+	}
+
+	// Nothing is left to migrate on the next start
+	if err := dirs.MigrateLegacy(workDir, logging.Discard()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrateLegacyKeepsExistingData(t *testing.T) {
+	workDir := t.TempDir()
+	writeFile(t, filepath.Join(workDir, ".file:locked.db"), "legacy database")
+	writeFile(t, filepath.Join(workDir, "tmp", "thumbnails", "1", "movie.png"), "legacy thumbnail")
+	dirs := &Dirs{Root: t.TempDir()}
+	writeFile(t, dirs.Database(), "database")
+	writeFile(t, filepath.Join(dirs.FolderThumbnails(2), "other.png"), "thumbnail")
+
+	if err := dirs.MigrateLegacy(workDir, logging.Discard()); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readFile(t, dirs.Database()); got != "database" {
+		t.Errorf("got database %q, want the existing one kept", got)
+	}
+	if _, err := os.Stat(filepath.Join(dirs.FolderThumbnails(1), "movie.png")); !os.IsNotExist(err) {
+		t.Error("legacy thumbnail was merged into the existing ones")
+	}
+	if got := readFile(t, filepath.Join(workDir, ".file:locked.db")); got != "legacy database" {
+		t.Errorf("got legacy database %q, want it left in place", got)
+	}
+}
@@ -3,6 +3,8 @@ package db
 import (
 	"database/sql"
 	"fmt"
+	"log/slog"
+	"strings"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -11,16 +13,31 @@ type AppDatabase struct {
 	Db *sql.DB
 }
 
-func NewAppDatabase() *AppDatabase {
-	db, err := sql.Open("sqlite3", ".file:locked.db?cache=shared")
+// OpenAppDatabase opens the database at path, migrates it to the latest
+// schema version and creates the search index when missing. path may be a
+// file: URI with its own query, like the in-memory databases used by tests.
+func OpenAppDatabase(path string, logger *slog.Logger) (*AppDatabase, error) {
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+
+	// SQLite only enforces foreign keys when asked to, on every connection
+	db, err := sql.Open("sqlite3", path+separator+"_foreign_keys=on")
 	if err != nil {
-		fmt.Printf("error opening database: %v\n", err)
-		panic(err)
+		return nil, fmt.Errorf("opening database: %w", err)
+	}
+
+	if err := Migrate(db, logger); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrating database: %w", err)
+	}
+	if err := ensureSearchIndex(db, logger); err != nil {
+		db.Close()
+		return nil, err
 	}
 
-	db.Exec("CREATE TABLE IF NOT EXISTS categories (id INTEGER PRIMARY KEY, name TEXT)")
-	db.Exec("CREATE TABLE IF NOT EXISTS folders (id INTEGER PRIMARY KEY, path TEXT, category_id INTEGER, FOREIGN KEY(category_id) REFERENCES categories(id))")
 	return &AppDatabase{
 		Db: db,
-	}
+	}, nil
 }
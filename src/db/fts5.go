@@ -0,0 +1,7 @@
+//go:build sqlite_fts5
+
+package db
+
+// fts5Enabled is whether go-sqlite3 was compiled with FTS5, which takes the
+// sqlite_fts5 build tag
+const fts5Enabled = true
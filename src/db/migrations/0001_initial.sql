@@ -0,0 +1,2 @@
+CREATE TABLE IF NOT EXISTS categories (id INTEGER PRIMARY KEY, name TEXT);
+CREATE TABLE IF NOT EXISTS folders (id INTEGER PRIMARY KEY, path TEXT, category_id INTEGER, FOREIGN KEY(category_id) REFERENCES categories(id));
@@ -0,0 +1 @@
+CREATE TABLE IF NOT EXISTS api_keys (id INTEGER PRIMARY KEY, name TEXT, prefix TEXT, key_hash TEXT UNIQUE, scopes TEXT, created_at DATETIME, last_used_at DATETIME);
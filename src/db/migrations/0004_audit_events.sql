@@ -0,0 +1,2 @@
+CREATE TABLE IF NOT EXISTS audit_events (id INTEGER PRIMARY KEY, created_at DATETIME, action TEXT, actor TEXT, ip TEXT, target_type TEXT, target_id INTEGER, details TEXT);
+CREATE INDEX IF NOT EXISTS audit_events_created_at ON audit_events (created_at);
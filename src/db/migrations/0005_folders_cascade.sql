@@ -0,0 +1,14 @@
+-- Foreign keys were never enforced, drop folders left behind by deleted
+-- categories before rebuilding the table with a cascading reference.
+DELETE FROM folders WHERE category_id IS NULL OR category_id NOT IN (SELECT id FROM categories);
+
+CREATE TABLE folders_new (
+    id INTEGER PRIMARY KEY,
+    path TEXT NOT NULL,
+    category_id INTEGER NOT NULL REFERENCES categories(id) ON DELETE CASCADE
+);
+INSERT INTO folders_new (id, path, category_id) SELECT id, path, category_id FROM folders;
+DROP TABLE folders;
+ALTER TABLE folders_new RENAME TO folders;
+
+CREATE INDEX folders_category_id ON folders (category_id);
@@ -0,0 +1,12 @@
+CREATE TABLE media_items (
+    id INTEGER PRIMARY KEY,
+    folder_id INTEGER NOT NULL REFERENCES folders(id) ON DELETE CASCADE,
+    rel_path TEXT NOT NULL,
+    name TEXT NOT NULL,
+    size INTEGER NOT NULL,
+    modified_at DATETIME NOT NULL,
+    duration REAL NOT NULL DEFAULT 0,
+    added_at DATETIME NOT NULL,
+    scanned_at DATETIME NOT NULL,
+    UNIQUE (folder_id, rel_path)
+);
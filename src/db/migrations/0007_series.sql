@@ -0,0 +1,29 @@
+CREATE TABLE series (
+    id INTEGER PRIMARY KEY,
+    title TEXT NOT NULL,
+    year INTEGER NOT NULL DEFAULT 0,
+    -- Lowercased title without punctuation, "Show.Name" and "Show Name" are
+    -- the same series
+    match_key TEXT NOT NULL UNIQUE,
+    created_at DATETIME NOT NULL
+);
+
+CREATE TABLE seasons (
+    id INTEGER PRIMARY KEY,
+    series_id INTEGER NOT NULL REFERENCES series(id) ON DELETE CASCADE,
+    number INTEGER NOT NULL,
+    UNIQUE (series_id, number)
+);
+
+CREATE TABLE episodes (
+    id INTEGER PRIMARY KEY,
+    season_id INTEGER NOT NULL REFERENCES seasons(id) ON DELETE CASCADE,
+    media_item_id INTEGER NOT NULL UNIQUE REFERENCES media_items(id) ON DELETE CASCADE,
+    number INTEGER NOT NULL DEFAULT 0,
+    end_number INTEGER NOT NULL DEFAULT 0,
+    absolute_number INTEGER NOT NULL DEFAULT 0,
+    air_date TEXT NOT NULL DEFAULT '',
+    title TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX episodes_season_id ON episodes (season_id);
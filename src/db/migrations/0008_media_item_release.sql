@@ -0,0 +1,10 @@
+ALTER TABLE media_items ADD COLUMN title TEXT NOT NULL DEFAULT '';
+ALTER TABLE media_items ADD COLUMN sort_title TEXT NOT NULL DEFAULT '';
+ALTER TABLE media_items ADD COLUMN year INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE media_items ADD COLUMN resolution TEXT NOT NULL DEFAULT '';
+ALTER TABLE media_items ADD COLUMN source TEXT NOT NULL DEFAULT '';
+ALTER TABLE media_items ADD COLUMN codec TEXT NOT NULL DEFAULT '';
+ALTER TABLE media_items ADD COLUMN edition TEXT NOT NULL DEFAULT '';
+ALTER TABLE media_items ADD COLUMN release_group TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX media_items_sort_title ON media_items (sort_title, year);
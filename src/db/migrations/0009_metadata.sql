@@ -0,0 +1,26 @@
+CREATE TABLE media_metadata (
+    media_item_id INTEGER PRIMARY KEY REFERENCES media_items(id) ON DELETE CASCADE,
+    -- Where the metadata came from, "nfo" for Kodi NFO files
+    source TEXT NOT NULL,
+    title TEXT NOT NULL DEFAULT '',
+    original_title TEXT NOT NULL DEFAULT '',
+    plot TEXT NOT NULL DEFAULT '',
+    year INTEGER NOT NULL DEFAULT 0,
+    rating REAL NOT NULL DEFAULT 0,
+    -- JSON arrays and objects, see models.Metadata
+    genres TEXT NOT NULL DEFAULT '[]',
+    cast_members TEXT NOT NULL DEFAULT '[]',
+    external_ids TEXT NOT NULL DEFAULT '{}',
+    -- The file read and its modification time, so unchanged files aren't
+    -- read again
+    source_path TEXT NOT NULL DEFAULT '',
+    source_modified_at DATETIME,
+    updated_at DATETIME NOT NULL
+);
+
+CREATE TABLE artwork (
+    media_item_id INTEGER NOT NULL REFERENCES media_items(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL,
+    path TEXT NOT NULL,
+    PRIMARY KEY (media_item_id, kind)
+);
@@ -0,0 +1,8 @@
+-- Metadata fetched from a provider records how confident the match was, and
+-- whether it was fixed by hand so automatic matching leaves it alone
+ALTER TABLE media_metadata ADD COLUMN confidence REAL NOT NULL DEFAULT 0;
+ALTER TABLE media_metadata ADD COLUMN locked INTEGER NOT NULL DEFAULT 0;
+
+-- "local" for images found next to the video, otherwise the provider the
+-- image was downloaded from
+ALTER TABLE artwork ADD COLUMN source TEXT NOT NULL DEFAULT 'local';
@@ -0,0 +1,3 @@
+-- Looks up the audit events of a target, like the streams of a folder. The
+-- search index itself is created outside the migrations, see search_index.go.
+CREATE INDEX audit_events_target ON audit_events (target_type, target_id, action);
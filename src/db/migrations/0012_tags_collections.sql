@@ -0,0 +1,47 @@
+-- Tags label media items, many to many. Genres are tags as well, kept in sync
+-- with the genres of the metadata, while tags of kind "tag" are set by hand.
+CREATE TABLE tags (
+    id INTEGER PRIMARY KEY,
+    name TEXT NOT NULL COLLATE NOCASE,
+    kind TEXT NOT NULL,
+    created_at DATETIME NOT NULL,
+    UNIQUE (kind, name)
+);
+
+CREATE TABLE media_item_tags (
+    media_item_id INTEGER NOT NULL REFERENCES media_items(id) ON DELETE CASCADE,
+    tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (media_item_id, tag_id)
+);
+
+CREATE INDEX media_item_tags_tag_id ON media_item_tags (tag_id);
+
+-- Collections are lists of media items curated by hand, position is the
+-- item's place in the list starting from 0
+CREATE TABLE collections (
+    id INTEGER PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+    description TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
+
+CREATE TABLE collection_items (
+    collection_id INTEGER NOT NULL REFERENCES collections(id) ON DELETE CASCADE,
+    media_item_id INTEGER NOT NULL REFERENCES media_items(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    PRIMARY KEY (collection_id, media_item_id)
+);
+
+CREATE INDEX collection_items_media_item_id ON collection_items (media_item_id);
+
+-- Genres of the metadata read so far
+INSERT OR IGNORE INTO tags (name, kind, created_at)
+    SELECT DISTINCT trim(g.value), 'genre', CURRENT_TIMESTAMP
+    FROM media_metadata md, json_each(md.genres) g
+    WHERE trim(g.value) != '';
+
+INSERT OR IGNORE INTO media_item_tags (media_item_id, tag_id)
+    SELECT md.media_item_id, t.id
+    FROM media_metadata md, json_each(md.genres) g
+    JOIN tags t ON t.kind = 'genre' AND t.name = trim(g.value);
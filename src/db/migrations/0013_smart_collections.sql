@@ -0,0 +1,10 @@
+-- Smart collections keep their rule tree as JSON, see models.SmartRule. Their
+-- media items are found by evaluating the rules on request.
+CREATE TABLE smart_collections (
+    id INTEGER PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+    rules TEXT NOT NULL,
+    sort TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
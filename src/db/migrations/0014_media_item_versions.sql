@@ -0,0 +1,10 @@
+-- Versions of the same title, like a 1080p and a 4K file of a film, share
+-- the version_group of the version with the lowest id. It stays 0 for titles
+-- with a single version.
+ALTER TABLE media_items ADD COLUMN version_group INTEGER NOT NULL DEFAULT 0;
+-- Hash of the size, the start and the end of the file, only computed when
+-- enabled in the scan settings. Copies of a file have the same hash whatever
+-- their name.
+ALTER TABLE media_items ADD COLUMN content_hash TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX media_items_version_group ON media_items (version_group);
@@ -0,0 +1,7 @@
+-- Parts of a stacked video, like "Movie-cd1.avi" and "Movie-cd2.avi", share
+-- the stack_id of their first part and are numbered from 1. Both stay 0 for
+-- videos in a single file. Listings only show the first part.
+ALTER TABLE media_items ADD COLUMN stack_id INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE media_items ADD COLUMN part INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX media_items_stack_id ON media_items (stack_id);
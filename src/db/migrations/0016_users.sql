@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS users (id INTEGER PRIMARY KEY, name TEXT NOT NULL UNIQUE COLLATE NOCASE, created_at DATETIME);
+-- Keys without a user are the ones of shared clients, like a TV in the
+-- living room. Deleting a user revokes its keys.
+ALTER TABLE api_keys ADD COLUMN user_id INTEGER REFERENCES users (id) ON DELETE CASCADE;
+CREATE INDEX IF NOT EXISTS api_keys_user_id ON api_keys (user_id);
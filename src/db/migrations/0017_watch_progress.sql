@@ -0,0 +1,24 @@
+-- How far each user got into a media item, in seconds. user_id is 0 for the
+-- desktop app and the keys of shared clients, so it isn't a foreign key and
+-- deleting a user deletes its progress explicitly. Stacked items keep their
+-- progress on the first part.
+CREATE TABLE watch_progress (
+    user_id INTEGER NOT NULL,
+    media_item_id INTEGER NOT NULL REFERENCES media_items(id) ON DELETE CASCADE,
+    position REAL NOT NULL,
+    duration REAL NOT NULL,
+    watched INTEGER NOT NULL,
+    updated_at DATETIME NOT NULL,
+    PRIMARY KEY (user_id, media_item_id)
+);
+
+CREATE INDEX watch_progress_media_item_id ON watch_progress (media_item_id);
+
+-- Streams used to be only known from the audit log, which doesn't tell users
+-- apart. Every stream counted as watched, it's kept that way for user 0.
+INSERT INTO watch_progress (user_id, media_item_id, position, duration, watched, updated_at)
+SELECT 0, m.id, m.duration, m.duration, 1, MAX(a.created_at)
+FROM audit_events a
+JOIN media_items m ON m.folder_id = a.target_id AND m.rel_path = a.details
+WHERE a.target_type = 'folder' AND a.action = 'stream_start'
+GROUP BY m.id;
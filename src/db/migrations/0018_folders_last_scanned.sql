@@ -0,0 +1,5 @@
+-- When the folder was last scanned, NULL until its first scan. Folders with
+-- media items were scanned already, the latest of their scans is used.
+ALTER TABLE folders ADD COLUMN last_scanned_at DATETIME;
+
+UPDATE folders SET last_scanned_at = (SELECT MAX(m.scanned_at) FROM media_items m WHERE m.folder_id = folders.id);
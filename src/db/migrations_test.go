@@ -0,0 +1,117 @@
+package db
+
+import (
+	"database/sql"
+	"localflix-server/src/logging"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func openTestDatabase(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func migrationFS(files map[string]string) fstest.MapFS {
+	fsys := fstest.MapFS{}
+	for name, content := range files {
+		fsys["migrations/"+name] = &fstest.MapFile{Data: []byte(content)}
+	}
+
+	return fsys
+}
+
+func TestMigrateAppliesInOrder(t *testing.T) {
+	db := openTestDatabase(t)
+	// 0002 needs the table of 0001, listed out of order on purpose
+	files := migrationFS(map[string]string{
+		"0002_seed.sql":   "INSERT INTO things (name) VALUES ('first');",
+		"0001_things.sql": "CREATE TABLE things (name TEXT);",
+		"0010_more.sql":   "INSERT INTO things (name) VALUES ('second');",
+	})
+
+	if err := migrate(db, files, logging.Discard()); err != nil {
+		t.Fatal(err)
+	}
+	// Running again applies nothing
+	if err := migrate(db, files, logging.Discard()); err != nil {
+		t.Fatal(err)
+	}
+
+	version, err := SchemaVersion(db)
+	if err != nil || version != 10 {
+		t.Errorf("SchemaVersion = %d, %v, want 10", version, err)
+	}
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM things").Scan(&count); err != nil || count != 2 {
+		t.Errorf("got %d rows, %v, want each seed applied once", count, err)
+	}
+}
+
+func TestLoadMigrationsRejectsBadFiles(t *testing.T) {
+	invalid := map[string]map[string]string{
+		"duplicate version": {"0001_a.sql": "", "0001_b.sql": ""},
+		"missing version":   {"things.sql": ""},
+		"zero version":      {"0000_things.sql": ""},
+	}
+	for name, files := range invalid {
+		if _, err := loadMigrations(migrationFS(files)); err == nil {
+			t.Errorf("%s: loadMigrations succeeded", name)
+		}
+	}
+}
+
+func TestFailingMigrationIsRolledBack(t *testing.T) {
+	db := openTestDatabase(t)
+	files := migrationFS(map[string]string{
+		"0001_things.sql": "CREATE TABLE things (name TEXT);",
+		"0002_broken.sql": "CREATE TABLE others (name TEXT); INSERT INTO missing VALUES (1);",
+	})
+
+	err := migrate(db, files, logging.Discard())
+	if err == nil || !strings.Contains(err.Error(), "0002_broken") {
+		t.Fatalf("migrate = %v, want the failing migration named", err)
+	}
+
+	if version, err := SchemaVersion(db); err != nil || version != 1 {
+		t.Errorf("SchemaVersion = %d, %v, want 1", version, err)
+	}
+	var tables int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'others'").Scan(&tables); err != nil || tables != 0 {
+		t.Errorf("table of the failed migration exists")
+	}
+}
+
+func TestMigrateRejectsNewerSchema(t *testing.T) {
+	db := openTestDatabase(t)
+	if err := migrate(db, migrationFS(map[string]string{
+		"0001_things.sql": "CREATE TABLE things (name TEXT);",
+		"0002_others.sql": "CREATE TABLE others (name TEXT);",
+	}), logging.Discard()); err != nil {
+		t.Fatal(err)
+	}
+
+	// An older build only knows the first migration
+	err := migrate(db, migrationFS(map[string]string{
+		"0001_things.sql": "CREATE TABLE things (name TEXT);",
+	}), logging.Discard())
+	if err == nil || !strings.Contains(err.Error(), "newer than this build") {
+		t.Errorf("migrate = %v, want the newer schema rejected", err)
+	}
+}
+
+func TestEmbeddedMigrationsLoad(t *testing.T) {
+	migrations, err := loadMigrations(migrationFiles)
+	if err != nil || len(migrations) == 0 {
+		t.Fatalf("loadMigrations = %d, %v", len(migrations), err)
+	}
+}
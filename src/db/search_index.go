@@ -0,0 +1,63 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+)
+
+// The full-text search index is created outside the migrations: FTS5 is only
+// compiled into go-sqlite3 with the sqlite_fts5 build tag, set by the
+// Makefile, and builds without it fall back to FTS4. Both take the same MATCH
+// queries and expose their terms through search_terms. The index only holds
+// data derived from the library, so it's filled again whenever it has to be
+// created.
+const (
+	searchIndexColumns = "title, original_title, series, file_name, plot, cast_members, genres, subtitles"
+
+	fts5SearchIndex = "CREATE VIRTUAL TABLE search_index USING fts5(" + searchIndexColumns + ", tokenize = 'unicode61 remove_diacritics 2', prefix = '2 3')"
+	fts5SearchTerms = "CREATE VIRTUAL TABLE search_terms USING fts5vocab(search_index, 'row')"
+	fts4SearchIndex = "CREATE VIRTUAL TABLE search_index USING fts4(" + searchIndexColumns + ", tokenize=unicode61 \"remove_diacritics=2\", prefix=\"2,3\")"
+	fts4SearchTerms = "CREATE VIRTUAL TABLE search_terms USING fts4aux(search_index)"
+)
+
+// ensureSearchIndex creates the search index tables when missing. An FTS4
+// index left by a build without FTS5 is created again with FTS5.
+func ensureSearchIndex(db *sql.DB, logger *slog.Logger) error {
+	if !fts5Enabled {
+		logger.Warn("built without the sqlite_fts5 build tag, the search index falls back to FTS4")
+	}
+
+	var count int
+	var fts4 bool
+	err := db.QueryRow(
+		"SELECT COUNT(*), COALESCE(MAX(name = 'search_index' AND sql LIKE '%USING fts4%'), 0) FROM sqlite_master WHERE name IN ('search_index', 'search_terms')",
+	).Scan(&count, &fts4)
+	if err != nil {
+		return fmt.Errorf("checking search index: %w", err)
+	}
+	if count == 2 && (!fts4 || !fts5Enabled) {
+		return nil
+	}
+
+	// A half created index is dropped, FTS4 and FTS5 tables don't mix
+	for _, name := range []string{"search_terms", "search_index"} {
+		if _, err := db.Exec("DROP TABLE IF EXISTS " + name); err != nil {
+			return fmt.Errorf("dropping %s: %w", name, err)
+		}
+	}
+
+	module, indexTable, termsTable := "fts5", fts5SearchIndex, fts5SearchTerms
+	if !fts5Enabled {
+		module, indexTable, termsTable = "fts4", fts4SearchIndex, fts4SearchTerms
+	}
+	if _, err := db.Exec(indexTable); err != nil {
+		return fmt.Errorf("creating %s search index: %w", module, err)
+	}
+	if _, err := db.Exec(termsTable); err != nil {
+		return fmt.Errorf("creating search terms: %w", err)
+	}
+
+	logger.Info("created search index", "module", module)
+	return nil
+}
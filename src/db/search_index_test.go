@@ -0,0 +1,35 @@
+package db
+
+import (
+	"localflix-server/src/logging"
+	"strings"
+	"testing"
+)
+
+func TestEnsureSearchIndexUsesCompiledModule(t *testing.T) {
+	db := openTestDatabase(t)
+	// Left by a build without FTS5
+	for _, statement := range []string{fts4SearchIndex, fts4SearchTerms} {
+		if _, err := db.Exec(statement); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := ensureSearchIndex(db, logging.Discard()); err != nil {
+			t.Fatalf("ensureSearchIndex: %v", err)
+		}
+	}
+
+	var statement string
+	if err := db.QueryRow("SELECT sql FROM sqlite_master WHERE name = 'search_index'").Scan(&statement); err != nil {
+		t.Fatal(err)
+	}
+	want := "USING fts4"
+	if fts5Enabled {
+		want = "USING fts5"
+	}
+	if !strings.Contains(statement, want) {
+		t.Errorf("search index is %q, want it %s", statement, want)
+	}
+}
@@ -0,0 +1,269 @@
+package logging
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"localflix-server/src/models"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	logFileName    = "localflix.log"
+	maxLogFileSize = 10 * 1024 * 1024
+	maxLogBackups  = 5
+
+	defaultEntryLimit = 200
+
+	// RequestIDKey is the attribute holding the id of the HTTP request a log
+	// entry belongs to
+	RequestIDKey = "request_id"
+	subsystemKey = "subsystem"
+)
+
+// Logging writes JSON logs to a rotating file in the data dir and text logs
+// to the console. Every subsystem gets its own logger and level.
+type Logging struct {
+	mu      sync.Mutex
+	file    *rotatingFile
+	handler slog.Handler
+	levels  map[string]*slog.LevelVar
+}
+
+// New opens the log file in dir. Console receives the entries at or above
+// consoleLevel as text, on top of the subsystem's level.
+func New(dir string, console io.Writer, consoleLevel slog.Level) (*Logging, error) {
+	file, err := openRotatingFile(filepath.Join(dir, logFileName), maxLogFileSize, maxLogBackups)
+	if err != nil {
+		return nil, fmt.Errorf("opening log file: %w", err)
+	}
+
+	// Both handlers accept everything, subsystemHandler does the filtering
+	handlerOptions := &slog.HandlerOptions{Level: slog.LevelDebug}
+	l := &Logging{
+		file: file,
+		handler: &teeHandler{
+			file:         slog.NewJSONHandler(file, handlerOptions),
+			console:      slog.NewTextHandler(console, handlerOptions),
+			consoleLevel: consoleLevel,
+		},
+		levels: map[string]*slog.LevelVar{},
+	}
+	for _, subsystem := range models.LogSubsystems {
+		l.levels[subsystem] = &slog.LevelVar{}
+	}
+
+	return l, nil
+}
+
+// Discard returns loggers that drop everything, for tests and tools that
+// don't keep logs
+func Discard() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError + 1}))
+}
+
+// Logger returns the logger of a subsystem, see models.LogSubsystems
+func (l *Logging) Logger(subsystem string) *slog.Logger {
+	return slog.New(&subsystemHandler{
+		handler: l.handler.WithAttrs([]slog.Attr{slog.String(subsystemKey, subsystem)}),
+		level:   l.level(subsystem),
+	})
+}
+
+func (l *Logging) level(subsystem string) *slog.LevelVar {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	level, ok := l.levels[subsystem]
+	if !ok {
+		level = &slog.LevelVar{}
+		l.levels[subsystem] = level
+	}
+	return level
+}
+
+// Apply changes the levels of the loggers already handed out
+func (l *Logging) Apply(settings models.LogSettings) error {
+	level, err := ParseLevel(settings.Level)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for subsystem, levelVar := range l.levels {
+		subsystemLevel := level
+		if override, ok := settings.Subsystems[subsystem]; ok {
+			subsystemLevel, err = ParseLevel(override)
+			if err != nil {
+				return err
+			}
+		}
+		levelVar.Set(subsystemLevel)
+	}
+
+	return nil
+}
+
+// ParseLevel parses debug, info, warn or error, "" is info
+func ParseLevel(value string) (slog.Level, error) {
+	if value == "" {
+		return slog.LevelInfo, nil
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(value)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q, expected debug, info, warn or error", value)
+	}
+	return level, nil
+}
+
+// ListEntries reads the log files and returns the matching entries, newest
+// first
+func (l *Logging) ListEntries(filter models.LogFilter) ([]models.LogEntry, error) {
+	minLevel, err := ParseLevel(filter.MinLevel)
+	if err != nil {
+		return nil, err
+	}
+	if filter.MinLevel == "" {
+		minLevel = slog.LevelDebug
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultEntryLimit
+	}
+
+	var entries []models.LogEntry
+	for _, path := range l.file.paths() {
+		fileEntries, err := readEntries(path, func(entry models.LogEntry, line string) bool {
+			var level slog.Level
+			if err := level.UnmarshalText([]byte(entry.Level)); err != nil || level < minLevel {
+				return false
+			}
+			if filter.Subsystem != "" && entry.Subsystem != filter.Subsystem {
+				return false
+			}
+			if filter.RequestID != "" && entry.RequestID != filter.RequestID {
+				return false
+			}
+			return filter.Search == "" || strings.Contains(strings.ToLower(line), strings.ToLower(filter.Search))
+		})
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, fileEntries...)
+		// Only the newest entries are returned, older ones can go early
+		if len(entries) > filter.Limit {
+			entries = entries[len(entries)-filter.Limit:]
+		}
+	}
+
+	slices.Reverse(entries)
+	return entries, nil
+}
+
+func readEntries(path string, keep func(models.LogEntry, string) bool) ([]models.LogEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []models.LogEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		var attrs map[string]any
+		if err := json.Unmarshal([]byte(line), &attrs); err != nil {
+			continue
+		}
+
+		entry := models.LogEntry{Attrs: attrs}
+		entry.Time, _ = time.Parse(time.RFC3339Nano, takeString(attrs, slog.TimeKey))
+		entry.Level = takeString(attrs, slog.LevelKey)
+		entry.Message = takeString(attrs, slog.MessageKey)
+		entry.Subsystem = takeString(attrs, subsystemKey)
+		entry.RequestID = takeString(attrs, RequestIDKey)
+		if keep(entry, line) {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, scanner.Err()
+}
+
+// takeString removes key from attrs and returns its value
+func takeString(attrs map[string]any, key string) string {
+	value, _ := attrs[key].(string)
+	delete(attrs, key)
+	return value
+}
+
+func (l *Logging) Close() error {
+	return l.file.Close()
+}
+
+// subsystemHandler drops the records below its subsystem's level
+type subsystemHandler struct {
+	handler slog.Handler
+	level   *slog.LevelVar
+}
+
+func (h *subsystemHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *subsystemHandler) Handle(ctx context.Context, record slog.Record) error {
+	return h.handler.Handle(ctx, record)
+}
+
+func (h *subsystemHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &subsystemHandler{handler: h.handler.WithAttrs(attrs), level: h.level}
+}
+
+func (h *subsystemHandler) WithGroup(name string) slog.Handler {
+	return &subsystemHandler{handler: h.handler.WithGroup(name), level: h.level}
+}
+
+// teeHandler sends records to the file, and to the console when they're at
+// least consoleLevel
+type teeHandler struct {
+	file         slog.Handler
+	console      slog.Handler
+	consoleLevel slog.Level
+}
+
+func (h *teeHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return true
+}
+
+func (h *teeHandler) Handle(ctx context.Context, record slog.Record) error {
+	err := h.file.Handle(ctx, record.Clone())
+	if record.Level >= h.consoleLevel {
+		if consoleErr := h.console.Handle(ctx, record); err == nil {
+			err = consoleErr
+		}
+	}
+
+	return err
+}
+
+func (h *teeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &teeHandler{file: h.file.WithAttrs(attrs), console: h.console.WithAttrs(attrs), consoleLevel: h.consoleLevel}
+}
+
+func (h *teeHandler) WithGroup(name string) slog.Handler {
+	return &teeHandler{file: h.file.WithGroup(name), console: h.console.WithGroup(name), consoleLevel: h.consoleLevel}
+}
@@ -0,0 +1,124 @@
+package logging
+
+import (
+	"bytes"
+	"localflix-server/src/models"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSubsystemLevels(t *testing.T) {
+	var console bytes.Buffer
+	logging, err := New(t.TempDir(), &console, slog.LevelWarn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logging.Close()
+
+	err = logging.Apply(models.LogSettings{
+		Level:      "warn",
+		Subsystems: map[string]string{models.LogSubsystemHttp: "debug"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logging.Logger(models.LogSubsystemHttp).Debug("request", RequestIDKey, "abc")
+	logging.Logger(models.LogSubsystemLibrary).Info("folder added")
+	logging.Logger(models.LogSubsystemLibrary).Error("scan failed", "folder_id", 3)
+
+	entries, err := logging.ListEntries(models.LogFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got entries %+v, want the http debug and library error entries", entries)
+	}
+	if entries[0].Message != "scan failed" || entries[0].Subsystem != models.LogSubsystemLibrary || entries[0].Attrs["folder_id"] != float64(3) {
+		t.Errorf("newest entry = %+v, want the library error", entries[0])
+	}
+	if entries[1].RequestID != "abc" || entries[1].Level != "DEBUG" {
+		t.Errorf("oldest entry = %+v, want the http request", entries[1])
+	}
+
+	// The console only gets entries at its own level or above
+	if strings.Contains(console.String(), "request") || !strings.Contains(console.String(), "scan failed") {
+		t.Errorf("console got %q, want only the error", console.String())
+	}
+}
+
+func TestListEntriesFilter(t *testing.T) {
+	logging, err := New(t.TempDir(), &bytes.Buffer{}, slog.LevelError)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logging.Close()
+	logging.Apply(models.LogSettings{Level: "debug"})
+
+	http := logging.Logger(models.LogSubsystemHttp)
+	for _, id := range []string{"a", "b", "c"} {
+		http.Info("request", RequestIDKey, id, "path", "/stream/"+id)
+	}
+	logging.Logger(models.LogSubsystemMedia).Warn("ffmpeg not found")
+
+	tests := []struct {
+		filter models.LogFilter
+		want   int
+	}{
+		{models.LogFilter{}, 4},
+		{models.LogFilter{Limit: 2}, 2},
+		{models.LogFilter{MinLevel: "warn"}, 1},
+		{models.LogFilter{Subsystem: models.LogSubsystemHttp}, 3},
+		{models.LogFilter{RequestID: "b"}, 1},
+		{models.LogFilter{Search: "/STREAM/C"}, 1},
+	}
+	for _, test := range tests {
+		entries, err := logging.ListEntries(test.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != test.want {
+			t.Errorf("ListEntries(%+v) returned %d entries, want %d", test.filter, len(entries), test.want)
+		}
+	}
+
+	if _, err := logging.ListEntries(models.LogFilter{MinLevel: "loud"}); err == nil {
+		t.Error("ListEntries with an invalid level succeeded")
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+	file, err := openRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := file.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := map[string]string{
+		path:        "fourth\n",
+		path + ".1": "third\n",
+		path + ".2": "second\n",
+	}
+	for path, content := range want {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != content {
+			t.Errorf("%s = %q, want %q", filepath.Base(path), data, content)
+		}
+	}
+	if paths := file.paths(); len(paths) != 3 || paths[2] != path {
+		t.Errorf("paths = %v, want both backups then the current file", paths)
+	}
+}
@@ -2,10 +2,18 @@ package models
 
 type FFprobeOutput struct {
 	Streams []struct {
+		Index     int    `json:"index"`
 		CodecType string `json:"codec_type"`
+		CodecName string `json:"codec_name"`
+		Width     int    `json:"width"`
+		Height    int    `json:"height"`
 		Duration  string `json:"duration"`
+		Tags      struct {
+			Language string `json:"language"`
+		} `json:"tags"`
 	} `json:"streams"`
 	Format struct {
-		Duration string `json:"duration"`
+		FormatName string `json:"format_name"`
+		Duration   string `json:"duration"`
 	} `json:"format"`
 }
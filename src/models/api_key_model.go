@@ -0,0 +1,33 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+const (
+	ScopeLibraryRead = "library:read"
+	ScopeStream      = "stream"
+	ScopeAdmin       = "admin"
+)
+
+var ApiKeyScopes = []string{ScopeLibraryRead, ScopeStream, ScopeAdmin}
+
+type ApiKey struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	// UserID is the user the key belongs to, 0 for keys of shared clients
+	UserID int `json:"user_id"`
+	// Key is only filled in when the key is created, it is never stored
+	Key string `json:"key,omitempty"`
+}
+
+// HasScope reports whether the key grants the given scope. Admin keys are
+// allowed everything.
+func (k *ApiKey) HasScope(scope string) bool {
+	return slices.Contains(k.Scopes, ScopeAdmin) || slices.Contains(k.Scopes, scope)
+}
@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+const (
+	AuditLogin          = "login"
+	AuditLoginFailed    = "login_failed"
+	AuditStreamStart    = "stream_start"
+	AuditStreamStop     = "stream_stop"
+	AuditCategoryCreate = "category_create"
+	AuditCategoryUpdate = "category_update"
+	AuditCategoryDelete = "category_delete"
+	AuditFolderAdd      = "folder_add"
+	AuditFolderRemove   = "folder_remove"
+	AuditPathRejected   = "path_rejected"
+	AuditUserCreate     = "user_create"
+	AuditUserDelete     = "user_delete"
+)
+
+// AuditActorDesktop is the actor recorded for changes made from the desktop app
+const AuditActorDesktop = "desktop"
+
+type AuditEvent struct {
+	ID         int       `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	Action     string    `json:"action"`
+	Actor      string    `json:"actor"`
+	IP         string    `json:"ip"`
+	TargetType string    `json:"target_type"`
+	TargetID   int       `json:"target_id"`
+	Details    string    `json:"details"`
+}
+
+// AuditFilter narrows down ListAuditEvents, empty fields match everything
+type AuditFilter struct {
+	Action     string     `json:"action"`
+	Actor      string     `json:"actor"`
+	TargetType string     `json:"target_type"`
+	TargetID   int        `json:"target_id"`
+	Since      *time.Time `json:"since,omitempty"`
+	Until      *time.Time `json:"until,omitempty"`
+	Limit      int        `json:"limit"`
+	Offset     int        `json:"offset"`
+}
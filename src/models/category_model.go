@@ -1,6 +1,10 @@
 package models
 
+// Category groups folders. The /categories route lists smart collections
+// after them as virtual categories, with the negated collection id as ID and
+// SmartCollectionID set.
 type Category struct {
-	ID   int
-	Name string
+	ID                int
+	Name              string
+	SmartCollectionID int `json:"SmartCollectionID,omitempty"`
 }
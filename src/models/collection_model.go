@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// Collection is a list of media items curated by hand, like "Marvel in
+// order". Its items keep the order they were arranged in.
+type Collection struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	ItemCount   int       `json:"item_count"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
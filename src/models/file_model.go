@@ -1,13 +1,70 @@
 package models
 
+import "time"
+
+// File is a video of a folder as served by the /files route
 type File struct {
+	MediaItemID   int        `json:"media_item_id"`
+	Name          string     `json:"name"`
+	Title         string     `json:"title"`
+	Year          int        `json:"year,omitempty"`
+	Edition       string     `json:"edition,omitempty"`
+	Path          string     `json:"path"`
+	URL           string     `json:"url"`
+	SubtitlesURL  string     `json:"subtitles_url"`
+	CategoryID    int        `json:"category_id"`
+	FolderID      int        `json:"folder_id"`
+	ThumbnailURL  string     `json:"thumbnail_url"`
+	Duration      float64    `json:"time_length"`
+	ContentLength int64      `json:"content_length"`
+	AddedAt       time.Time  `json:"added_at"`
+	Resolution    string     `json:"resolution,omitempty"`
+	Codec         string     `json:"codec,omitempty"`
+	LastWatchedAt *time.Time `json:"last_watched_at,omitempty"`
+	// Versions are the other versions of the same title, in any folder
+	Versions []FileVersion `json:"versions,omitempty"`
+	// Parts are the files of a video split in several, Duration and
+	// ContentLength add them up. PlaylistURL plays them as one HLS stream.
+	Parts       []FilePart `json:"parts,omitempty"`
+	PlaylistURL string     `json:"playlist_url,omitempty"`
+}
+
+// FilePart is one of the files of a stacked File, numbered from 1
+type FilePart struct {
+	MediaItemID   int     `json:"media_item_id"`
+	Part          int     `json:"part"`
+	Name          string  `json:"name"`
+	URL           string  `json:"url"`
+	Duration      float64 `json:"time_length"`
+	ContentLength int64   `json:"content_length"`
+}
+
+// FileVersion is another version of a File, like the 4K file of a film
+// listed in 1080p
+type FileVersion struct {
+	MediaItemID   int     `json:"media_item_id"`
 	Name          string  `json:"name"`
-	Path          string  `json:"path"`
 	URL           string  `json:"url"`
-	SubtitlesURL  string  `json:"subtitles_url"`
-	CategoryID    int     `json:"category_id"`
 	FolderID      int     `json:"folder_id"`
-	ThumbnailURL  string  `json:"thumbnail_url"`
+	Edition       string  `json:"edition,omitempty"`
+	Resolution    string  `json:"resolution,omitempty"`
+	Codec         string  `json:"codec,omitempty"`
 	Duration      float64 `json:"time_length"`
 	ContentLength int64   `json:"content_length"`
 }
+
+// PlaybackClient describes what a player can play as is. A MaxHeight of 0
+// and no Codecs put no limit.
+type PlaybackClient struct {
+	MaxHeight int      `json:"max_height"`
+	Codecs    []string `json:"codecs"`
+}
+
+// PlaybackDecision is the version of a title picked for a client and how to
+// play it. URL streams the version as is, through the playlist of its parts
+// when stacked, or transcodes it when Transcode is set.
+type PlaybackDecision struct {
+	File      File   `json:"file"`
+	Transcode bool   `json:"transcode"`
+	URL       string `json:"url"`
+}
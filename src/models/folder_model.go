@@ -1,7 +1,12 @@
 package models
 
+import "time"
+
+// Folder is a directory of videos in a category. LastScannedAt is nil until
+// the folder was scanned once.
 type Folder struct {
-	ID         int    `json:"id"`
-	Path       string `json:"path"`
-	CategoryID int    `json:"category_id"`
+	ID            int        `json:"id"`
+	Path          string     `json:"path"`
+	CategoryID    int        `json:"category_id"`
+	LastScannedAt *time.Time `json:"last_scanned_at,omitempty"`
 }
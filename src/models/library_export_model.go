@@ -0,0 +1,71 @@
+package models
+
+import "time"
+
+// LibraryExportVersion is bumped whenever LibraryExport changes in a way
+// older versions can't import. Version 2 added users and watch progress.
+const LibraryExportVersion = 2
+
+// LibraryExport is the portable JSON representation of a library. It holds no
+// database ids, folders are nested under their category and matched by path,
+// users are matched by name.
+type LibraryExport struct {
+	Version       int                     `json:"version"`
+	ExportedAt    time.Time               `json:"exported_at"`
+	Categories    []ExportedCategory      `json:"categories"`
+	Settings      map[string]string       `json:"settings"`
+	Users         []ExportedUser          `json:"users"`
+	ApiKeys       []ExportedApiKey        `json:"api_keys"`
+	WatchProgress []ExportedWatchProgress `json:"watch_progress"`
+}
+
+type ExportedCategory struct {
+	Name    string   `json:"name"`
+	Folders []string `json:"folders"`
+}
+
+type ExportedUser struct {
+	Name string `json:"name"`
+}
+
+// ExportedApiKey carries the key hash so existing clients keep working after
+// an import, the plain key is never stored. User is empty for the keys of
+// shared clients.
+type ExportedApiKey struct {
+	Name       string     `json:"name"`
+	User       string     `json:"user,omitempty"`
+	Prefix     string     `json:"prefix"`
+	KeyHash    string     `json:"key_hash"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+// ExportedWatchProgress is the progress of a user on the file at Path in
+// Folder. User is empty for the desktop app and shared clients.
+type ExportedWatchProgress struct {
+	User      string    `json:"user,omitempty"`
+	Folder    string    `json:"folder"`
+	Path      string    `json:"path"`
+	Position  float64   `json:"position"`
+	Duration  float64   `json:"duration"`
+	Watched   bool      `json:"watched"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// PathRemap rewrites folder paths starting with From to start with To
+type PathRemap struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type ImportResult struct {
+	CategoriesCreated int      `json:"categories_created"`
+	FoldersCreated    int      `json:"folders_created"`
+	FoldersSkipped    int      `json:"folders_skipped"`
+	SettingsImported  int      `json:"settings_imported"`
+	UsersCreated      int      `json:"users_created"`
+	ApiKeysImported   int      `json:"api_keys_imported"`
+	ProgressImported  int      `json:"progress_imported"`
+	Warnings          []string `json:"warnings"`
+}
@@ -0,0 +1,83 @@
+package models
+
+// Sort fields of ListOptions. Not every list supports every field.
+const (
+	SortName        = "name"
+	SortAddedAt     = "added_at"
+	SortYear        = "year"
+	SortDuration    = "duration"
+	SortSize        = "size"
+	SortLastWatched = "last_watched"
+	SortNumber      = "number"
+)
+
+// Filters of ListOptions. All but unwatched take a value, like
+// "resolution:1080p". Tag matches tags of any kind, genre only genres.
+const (
+	FilterUnwatched  = "unwatched"
+	FilterResolution = "resolution"
+	FilterCodec      = "codec"
+	FilterTag        = "tag"
+	FilterGenre      = "genre"
+)
+
+// ListOptions pages, sorts and filters a list. Sort is one of the Sort
+// fields, prefixed with "-" for descending order, empty for the list's
+// natural order. A Limit of 0 returns every item from Offset on. UserID is
+// whose watch progress is listed, it comes from the api key and the desktop
+// app is user 0.
+type ListOptions struct {
+	UserID  int      `json:"-"`
+	Offset  int      `json:"offset"`
+	Limit   int      `json:"limit"`
+	Sort    string   `json:"sort"`
+	Filters []string `json:"filters"`
+}
+
+// The pages below are one page of a list, Total counts the items of every
+// page together
+
+type CategoryPage struct {
+	Items []Category `json:"items"`
+	Total int        `json:"total"`
+}
+
+type FolderPage struct {
+	Items []Folder `json:"items"`
+	Total int      `json:"total"`
+}
+
+type MediaItemPage struct {
+	Items []MediaItem `json:"items"`
+	Total int         `json:"total"`
+}
+
+type SeriesPage struct {
+	Items []Series `json:"items"`
+	Total int      `json:"total"`
+}
+
+type SeasonPage struct {
+	Items []Season `json:"items"`
+	Total int      `json:"total"`
+}
+
+type EpisodePage struct {
+	Items []Episode `json:"items"`
+	Total int       `json:"total"`
+}
+
+type TagPage struct {
+	Items []Tag `json:"items"`
+	Total int   `json:"total"`
+}
+
+type CollectionPage struct {
+	Items []Collection `json:"items"`
+	Total int          `json:"total"`
+}
+
+type SmartCollectionPage struct {
+	Items []SmartCollection `json:"items"`
+	Total int               `json:"total"`
+}
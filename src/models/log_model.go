@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// Subsystems have their own log level, so one part of the app can be
+// debugged without drowning in the others' logs
+const (
+	LogSubsystemApp     = "app"
+	LogSubsystemDb      = "db"
+	LogSubsystemHttp    = "http"
+	LogSubsystemLibrary = "library"
+	LogSubsystemMedia   = "media"
+	LogSubsystemAuth    = "auth"
+)
+
+var LogSubsystems = []string{LogSubsystemApp, LogSubsystemDb, LogSubsystemHttp, LogSubsystemLibrary, LogSubsystemMedia, LogSubsystemAuth}
+
+// LogSettings sets the level of every subsystem, Subsystems overrides it for
+// single ones. Levels are debug, info, warn or error.
+type LogSettings struct {
+	Level      string            `json:"level"`
+	Subsystems map[string]string `json:"subsystems"`
+}
+
+// LogEntry is one line of the log file
+type LogEntry struct {
+	Time      time.Time      `json:"time"`
+	Level     string         `json:"level"`
+	Subsystem string         `json:"subsystem"`
+	Message   string         `json:"message"`
+	RequestID string         `json:"request_id"`
+	Attrs     map[string]any `json:"attrs"`
+}
+
+// LogFilter narrows down the log entries shown in the viewer, empty fields
+// match everything
+type LogFilter struct {
+	MinLevel  string `json:"min_level"`
+	Subsystem string `json:"subsystem"`
+	RequestID string `json:"request_id"`
+	Search    string `json:"search"`
+	Limit     int    `json:"limit"`
+}
@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+// MediaItem is a video file found in a folder by the scanner. RelPath is
+// relative to the folder and always uses forward slashes.
+type MediaItem struct {
+	ID         int       `json:"id"`
+	FolderID   int       `json:"folder_id"`
+	RelPath    string    `json:"rel_path"`
+	Name       string    `json:"name"`
+	Size       int64     `json:"size"`
+	ModifiedAt time.Time `json:"modified_at"`
+	Duration   float64   `json:"duration"`
+	AddedAt    time.Time `json:"added_at"`
+	ScannedAt  time.Time `json:"scanned_at"`
+	// SortTitle is the release title lowercased without its leading article
+	SortTitle string      `json:"sort_title"`
+	Release   ReleaseInfo `json:"release"`
+	// VersionGroup is shared by the versions of the same title, 0 when the
+	// item is the only one
+	VersionGroup int `json:"version_group,omitempty"`
+	// ContentHash identifies the content whatever the file name, empty
+	// unless content hashing is enabled
+	ContentHash string `json:"content_hash,omitempty"`
+	// StackID is the id of the first part of a video split in several files,
+	// numbered from 1 by Part. Both are 0 for videos in a single file.
+	StackID int `json:"stack_id,omitempty"`
+	Part    int `json:"part,omitempty"`
+	// LastWatchedAt is when the item was last streamed, only set by listings
+	LastWatchedAt *time.Time `json:"last_watched_at,omitempty"`
+}
+
+// ReleaseInfo is what the scanner reads out of a file name like
+// "The.Matrix.1999.2160p.UHD.BluRay.x265-GROUP.mkv". Fields not in the name
+// are left empty.
+type ReleaseInfo struct {
+	Title      string `json:"title"`
+	Year       int    `json:"year,omitempty"`
+	Resolution string `json:"resolution,omitempty"`
+	Source     string `json:"source,omitempty"`
+	Codec      string `json:"codec,omitempty"`
+	Edition    string `json:"edition,omitempty"`
+	Group      string `json:"release_group,omitempty"`
+}
+
+// ScanResult counts the changes of a scan. Stacks is how many videos of the
+// folder are split in parts, Versions how many media items of the whole
+// library have other versions.
+type ScanResult struct {
+	FolderID  int      `json:"folder_id"`
+	Added     int      `json:"added"`
+	Updated   int      `json:"updated"`
+	Removed   int      `json:"removed"`
+	Unchanged int      `json:"unchanged"`
+	Stacks    int      `json:"stacks"`
+	Episodes  int      `json:"episodes"`
+	Versions  int      `json:"versions"`
+	Errors    []string `json:"errors"`
+}
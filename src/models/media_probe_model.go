@@ -0,0 +1,36 @@
+package models
+
+// MediaProbe describes a media file as reported by the media toolkit
+type MediaProbe struct {
+	FormatName string        `json:"format_name"`
+	Duration   float64       `json:"duration"`
+	Streams    []MediaStream `json:"streams"`
+}
+
+type MediaStream struct {
+	Index     int    `json:"index"`
+	CodecType string `json:"codec_type"`
+	CodecName string `json:"codec_name"`
+	Language  string `json:"language"`
+	Width     int    `json:"width"`
+	Height    int    `json:"height"`
+}
+
+// TranscodeOptions control how a video is converted for players that can't
+// play the original. Start and Duration are in seconds, a Duration of 0 goes
+// to the end and a MaxHeight of 0 keeps the size.
+type TranscodeOptions struct {
+	Start     float64 `json:"start"`
+	Duration  float64 `json:"duration"`
+	MaxHeight int     `json:"max_height"`
+}
+
+// MediaToolkitStatus reports which media tools were found, Error explains why
+// they are unavailable
+type MediaToolkitStatus struct {
+	Available   bool   `json:"available"`
+	FFmpegPath  string `json:"ffmpeg_path"`
+	FFprobePath string `json:"ffprobe_path"`
+	Version     string `json:"version"`
+	Error       string `json:"error"`
+}
@@ -0,0 +1,100 @@
+package models
+
+import "time"
+
+// MetadataSourceNFO is the source of metadata read from Kodi NFO files,
+// metadata fetched from a provider has the provider's name as source
+const MetadataSourceNFO = "nfo"
+
+// ArtworkSourceLocal is the source of images found next to the video
+const ArtworkSourceLocal = "local"
+
+const (
+	ArtworkPoster = "poster"
+	ArtworkFanart = "fanart"
+	ArtworkLogo   = "logo"
+	ArtworkThumb  = "thumb"
+)
+
+var ArtworkKinds = []string{ArtworkPoster, ArtworkFanart, ArtworkLogo, ArtworkThumb}
+
+// Metadata describes a media item beyond its file name. ExternalIDs are keyed
+// by provider, like "imdb" or "tmdb".
+type Metadata struct {
+	MediaItemID   int               `json:"media_item_id"`
+	Source        string            `json:"source"`
+	Title         string            `json:"title"`
+	OriginalTitle string            `json:"original_title,omitempty"`
+	Plot          string            `json:"plot,omitempty"`
+	Year          int               `json:"year,omitempty"`
+	Rating        float64           `json:"rating,omitempty"`
+	Genres        []string          `json:"genres"`
+	Cast          []CastMember      `json:"cast"`
+	ExternalIDs   map[string]string `json:"external_ids"`
+	// Confidence is the score of the provider match, Locked is set when the
+	// match was fixed by hand
+	Confidence       float64   `json:"confidence,omitempty"`
+	Locked           bool      `json:"locked"`
+	SourcePath       string    `json:"-"`
+	SourceModifiedAt time.Time `json:"-"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+type CastMember struct {
+	Name  string `json:"name"`
+	Role  string `json:"role,omitempty"`
+	Order int    `json:"order"`
+}
+
+// Artwork is an image of a media item, found next to it or downloaded from a
+// metadata provider. Kind is one of ArtworkKinds.
+type Artwork struct {
+	MediaItemID int    `json:"media_item_id"`
+	Kind        string `json:"kind"`
+	Path        string `json:"path"`
+	Source      string `json:"source"`
+}
+
+// MediaItemDetails is a media item with its metadata, its tags and genres,
+// and the URLs of its artwork by kind
+type MediaItemDetails struct {
+	MediaItem MediaItem         `json:"media_item"`
+	Metadata  *Metadata         `json:"metadata"`
+	Tags      []Tag             `json:"tags"`
+	Artwork   map[string]string `json:"artwork"`
+}
+
+// MetadataQuery is what a metadata provider searches for. ExternalIDs known
+// from an NFO file identify the item without guessing.
+type MetadataQuery struct {
+	Title       string            `json:"title"`
+	Year        int               `json:"year,omitempty"`
+	ExternalIDs map[string]string `json:"external_ids,omitempty"`
+}
+
+// MetadataMatch is a search result of a provider, Confidence is filled in by
+// the scoring from 0 to 1
+type MetadataMatch struct {
+	Provider    string            `json:"provider"`
+	ID          string            `json:"id"`
+	Title       string            `json:"title"`
+	Year        int               `json:"year,omitempty"`
+	Overview    string            `json:"overview,omitempty"`
+	ExternalIDs map[string]string `json:"external_ids,omitempty"`
+	Confidence  float64           `json:"confidence"`
+}
+
+// RemoteImage is an image offered by a provider, URL can be http(s) or a
+// local file path
+type RemoteImage struct {
+	Kind string `json:"kind"`
+	URL  string `json:"url"`
+}
+
+// MatchResult tells what automatic matching did with a media item
+type MatchResult struct {
+	MediaItemID int            `json:"media_item_id"`
+	Matched     bool           `json:"matched"`
+	Match       *MetadataMatch `json:"match,omitempty"`
+	Reason      string         `json:"reason,omitempty"`
+}
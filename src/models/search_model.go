@@ -0,0 +1,44 @@
+package models
+
+// SearchFilter narrows down a search. Query is required, the other fields
+// match everything when empty. Watched is true for items UserID watched to
+// the end and false for the others. UserID comes from the api key, the
+// desktop app is user 0.
+type SearchFilter struct {
+	UserID     int    `json:"-"`
+	Query      string `json:"q"`
+	CategoryID int    `json:"category_id"`
+	Year       int    `json:"year"`
+	Genre      string `json:"genre"`
+	Watched    *bool  `json:"watched,omitempty"`
+	Limit      int    `json:"limit"`
+}
+
+// SearchResult is a media item found by a search, best results come first.
+// Title and Year come from the metadata when known, otherwise from the file
+// name.
+type SearchResult struct {
+	MediaItem MediaItem `json:"media_item"`
+	Title     string    `json:"title"`
+	Year      int       `json:"year,omitempty"`
+	Genres    []string  `json:"genres"`
+	Series    string    `json:"series,omitempty"`
+	Watched   bool      `json:"watched"`
+	Score     float64   `json:"score"`
+	URL       string    `json:"url,omitempty"`
+}
+
+// SearchDocument is what the search index holds for a media item, Path is
+// the video file on disk
+type SearchDocument struct {
+	MediaItemID   int
+	Path          string
+	Title         string
+	OriginalTitle string
+	Series        string
+	FileName      string
+	Plot          string
+	Cast          string
+	Genres        string
+	Subtitles     string
+}
@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+type Series struct {
+	ID           int       `json:"id"`
+	Title        string    `json:"title"`
+	Year         int       `json:"year,omitempty"`
+	SeasonCount  int       `json:"season_count"`
+	EpisodeCount int       `json:"episode_count"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type Season struct {
+	ID           int `json:"id"`
+	SeriesID     int `json:"series_id"`
+	Number       int `json:"number"`
+	EpisodeCount int `json:"episode_count"`
+}
+
+// Episode is a media item placed in a season. Date-based episodes have no
+// number, they are ordered by AirDate (YYYY-MM-DD) instead.
+type Episode struct {
+	ID             int       `json:"id"`
+	SeasonID       int       `json:"season_id"`
+	MediaItemID    int       `json:"media_item_id"`
+	Number         int       `json:"number"`
+	EndNumber      int       `json:"end_number,omitempty"`
+	AbsoluteNumber int       `json:"absolute_number,omitempty"`
+	AirDate        string    `json:"air_date,omitempty"`
+	Title          string    `json:"title,omitempty"`
+	MediaItem      MediaItem `json:"media_item"`
+	URL            string    `json:"url,omitempty"`
+}
+
+// EpisodeInfo is what the scanner could tell about an episode from its path
+type EpisodeInfo struct {
+	SeriesTitle    string `json:"series_title"`
+	SeriesYear     int    `json:"series_year,omitempty"`
+	Season         int    `json:"season"`
+	Number         int    `json:"number"`
+	EndNumber      int    `json:"end_number,omitempty"`
+	AbsoluteNumber int    `json:"absolute_number,omitempty"`
+	AirDate        string `json:"air_date,omitempty"`
+	Title          string `json:"title,omitempty"`
+}
@@ -0,0 +1,41 @@
+package models
+
+type CorsSettings struct {
+	AllowOrigins     []string `json:"allow_origins"`
+	AllowMethods     []string `json:"allow_methods"`
+	AllowCredentials bool     `json:"allow_credentials"`
+}
+
+// TlsSettings configures HTTPS for the streaming server. When CertFile and
+// KeyFile are empty a self-signed certificate is generated.
+type TlsSettings struct {
+	Enabled      bool   `json:"enabled"`
+	CertFile     string `json:"cert_file"`
+	KeyFile      string `json:"key_file"`
+	RedirectHTTP bool   `json:"redirect_http"`
+	RedirectPort int    `json:"redirect_port"`
+}
+
+// RateLimitSettings caps how much a single client can use the server. Zero
+// values disable the matching limit.
+type RateLimitSettings struct {
+	RequestsPerMinute int     `json:"requests_per_minute"`
+	MaxBandwidthMbps  float64 `json:"max_bandwidth_mbps"`
+}
+
+// MetadataSettings picks the provider automatic matching uses, none when
+// Provider is empty. Matches scoring below MinConfidence are left for the
+// user to fix.
+type MetadataSettings struct {
+	Provider      string  `json:"provider"`
+	MinConfidence float64 `json:"min_confidence"`
+}
+
+// ScanSettings control what the scanner reads of the video files. Hashing
+// the content reads the start and the end of every new or changed file, it
+// finds copies of a video whatever their name. Folders are scanned again
+// every RescanMinutes while the server runs, never when 0.
+type ScanSettings struct {
+	HashContent   bool `json:"hash_content"`
+	RescanMinutes int  `json:"rescan_minutes"`
+}
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Fields compared by the conditions of smart collection rules. Durations are
+// in seconds and sizes in bytes, type is "movie" or "episode".
+const (
+	SmartFieldTitle         = "title"
+	SmartFieldYear          = "year"
+	SmartFieldResolution    = "resolution"
+	SmartFieldCodec         = "codec"
+	SmartFieldDuration      = "duration"
+	SmartFieldSize          = "size"
+	SmartFieldAddedAt       = "added_at"
+	SmartFieldLastWatchedAt = "last_watched_at"
+	SmartFieldWatched       = "watched"
+	SmartFieldType          = "type"
+	SmartFieldTag           = "tag"
+	SmartFieldGenre         = "genre"
+	SmartFieldCategoryID    = "category_id"
+	SmartFieldFolderID      = "folder_id"
+)
+
+// Operators of smart collection conditions. InLast takes a period like "7d",
+// "2w", "1m" or "1y".
+const (
+	SmartOpEquals         = "eq"
+	SmartOpNotEquals      = "ne"
+	SmartOpLess           = "lt"
+	SmartOpLessOrEqual    = "lte"
+	SmartOpGreater        = "gt"
+	SmartOpGreaterOrEqual = "gte"
+	SmartOpContains       = "contains"
+	SmartOpBefore         = "before"
+	SmartOpAfter          = "after"
+	SmartOpInLast         = "in_last"
+)
+
+// How a group of smart collection rules combines them
+const (
+	SmartMatchAll = "all"
+	SmartMatchAny = "any"
+)
+
+const (
+	MediaTypeMovie   = "movie"
+	MediaTypeEpisode = "episode"
+)
+
+// SmartRule is a node of the rule tree of a smart collection: either a group
+// matching all or any of its Rules, or a condition comparing a Field of the
+// media items to Value with Op. "Unwatched 4K movies added this month" is
+//
+//	{"match": "all", "rules": [
+//		{"field": "watched", "op": "eq", "value": false},
+//		{"field": "resolution", "op": "eq", "value": "2160p"},
+//		{"field": "type", "op": "eq", "value": "movie"},
+//		{"field": "added_at", "op": "in_last", "value": "1m"}]}
+type SmartRule struct {
+	Match string      `json:"match,omitempty"`
+	Rules []SmartRule `json:"rules,omitempty"`
+	Field string      `json:"field,omitempty"`
+	Op    string      `json:"op,omitempty"`
+	Value any         `json:"value"`
+}
+
+// SmartCollection lists the media items matching its rules when requested,
+// sorted by Sort unless the request sorts them otherwise
+type SmartCollection struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Rules     SmartRule `json:"rules"`
+	Sort      string    `json:"sort"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SmartPeriodStart is when the period of an in_last condition, like "7d",
+// "2w", "1m" or "1y", started before now. Months and years are calendar ones.
+func SmartPeriodStart(period string, now time.Time) (time.Time, error) {
+	if len(period) < 2 {
+		return time.Time{}, fmt.Errorf("invalid period %q", period)
+	}
+	count, err := strconv.Atoi(period[:len(period)-1])
+	if err != nil || count <= 0 {
+		return time.Time{}, fmt.Errorf("invalid period %q", period)
+	}
+
+	switch period[len(period)-1] {
+	case 'd':
+		return now.AddDate(0, 0, -count), nil
+	case 'w':
+		return now.AddDate(0, 0, -7*count), nil
+	case 'm':
+		return now.AddDate(0, -count, 0), nil
+	case 'y':
+		return now.AddDate(-count, 0, 0), nil
+	default:
+		return time.Time{}, fmt.Errorf("invalid period %q, expected a number of days, weeks, months or years like 7d, 2w, 1m or 1y", period)
+	}
+}
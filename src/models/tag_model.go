@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Kinds of tags. Genres follow the genres of the metadata, tags are set by
+// hand.
+const (
+	TagKindTag   = "tag"
+	TagKindGenre = "genre"
+)
+
+var TagKinds = []string{TagKindTag, TagKindGenre}
+
+// Tag labels media items, many to many. Names are unique per kind, ignoring
+// case.
+type Tag struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Kind      string    `json:"kind"`
+	ItemCount int       `json:"item_count"`
+	CreatedAt time.Time `json:"created_at"`
+}
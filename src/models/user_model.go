@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// User is a person watching the library. Their api keys identify them, so
+// what they watched is kept apart from the other users.
+type User struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	ApiKeys   int       `json:"api_keys"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// WatchProgress is how far a user got into a media item, in seconds. UserID
+// is 0 for the desktop app and the api keys of shared clients. Watched stays
+// set once the item was played to the end, even when played again.
+type WatchProgress struct {
+	UserID      int       `json:"user_id"`
+	MediaItemID int       `json:"media_item_id"`
+	Position    float64   `json:"position"`
+	Duration    float64   `json:"duration"`
+	Watched     bool      `json:"watched"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
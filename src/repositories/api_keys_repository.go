@@ -0,0 +1,171 @@
+package repositories
+
+import (
+	"database/sql"
+	"localflix-server/src/models"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const apiKeySelect = "SELECT id, name, prefix, scopes, created_at, last_used_at, user_id FROM api_keys"
+
+type ApiKeysRepository struct {
+	db DBTX
+}
+
+func NewApiKeysRepository(db DBTX) *ApiKeysRepository {
+	return &ApiKeysRepository{
+		db: db,
+	}
+}
+
+// CreateApiKey stores a key of the user, userId 0 is for keys without one
+func (a *ApiKeysRepository) CreateApiKey(name string, prefix string, keyHash string, scopes []string, userId int) (*models.ApiKey, error) {
+	createdAt := time.Now().UTC()
+	result, err := a.db.Exec(
+		"INSERT INTO api_keys (name, prefix, key_hash, scopes, created_at, user_id) VALUES (?, ?, ?, ?, ?, ?)",
+		name, prefix, keyHash, strings.Join(scopes, ","), createdAt, nullableId(userId),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.ApiKey{
+		ID:        int(id),
+		Name:      name,
+		Prefix:    prefix,
+		Scopes:    scopes,
+		CreatedAt: createdAt,
+		UserID:    userId,
+	}, nil
+}
+
+func (a *ApiKeysRepository) GetApiKeyByHash(keyHash string) (*models.ApiKey, error) {
+	row := a.db.QueryRow(apiKeySelect+" WHERE key_hash = ?", keyHash)
+	apiKey, err := scanApiKey(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return apiKey, nil
+}
+
+func (a *ApiKeysRepository) ListApiKeys() []*models.ApiKey {
+	rows, err := a.db.Query(apiKeySelect)
+	if err != nil {
+		slog.Error("listing api keys", "err", err)
+		return nil
+	}
+	defer rows.Close()
+
+	var apiKeys []*models.ApiKey
+	for rows.Next() {
+		apiKey, err := scanApiKey(rows)
+		if err != nil {
+			slog.Error("scanning api key", "err", err)
+			return nil
+		}
+
+		apiKeys = append(apiKeys, apiKey)
+	}
+
+	return apiKeys
+}
+
+func (a *ApiKeysRepository) TouchApiKey(id int, usedAt time.Time) error {
+	_, err := a.db.Exec("UPDATE api_keys SET last_used_at = ? WHERE id = ?", usedAt, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (a *ApiKeysRepository) DeleteApiKey(id int) error {
+	_, err := a.db.Exec("DELETE FROM api_keys WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ExportApiKeys returns every key with the name of its user
+func (a *ApiKeysRepository) ExportApiKeys() ([]models.ExportedApiKey, error) {
+	rows, err := a.db.Query("SELECT k.name, COALESCE(u.name, ''), k.prefix, k.key_hash, k.scopes, k.created_at, k.last_used_at FROM api_keys k LEFT JOIN users u ON u.id = k.user_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var apiKeys []models.ExportedApiKey
+	for rows.Next() {
+		var apiKey models.ExportedApiKey
+		var scopes string
+		var lastUsedAt sql.NullTime
+		err := rows.Scan(&apiKey.Name, &apiKey.User, &apiKey.Prefix, &apiKey.KeyHash, &scopes, &apiKey.CreatedAt, &lastUsedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		if scopes != "" {
+			apiKey.Scopes = strings.Split(scopes, ",")
+		}
+		if lastUsedAt.Valid {
+			apiKey.LastUsedAt = &lastUsedAt.Time
+		}
+		apiKeys = append(apiKeys, apiKey)
+	}
+
+	return apiKeys, rows.Err()
+}
+
+// ImportApiKey inserts an exported key for the user, 0 for a shared client.
+// It returns false when a key with the same hash already exists.
+func (a *ApiKeysRepository) ImportApiKey(apiKey models.ExportedApiKey, userId int) (bool, error) {
+	result, err := a.db.Exec(
+		"INSERT OR IGNORE INTO api_keys (name, prefix, key_hash, scopes, created_at, last_used_at, user_id) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		apiKey.Name, apiKey.Prefix, apiKey.KeyHash, strings.Join(apiKey.Scopes, ","), apiKey.CreatedAt, apiKey.LastUsedAt, nullableId(userId),
+	)
+	if err != nil {
+		return false, err
+	}
+
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return inserted > 0, nil
+}
+
+func scanApiKey(row rowScanner) (*models.ApiKey, error) {
+	var apiKey models.ApiKey
+	var scopes string
+	var lastUsedAt sql.NullTime
+	var userId sql.NullInt64
+	err := row.Scan(&apiKey.ID, &apiKey.Name, &apiKey.Prefix, &scopes, &apiKey.CreatedAt, &lastUsedAt, &userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if scopes != "" {
+		apiKey.Scopes = strings.Split(scopes, ",")
+	}
+	if lastUsedAt.Valid {
+		apiKey.LastUsedAt = &lastUsedAt.Time
+	}
+	apiKey.UserID = int(userId.Int64)
+
+	return &apiKey, nil
+}
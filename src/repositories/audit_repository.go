@@ -0,0 +1,111 @@
+package repositories
+
+import (
+	"localflix-server/src/models"
+	"strings"
+	"time"
+)
+
+type AuditRepository struct {
+	db DBTX
+}
+
+func NewAuditRepository(db DBTX) *AuditRepository {
+	return &AuditRepository{
+		db: db,
+	}
+}
+
+func (a *AuditRepository) CreateAuditEvent(event models.AuditEvent) error {
+	_, err := a.db.Exec(
+		"INSERT INTO audit_events (created_at, action, actor, ip, target_type, target_id, details) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		event.CreatedAt, event.Action, event.Actor, event.IP, event.TargetType, event.TargetID, event.Details,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// auditConditions is the WHERE clause of the filter, empty when it matches
+// every event
+func auditConditions(filter models.AuditFilter) (string, []any) {
+	var conditions []string
+	var args []any
+	if filter.Action != "" {
+		conditions = append(conditions, "action = ?")
+		args = append(args, filter.Action)
+	}
+	if filter.Actor != "" {
+		conditions = append(conditions, "actor = ?")
+		args = append(args, filter.Actor)
+	}
+	if filter.TargetType != "" {
+		conditions = append(conditions, "target_type = ?")
+		args = append(args, filter.TargetType)
+	}
+	if filter.TargetID != 0 {
+		conditions = append(conditions, "target_id = ?")
+		args = append(args, filter.TargetID)
+	}
+	if filter.Since != nil {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, filter.Since.UTC())
+	}
+	if filter.Until != nil {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, filter.Until.UTC())
+	}
+	if len(conditions) == 0 {
+		return "", nil
+	}
+
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+func (a *AuditRepository) ListAuditEvents(filter models.AuditFilter) ([]*models.AuditEvent, error) {
+	where, args := auditConditions(filter)
+	query := "SELECT id, created_at, action, actor, ip, target_type, target_id, details FROM audit_events" + where
+	query += " ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?"
+	args = append(args, filter.Limit, filter.Offset)
+
+	rows, err := a.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*models.AuditEvent
+	for rows.Next() {
+		var event models.AuditEvent
+		err := rows.Scan(&event.ID, &event.CreatedAt, &event.Action, &event.Actor, &event.IP, &event.TargetType, &event.TargetID, &event.Details)
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, &event)
+	}
+
+	return events, nil
+}
+
+// CountAuditEvents counts the events matching the filter, ignoring its limit
+// and offset
+func (a *AuditRepository) CountAuditEvents(filter models.AuditFilter) (int, error) {
+	where, args := auditConditions(filter)
+	var count int
+	err := a.db.QueryRow("SELECT COUNT(*) FROM audit_events"+where, args...).Scan(&count)
+	return count, err
+}
+
+// DeleteAuditEventsBefore deletes the events created before the given time
+// and returns how many were deleted
+func (a *AuditRepository) DeleteAuditEventsBefore(before time.Time) (int64, error) {
+	result, err := a.db.Exec("DELETE FROM audit_events WHERE created_at < ?", before.UTC())
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
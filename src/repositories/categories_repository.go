@@ -2,15 +2,15 @@ package repositories
 
 import (
 	"database/sql"
-	"fmt"
 	"localflix-server/src/models"
+	"log/slog"
 )
 
 type CategoriesRepository struct {
-	db *sql.DB
+	db DBTX
 }
 
-func NewCategoriesRepository(db *sql.DB) *CategoriesRepository {
+func NewCategoriesRepository(db DBTX) *CategoriesRepository {
 	return &CategoriesRepository{
 		db: db,
 	}
@@ -19,13 +19,11 @@ func NewCategoriesRepository(db *sql.DB) *CategoriesRepository {
 func (c *CategoriesRepository) CreateCategory(name string) (*models.Category, error) {
 	result, err := c.db.Exec("INSERT INTO categories (name) VALUES (?)", name)
 	if err != nil {
-		fmt.Printf("error inserting category: %v\n", err)
 		return nil, err
 	}
 
 	id, err := result.LastInsertId()
 	if err != nil {
-		fmt.Printf("error getting last insert id: %v\n", err)
 		return nil, err
 	}
 
@@ -40,7 +38,6 @@ func (c *CategoriesRepository) GetCategory(id int) (*models.Category, error) {
 	var category models.Category
 	err := row.Scan(&category.ID, &category.Name)
 	if err != nil {
-		fmt.Printf("error getting category: %v\n", err)
 		return nil, err
 	}
 
@@ -50,7 +47,7 @@ func (c *CategoriesRepository) GetCategory(id int) (*models.Category, error) {
 func (c *CategoriesRepository) ListCategories() []*models.Category {
 	rows, err := c.db.Query("SELECT * FROM categories")
 	if err != nil {
-		fmt.Printf("error listing categories: %v\n", err)
+		slog.Error("listing categories", "err", err)
 		return nil
 	}
 
@@ -59,7 +56,7 @@ func (c *CategoriesRepository) ListCategories() []*models.Category {
 		var category models.Category
 		err := rows.Scan(&category.ID, &category.Name)
 		if err != nil {
-			fmt.Printf("error scanning category: %v\n", err)
+			slog.Error("scanning category", "err", err)
 			return nil
 		}
 
@@ -72,17 +69,20 @@ func (c *CategoriesRepository) ListCategories() []*models.Category {
 func (c *CategoriesRepository) DeleteCategory(id int) error {
 	_, err := c.db.Exec("DELETE FROM categories WHERE id = ?", id)
 	if err != nil {
-		fmt.Printf("error deleting category: %v\n", err)
 		return err
 	}
 
 	return nil
 }
 
+// UpdateCategory renames the category. Returns sql.ErrNoRows for an unknown
+// category.
 func (c *CategoriesRepository) UpdateCategory(id int, name string) (*models.Category, error) {
-	_, err := c.db.Exec("UPDATE categories SET name = ? WHERE id = ?", name, id)
+	result, err := c.db.Exec("UPDATE categories SET name = ? WHERE id = ?", name, id)
 	if err != nil {
-		fmt.Printf("error updating category: %v\n", err)
+		return nil, err
+	}
+	if err := requireAffected(result); err != nil {
 		return nil, err
 	}
 
@@ -101,7 +101,6 @@ func (c *CategoriesRepository) GetCategoryByName(name string) (*models.Category,
 			return nil, nil
 		}
 
-		fmt.Printf("error getting category: %v\n", err)
 		return nil, err
 	}
 
@@ -0,0 +1,164 @@
+package repositories
+
+import (
+	"database/sql"
+	"localflix-server/src/models"
+	"time"
+)
+
+type CollectionsRepository struct {
+	db DBTX
+}
+
+func NewCollectionsRepository(db DBTX) *CollectionsRepository {
+	return &CollectionsRepository{
+		db: db,
+	}
+}
+
+const collectionSelect = `SELECT c.id, c.name, c.description, c.created_at, c.updated_at, COUNT(ci.media_item_id)
+	FROM collections c LEFT JOIN collection_items ci ON ci.collection_id = c.id`
+
+func (c *CollectionsRepository) CreateCollection(name string, description string) (*models.Collection, error) {
+	now := time.Now().UTC()
+	result, err := c.db.Exec(
+		"INSERT INTO collections (name, description, created_at, updated_at) VALUES (?, ?, ?, ?)",
+		name, description, now, now,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Collection{ID: int(id), Name: name, Description: description, CreatedAt: now, UpdatedAt: now}, nil
+}
+
+func (c *CollectionsRepository) GetCollection(id int) (*models.Collection, error) {
+	collection, err := scanCollection(c.db.QueryRow(collectionSelect+" WHERE c.id = ? GROUP BY c.id", id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return collection, nil
+}
+
+// GetCollectionByName finds the collection ignoring case
+func (c *CollectionsRepository) GetCollectionByName(name string) (*models.Collection, error) {
+	collection, err := scanCollection(c.db.QueryRow(collectionSelect+" WHERE c.name = ? GROUP BY c.id", name))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return collection, nil
+}
+
+func (c *CollectionsRepository) ListCollections() ([]*models.Collection, error) {
+	rows, err := c.db.Query(collectionSelect + " GROUP BY c.id ORDER BY c.name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var collections []*models.Collection
+	for rows.Next() {
+		collection, err := scanCollection(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		collections = append(collections, collection)
+	}
+
+	return collections, rows.Err()
+}
+
+func (c *CollectionsRepository) UpdateCollection(id int, name string, description string) error {
+	result, err := c.db.Exec(
+		"UPDATE collections SET name = ?, description = ?, updated_at = ? WHERE id = ?",
+		name, description, time.Now().UTC(), id,
+	)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(result)
+}
+
+func (c *CollectionsRepository) DeleteCollection(id int) error {
+	result, err := c.db.Exec("DELETE FROM collections WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(result)
+}
+
+// ListItemIds returns the ids of the collection's media items in order
+func (c *CollectionsRepository) ListItemIds(collectionId int) ([]int, error) {
+	rows, err := c.db.Query("SELECT media_item_id FROM collection_items WHERE collection_id = ? ORDER BY position", collectionId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// ReplaceItems sets the media items of the collection, in the order of ids
+func (c *CollectionsRepository) ReplaceItems(collectionId int, mediaItemIds []int) error {
+	if _, err := c.db.Exec("DELETE FROM collection_items WHERE collection_id = ?", collectionId); err != nil {
+		return err
+	}
+
+	for position, mediaItemId := range mediaItemIds {
+		_, err := c.db.Exec(
+			"INSERT INTO collection_items (collection_id, media_item_id, position) VALUES (?, ?, ?)",
+			collectionId, mediaItemId, position,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := c.db.Exec("UPDATE collections SET updated_at = ? WHERE id = ?", time.Now().UTC(), collectionId)
+	return err
+}
+
+// ListItems returns a page of the collection's media items with when they
+// were last watched, and how many items the filters let through. Without a
+// sort items come in the collection's order.
+func (c *CollectionsRepository) ListItems(collectionId int, q ListQuery) ([]*models.MediaItem, int, error) {
+	return listMediaItemsPage(c.db, " FROM collection_items ci JOIN media_items m ON m.id = ci.media_item_id WHERE ci.collection_id = ?", []any{collectionId}, "ci.position", q)
+}
+
+func scanCollection(row rowScanner) (*models.Collection, error) {
+	var collection models.Collection
+	err := row.Scan(&collection.ID, &collection.Name, &collection.Description, &collection.CreatedAt, &collection.UpdatedAt, &collection.ItemCount)
+	if err != nil {
+		return nil, err
+	}
+
+	return &collection, nil
+}
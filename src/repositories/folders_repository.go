@@ -2,34 +2,35 @@ package repositories
 
 import (
 	"database/sql"
-	"fmt"
 	"localflix-server/src/models"
+	"log/slog"
+	"time"
 )
 
 type FoldersRepository struct {
-	db *sql.DB
+	db DBTX
 }
 
-func NewFoldersRepository(db *sql.DB) *FoldersRepository {
+func NewFoldersRepository(db DBTX) *FoldersRepository {
 	return &FoldersRepository{
 		db: db,
 	}
 }
 
+const folderColumns = "id, path, category_id, last_scanned_at"
+
 func (f *FoldersRepository) CreateFolder(folderPath string, categoryId int) (*models.Folder, error) {
 	result, err := f.db.Exec("INSERT INTO folders (path, category_id) VALUES (?, ?)", folderPath, categoryId)
 	if err != nil {
-		fmt.Printf("error inserting folder %v", err)
 		return nil, err
 	}
 
 	id, err := result.LastInsertId()
 	if err != nil {
-		fmt.Printf("error getting last insert id %v", err)
 		return nil, err
 	}
 
-	fmt.Printf("inserted folder with id %v", id)
+	slog.Debug("inserted folder", "id", id)
 	return &models.Folder{
 		ID:         int(id),
 		Path:       folderPath,
@@ -38,56 +39,46 @@ func (f *FoldersRepository) CreateFolder(folderPath string, categoryId int) (*mo
 }
 
 func (f *FoldersRepository) GetFolderById(id int) (*models.Folder, error) {
-	row := f.db.QueryRow("SELECT * FROM folders WHERE id = ?", id)
-	var folder models.Folder
-	err := row.Scan(&folder.ID, &folder.Path, &folder.CategoryID)
-	if err != nil {
-		fmt.Printf("error getting folder %v", err)
-		return nil, err
-	}
-
-	return &folder, nil
+	return scanFolder(f.db.QueryRow("SELECT "+folderColumns+" FROM folders WHERE id = ?", id))
 }
 
 func (f *FoldersRepository) GetFolderByCategory(categoryId int) []*models.Folder {
-	rows, err := f.db.Query("SELECT * FROM folders WHERE category_id = ?", categoryId)
+	rows, err := f.db.Query("SELECT "+folderColumns+" FROM folders WHERE category_id = ?", categoryId)
 	if err != nil {
-		fmt.Printf("error getting folders %v", err)
+		slog.Error("getting folders", "err", err)
 		return nil
 	}
 
 	var folders []*models.Folder
 	for rows.Next() {
-		var folder models.Folder
-		err := rows.Scan(&folder.ID, &folder.Path, &folder.CategoryID)
+		folder, err := scanFolder(rows)
 		if err != nil {
-			fmt.Printf("error scanning folder %v", err)
+			slog.Error("scanning folder", "err", err)
 			return nil
 		}
 
-		folders = append(folders, &folder)
+		folders = append(folders, folder)
 	}
 
 	return folders
 }
 
 func (f *FoldersRepository) ListFolders() []*models.Folder {
-	rows, err := f.db.Query("SELECT * FROM folders")
+	rows, err := f.db.Query("SELECT " + folderColumns + " FROM folders")
 	if err != nil {
-		fmt.Printf("error getting folders %v", err)
+		slog.Error("getting folders", "err", err)
 		return nil
 	}
 
 	var folders []*models.Folder
 	for rows.Next() {
-		var folder models.Folder
-		err := rows.Scan(&folder.ID, &folder.Path, &folder.CategoryID)
+		folder, err := scanFolder(rows)
 		if err != nil {
-			fmt.Printf("error scanning folder %v", err)
+			slog.Error("scanning folder", "err", err)
 			return nil
 		}
 
-		folders = append(folders, &folder)
+		folders = append(folders, folder)
 	}
 
 	return folders
@@ -96,9 +87,51 @@ func (f *FoldersRepository) ListFolders() []*models.Folder {
 func (f *FoldersRepository) DeleteFolder(id int) error {
 	_, err := f.db.Exec("DELETE FROM folders WHERE id = ?", id)
 	if err != nil {
-		fmt.Printf("error deleting folder %v", err)
 		return err
 	}
 
 	return nil
 }
+
+// MarkFolderScanned records when the folder was scanned
+func (f *FoldersRepository) MarkFolderScanned(id int, scannedAt time.Time) error {
+	result, err := f.db.Exec("UPDATE folders SET last_scanned_at = ? WHERE id = ?", scannedAt, id)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(result)
+}
+
+func (f *FoldersRepository) ListFolderIdsByCategory(categoryId int) ([]int, error) {
+	rows, err := f.db.Query("SELECT id FROM folders WHERE category_id = ?", categoryId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+func scanFolder(row rowScanner) (*models.Folder, error) {
+	var folder models.Folder
+	var lastScannedAt sql.NullTime
+	if err := row.Scan(&folder.ID, &folder.Path, &folder.CategoryID, &lastScannedAt); err != nil {
+		return nil, err
+	}
+
+	if lastScannedAt.Valid {
+		folder.LastScannedAt = &lastScannedAt.Time
+	}
+	return &folder, nil
+}
@@ -0,0 +1,342 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"localflix-server/src/models"
+)
+
+type MediaItemsRepository struct {
+	db DBTX
+}
+
+func NewMediaItemsRepository(db DBTX) *MediaItemsRepository {
+	return &MediaItemsRepository{
+		db: db,
+	}
+}
+
+const (
+	// firstPartCondition lets through the media items m that are single files
+	// or the first part of a stack
+	firstPartCondition = "(m.stack_id = 0 OR m.stack_id = m.id)"
+
+	// stackTotalDuration and stackTotalSize add up the parts of the media
+	// item m when it is stacked
+	stackTotalDuration = "(CASE WHEN m.stack_id = 0 THEN m.duration ELSE (SELECT SUM(p.duration) FROM media_items p WHERE p.stack_id = m.stack_id) END)"
+	stackTotalSize     = "(CASE WHEN m.stack_id = 0 THEN m.size ELSE (SELECT SUM(p.size) FROM media_items p WHERE p.stack_id = m.stack_id) END)"
+
+	mediaItemInsertColumns = "folder_id, rel_path, name, size, modified_at, duration, added_at, scanned_at, " +
+		"title, sort_title, year, resolution, source, codec, edition, release_group, content_hash"
+	mediaItemColumns = "id, " + mediaItemInsertColumns + ", version_group, stack_id, part"
+)
+
+func (m *MediaItemsRepository) CreateMediaItem(item models.MediaItem) (*models.MediaItem, error) {
+	result, err := m.db.Exec(
+		"INSERT INTO media_items ("+mediaItemInsertColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		item.FolderID, item.RelPath, item.Name, item.Size, item.ModifiedAt, item.Duration, item.AddedAt, item.ScannedAt,
+		item.Release.Title, item.SortTitle, item.Release.Year, item.Release.Resolution, item.Release.Source, item.Release.Codec, item.Release.Edition, item.Release.Group, item.ContentHash,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	item.ID = int(id)
+	return &item, nil
+}
+
+func (m *MediaItemsRepository) UpdateMediaItem(item models.MediaItem) error {
+	_, err := m.db.Exec(
+		"UPDATE media_items SET name = ?, size = ?, modified_at = ?, duration = ?, scanned_at = ?, content_hash = ? WHERE id = ?",
+		item.Name, item.Size, item.ModifiedAt, item.Duration, item.ScannedAt, item.ContentHash, item.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	return m.UpdateMediaItemRelease(item)
+}
+
+// UpdateMediaItemRelease only updates what was parsed from the file name
+func (m *MediaItemsRepository) UpdateMediaItemRelease(item models.MediaItem) error {
+	_, err := m.db.Exec(
+		"UPDATE media_items SET title = ?, sort_title = ?, year = ?, resolution = ?, source = ?, codec = ?, edition = ?, release_group = ? WHERE id = ?",
+		item.Release.Title, item.SortTitle, item.Release.Year, item.Release.Resolution, item.Release.Source, item.Release.Codec, item.Release.Edition, item.Release.Group, item.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (m *MediaItemsRepository) DeleteMediaItem(id int) error {
+	_, err := m.db.Exec("DELETE FROM media_items WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (m *MediaItemsRepository) GetMediaItem(id int) (*models.MediaItem, error) {
+	row := m.db.QueryRow("SELECT "+mediaItemColumns+" FROM media_items WHERE id = ?", id)
+	item, err := scanMediaItem(row)
+	if err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// GetMediaItemByPath returns nil when the folder has no media item at relPath
+func (m *MediaItemsRepository) GetMediaItemByPath(folderId int, relPath string) (*models.MediaItem, error) {
+	row := m.db.QueryRow("SELECT "+mediaItemColumns+" FROM media_items WHERE folder_id = ? AND rel_path = ?", folderId, relPath)
+	item, err := scanMediaItem(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// mediaItemOrders are the ORDER BY of the sort fields of media item lists,
+// for alias m. Ties are broken by path so pages don't overlap.
+var mediaItemOrders = map[string]string{
+	models.SortName:        "m.sort_title %[1]s, m.year %[1]s",
+	models.SortAddedAt:     "m.added_at %[1]s",
+	models.SortYear:        "m.year %[1]s",
+	models.SortDuration:    stackTotalDuration + " %[1]s",
+	models.SortSize:        stackTotalSize + " %[1]s",
+	models.SortLastWatched: "last_watched_at %[1]s",
+}
+
+// mediaItemFilters is the WHERE clause of the query's filters on the media
+// item m, starting with " AND" when there is one
+func mediaItemFilters(q ListQuery) (string, []any) {
+	var where string
+	var args []any
+	if q.Unwatched {
+		where += " AND " + watchedExpression(q.UserID) + " = 0"
+	}
+	if q.Resolution != "" {
+		where += " AND m.resolution = ? COLLATE NOCASE"
+		args = append(args, q.Resolution)
+	}
+	if q.Codec != "" {
+		where += " AND m.codec = ? COLLATE NOCASE"
+		args = append(args, q.Codec)
+	}
+	for _, tag := range q.Tags {
+		where += " AND EXISTS (SELECT 1 FROM media_item_tags mt JOIN tags t ON t.id = mt.tag_id WHERE mt.media_item_id = m.id AND t.name = ?)"
+		args = append(args, tag)
+	}
+	for _, genre := range q.Genres {
+		where += " AND EXISTS (SELECT 1 FROM media_item_tags mt JOIN tags t ON t.id = mt.tag_id WHERE mt.media_item_id = m.id AND t.kind = ? AND t.name = ?)"
+		args = append(args, models.TagKindGenre, genre)
+	}
+
+	return where, args
+}
+
+// ListMediaItemsPage returns a page of the folder's media items with when
+// the query's user last watched them, and how many items the filters let through
+func (m *MediaItemsRepository) ListMediaItemsPage(folderId int, q ListQuery) ([]*models.MediaItem, int, error) {
+	return listMediaItemsPage(m.db, " FROM media_items m WHERE m.folder_id = ?", []any{folderId}, "m.rel_path", q)
+}
+
+// listMediaItemsPage returns a page of the media items m selected by from,
+// which ends with a WHERE clause, with when the query's user last watched
+// them. Stacked
+// items are only listed by their first part, with the duration and size of
+// every part. The query's sort comes first and ties keep the list's order.
+func listMediaItemsPage(db DBTX, from string, args []any, order string, q ListQuery) ([]*models.MediaItem, int, error) {
+	filters, filterArgs := mediaItemFilters(q)
+	from += " AND " + firstPartCondition + filters
+	args = append(args, filterArgs...)
+
+	var total int
+	if err := db.QueryRow("SELECT COUNT(*)"+from, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	if q.Sort != "" {
+		order = fmt.Sprintf(mediaItemOrders[q.Sort], q.direction()) + ", " + order
+	}
+	limit, limitArgs := q.limitClause()
+	rows, err := db.Query(
+		"SELECT "+prefixColumns("m", mediaItemColumns)+", "+lastWatchedExpression(q.UserID)+" AS last_watched_at, "+stackTotalDuration+", "+stackTotalSize+from+" ORDER BY "+order+limit,
+		append(args, limitArgs...)...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var items []*models.MediaItem
+	for rows.Next() {
+		var item models.MediaItem
+		var lastWatchedAt sql.NullString
+		var duration float64
+		var size int64
+		if err := rows.Scan(append(mediaItemFields(&item), &lastWatchedAt, &duration, &size)...); err != nil {
+			return nil, 0, err
+		}
+
+		item.Duration, item.Size = duration, size
+		item.LastWatchedAt = parseTime(lastWatchedAt)
+		items = append(items, &item)
+	}
+
+	return items, total, rows.Err()
+}
+
+func (m *MediaItemsRepository) ListMediaItemsByFolder(folderId int) ([]*models.MediaItem, error) {
+	rows, err := m.db.Query("SELECT "+mediaItemColumns+" FROM media_items WHERE folder_id = ? ORDER BY rel_path", folderId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []*models.MediaItem
+	for rows.Next() {
+		item, err := scanMediaItem(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// ListMediaItems returns the media items of every folder, by id
+func (m *MediaItemsRepository) ListMediaItems() ([]*models.MediaItem, error) {
+	rows, err := m.db.Query("SELECT " + mediaItemColumns + " FROM media_items ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []*models.MediaItem
+	for rows.Next() {
+		item, err := scanMediaItem(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// ListVersions returns the versions of the media item, itself included, by
+// id. An item without other versions is its only version.
+func (m *MediaItemsRepository) ListVersions(id int) ([]*models.MediaItem, error) {
+	rows, err := m.db.Query(
+		"SELECT "+mediaItemColumns+" FROM media_items WHERE id = ? OR (version_group != 0 AND version_group = (SELECT version_group FROM media_items WHERE id = ?)) ORDER BY id",
+		id, id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []*models.MediaItem
+	for rows.Next() {
+		item, err := scanMediaItem(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// ListParts returns the parts of the stacked media item in order, or the
+// item alone when it is a single file
+func (m *MediaItemsRepository) ListParts(id int) ([]*models.MediaItem, error) {
+	rows, err := m.db.Query(
+		"SELECT "+mediaItemColumns+" FROM media_items WHERE (id = ? AND stack_id = 0) OR (stack_id != 0 AND stack_id = (SELECT stack_id FROM media_items WHERE id = ?)) ORDER BY part",
+		id, id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []*models.MediaItem
+	for rows.Next() {
+		item, err := scanMediaItem(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// UpdateStack sets the stack and the part number of the media item, 0 for
+// both when it is a single file
+func (m *MediaItemsRepository) UpdateStack(id int, stackId int, part int) error {
+	_, err := m.db.Exec("UPDATE media_items SET stack_id = ?, part = ? WHERE id = ?", stackId, part, id)
+	return err
+}
+
+// UpdateContentHash sets the content hash of a media item scanned before
+// content hashing was enabled
+func (m *MediaItemsRepository) UpdateContentHash(id int, hash string) error {
+	_, err := m.db.Exec("UPDATE media_items SET content_hash = ? WHERE id = ?", hash, id)
+	return err
+}
+
+// ReplaceVersionGroups sets the version group of the media items in groups,
+// by media item id, every other item has a single version
+func (m *MediaItemsRepository) ReplaceVersionGroups(groups map[int]int) error {
+	if _, err := m.db.Exec("UPDATE media_items SET version_group = 0 WHERE version_group != 0"); err != nil {
+		return err
+	}
+
+	for id, group := range groups {
+		if _, err := m.db.Exec("UPDATE media_items SET version_group = ? WHERE id = ?", group, id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func scanMediaItem(row rowScanner) (*models.MediaItem, error) {
+	var item models.MediaItem
+	err := row.Scan(mediaItemFields(&item)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &item, nil
+}
+
+// mediaItemFields are the scan destinations of mediaItemColumns, for queries
+// selecting media items along with other columns
+func mediaItemFields(item *models.MediaItem) []any {
+	return []any{
+		&item.ID, &item.FolderID, &item.RelPath, &item.Name, &item.Size, &item.ModifiedAt, &item.Duration, &item.AddedAt, &item.ScannedAt,
+		&item.Release.Title, &item.SortTitle, &item.Release.Year, &item.Release.Resolution, &item.Release.Source, &item.Release.Codec, &item.Release.Edition, &item.Release.Group, &item.ContentHash,
+		&item.VersionGroup, &item.StackID, &item.Part,
+	}
+}
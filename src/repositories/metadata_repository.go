@@ -0,0 +1,171 @@
+package repositories
+
+import (
+	"database/sql"
+	"encoding/json"
+	"localflix-server/src/models"
+)
+
+type MetadataRepository struct {
+	db DBTX
+}
+
+func NewMetadataRepository(db DBTX) *MetadataRepository {
+	return &MetadataRepository{
+		db: db,
+	}
+}
+
+// GetMetadata returns nil when the media item has no metadata
+func (m *MetadataRepository) GetMetadata(mediaItemId int) (*models.Metadata, error) {
+	row := m.db.QueryRow(
+		`SELECT media_item_id, source, title, original_title, plot, year, rating, genres, cast_members, external_ids, confidence, locked,
+			source_path, source_modified_at, updated_at
+		FROM media_metadata WHERE media_item_id = ?`,
+		mediaItemId,
+	)
+
+	var metadata models.Metadata
+	var genres, cast, externalIds string
+	var sourceModifiedAt sql.NullTime
+	err := row.Scan(
+		&metadata.MediaItemID, &metadata.Source, &metadata.Title, &metadata.OriginalTitle, &metadata.Plot, &metadata.Year, &metadata.Rating,
+		&genres, &cast, &externalIds, &metadata.Confidence, &metadata.Locked, &metadata.SourcePath, &sourceModifiedAt, &metadata.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	metadata.SourceModifiedAt = sourceModifiedAt.Time
+	if err := json.Unmarshal([]byte(genres), &metadata.Genres); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(cast), &metadata.Cast); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(externalIds), &metadata.ExternalIDs); err != nil {
+		return nil, err
+	}
+	return &metadata, nil
+}
+
+// UpsertMetadata replaces the metadata of the media item, and its genre tags
+// with the metadata's genres
+func (m *MetadataRepository) UpsertMetadata(metadata models.Metadata) error {
+	if metadata.Genres == nil {
+		metadata.Genres = []string{}
+	}
+	if metadata.Cast == nil {
+		metadata.Cast = []models.CastMember{}
+	}
+	if metadata.ExternalIDs == nil {
+		metadata.ExternalIDs = map[string]string{}
+	}
+	genres, err := json.Marshal(metadata.Genres)
+	if err != nil {
+		return err
+	}
+	cast, err := json.Marshal(metadata.Cast)
+	if err != nil {
+		return err
+	}
+	externalIds, err := json.Marshal(metadata.ExternalIDs)
+	if err != nil {
+		return err
+	}
+
+	var sourceModifiedAt sql.NullTime
+	if !metadata.SourceModifiedAt.IsZero() {
+		sourceModifiedAt = sql.NullTime{Time: metadata.SourceModifiedAt, Valid: true}
+	}
+	_, err = m.db.Exec(
+		`INSERT OR REPLACE INTO media_metadata
+			(media_item_id, source, title, original_title, plot, year, rating, genres, cast_members, external_ids, confidence, locked,
+				source_path, source_modified_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		metadata.MediaItemID, metadata.Source, metadata.Title, metadata.OriginalTitle, metadata.Plot, metadata.Year, metadata.Rating,
+		string(genres), string(cast), string(externalIds), metadata.Confidence, metadata.Locked,
+		metadata.SourcePath, sourceModifiedAt, metadata.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return NewTagsRepository(m.db).ReplaceItemTags(metadata.MediaItemID, models.TagKindGenre, metadata.Genres)
+}
+
+// DeleteMetadata deletes the metadata of the media item and its genre tags
+func (m *MetadataRepository) DeleteMetadata(mediaItemId int) error {
+	_, err := m.db.Exec("DELETE FROM media_metadata WHERE media_item_id = ?", mediaItemId)
+	if err != nil {
+		return err
+	}
+
+	return NewTagsRepository(m.db).ReplaceItemTags(mediaItemId, models.TagKindGenre, nil)
+}
+
+func (m *MetadataRepository) ListArtwork(mediaItemId int) ([]models.Artwork, error) {
+	rows, err := m.db.Query("SELECT media_item_id, kind, path, source FROM artwork WHERE media_item_id = ? ORDER BY kind", mediaItemId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var artwork []models.Artwork
+	for rows.Next() {
+		var image models.Artwork
+		if err := rows.Scan(&image.MediaItemID, &image.Kind, &image.Path, &image.Source); err != nil {
+			return nil, err
+		}
+
+		artwork = append(artwork, image)
+	}
+
+	return artwork, rows.Err()
+}
+
+// ReplaceLocalArtwork sets the local artwork of the media item to the given
+// images. Local images win over downloaded ones of the same kind.
+func (m *MetadataRepository) ReplaceLocalArtwork(mediaItemId int, artwork []models.Artwork) error {
+	_, err := m.db.Exec("DELETE FROM artwork WHERE media_item_id = ? AND source = ?", mediaItemId, models.ArtworkSourceLocal)
+	if err != nil {
+		return err
+	}
+
+	for _, image := range artwork {
+		_, err := m.db.Exec(
+			"INSERT OR REPLACE INTO artwork (media_item_id, kind, path, source) VALUES (?, ?, ?, ?)",
+			mediaItemId, image.Kind, image.Path, models.ArtworkSourceLocal,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReplaceDownloadedArtwork sets the downloaded artwork of the media item to
+// the given images, kinds with a local image are skipped
+func (m *MetadataRepository) ReplaceDownloadedArtwork(mediaItemId int, artwork []models.Artwork) error {
+	_, err := m.db.Exec("DELETE FROM artwork WHERE media_item_id = ? AND source != ?", mediaItemId, models.ArtworkSourceLocal)
+	if err != nil {
+		return err
+	}
+
+	for _, image := range artwork {
+		_, err := m.db.Exec(
+			"INSERT OR IGNORE INTO artwork (media_item_id, kind, path, source) VALUES (?, ?, ?, ?)",
+			mediaItemId, image.Kind, image.Path, image.Source,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package repositories
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// DBTX is satisfied by both *sql.DB and *sql.Tx, so services can run several
+// repositories inside one transaction.
+type DBTX interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// prefixColumns qualifies a comma separated column list with a table alias,
+// "id, name" becomes "m.id, m.name"
+func prefixColumns(alias string, columns string) string {
+	names := strings.Split(columns, ", ")
+	for i, name := range names {
+		names[i] = alias + "." + name
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// ListQuery is a validated models.ListOptions. Sort is a models.Sort field
+// supported by the list, Limit 0 means no limit. Items must have every tag
+// and genre listed. UserID is the user whose watch progress is listed and
+// filtered on.
+type ListQuery struct {
+	UserID     int
+	Sort       string
+	Descending bool
+	Unwatched  bool
+	Resolution string
+	Codec      string
+	Tags       []string
+	Genres     []string
+	Offset     int
+	Limit      int
+}
+
+// limitClause is the LIMIT and OFFSET of the query, SQLite takes a negative
+// limit as none
+func (q ListQuery) limitClause() (string, []any) {
+	limit := q.Limit
+	if limit == 0 {
+		limit = -1
+	}
+
+	return " LIMIT ? OFFSET ?", []any{limit, q.Offset}
+}
+
+// direction is the SQL sort direction of the query
+func (q ListQuery) direction() string {
+	if q.Descending {
+		return "DESC"
+	}
+
+	return "ASC"
+}
+
+// parseTime reads a time computed by a query, e.g. with MAX(), which the
+// driver only returns as text. Columns declared DATETIME are already parsed.
+func parseTime(value sql.NullString) *time.Time {
+	if !value.Valid {
+		return nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05.999999999-07:00", time.RFC3339Nano, "2006-01-02 15:04:05.999999999"} {
+		if parsed, err := time.Parse(layout, value.String); err == nil {
+			return &parsed
+		}
+	}
+
+	return nil
+}
+
+// requireAffected returns sql.ErrNoRows when an UPDATE or DELETE matched no
+// row, e.g. when the id doesn't exist
+func requireAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// nullableId stores the id 0 as NULL, for optional foreign keys
+func nullableId(id int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(id), Valid: id != 0}
+}
@@ -0,0 +1,214 @@
+package repositories
+
+import (
+	"encoding/json"
+	"localflix-server/src/models"
+	"path/filepath"
+	"strings"
+)
+
+type SearchRepository struct {
+	db DBTX
+}
+
+func NewSearchRepository(db DBTX) *SearchRepository {
+	return &SearchRepository{
+		db: db,
+	}
+}
+
+// SearchHit is a media item matching a search, with the indexed document
+// used to rank it
+type SearchHit struct {
+	Result   models.SearchResult
+	Document models.SearchDocument
+}
+
+const searchIndexColumns = "title, original_title, series, file_name, plot, cast_members, genres, subtitles"
+
+// ListDocuments returns the search documents of the folder's media items,
+// without subtitles which are read from disk
+func (s *SearchRepository) ListDocuments(folderId int) ([]*models.SearchDocument, error) {
+	return s.listDocuments("m.folder_id = ?", folderId)
+}
+
+// GetDocument returns nil when there is no media item with the id
+func (s *SearchRepository) GetDocument(mediaItemId int) (*models.SearchDocument, error) {
+	documents, err := s.listDocuments("m.id = ?", mediaItemId)
+	if err != nil || len(documents) == 0 {
+		return nil, err
+	}
+
+	return documents[0], nil
+}
+
+func (s *SearchRepository) listDocuments(where string, args ...any) ([]*models.SearchDocument, error) {
+	rows, err := s.db.Query(
+		`SELECT m.id, f.path, m.rel_path, m.title, COALESCE(md.title, ''), COALESCE(md.original_title, ''), COALESCE(md.plot, ''),
+			COALESCE(md.genres, '[]'), COALESCE(md.cast_members, '[]'), COALESCE(sr.title, ''), COALESCE(e.title, '')
+		FROM media_items m
+		JOIN folders f ON f.id = m.folder_id
+		LEFT JOIN media_metadata md ON md.media_item_id = m.id
+		LEFT JOIN episodes e ON e.media_item_id = m.id
+		LEFT JOIN seasons sn ON sn.id = e.season_id
+		LEFT JOIN series sr ON sr.id = sn.series_id
+		WHERE `+where+` ORDER BY m.id`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var documents []*models.SearchDocument
+	for rows.Next() {
+		var document models.SearchDocument
+		var folderPath, releaseTitle, genres, cast, seriesTitle, episodeTitle string
+		err := rows.Scan(
+			&document.MediaItemID, &folderPath, &document.FileName, &releaseTitle, &document.Title, &document.OriginalTitle, &document.Plot,
+			&genres, &cast, &seriesTitle, &episodeTitle,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		document.Path = filepath.Join(folderPath, filepath.FromSlash(document.FileName))
+		if document.Title == "" {
+			document.Title = releaseTitle
+		}
+		document.Series = strings.TrimSpace(seriesTitle + " " + episodeTitle)
+
+		var genreNames []string
+		if err := json.Unmarshal([]byte(genres), &genreNames); err != nil {
+			return nil, err
+		}
+		document.Genres = strings.Join(genreNames, " ")
+		var castMembers []models.CastMember
+		if err := json.Unmarshal([]byte(cast), &castMembers); err != nil {
+			return nil, err
+		}
+		var names []string
+		for _, member := range castMembers {
+			names = append(names, member.Name, member.Role)
+		}
+		document.Cast = strings.TrimSpace(strings.Join(names, " "))
+
+		documents = append(documents, &document)
+	}
+
+	return documents, rows.Err()
+}
+
+// ReplaceDocument indexes the document in place of the previous one of its
+// media item
+func (s *SearchRepository) ReplaceDocument(document models.SearchDocument) error {
+	if _, err := s.db.Exec("DELETE FROM search_index WHERE rowid = ?", document.MediaItemID); err != nil {
+		return err
+	}
+
+	_, err := s.db.Exec(
+		"INSERT INTO search_index (rowid, "+searchIndexColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		document.MediaItemID, document.Title, document.OriginalTitle, document.Series, document.FileName, document.Plot,
+		document.Cast, document.Genres, document.Subtitles,
+	)
+	return err
+}
+
+// DeleteOrphans removes the documents of media items that no longer exist
+func (s *SearchRepository) DeleteOrphans() error {
+	_, err := s.db.Exec("DELETE FROM search_index WHERE rowid NOT IN (SELECT id FROM media_items)")
+	return err
+}
+
+// IsComplete reports whether every media item has a document
+func (s *SearchRepository) IsComplete() (bool, error) {
+	var complete bool
+	err := s.db.QueryRow("SELECT (SELECT COUNT(*) FROM search_index) = (SELECT COUNT(*) FROM media_items)").Scan(&complete)
+	return complete, err
+}
+
+// ListTerms returns the indexed terms starting with prefix
+func (s *SearchRepository) ListTerms(prefix string, limit int) ([]string, error) {
+	// Terms are compared bytewise, every term with the prefix sorts before
+	// the prefix followed by the highest code point
+	rows, err := s.db.Query(
+		"SELECT DISTINCT term FROM search_terms WHERE term >= ? AND term < ? ORDER BY term LIMIT ?",
+		prefix, prefix+"\U0010FFFF", limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var terms []string
+	for rows.Next() {
+		var term string
+		if err := rows.Scan(&term); err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
+	}
+
+	return terms, rows.Err()
+}
+
+// Search returns the media items whose documents match the FTS query match
+// and the filter, in no particular order
+func (s *SearchRepository) Search(match string, filter models.SearchFilter) ([]*SearchHit, error) {
+	query := `SELECT ` + prefixColumns("m", mediaItemColumns) + `, COALESCE(NULLIF(md.year, 0), m.year), COALESCE(md.genres, '[]'), ` + watchedExpression(filter.UserID) + `,
+			si.title, si.original_title, si.series, si.file_name, si.cast_members, si.genres, COALESCE(sr.title, '')
+		FROM search_index si
+		JOIN media_items m ON m.id = si.rowid
+		JOIN folders f ON f.id = m.folder_id
+		LEFT JOIN media_metadata md ON md.media_item_id = m.id
+		LEFT JOIN episodes e ON e.media_item_id = m.id
+		LEFT JOIN seasons sn ON sn.id = e.season_id
+		LEFT JOIN series sr ON sr.id = sn.series_id
+		WHERE search_index MATCH ? AND ` + firstPartCondition
+	args := []any{match}
+	if filter.CategoryID != 0 {
+		query += " AND f.category_id = ?"
+		args = append(args, filter.CategoryID)
+	}
+	if filter.Year != 0 {
+		query += " AND COALESCE(NULLIF(md.year, 0), m.year) = ?"
+		args = append(args, filter.Year)
+	}
+	if filter.Genre != "" {
+		query += " AND EXISTS (SELECT 1 FROM json_each(md.genres) g WHERE lower(g.value) = lower(?))"
+		args = append(args, filter.Genre)
+	}
+	if filter.Watched != nil {
+		query += " AND " + watchedExpression(filter.UserID) + " = ?"
+		args = append(args, *filter.Watched)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hits []*SearchHit
+	for rows.Next() {
+		var hit SearchHit
+		var genres string
+		fields := mediaItemFields(&hit.Result.MediaItem)
+		fields = append(fields, &hit.Result.Year, &genres, &hit.Result.Watched,
+			&hit.Document.Title, &hit.Document.OriginalTitle, &hit.Document.Series, &hit.Document.FileName, &hit.Document.Cast, &hit.Document.Genres,
+			&hit.Result.Series,
+		)
+		if err := rows.Scan(fields...); err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal([]byte(genres), &hit.Result.Genres); err != nil {
+			return nil, err
+		}
+		hit.Document.MediaItemID = hit.Result.MediaItem.ID
+		hit.Result.Title = hit.Document.Title
+		hits = append(hits, &hit)
+	}
+
+	return hits, rows.Err()
+}
@@ -0,0 +1,244 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"localflix-server/src/models"
+	"time"
+)
+
+type SeriesRepository struct {
+	db DBTX
+}
+
+func NewSeriesRepository(db DBTX) *SeriesRepository {
+	return &SeriesRepository{
+		db: db,
+	}
+}
+
+// FindOrCreateSeries returns the id of the series with matchKey, creating it
+// with title and year when there is none
+func (s *SeriesRepository) FindOrCreateSeries(title string, year int, matchKey string) (int, error) {
+	_, err := s.db.Exec(
+		"INSERT INTO series (title, year, match_key, created_at) VALUES (?, ?, ?, ?) ON CONFLICT (match_key) DO NOTHING",
+		title, year, matchKey, time.Now().UTC(),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	var id int
+	err = s.db.QueryRow("SELECT id FROM series WHERE match_key = ?", matchKey).Scan(&id)
+	return id, err
+}
+
+// FindOrCreateSeason returns the id of the season of the series, creating it
+// when there is none
+func (s *SeriesRepository) FindOrCreateSeason(seriesId int, number int) (int, error) {
+	_, err := s.db.Exec(
+		"INSERT INTO seasons (series_id, number) VALUES (?, ?) ON CONFLICT (series_id, number) DO NOTHING",
+		seriesId, number,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	var id int
+	err = s.db.QueryRow("SELECT id FROM seasons WHERE series_id = ? AND number = ?", seriesId, number).Scan(&id)
+	return id, err
+}
+
+// UpsertEpisode creates the episode of the media item or moves it to the
+// episode's season
+func (s *SeriesRepository) UpsertEpisode(episode models.Episode) error {
+	_, err := s.db.Exec(
+		`INSERT INTO episodes (season_id, media_item_id, number, end_number, absolute_number, air_date, title) VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (media_item_id) DO UPDATE SET season_id = excluded.season_id, number = excluded.number, end_number = excluded.end_number,
+			absolute_number = excluded.absolute_number, air_date = excluded.air_date, title = excluded.title`,
+		episode.SeasonID, episode.MediaItemID, episode.Number, episode.EndNumber, episode.AbsoluteNumber, episode.AirDate, episode.Title,
+	)
+	return err
+}
+
+func (s *SeriesRepository) DeleteEpisodeByMediaItem(mediaItemId int) error {
+	_, err := s.db.Exec("DELETE FROM episodes WHERE media_item_id = ?", mediaItemId)
+	return err
+}
+
+// ListEpisodesByMediaItem returns every episode by the id of its media item,
+// without the media item
+func (s *SeriesRepository) ListEpisodesByMediaItem() (map[int]models.Episode, error) {
+	rows, err := s.db.Query("SELECT id, season_id, media_item_id, number, end_number, absolute_number, air_date, title FROM episodes")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	episodes := map[int]models.Episode{}
+	for rows.Next() {
+		var episode models.Episode
+		err := rows.Scan(&episode.ID, &episode.SeasonID, &episode.MediaItemID, &episode.Number, &episode.EndNumber, &episode.AbsoluteNumber, &episode.AirDate, &episode.Title)
+		if err != nil {
+			return nil, err
+		}
+
+		episodes[episode.MediaItemID] = episode
+	}
+
+	return episodes, rows.Err()
+}
+
+// DeleteEmpty removes the seasons without episodes, then the series without
+// seasons
+func (s *SeriesRepository) DeleteEmpty() error {
+	_, err := s.db.Exec("DELETE FROM seasons WHERE id NOT IN (SELECT season_id FROM episodes)")
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec("DELETE FROM series WHERE id NOT IN (SELECT series_id FROM seasons)")
+	return err
+}
+
+// Series and seasons left without episodes by a deleted folder are only
+// removed by the next scan, the list queries skip them.
+const seriesSelect = `SELECT s.id, s.title, s.year, s.created_at, COUNT(DISTINCT se.id), COUNT(e.id)
+	FROM series s JOIN seasons se ON se.series_id = s.id JOIN episodes e ON e.season_id = se.id`
+
+func (s *SeriesRepository) ListSeries() ([]*models.Series, error) {
+	rows, err := s.db.Query(seriesSelect + " GROUP BY s.id ORDER BY s.title COLLATE NOCASE, s.year")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var series []*models.Series
+	for rows.Next() {
+		item, err := scanSeries(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		series = append(series, item)
+	}
+
+	return series, rows.Err()
+}
+
+func (s *SeriesRepository) GetSeries(id int) (*models.Series, error) {
+	series, err := scanSeries(s.db.QueryRow(seriesSelect+" WHERE s.id = ? GROUP BY s.id", id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return series, nil
+}
+
+const seasonSelect = `SELECT se.id, se.series_id, se.number, COUNT(e.id)
+	FROM seasons se JOIN episodes e ON e.season_id = se.id`
+
+func (s *SeriesRepository) ListSeasons(seriesId int) ([]*models.Season, error) {
+	rows, err := s.db.Query(seasonSelect+" WHERE se.series_id = ? GROUP BY se.id ORDER BY se.number", seriesId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var seasons []*models.Season
+	for rows.Next() {
+		season, err := scanSeason(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		seasons = append(seasons, season)
+	}
+
+	return seasons, rows.Err()
+}
+
+func (s *SeriesRepository) GetSeason(id int) (*models.Season, error) {
+	season, err := scanSeason(s.db.QueryRow(seasonSelect+" WHERE se.id = ? GROUP BY se.id", id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return season, nil
+}
+
+// ListEpisodes returns a page of the season's episodes with their media
+// items, and how many episodes the filters let through. Without a sort
+// episodes come in episode order, date-based episodes all have number 0 and
+// follow air dates.
+func (s *SeriesRepository) ListEpisodes(seasonId int, q ListQuery) ([]*models.Episode, int, error) {
+	filters, filterArgs := mediaItemFilters(q)
+	from := " FROM episodes e JOIN media_items m ON m.id = e.media_item_id WHERE e.season_id = ?" + filters
+	args := append([]any{seasonId}, filterArgs...)
+
+	var total int
+	if err := s.db.QueryRow("SELECT COUNT(*)"+from, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	order := "e.number, e.air_date, m.rel_path"
+	if q.Sort == models.SortNumber {
+		order = fmt.Sprintf("e.number %[1]s, e.air_date %[1]s, m.rel_path", q.direction())
+	} else if q.Sort != "" {
+		order = fmt.Sprintf(mediaItemOrders[q.Sort], q.direction()) + ", " + order
+	}
+	limit, limitArgs := q.limitClause()
+	rows, err := s.db.Query(
+		`SELECT e.id, e.season_id, e.media_item_id, e.number, e.end_number, e.absolute_number, e.air_date, e.title, `+prefixColumns("m", mediaItemColumns)+`,
+			`+lastWatchedExpression(q.UserID)+` AS last_watched_at`+from+` ORDER BY `+order+limit,
+		append(args, limitArgs...)...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var episodes []*models.Episode
+	for rows.Next() {
+		var episode models.Episode
+		var lastWatchedAt sql.NullString
+		fields := []any{&episode.ID, &episode.SeasonID, &episode.MediaItemID, &episode.Number, &episode.EndNumber, &episode.AbsoluteNumber, &episode.AirDate, &episode.Title}
+		fields = append(fields, mediaItemFields(&episode.MediaItem)...)
+		if err := rows.Scan(append(fields, &lastWatchedAt)...); err != nil {
+			return nil, 0, err
+		}
+
+		episode.MediaItem.LastWatchedAt = parseTime(lastWatchedAt)
+		episodes = append(episodes, &episode)
+	}
+
+	return episodes, total, rows.Err()
+}
+
+func scanSeries(row rowScanner) (*models.Series, error) {
+	var series models.Series
+	err := row.Scan(&series.ID, &series.Title, &series.Year, &series.CreatedAt, &series.SeasonCount, &series.EpisodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	return &series, nil
+}
+
+func scanSeason(row rowScanner) (*models.Season, error) {
+	var season models.Season
+	err := row.Scan(&season.ID, &season.SeriesID, &season.Number, &season.EpisodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	return &season, nil
+}
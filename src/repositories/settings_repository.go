@@ -0,0 +1,60 @@
+package repositories
+
+import (
+	"database/sql"
+)
+
+type SettingsRepository struct {
+	db DBTX
+}
+
+func NewSettingsRepository(db DBTX) *SettingsRepository {
+	return &SettingsRepository{
+		db: db,
+	}
+}
+
+// GetSetting returns the raw value stored for key, or "" when it was never set.
+func (s *SettingsRepository) GetSetting(key string) (string, error) {
+	row := s.db.QueryRow("SELECT value FROM settings WHERE key = ?", key)
+	var value string
+	err := row.Scan(&value)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+
+		return "", err
+	}
+
+	return value, nil
+}
+
+func (s *SettingsRepository) SetSetting(key string, value string) error {
+	_, err := s.db.Exec("INSERT INTO settings (key, value) VALUES (?, ?) ON CONFLICT(key) DO UPDATE SET value = excluded.value", key, value)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *SettingsRepository) ListSettings() (map[string]string, error) {
+	rows, err := s.db.Query("SELECT key, value FROM settings")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	settings := map[string]string{}
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, err
+		}
+
+		settings[key] = value
+	}
+
+	return settings, rows.Err()
+}
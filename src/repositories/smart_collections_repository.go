@@ -0,0 +1,273 @@
+package repositories
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"localflix-server/src/models"
+	"strings"
+	"time"
+)
+
+type SmartCollectionsRepository struct {
+	db DBTX
+}
+
+func NewSmartCollectionsRepository(db DBTX) *SmartCollectionsRepository {
+	return &SmartCollectionsRepository{
+		db: db,
+	}
+}
+
+const smartCollectionColumns = "id, name, rules, sort, created_at, updated_at"
+
+// smartColumns are the expressions of the fields compared by smart rules,
+// for the media item m. Unknown years never match. When the item was last
+// watched depends on the user, see smartRuleCondition.
+var smartColumns = map[string]string{
+	models.SmartFieldTitle:      "COALESCE(NULLIF((SELECT md.title FROM media_metadata md WHERE md.media_item_id = m.id), ''), m.title)",
+	models.SmartFieldYear:       "NULLIF(m.year, 0)",
+	models.SmartFieldResolution: "m.resolution",
+	models.SmartFieldCodec:      "m.codec",
+	models.SmartFieldDuration:   stackTotalDuration,
+	models.SmartFieldSize:       stackTotalSize,
+	models.SmartFieldAddedAt:    "m.added_at",
+	models.SmartFieldCategoryID: "(SELECT f.category_id FROM folders f WHERE f.id = m.folder_id)",
+	models.SmartFieldFolderID:   "m.folder_id",
+}
+
+// smartOperators are the SQL of the comparison operators
+var smartOperators = map[string]string{
+	models.SmartOpEquals:         "=",
+	models.SmartOpNotEquals:      "!=",
+	models.SmartOpLess:           "<",
+	models.SmartOpLessOrEqual:    "<=",
+	models.SmartOpGreater:        ">",
+	models.SmartOpGreaterOrEqual: ">=",
+	models.SmartOpBefore:         "<",
+	models.SmartOpAfter:          ">",
+}
+
+func (s *SmartCollectionsRepository) CreateSmartCollection(collection models.SmartCollection) (*models.SmartCollection, error) {
+	rules, err := json.Marshal(collection.Rules)
+	if err != nil {
+		return nil, err
+	}
+
+	collection.CreatedAt = time.Now().UTC()
+	collection.UpdatedAt = collection.CreatedAt
+	result, err := s.db.Exec(
+		"INSERT INTO smart_collections (name, rules, sort, created_at, updated_at) VALUES (?, ?, ?, ?, ?)",
+		collection.Name, string(rules), collection.Sort, collection.CreatedAt, collection.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	collection.ID = int(id)
+	return &collection, nil
+}
+
+func (s *SmartCollectionsRepository) GetSmartCollection(id int) (*models.SmartCollection, error) {
+	collection, err := scanSmartCollection(s.db.QueryRow("SELECT "+smartCollectionColumns+" FROM smart_collections WHERE id = ?", id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return collection, nil
+}
+
+// GetSmartCollectionByName finds the smart collection ignoring case
+func (s *SmartCollectionsRepository) GetSmartCollectionByName(name string) (*models.SmartCollection, error) {
+	collection, err := scanSmartCollection(s.db.QueryRow("SELECT "+smartCollectionColumns+" FROM smart_collections WHERE name = ?", name))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return collection, nil
+}
+
+func (s *SmartCollectionsRepository) ListSmartCollections() ([]*models.SmartCollection, error) {
+	rows, err := s.db.Query("SELECT " + smartCollectionColumns + " FROM smart_collections ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var collections []*models.SmartCollection
+	for rows.Next() {
+		collection, err := scanSmartCollection(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		collections = append(collections, collection)
+	}
+
+	return collections, rows.Err()
+}
+
+// UpdateSmartCollection replaces the name, rules and sort of the smart
+// collection
+func (s *SmartCollectionsRepository) UpdateSmartCollection(collection models.SmartCollection) error {
+	rules, err := json.Marshal(collection.Rules)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.db.Exec(
+		"UPDATE smart_collections SET name = ?, rules = ?, sort = ?, updated_at = ? WHERE id = ?",
+		collection.Name, string(rules), collection.Sort, time.Now().UTC(), collection.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(result)
+}
+
+func (s *SmartCollectionsRepository) DeleteSmartCollection(id int) error {
+	result, err := s.db.Exec("DELETE FROM smart_collections WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(result)
+}
+
+// ListMatchingItems returns a page of the media items matching the rule with
+// when they were last watched, and how many items the rule and the filters
+// let through. Watched rules are about the query's user. Without a sort
+// items come by title.
+func (s *SmartCollectionsRepository) ListMatchingItems(rule models.SmartRule, q ListQuery) ([]*models.MediaItem, int, error) {
+	condition, args, err := smartRuleCondition(rule, time.Now().UTC(), q.UserID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return listMediaItemsPage(s.db, " FROM media_items m WHERE ("+condition+")", args, "m.sort_title, m.year, m.id", q)
+}
+
+// smartRuleCondition is the SQL condition of the rule tree on the media item
+// m, watched by the user. Values are expected of the type of their field, as
+// checked by the smart collections service.
+func smartRuleCondition(rule models.SmartRule, now time.Time, userId int) (string, []any, error) {
+	if rule.Field == "" {
+		join, empty := " AND ", "1"
+		if rule.Match == models.SmartMatchAny {
+			join, empty = " OR ", "0"
+		}
+		if len(rule.Rules) == 0 {
+			return empty, nil, nil
+		}
+
+		conditions := make([]string, len(rule.Rules))
+		var args []any
+		for i, child := range rule.Rules {
+			condition, childArgs, err := smartRuleCondition(child, now, userId)
+			if err != nil {
+				return "", nil, err
+			}
+			conditions[i] = "(" + condition + ")"
+			args = append(args, childArgs...)
+		}
+		return strings.Join(conditions, join), args, nil
+	}
+
+	negate := rule.Op == models.SmartOpNotEquals
+	switch rule.Field {
+	case models.SmartFieldWatched:
+		watched, _ := rule.Value.(bool)
+		if watched != negate {
+			return watchedExpression(userId) + " = 1", nil, nil
+		}
+		return watchedExpression(userId) + " = 0", nil, nil
+	case models.SmartFieldType:
+		episode := rule.Value == models.MediaTypeEpisode
+		exists := "EXISTS (SELECT 1 FROM episodes e WHERE e.media_item_id = m.id)"
+		if episode == negate {
+			exists = "NOT " + exists
+		}
+		return exists, nil, nil
+	case models.SmartFieldTag, models.SmartFieldGenre:
+		exists := "EXISTS (SELECT 1 FROM media_item_tags mt JOIN tags t ON t.id = mt.tag_id WHERE mt.media_item_id = m.id AND t.name = ?"
+		args := []any{rule.Value}
+		if rule.Field == models.SmartFieldGenre {
+			exists += " AND t.kind = ?"
+			args = append(args, models.TagKindGenre)
+		}
+		exists += ")"
+		if negate {
+			exists = "NOT " + exists
+		}
+		return exists, args, nil
+	}
+
+	column, ok := smartColumns[rule.Field]
+	if rule.Field == models.SmartFieldLastWatchedAt {
+		column, ok = lastWatchedExpression(userId), true
+	}
+	if !ok {
+		return "", nil, fmt.Errorf("unknown smart rule field %q", rule.Field)
+	}
+	switch rule.Op {
+	case models.SmartOpContains:
+		text, _ := rule.Value.(string)
+		return column + ` LIKE ? ESCAPE '\'`, []any{"%" + escapeLike(text) + "%"}, nil
+	case models.SmartOpInLast:
+		period, _ := rule.Value.(string)
+		start, err := models.SmartPeriodStart(period, now)
+		if err != nil {
+			return "", nil, err
+		}
+		return column + " >= ?", []any{start}, nil
+	case models.SmartOpBefore, models.SmartOpAfter:
+		date, _ := rule.Value.(string)
+		value, err := time.Parse(time.RFC3339, date)
+		if err != nil {
+			return "", nil, err
+		}
+		return column + " " + smartOperators[rule.Op] + " ?", []any{value.UTC()}, nil
+	}
+
+	operator, ok := smartOperators[rule.Op]
+	if !ok {
+		return "", nil, fmt.Errorf("unknown smart rule operator %q", rule.Op)
+	}
+	if _, text := rule.Value.(string); text {
+		return column + " " + operator + " ? COLLATE NOCASE", []any{rule.Value}, nil
+	}
+	return column + " " + operator + " ?", []any{rule.Value}, nil
+}
+
+// escapeLike escapes the wildcards of LIKE patterns with a backslash
+func escapeLike(text string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(text)
+}
+
+func scanSmartCollection(row rowScanner) (*models.SmartCollection, error) {
+	var collection models.SmartCollection
+	var rules string
+	err := row.Scan(&collection.ID, &collection.Name, &rules, &collection.Sort, &collection.CreatedAt, &collection.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(rules), &collection.Rules); err != nil {
+		return nil, err
+	}
+	return &collection, nil
+}
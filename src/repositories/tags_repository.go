@@ -0,0 +1,180 @@
+package repositories
+
+import (
+	"database/sql"
+	"localflix-server/src/models"
+	"strings"
+	"time"
+)
+
+type TagsRepository struct {
+	db DBTX
+}
+
+func NewTagsRepository(db DBTX) *TagsRepository {
+	return &TagsRepository{
+		db: db,
+	}
+}
+
+// Genres left without media items by changed metadata are kept, the list
+// queries skip them. Tags set by hand are listed until deleted.
+const tagSelect = `SELECT t.id, t.name, t.kind, t.created_at, COUNT(mt.media_item_id)
+	FROM tags t LEFT JOIN media_item_tags mt ON mt.tag_id = t.id`
+
+func (t *TagsRepository) CreateTag(name string, kind string) (*models.Tag, error) {
+	createdAt := time.Now().UTC()
+	result, err := t.db.Exec("INSERT INTO tags (name, kind, created_at) VALUES (?, ?, ?)", name, kind, createdAt)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Tag{ID: int(id), Name: name, Kind: kind, CreatedAt: createdAt}, nil
+}
+
+func (t *TagsRepository) GetTag(id int) (*models.Tag, error) {
+	tag, err := scanTag(t.db.QueryRow(tagSelect+" WHERE t.id = ? GROUP BY t.id", id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return tag, nil
+}
+
+// GetTagByName finds the tag of the kind ignoring case
+func (t *TagsRepository) GetTagByName(kind string, name string) (*models.Tag, error) {
+	tag, err := scanTag(t.db.QueryRow(tagSelect+" WHERE t.kind = ? AND t.name = ? GROUP BY t.id", kind, name))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return tag, nil
+}
+
+// ListTags returns the tags of the kind, or of every kind when kind is
+// empty, by name
+func (t *TagsRepository) ListTags(kind string) ([]*models.Tag, error) {
+	rows, err := t.db.Query(
+		tagSelect+" WHERE ? IN ('', t.kind) GROUP BY t.id HAVING t.kind = ? OR COUNT(mt.media_item_id) > 0 ORDER BY t.name, t.kind",
+		kind, models.TagKindTag,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []*models.Tag
+	for rows.Next() {
+		tag, err := scanTag(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
+
+// ListItemTags returns the tags and genres of the media item, by kind then
+// name. Their item count is left at 0.
+func (t *TagsRepository) ListItemTags(mediaItemId int) ([]*models.Tag, error) {
+	rows, err := t.db.Query(
+		`SELECT t.id, t.name, t.kind, t.created_at, 0 FROM tags t JOIN media_item_tags mt ON mt.tag_id = t.id
+		WHERE mt.media_item_id = ? ORDER BY t.kind, t.name`,
+		mediaItemId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []*models.Tag
+	for rows.Next() {
+		tag, err := scanTag(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
+
+func (t *TagsRepository) RenameTag(id int, name string) error {
+	result, err := t.db.Exec("UPDATE tags SET name = ? WHERE id = ?", name, id)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(result)
+}
+
+func (t *TagsRepository) DeleteTag(id int) error {
+	result, err := t.db.Exec("DELETE FROM tags WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(result)
+}
+
+// ReplaceItemTags sets the tags of the kind of the media item to names,
+// creating the tags missing. Blank and repeated names are skipped.
+func (t *TagsRepository) ReplaceItemTags(mediaItemId int, kind string, names []string) error {
+	_, err := t.db.Exec(
+		"DELETE FROM media_item_tags WHERE media_item_id = ? AND tag_id IN (SELECT id FROM tags WHERE kind = ?)",
+		mediaItemId, kind,
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		_, err := t.db.Exec(
+			"INSERT INTO tags (name, kind, created_at) VALUES (?, ?, ?) ON CONFLICT (kind, name) DO NOTHING",
+			name, kind, time.Now().UTC(),
+		)
+		if err != nil {
+			return err
+		}
+		_, err = t.db.Exec(
+			"INSERT OR IGNORE INTO media_item_tags (media_item_id, tag_id) SELECT ?, id FROM tags WHERE kind = ? AND name = ?",
+			mediaItemId, kind, name,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func scanTag(row rowScanner) (*models.Tag, error) {
+	var tag models.Tag
+	err := row.Scan(&tag.ID, &tag.Name, &tag.Kind, &tag.CreatedAt, &tag.ItemCount)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tag, nil
+}
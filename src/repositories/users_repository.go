@@ -0,0 +1,104 @@
+package repositories
+
+import (
+	"database/sql"
+	"localflix-server/src/models"
+	"time"
+)
+
+type UsersRepository struct {
+	db DBTX
+}
+
+func NewUsersRepository(db DBTX) *UsersRepository {
+	return &UsersRepository{
+		db: db,
+	}
+}
+
+const userSelect = `SELECT u.id, u.name, u.created_at, COUNT(k.id)
+	FROM users u LEFT JOIN api_keys k ON k.user_id = u.id`
+
+func (u *UsersRepository) CreateUser(name string) (*models.User, error) {
+	createdAt := time.Now().UTC()
+	result, err := u.db.Exec("INSERT INTO users (name, created_at) VALUES (?, ?)", name, createdAt)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.User{ID: int(id), Name: name, CreatedAt: createdAt}, nil
+}
+
+func (u *UsersRepository) GetUser(id int) (*models.User, error) {
+	user, err := scanUser(u.db.QueryRow(userSelect+" WHERE u.id = ? GROUP BY u.id", id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// GetUserByName finds the user ignoring case
+func (u *UsersRepository) GetUserByName(name string) (*models.User, error) {
+	user, err := scanUser(u.db.QueryRow(userSelect+" WHERE u.name = ? GROUP BY u.id", name))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// ListUsers returns the users by name
+func (u *UsersRepository) ListUsers() ([]*models.User, error) {
+	rows, err := u.db.Query(userSelect + " GROUP BY u.id ORDER BY u.name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*models.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+// DeleteUser deletes the user, its api keys cascade. Returns sql.ErrNoRows
+// for an unknown user.
+func (u *UsersRepository) DeleteUser(id int) error {
+	result, err := u.db.Exec("DELETE FROM users WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(result)
+}
+
+func scanUser(row rowScanner) (*models.User, error) {
+	var user models.User
+	err := row.Scan(&user.ID, &user.Name, &user.CreatedAt, &user.ApiKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
@@ -0,0 +1,122 @@
+package repositories
+
+import (
+	"fmt"
+	"localflix-server/src/models"
+)
+
+type WatchProgressRepository struct {
+	db DBTX
+}
+
+func NewWatchProgressRepository(db DBTX) *WatchProgressRepository {
+	return &WatchProgressRepository{
+		db: db,
+	}
+}
+
+// lastWatchedExpression is when the user last streamed the media item m, or
+// NULL
+func lastWatchedExpression(userId int) string {
+	return fmt.Sprintf("(SELECT w.updated_at FROM watch_progress w WHERE w.user_id = %d AND w.media_item_id = m.id)", userId)
+}
+
+// watchedExpression is 1 when the user watched the media item m to the end,
+// 0 otherwise
+func watchedExpression(userId int) string {
+	return fmt.Sprintf("COALESCE((SELECT w.watched FROM watch_progress w WHERE w.user_id = %d AND w.media_item_id = m.id), 0)", userId)
+}
+
+// SaveProgress replaces the progress of the user on the media item, keeping
+// it watched when it already was
+func (w *WatchProgressRepository) SaveProgress(progress models.WatchProgress) error {
+	_, err := w.db.Exec(
+		`INSERT INTO watch_progress (user_id, media_item_id, position, duration, watched, updated_at) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (user_id, media_item_id) DO UPDATE SET position = excluded.position, duration = excluded.duration,
+			watched = MAX(watched, excluded.watched), updated_at = excluded.updated_at`,
+		progress.UserID, progress.MediaItemID, progress.Position, progress.Duration, progress.Watched, progress.UpdatedAt,
+	)
+	return err
+}
+
+// ListProgress returns the progress of the user, last watched first
+func (w *WatchProgressRepository) ListProgress(userId int) ([]*models.WatchProgress, error) {
+	rows, err := w.db.Query(
+		"SELECT user_id, media_item_id, position, duration, watched, updated_at FROM watch_progress WHERE user_id = ? ORDER BY updated_at DESC, media_item_id",
+		userId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var progresses []*models.WatchProgress
+	for rows.Next() {
+		var progress models.WatchProgress
+		err := rows.Scan(&progress.UserID, &progress.MediaItemID, &progress.Position, &progress.Duration, &progress.Watched, &progress.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		progresses = append(progresses, &progress)
+	}
+
+	return progresses, rows.Err()
+}
+
+// ExportProgress returns the progress of every user, with the name of the
+// user and the paths of the media item
+func (w *WatchProgressRepository) ExportProgress() ([]models.ExportedWatchProgress, error) {
+	rows, err := w.db.Query(
+		`SELECT COALESCE(u.name, ''), f.path, m.rel_path, w.position, w.duration, w.watched, w.updated_at
+		FROM watch_progress w
+		JOIN media_items m ON m.id = w.media_item_id
+		JOIN folders f ON f.id = m.folder_id
+		LEFT JOIN users u ON u.id = w.user_id
+		ORDER BY w.user_id, f.path, m.rel_path`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var progresses []models.ExportedWatchProgress
+	for rows.Next() {
+		var progress models.ExportedWatchProgress
+		err := rows.Scan(&progress.User, &progress.Folder, &progress.Path, &progress.Position, &progress.Duration, &progress.Watched, &progress.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		progresses = append(progresses, progress)
+	}
+
+	return progresses, rows.Err()
+}
+
+// ImportProgress stores imported progress unless the stored one is more
+// recent, it returns whether it was stored
+func (w *WatchProgressRepository) ImportProgress(progress models.WatchProgress) (bool, error) {
+	result, err := w.db.Exec(
+		`INSERT INTO watch_progress (user_id, media_item_id, position, duration, watched, updated_at) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (user_id, media_item_id) DO UPDATE SET position = excluded.position, duration = excluded.duration,
+			watched = MAX(watched, excluded.watched), updated_at = excluded.updated_at
+		WHERE excluded.updated_at > watch_progress.updated_at`,
+		progress.UserID, progress.MediaItemID, progress.Position, progress.Duration, progress.Watched, progress.UpdatedAt,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	stored, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return stored > 0, nil
+}
+
+func (w *WatchProgressRepository) DeleteUserProgress(userId int) error {
+	_, err := w.db.Exec("DELETE FROM watch_progress WHERE user_id = ?", userId)
+	return err
+}
@@ -0,0 +1,243 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"localflix-server/src/models"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// registerAdminRoutes adds the routes used to manage the library without the
+// desktop app, e.g. when running headless. All of them need the admin scope.
+func (s *StreamService) registerAdminRoutes(app *fiber.App) {
+	admin := app.Group("/admin", s.requireScope(models.ScopeAdmin), s.rateLimit)
+	admin.Get("/audit", s.listAuditEvents)
+	admin.Post("/categories", s.createCategory)
+	admin.Patch("/categories/:categoryId", s.updateCategory)
+	admin.Delete("/categories/:categoryId", s.deleteCategory)
+	admin.Get("/folders", s.listFolders)
+	admin.Post("/folders", s.createFolder)
+	admin.Delete("/folders/:folderId", s.deleteFolder)
+	admin.Post("/scan", s.scan)
+	admin.Get("/metadata/providers", s.listMetadataProviders)
+	admin.Get("/items/:itemId/matches", s.searchMetadataMatches)
+	admin.Put("/items/:itemId/match", s.fixMetadataMatch)
+	admin.Post("/match", s.matchFolderMetadata)
+	admin.Post("/tags", s.createTag)
+	admin.Patch("/tags/:tagId", s.renameTag)
+	admin.Delete("/tags/:tagId", s.deleteTag)
+	admin.Put("/items/:itemId/tags", s.setMediaItemTags)
+	admin.Post("/collections", s.createCollection)
+	admin.Patch("/collections/:collectionId", s.updateCollection)
+	admin.Delete("/collections/:collectionId", s.deleteCollection)
+	admin.Put("/collections/:collectionId/items", s.setCollectionItems)
+	admin.Post("/collections/:collectionId/items", s.addCollectionItem)
+	admin.Delete("/collections/:collectionId/items/:itemId", s.removeCollectionItem)
+	admin.Post("/smart-collections", s.createSmartCollection)
+	admin.Post("/smart-collections/preview", s.previewSmartCollection)
+	admin.Put("/smart-collections/:smartCollectionId", s.updateSmartCollection)
+	admin.Delete("/smart-collections/:smartCollectionId", s.deleteSmartCollection)
+}
+
+type categoryRequest struct {
+	Name string `json:"name"`
+}
+
+type folderRequest struct {
+	Path       string `json:"path"`
+	CategoryID int    `json:"category_id"`
+}
+
+type matchRequest struct {
+	Provider string `json:"provider"`
+	ID       string `json:"id"`
+}
+
+func (s *StreamService) createCategory(c *fiber.Ctx) error {
+	var request categoryRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid request body")
+	}
+
+	category, err := s.categoriesService.WithActor(actorName(c), c.IP()).CreateCategory(request.Name)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(category)
+}
+
+func (s *StreamService) updateCategory(c *fiber.Ctx) error {
+	categoryId, err := strconv.Atoi(c.Params("categoryId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid category ID")
+	}
+	if categoryId < 0 {
+		return sendVirtualCategory(c, categoryId, "/admin/smart-collections/%d")
+	}
+
+	var request categoryRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid request body")
+	}
+
+	category, err := s.categoriesService.WithActor(actorName(c), c.IP()).UpdateCategory(categoryId, request.Name)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return c.Status(fiber.StatusNotFound).SendString("Category not found")
+	case errors.Is(err, ErrInvalidCategory):
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	case err != nil:
+		return c.Status(fiber.StatusInternalServerError).SendString("Error updating category")
+	}
+
+	return c.JSON(category)
+}
+
+func (s *StreamService) deleteCategory(c *fiber.Ctx) error {
+	categoryId, err := strconv.Atoi(c.Params("categoryId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid category ID")
+	}
+	if categoryId < 0 {
+		return sendVirtualCategory(c, categoryId, "/admin/smart-collections/%d")
+	}
+
+	if err := s.categoriesService.WithActor(actorName(c), c.IP()).DeleteCategory(categoryId); err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("Error deleting category")
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (s *StreamService) listFolders(c *fiber.Ctx) error {
+	page, err := s.foldersService.ListFoldersPage(listOptions(c))
+	if err != nil {
+		return sendListError(c, err, "Error listing folders")
+	}
+
+	return sendPage(c, page.Items, page.Total)
+}
+
+func (s *StreamService) createFolder(c *fiber.Ctx) error {
+	var request folderRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid request body")
+	}
+
+	category, err := s.categoriesService.GetCategory(request.CategoryID)
+	if err != nil || category == nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Unknown category")
+	}
+
+	folder, err := s.foldersService.WithActor(actorName(c), c.IP()).CreateFolder(request.Path, category.ID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(folder)
+}
+
+func (s *StreamService) deleteFolder(c *fiber.Ctx) error {
+	folderId, err := strconv.Atoi(c.Params("folderId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid folder ID")
+	}
+
+	if err := s.foldersService.WithActor(actorName(c), c.IP()).DeleteFolder(folderId); err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("Error deleting folder")
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// scan rescans the folder given in the folder_id query param, or the whole
+// library without it
+func (s *StreamService) scan(c *fiber.Ctx) error {
+	folderId := c.QueryInt("folder_id")
+	if folderId == 0 {
+		results, err := s.scanService.ScanLibrary()
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("Error scanning library")
+		}
+		return c.JSON(results)
+	}
+
+	result, err := s.scanService.ScanFolder(folderId)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("Error scanning folder")
+	}
+	return c.JSON(result)
+}
+
+func (s *StreamService) listMetadataProviders(c *fiber.Ctx) error {
+	return c.JSON(s.metadataService.ListMetadataProviders())
+}
+
+// searchMetadataMatches lists the candidates of the provider query param, or
+// of the configured provider. The title and year query params replace the
+// ones parsed from the file name.
+func (s *StreamService) searchMetadataMatches(c *fiber.Ctx) error {
+	itemId, err := strconv.Atoi(c.Params("itemId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid item ID")
+	}
+
+	query := models.MetadataQuery{Title: c.Query("title"), Year: c.QueryInt("year")}
+	matches, err := s.metadataService.SearchMatches(itemId, c.Query("provider"), query)
+	if err != nil {
+		return sendMatchError(c, err, "Error searching metadata")
+	}
+
+	return c.JSON(matches)
+}
+
+func (s *StreamService) fixMetadataMatch(c *fiber.Ctx) error {
+	itemId, err := strconv.Atoi(c.Params("itemId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid item ID")
+	}
+
+	var request matchRequest
+	if err := c.BodyParser(&request); err != nil || request.ID == "" {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid request body")
+	}
+
+	details, err := s.metadataService.FixMatch(itemId, request.Provider, request.ID)
+	if err != nil {
+		return sendMatchError(c, err, "Error fixing match")
+	}
+
+	return c.JSON(artworkURLs(c, details))
+}
+
+// matchFolderMetadata matches the media items of the folder given in the
+// folder_id query param
+func (s *StreamService) matchFolderMetadata(c *fiber.Ctx) error {
+	folderId := c.QueryInt("folder_id")
+	if folderId == 0 {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid folder ID")
+	}
+
+	results, err := s.metadataService.MatchFolder(folderId)
+	if err != nil {
+		return sendMatchError(c, err, "Error matching folder")
+	}
+
+	return c.JSON(results)
+}
+
+func sendMatchError(c *fiber.Ctx, err error, message string) error {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return c.Status(fiber.StatusNotFound).SendString("Item not found")
+	case errors.Is(err, ErrMetadataNotFound):
+		return c.Status(fiber.StatusNotFound).SendString(err.Error())
+	case errors.Is(err, ErrUnknownMetadataProvider), errors.Is(err, ErrNoMetadataProvider):
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	default:
+		return c.Status(fiber.StatusInternalServerError).SendString(message)
+	}
+}
@@ -0,0 +1,224 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"localflix-server/src/models"
+	"localflix-server/src/repositories"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+const apiKeyPrefix = "lfx_"
+
+// lastUsedResolution limits how often last_used_at is written, streaming
+// clients authenticate on every range request.
+const lastUsedResolution = time.Minute
+
+// loginSessionGap is how long a key has to be unused before its next request
+// is audited as a new login
+const loginSessionGap = 30 * time.Minute
+
+// failedLoginGap is how long failed logins from an IP are counted into one
+// audit event, guessing keys doesn't write a row per attempt
+const failedLoginGap = 10 * time.Minute
+
+type ApiKeysService struct {
+	ctx               context.Context
+	apiKeysRepository *repositories.ApiKeysRepository
+	usersRepository   *repositories.UsersRepository
+	auditService      *AuditService
+	failedLogins      *failedLogins
+	logger            *slog.Logger
+}
+
+// failedLogins counts the failed logins per IP not audited yet. It is shared
+// by the copies of the service.
+type failedLogins struct {
+	mu       sync.Mutex
+	attempts map[string]*failedLoginAttempts
+}
+
+type failedLoginAttempts struct {
+	auditedAt time.Time
+	unaudited int
+}
+
+// NewApiKeysService creates a new ApiKeysService struct
+func NewApiKeysService(ctx context.Context, db *sql.DB, logger *slog.Logger) *ApiKeysService {
+	return &ApiKeysService{
+		ctx:               ctx,
+		apiKeysRepository: repositories.NewApiKeysRepository(db),
+		usersRepository:   repositories.NewUsersRepository(db),
+		auditService:      NewAuditService(ctx, db, logger),
+		failedLogins:      &failedLogins{attempts: map[string]*failedLoginAttempts{}},
+		logger:            logger,
+	}
+}
+
+// CreateApiKey generates a new key with the given scopes for a shared client.
+// The returned key is the only time the plain text value is available.
+func (a *ApiKeysService) CreateApiKey(name string, scopes []string) (*models.ApiKey, error) {
+	return a.CreateUserApiKey(0, name, scopes)
+}
+
+// CreateUserApiKey generates a new key for the user, or for a shared client
+// when userId is 0. The returned key is the only time the plain text value is
+// available.
+func (a *ApiKeysService) CreateUserApiKey(userId int, name string, scopes []string) (*models.ApiKey, error) {
+	if err := validateApiKey(name, scopes); err != nil {
+		return nil, err
+	}
+	if userId != 0 {
+		user, err := a.usersRepository.GetUser(userId)
+		if err != nil {
+			a.logger.Error("getting user", "id", userId, "err", err)
+			return nil, err
+		}
+		if user == nil {
+			return nil, fmt.Errorf("user %d not found", userId)
+		}
+	}
+
+	apiKey, err := createApiKey(a.apiKeysRepository, userId, name, scopes)
+	if err != nil {
+		a.logger.Error("creating api key", "err", err)
+		return nil, err
+	}
+
+	return apiKey, nil
+}
+
+func validateApiKey(name string, scopes []string) error {
+	if name == "" {
+		return fmt.Errorf("api key name is required")
+	}
+	if len(scopes) == 0 {
+		return fmt.Errorf("api key needs at least one scope")
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(models.ApiKeyScopes, scope) {
+			return fmt.Errorf("unknown api key scope: %s", scope)
+		}
+	}
+
+	return nil
+}
+
+// createApiKey generates a validated key and stores it through
+// apiKeysRepository, so it can be part of a transaction
+func createApiKey(apiKeysRepository *repositories.ApiKeysRepository, userId int, name string, scopes []string) (*models.ApiKey, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("generating api key: %w", err)
+	}
+	key := apiKeyPrefix + hex.EncodeToString(secret)
+
+	apiKey, err := apiKeysRepository.CreateApiKey(name, key[:len(apiKeyPrefix)+8], hashApiKey(key), scopes, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	apiKey.Key = key
+	return apiKey, nil
+}
+
+func (a *ApiKeysService) ListApiKeys() []models.ApiKey {
+	apiKeys := a.apiKeysRepository.ListApiKeys()
+	result := make([]models.ApiKey, len(apiKeys))
+	for i, apiKey := range apiKeys {
+		result[i] = *apiKey
+	}
+	return result
+}
+
+func (a *ApiKeysService) RevokeApiKey(id int) error {
+	err := a.apiKeysRepository.DeleteApiKey(id)
+	if err != nil {
+		a.logger.Error("revoking api key", "err", err)
+		return err
+	}
+
+	return nil
+}
+
+// Authenticate resolves a plain text key to the stored api key and records
+// its usage. It returns nil when the key is unknown.
+func (a *ApiKeysService) Authenticate(key string, ip string) (*models.ApiKey, error) {
+	apiKey, err := a.apiKeysRepository.GetApiKeyByHash(hashApiKey(key))
+	if err != nil {
+		return nil, err
+	}
+	if apiKey == nil {
+		a.recordFailedLogin(ip)
+		return nil, nil
+	}
+
+	now := time.Now().UTC()
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) > loginSessionGap {
+		a.auditService.Record(models.AuditEvent{
+			Action:     models.AuditLogin,
+			Actor:      apiKey.Name,
+			IP:         ip,
+			TargetType: "api_key",
+			TargetID:   apiKey.ID,
+		})
+	}
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) > lastUsedResolution {
+		if err := a.apiKeysRepository.TouchApiKey(apiKey.ID, now); err != nil {
+			return nil, err
+		}
+		apiKey.LastUsedAt = &now
+	}
+
+	return apiKey, nil
+}
+
+// recordFailedLogin audits the first failed login of ip and then at most one
+// per failedLoginGap, counting the attempts in between. The key itself is
+// never stored, it may be a typo of a real one.
+func (a *ApiKeysService) recordFailedLogin(ip string) {
+	now := time.Now().UTC()
+	a.failedLogins.mu.Lock()
+	for client, attempts := range a.failedLogins.attempts {
+		if now.Sub(attempts.auditedAt) > failedLoginGap && attempts.unaudited == 0 {
+			delete(a.failedLogins.attempts, client)
+		}
+	}
+	attempts, ok := a.failedLogins.attempts[ip]
+	if !ok {
+		attempts = &failedLoginAttempts{}
+		a.failedLogins.attempts[ip] = attempts
+	}
+	attempts.unaudited++
+	if ok && now.Sub(attempts.auditedAt) <= failedLoginGap {
+		a.failedLogins.mu.Unlock()
+		return
+	}
+	count := attempts.unaudited
+	attempts.auditedAt = now
+	attempts.unaudited = 0
+	a.failedLogins.mu.Unlock()
+
+	details := "unknown key"
+	if count > 1 {
+		details = fmt.Sprintf("%d attempts with unknown keys", count)
+	}
+	a.auditService.Record(models.AuditEvent{
+		Action:    models.AuditLoginFailed,
+		IP:        ip,
+		Details:   details,
+		CreatedAt: now,
+	})
+}
+
+func hashApiKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
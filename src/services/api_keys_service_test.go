@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"localflix-server/src/logging"
+	"localflix-server/src/models"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCreateApiKey(t *testing.T) {
+	apiKeys := NewApiKeysService(context.Background(), newTestDatabase(t), logging.Discard())
+
+	apiKey, err := apiKeys.CreateApiKey("tv", []string{models.ScopeStream})
+	if err != nil {
+		t.Fatalf("CreateApiKey: %v", err)
+	}
+	if !strings.HasPrefix(apiKey.Key, apiKey.Prefix) {
+		t.Errorf("key %q doesn't start with its prefix %q", apiKey.Key, apiKey.Prefix)
+	}
+
+	listed := apiKeys.ListApiKeys()
+	if len(listed) != 1 || listed[0].Key != "" {
+		t.Errorf("ListApiKeys = %+v, want one key without its plain value", listed)
+	}
+
+	invalid := [][]string{nil, {"everything"}}
+	for _, scopes := range invalid {
+		if _, err := apiKeys.CreateApiKey("tv", scopes); err == nil {
+			t.Errorf("CreateApiKey with scopes %v succeeded", scopes)
+		}
+	}
+	if _, err := apiKeys.CreateApiKey("", []string{models.ScopeStream}); err == nil {
+		t.Error("CreateApiKey without a name succeeded")
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	database := newTestDatabase(t)
+	apiKeys := NewApiKeysService(context.Background(), database, logging.Discard())
+	created, err := apiKeys.CreateApiKey("tv", []string{models.ScopeLibraryRead})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	apiKey, err := apiKeys.Authenticate(created.Key, "10.0.0.2")
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if apiKey == nil || apiKey.ID != created.ID || apiKey.LastUsedAt == nil {
+		t.Fatalf("Authenticate = %+v, want the created key marked as used", apiKey)
+	}
+	if !apiKey.HasScope(models.ScopeLibraryRead) || apiKey.HasScope(models.ScopeStream) {
+		t.Errorf("got scopes %v, want only %s", apiKey.Scopes, models.ScopeLibraryRead)
+	}
+
+	// A second request in the same session isn't another login
+	if _, err := apiKeys.Authenticate(created.Key, "10.0.0.2"); err != nil {
+		t.Fatal(err)
+	}
+	if apiKey, err := apiKeys.Authenticate(created.Key+"0", "10.0.0.3"); err != nil || apiKey != nil {
+		t.Errorf("Authenticate with a wrong key = %+v, %v, want nil", apiKey, err)
+	}
+
+	audit := NewAuditService(context.Background(), database, logging.Discard())
+	logins, err := audit.ListAuditEvents(models.AuditFilter{Action: models.AuditLogin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logins) != 1 || logins[0].Actor != "tv" {
+		t.Errorf("got logins %+v, want one for tv", logins)
+	}
+	failed, err := audit.ListAuditEvents(models.AuditFilter{Action: models.AuditLoginFailed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failed) != 1 || failed[0].IP != "10.0.0.3" || strings.Contains(failed[0].Details, created.Prefix) {
+		t.Errorf("got failed logins %+v, want one from 10.0.0.3 without the key", failed)
+	}
+}
+
+func TestFailedLoginsAreCoalesced(t *testing.T) {
+	database := newTestDatabase(t)
+	apiKeys := NewApiKeysService(context.Background(), database, logging.Discard())
+
+	for i := 0; i < 5; i++ {
+		if _, err := apiKeys.Authenticate("lfx_guess"+strconv.Itoa(i), "10.0.0.3"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	audit := NewAuditService(context.Background(), database, logging.Discard())
+	failed, err := audit.ListAuditEvents(models.AuditFilter{Action: models.AuditLoginFailed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failed) != 1 {
+		t.Fatalf("got failed logins %+v, want the first one only", failed)
+	}
+
+	// Once the gap is over the next attempt is audited with the ones counted
+	apiKeys.failedLogins.attempts["10.0.0.3"].auditedAt = time.Now().Add(-failedLoginGap - time.Minute)
+	if _, err := apiKeys.Authenticate("lfx_guess", "10.0.0.3"); err != nil {
+		t.Fatal(err)
+	}
+	failed, err = audit.ListAuditEvents(models.AuditFilter{Action: models.AuditLoginFailed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failed) != 2 || failed[0].Details != "5 attempts with unknown keys" {
+		t.Errorf("got failed logins %+v, want a second one counting 5 attempts", failed)
+	}
+}
+
+func TestRevokeApiKey(t *testing.T) {
+	apiKeys := NewApiKeysService(context.Background(), newTestDatabase(t), logging.Discard())
+	created, err := apiKeys.CreateApiKey("tv", []string{models.ScopeAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := apiKeys.RevokeApiKey(created.ID); err != nil {
+		t.Fatalf("RevokeApiKey: %v", err)
+	}
+
+	if apiKey, err := apiKeys.Authenticate(created.Key, "10.0.0.2"); err != nil || apiKey != nil {
+		t.Errorf("Authenticate with a revoked key = %+v, %v, want nil", apiKey, err)
+	}
+}
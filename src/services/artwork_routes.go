@@ -0,0 +1,141 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"localflix-server/src/models"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// localThumbnailKinds are the local images used instead of a generated
+// thumbnail, in order. Both are landscape like a video frame.
+var localThumbnailKinds = []string{models.ArtworkThumb, models.ArtworkFanart}
+
+// registerArtworkRoutes adds the routes serving the metadata and the artwork,
+// local or downloaded from a metadata provider
+func (s *StreamService) registerArtworkRoutes(app *fiber.App) {
+	app.Get("/items/:itemId", s.requireScope(models.ScopeLibraryRead), s.rateLimit, s.getMediaItem)
+	app.Get("/artwork/:itemId/:kind", s.requireScope(models.ScopeLibraryRead), s.rateLimit, s.getArtwork)
+}
+
+func (s *StreamService) getMediaItem(c *fiber.Ctx) error {
+	itemId, err := strconv.Atoi(c.Params("itemId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid item ID")
+	}
+
+	details, err := s.metadataService.GetMediaItemDetails(itemId)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("Error retrieving item")
+	}
+	if details == nil {
+		return c.Status(fiber.StatusNotFound).SendString("Item not found")
+	}
+
+	return c.JSON(artworkURLs(c, details))
+}
+
+// artworkURLs replaces the artwork paths of details with their URLs, paths
+// on the server are of no use to clients
+func artworkURLs(c *fiber.Ctx, details *models.MediaItemDetails) *models.MediaItemDetails {
+	for kind := range details.Artwork {
+		details.Artwork[kind] = withApiKey(c, fmt.Sprintf("%s/artwork/%d/%s", c.BaseURL(), details.MediaItem.ID, kind))
+	}
+	return details
+}
+
+// getArtwork serves the local image of the given kind. Items without a local
+// thumb get the thumbnail generated from the video instead.
+func (s *StreamService) getArtwork(c *fiber.Ctx) error {
+	itemId, err := strconv.Atoi(c.Params("itemId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid item ID")
+	}
+	kind := c.Params("kind")
+	if !slices.Contains(models.ArtworkKinds, kind) {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid artwork kind, expected one of " + strings.Join(models.ArtworkKinds, ", "))
+	}
+
+	details, err := s.metadataService.GetMediaItemDetails(itemId)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("Error retrieving item")
+	}
+	if details == nil {
+		return c.Status(fiber.StatusNotFound).SendString("Item not found")
+	}
+
+	if imagePath := details.Artwork[kind]; imagePath != "" {
+		return s.sendImage(c, imagePath)
+	}
+	if kind != models.ArtworkThumb {
+		return c.Status(fiber.StatusNotFound).SendString("Artwork not found")
+	}
+
+	item := details.MediaItem
+	fileName := strings.TrimSuffix(item.RelPath, path.Ext(item.RelPath)) + ".png"
+	thumbnailPath, ok := s.resolveInside(c, s.dirs.FolderThumbnails(item.FolderID), fileName)
+	if !ok {
+		return c.Status(fiber.StatusForbidden).SendString("Invalid file name")
+	}
+	err = s.generateCached(item.FolderID, fileName, thumbnailPath, func(videoPath string, tmpPath string) error {
+		return s.mediaToolkit.Thumbnail(videoPath, tmpPath, thumbnailPosition)
+	})
+	if err != nil {
+		s.requestLogger(c).Error("generating thumbnail", "file", fileName, "err", err)
+		return sendMediaError(c, err)
+	}
+
+	return s.sendImage(c, thumbnailPath)
+}
+
+// localThumbnail returns the local image to use as the thumbnail of the
+// video named like fileName, or "" when there is none
+func (s *StreamService) localThumbnail(folderId int, fileName string) string {
+	folder, err := s.foldersService.GetFolderById(folderId)
+	if err != nil {
+		return ""
+	}
+	videoPath, err := findVideo(filepath.Join(folder.Path, strings.TrimSuffix(fileName, filepath.Ext(fileName))))
+	if err != nil {
+		return ""
+	}
+	relPath, err := filepath.Rel(folder.Path, videoPath)
+	if err != nil {
+		return ""
+	}
+
+	item, err := s.metadataService.FindMediaItem(folderId, filepath.ToSlash(relPath))
+	if err != nil || item == nil {
+		return ""
+	}
+	for _, kind := range localThumbnailKinds {
+		if imagePath, err := s.metadataService.GetArtwork(item.ID, kind); err == nil && imagePath != "" {
+			return imagePath
+		}
+	}
+	return ""
+}
+
+func (s *StreamService) sendImage(c *fiber.Ctx, imagePath string) error {
+	file, err := os.Open(imagePath)
+	if err != nil {
+		s.requestLogger(c).Error("opening file", "file", imagePath, "err", err)
+		return c.Status(fiber.StatusNotFound).SendString("Artwork not found")
+	}
+	defer file.Close()
+
+	c.Type(strings.TrimPrefix(filepath.Ext(imagePath), "."))
+	if _, err := io.Copy(c.Response().BodyWriter(), file); err != nil {
+		s.requestLogger(c).Error("sending file", "file", imagePath, "err", err)
+		return c.Status(fiber.StatusInternalServerError).SendString("Internal Server Error")
+	}
+
+	return nil
+}
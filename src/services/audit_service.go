@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"localflix-server/src/models"
+	"localflix-server/src/repositories"
+	"log/slog"
+	"time"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+	// auditRetention is how long audit events are kept
+	auditRetention = 180 * 24 * time.Hour
+)
+
+type AuditService struct {
+	ctx             context.Context
+	auditRepository *repositories.AuditRepository
+	logger          *slog.Logger
+}
+
+// NewAuditService creates a new AuditService struct
+func NewAuditService(ctx context.Context, db *sql.DB, logger *slog.Logger) *AuditService {
+	return &AuditService{
+		ctx:             ctx,
+		auditRepository: repositories.NewAuditRepository(db),
+		logger:          logger,
+	}
+}
+
+// Record stores an audit event. Failing to audit never fails the action being
+// audited, the error is only logged.
+func (a *AuditService) Record(event models.AuditEvent) {
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now().UTC()
+	}
+
+	if err := a.auditRepository.CreateAuditEvent(event); err != nil {
+		a.logger.Error("recording audit event", "action", event.Action, "err", err)
+	}
+}
+
+func (a *AuditService) ListAuditEvents(filter models.AuditFilter) ([]models.AuditEvent, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultAuditLimit
+	}
+	filter.Limit = min(filter.Limit, maxAuditLimit)
+	filter.Offset = max(filter.Offset, 0)
+
+	events, err := a.auditRepository.ListAuditEvents(filter)
+	if err != nil {
+		a.logger.Error("listing audit events", "err", err)
+		return nil, err
+	}
+
+	result := make([]models.AuditEvent, len(events))
+	for i, event := range events {
+		result[i] = *event
+	}
+	return result, nil
+}
+
+func (a *AuditService) CountAuditEvents(filter models.AuditFilter) (int, error) {
+	count, err := a.auditRepository.CountAuditEvents(filter)
+	if err != nil {
+		a.logger.Error("counting audit events", "err", err)
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// PruneAuditEvents deletes the events older than auditRetention
+func (a *AuditService) PruneAuditEvents() error {
+	deleted, err := a.auditRepository.DeleteAuditEventsBefore(time.Now().UTC().Add(-auditRetention))
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		a.logger.Info("pruned audit events", "count", deleted)
+	}
+
+	return nil
+}
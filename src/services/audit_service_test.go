@@ -0,0 +1,26 @@
+package services
+
+import (
+	"context"
+	"localflix-server/src/logging"
+	"localflix-server/src/models"
+	"testing"
+	"time"
+)
+
+func TestPruneAuditEvents(t *testing.T) {
+	audit := NewAuditService(context.Background(), newTestDatabase(t), logging.Discard())
+	audit.Record(models.AuditEvent{Action: models.AuditLogin, Actor: "old", CreatedAt: time.Now().UTC().Add(-auditRetention - time.Hour)})
+	audit.Record(models.AuditEvent{Action: models.AuditLogin, Actor: "recent", CreatedAt: time.Now().UTC().Add(-time.Hour)})
+
+	if err := audit.PruneAuditEvents(); err != nil {
+		t.Fatal(err)
+	}
+	events, err := audit.ListAuditEvents(models.AuditFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Actor != "recent" {
+		t.Errorf("got events %+v, want only the recent one", events)
+	}
+}
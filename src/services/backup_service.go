@@ -0,0 +1,346 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"localflix-server/src/appdata"
+	"localflix-server/src/models"
+	"localflix-server/src/repositories"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+type BackupService struct {
+	ctx    context.Context
+	db     *sql.DB
+	dirs   *appdata.Dirs
+	logger *slog.Logger
+}
+
+// NewBackupService creates a new BackupService struct
+func NewBackupService(ctx context.Context, db *sql.DB, dirs *appdata.Dirs, logger *slog.Logger) *BackupService {
+	return &BackupService{
+		ctx:    ctx,
+		db:     db,
+		dirs:   dirs,
+		logger: logger,
+	}
+}
+
+// BackupDatabase writes a consistent copy of the database to path while the
+// app keeps running.
+func (b *BackupService) BackupDatabase(path string) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%s already exists", path)
+	}
+
+	_, err = b.db.Exec("VACUUM INTO ?", path)
+	if err != nil {
+		b.logger.Error("backing up database", "err", err)
+		return err
+	}
+
+	return nil
+}
+
+// ExportLibrary collects categories, folders, settings, users, api keys and
+// watch progress in a portable form.
+func (b *BackupService) ExportLibrary() (*models.LibraryExport, error) {
+	tx, err := b.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	export := &models.LibraryExport{
+		Version:       models.LibraryExportVersion,
+		ExportedAt:    time.Now().UTC(),
+		Categories:    []models.ExportedCategory{},
+		Users:         []models.ExportedUser{},
+		ApiKeys:       []models.ExportedApiKey{},
+		WatchProgress: []models.ExportedWatchProgress{},
+	}
+
+	foldersRepository := repositories.NewFoldersRepository(tx)
+	for _, category := range repositories.NewCategoriesRepository(tx).ListCategories() {
+		exported := models.ExportedCategory{Name: category.Name, Folders: []string{}}
+		for _, folder := range foldersRepository.GetFolderByCategory(category.ID) {
+			exported.Folders = append(exported.Folders, folder.Path)
+		}
+		export.Categories = append(export.Categories, exported)
+	}
+
+	export.Settings, err = repositories.NewSettingsRepository(tx).ListSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	users, err := repositories.NewUsersRepository(tx).ListUsers()
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		export.Users = append(export.Users, models.ExportedUser{Name: user.Name})
+	}
+
+	apiKeys, err := repositories.NewApiKeysRepository(tx).ExportApiKeys()
+	if err != nil {
+		return nil, err
+	}
+	export.ApiKeys = append(export.ApiKeys, apiKeys...)
+
+	progress, err := repositories.NewWatchProgressRepository(tx).ExportProgress()
+	if err != nil {
+		return nil, err
+	}
+	export.WatchProgress = append(export.WatchProgress, progress...)
+
+	return export, nil
+}
+
+func (b *BackupService) ExportLibraryToFile(path string) error {
+	export, err := b.ExportLibrary()
+	if err != nil {
+		b.logger.Error("exporting library", "err", err)
+		return err
+	}
+
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0o600)
+}
+
+// ImportLibrary merges an export into the library in one transaction.
+// Categories and users are matched by name and folders by their remapped
+// path, so importing the same file twice doesn't duplicate anything. Folders
+// and settings go through the same checks as in the app, the ones rejected on
+// this machine are skipped with a warning and the imported settings replace
+// the current ones. Watch progress only applies to scanned
+// files and replaces older progress, importing again once the new folders
+// are scanned imports the rest.
+func (b *BackupService) ImportLibrary(export models.LibraryExport, remaps []models.PathRemap) (*models.ImportResult, error) {
+	if export.Version == 0 || export.Version > models.LibraryExportVersion {
+		return nil, fmt.Errorf("unsupported export version %d", export.Version)
+	}
+
+	tx, err := b.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result := &models.ImportResult{Warnings: []string{}}
+	categoriesRepository := repositories.NewCategoriesRepository(tx)
+	foldersRepository := repositories.NewFoldersRepository(tx)
+
+	registered := map[string]int{}
+	for _, folder := range foldersRepository.ListFolders() {
+		registered[filepath.Clean(folder.Path)] = folder.ID
+	}
+
+	var createdFolders []int
+	for _, exported := range export.Categories {
+		category, err := categoriesRepository.GetCategoryByName(exported.Name)
+		if err != nil {
+			return nil, err
+		}
+		if category == nil {
+			category, err = categoriesRepository.CreateCategory(exported.Name)
+			if err != nil {
+				return nil, err
+			}
+			result.CategoriesCreated++
+		}
+
+		for _, folderPath := range exported.Folders {
+			folderPath = filepath.Clean(remapPath(folderPath, remaps))
+			if _, ok := registered[folderPath]; ok {
+				result.FoldersSkipped++
+				continue
+			}
+			folderPath, err = validateFolderPath(foldersRepository, folderPath)
+			if err != nil {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("folder skipped: %v", err))
+				continue
+			}
+
+			folder, err := foldersRepository.CreateFolder(folderPath, category.ID)
+			if err != nil {
+				return nil, err
+			}
+			registered[folderPath] = folder.ID
+			createdFolders = append(createdFolders, folder.ID)
+			result.FoldersCreated++
+		}
+	}
+
+	settingsService := &SettingsService{
+		ctx:                b.ctx,
+		settingsRepository: repositories.NewSettingsRepository(tx),
+		logger:             b.logger,
+	}
+	keys := make([]string, 0, len(export.Settings))
+	for key := range export.Settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if _, err := settingsService.UpdateSettingsGroup(key, []byte(export.Settings[key])); err != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("%s settings skipped: %v", key, err))
+			continue
+		}
+		result.SettingsImported++
+	}
+
+	usersRepository := repositories.NewUsersRepository(tx)
+	userIds := map[string]int{"": 0}
+	for _, exported := range export.Users {
+		user, err := usersRepository.GetUserByName(exported.Name)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			user, err = usersRepository.CreateUser(exported.Name)
+			if err != nil {
+				return nil, err
+			}
+			result.UsersCreated++
+		}
+		userIds[exported.Name] = user.ID
+	}
+
+	apiKeysRepository := repositories.NewApiKeysRepository(tx)
+	for _, apiKey := range export.ApiKeys {
+		userId, ok := userIds[apiKey.User]
+		if !ok {
+			return nil, fmt.Errorf("api key %s belongs to unknown user %q", apiKey.Name, apiKey.User)
+		}
+		imported, err := apiKeysRepository.ImportApiKey(apiKey, userId)
+		if err != nil {
+			return nil, err
+		}
+		if imported {
+			result.ApiKeysImported++
+		}
+	}
+
+	mediaItemsRepository := repositories.NewMediaItemsRepository(tx)
+	watchProgressRepository := repositories.NewWatchProgressRepository(tx)
+	unscanned := 0
+	for _, progress := range export.WatchProgress {
+		userId, ok := userIds[progress.User]
+		if !ok {
+			return nil, fmt.Errorf("watch progress of unknown user %q", progress.User)
+		}
+		var item *models.MediaItem
+		if folderId, ok := registered[filepath.Clean(remapPath(progress.Folder, remaps))]; ok {
+			item, err = mediaItemsRepository.GetMediaItemByPath(folderId, progress.Path)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if item == nil {
+			unscanned++
+			continue
+		}
+
+		imported, err := watchProgressRepository.ImportProgress(models.WatchProgress{
+			UserID:      userId,
+			MediaItemID: item.ID,
+			Position:    progress.Position,
+			Duration:    progress.Duration,
+			Watched:     progress.Watched,
+			UpdatedAt:   progress.UpdatedAt,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if imported {
+			result.ProgressImported++
+		}
+	}
+	if unscanned > 0 {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("watch progress of %d files not scanned yet was skipped, import again once their folders are scanned", unscanned))
+	}
+
+	for _, folderId := range createdFolders {
+		for _, dir := range []string{b.dirs.FolderSubtitles(folderId), b.dirs.FolderThumbnails(folderId)} {
+			if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (b *BackupService) ImportLibraryFromFile(path string, remaps []models.PathRemap) (*models.ImportResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var export models.LibraryExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, fmt.Errorf("invalid library export: %w", err)
+	}
+
+	result, err := b.ImportLibrary(export, remaps)
+	if err != nil {
+		b.logger.Error("importing library", "err", err)
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// remapPath applies the remap with the longest matching From prefix. Prefixes
+// only match whole path elements, /mnt/old doesn't remap /mnt/older.
+func remapPath(path string, remaps []models.PathRemap) string {
+	best := -1
+	for i, remap := range remaps {
+		from := strings.TrimRight(remap.From, "/\\")
+		if from == "" {
+			continue
+		}
+		if path != from && !strings.HasPrefix(path, from+"/") && !strings.HasPrefix(path, from+"\\") {
+			continue
+		}
+		if best == -1 || len(from) > len(strings.TrimRight(remaps[best].From, "/\\")) {
+			best = i
+		}
+	}
+	if best == -1 {
+		return path
+	}
+
+	from := strings.TrimRight(remaps[best].From, "/\\")
+	return strings.TrimRight(remaps[best].To, "/\\") + path[len(from):]
+}
+
+// ParsePathRemap parses the old=new form used on the command line
+func ParsePathRemap(value string) (models.PathRemap, error) {
+	from, to, found := strings.Cut(value, "=")
+	if !found || from == "" || to == "" {
+		return models.PathRemap{}, fmt.Errorf("invalid path remap %q, expected old=new", value)
+	}
+
+	return models.PathRemap{From: from, To: to}, nil
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"localflix-server/src/db"
+	"localflix-server/src/logging"
+	"localflix-server/src/models"
+	"path/filepath"
+	"testing"
+)
+
+func TestExportImportLibrary(t *testing.T) {
+	source := newTestLibrary(t)
+	category, err := source.categories.CreateCategory("Movies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldRoot := makeDir(t, "movies/a.mkv")
+	folder, err := source.folders.CreateFolder(filepath.Join(oldRoot, "movies"), category.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewScanService(context.Background(), source.db, NewFakeMediaToolkit(), logging.Discard()).ScanFolder(folder.ID); err != nil {
+		t.Fatal(err)
+	}
+	apiKey, err := NewApiKeysService(context.Background(), source.db, logging.Discard()).CreateApiKey("tv", []string{models.ScopeStream})
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice, aliceKey, err := NewUsersService(context.Background(), source.db, logging.Discard()).CreateUser("Alice", []string{models.ScopeStream})
+	if err != nil {
+		t.Fatal(err)
+	}
+	markWatched(t, source.db, 0, folder.ID, "a.mkv")
+	markWatched(t, source.db, alice.ID, folder.ID, "a.mkv")
+	export, err := NewBackupService(context.Background(), source.db, source.dirs, logging.Discard()).ExportLibrary()
+	if err != nil {
+		t.Fatalf("ExportLibrary: %v", err)
+	}
+
+	target := newTestLibrary(t)
+	newRoot := makeDir(t, "movies/a.mkv")
+	backup := NewBackupService(context.Background(), target.db, target.dirs, logging.Discard())
+	remaps := []models.PathRemap{{From: oldRoot, To: newRoot}}
+
+	result, err := backup.ImportLibrary(*export, remaps)
+	if err != nil {
+		t.Fatalf("ImportLibrary: %v", err)
+	}
+	if result.CategoriesCreated != 1 || result.FoldersCreated != 1 || result.UsersCreated != 1 || result.ApiKeysImported != 2 {
+		t.Errorf("got %+v, want one category, folder and user with two api keys", result)
+	}
+	// The folder isn't scanned yet, its media items don't exist
+	if result.ProgressImported != 0 || len(result.Warnings) != 1 {
+		t.Errorf("got %+v, want the watch progress skipped with a warning", result)
+	}
+	folders := target.folders.ListFolders()
+	if len(folders) != 1 || folders[0].Path != filepath.Join(newRoot, "movies") {
+		t.Errorf("ListFolders = %+v, want the remapped folder", folders)
+	}
+
+	// Clients keep working with the keys they already have
+	apiKeys := NewApiKeysService(context.Background(), target.db, logging.Discard())
+	imported, err := apiKeys.Authenticate(apiKey.Key, "10.0.0.2")
+	if err != nil || imported == nil || imported.UserID != 0 {
+		t.Errorf("Authenticate with an imported key = %+v, %v", imported, err)
+	}
+	imported, err = apiKeys.Authenticate(aliceKey.Key, "10.0.0.2")
+	if err != nil || imported == nil || imported.UserID == 0 {
+		t.Errorf("Authenticate with Alice's imported key = %+v, %v, want her key", imported, err)
+	}
+	if _, err := NewScanService(context.Background(), target.db, NewFakeMediaToolkit(), logging.Discard()).ScanFolder(folders[0].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	again, err := backup.ImportLibrary(*export, remaps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.CategoriesCreated != 0 || again.FoldersCreated != 0 || again.FoldersSkipped != 1 || again.UsersCreated != 0 || again.ApiKeysImported != 0 {
+		t.Errorf("importing twice got %+v, want nothing new", again)
+	}
+	if again.ProgressImported != 2 || len(again.Warnings) != 0 {
+		t.Errorf("importing once scanned got %+v, want the progress of both users", again)
+	}
+	progress, err := NewWatchProgressService(context.Background(), target.db, logging.Discard()).ListProgress(imported.UserID)
+	if err != nil || len(progress) != 1 || !progress[0].Watched {
+		t.Errorf("ListProgress of Alice = %+v, %v, want a.mkv watched", progress, err)
+	}
+}
+
+func TestImportLibrarySkipsInvalidFoldersAndSettings(t *testing.T) {
+	library := newTestLibrary(t)
+	root := makeDir(t, "movies/a.mkv", "movies/kids/b.mkv")
+	backup := NewBackupService(context.Background(), library.db, library.dirs, logging.Discard())
+
+	export := models.LibraryExport{
+		Version: models.LibraryExportVersion,
+		Categories: []models.ExportedCategory{{
+			Name: "Movies",
+			Folders: []string{
+				filepath.Join(root, "movies"),
+				filepath.Join(root, "movies", "kids"),
+				filepath.Join(root, "missing"),
+			},
+		}},
+		Settings: map[string]string{
+			"tls":        `{"cert_file":"/elsewhere/cert.pem","key_file":"/elsewhere/key.pem","redirect_port":3080}`,
+			"rate_limit": `{"requests_per_minute":60,"max_bandwidth_mbps":0}`,
+		},
+	}
+	result, err := backup.ImportLibrary(export, nil)
+	if err != nil {
+		t.Fatalf("ImportLibrary: %v", err)
+	}
+	if result.FoldersCreated != 1 || result.SettingsImported != 1 || len(result.Warnings) != 3 {
+		t.Errorf("got %+v, want the nested and missing folders and the tls settings skipped with warnings", result)
+	}
+
+	folders := library.folders.ListFolders()
+	if len(folders) != 1 || folders[0].Path != filepath.Join(root, "movies") {
+		t.Errorf("ListFolders = %+v, want only the movies folder", folders)
+	}
+	settings := NewSettingsService(context.Background(), library.db, logging.Discard())
+	tls, err := settings.GetTlsSettings()
+	if err != nil || tls.CertFile != "" {
+		t.Errorf("GetTlsSettings = %+v, %v, want the defaults", tls, err)
+	}
+	rateLimit, err := settings.GetRateLimitSettings()
+	if err != nil || rateLimit.RequestsPerMinute != 60 {
+		t.Errorf("GetRateLimitSettings = %+v, %v, want the imported limits", rateLimit, err)
+	}
+}
+
+func TestImportLibraryRejectsNewerVersions(t *testing.T) {
+	library := newTestLibrary(t)
+	backup := NewBackupService(context.Background(), library.db, library.dirs, logging.Discard())
+
+	export := models.LibraryExport{Version: models.LibraryExportVersion + 1}
+	if _, err := backup.ImportLibrary(export, nil); err == nil {
+		t.Error("importing a newer export version succeeded")
+	}
+}
+
+func TestBackupDatabase(t *testing.T) {
+	library := newTestLibrary(t)
+	if _, err := library.categories.CreateCategory("Movies"); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "backup.db")
+
+	if err := NewBackupService(context.Background(), library.db, library.dirs, logging.Discard()).BackupDatabase(path); err != nil {
+		t.Fatalf("BackupDatabase: %v", err)
+	}
+
+	restored, err := db.OpenAppDatabase(path, logging.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restored.Db.Close()
+	categories := NewCategoriesService(context.Background(), restored.Db, library.dirs, logging.Discard()).ListCategories()
+	if len(categories) != 1 || categories[0].Name != "Movies" {
+		t.Errorf("backup has categories %+v, want Movies", categories)
+	}
+}
+
+func TestRemapPath(t *testing.T) {
+	remaps := []models.PathRemap{
+		{From: "/mnt/old", To: "/media"},
+		{From: "/mnt/old/shows/", To: "/tv"},
+	}
+	tests := map[string]string{
+		"/mnt/old":               "/media",
+		"/mnt/old/movies":        "/media/movies",
+		"/mnt/old/shows/lost":    "/tv/lost",
+		"/mnt/older/movies":      "/mnt/older/movies",
+		"/elsewhere/mnt/old/abc": "/elsewhere/mnt/old/abc",
+	}
+	for path, want := range tests {
+		if got := remapPath(path, remaps); got != want {
+			t.Errorf("remapPath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
@@ -3,38 +3,67 @@ package services
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"localflix-server/src/appdata"
 	"localflix-server/src/models"
 	"localflix-server/src/repositories"
+	"log/slog"
+	"strings"
 )
 
+var ErrInvalidCategory = errors.New("invalid category")
+
 type CategoriesService struct {
 	ctx                  context.Context
+	db                   *sql.DB
 	categoriesRepository *repositories.CategoriesRepository
+	auditService         *AuditService
+	dirs                 *appdata.Dirs
+	actor                string
+	ip                   string
+	logger               *slog.Logger
 }
 
 // NewApp creates a new App application struct
-func NewCategoriesService(ctx context.Context, db *sql.DB) *CategoriesService {
+func NewCategoriesService(ctx context.Context, db *sql.DB, dirs *appdata.Dirs, logger *slog.Logger) *CategoriesService {
 	return &CategoriesService{
 		ctx:                  ctx,
+		db:                   db,
 		categoriesRepository: repositories.NewCategoriesRepository(db),
+		auditService:         NewAuditService(ctx, db, logger),
+		dirs:                 dirs,
+		logger:               logger,
 	}
 }
 
 func (c *CategoriesService) CreateCategory(name string) (*models.Category, error) {
+	name, err := c.checkName(name, 0)
+	if err != nil {
+		return nil, err
+	}
+
 	category, err := c.categoriesRepository.CreateCategory(name)
 	if err != nil {
-		fmt.Printf("error creating category: %v\n", err)
+		c.logger.Error("creating category", "err", err)
 		return nil, err
 	}
-	fmt.Printf("category created: %v\n", category)
+	c.logger.Info("category created", "id", category.ID, "name", category.Name)
+	c.auditService.Record(models.AuditEvent{
+		Action:     models.AuditCategoryCreate,
+		Actor:      c.auditActor(),
+		IP:         c.ip,
+		TargetType: "category",
+		TargetID:   category.ID,
+		Details:    category.Name,
+	})
 	return category, nil
 }
 
 func (c *CategoriesService) GetCategory(id int) (*models.Category, error) {
 	category, err := c.categoriesRepository.GetCategory(id)
 	if err != nil {
-		fmt.Printf("error getting category by id: %v\n", err)
+		c.logger.Error("getting category by id", "id", id, "err", err)
 		return nil, err
 	}
 
@@ -44,7 +73,7 @@ func (c *CategoriesService) GetCategory(id int) (*models.Category, error) {
 func (c *CategoriesService) GetCategoryByName(name string) (*models.Category, error) {
 	category, err := c.categoriesRepository.GetCategoryByName(name)
 	if err != nil {
-		fmt.Printf("error getting category by name: %v\n", err)
+		c.logger.Error("getting category by name", "err", err)
 		return nil, err
 	}
 
@@ -55,28 +84,151 @@ func (c *CategoriesService) ListCategories() []models.Category {
 	categories := c.categoriesRepository.ListCategories()
 	result := make([]models.Category, len(categories))
 	for i, category := range categories {
-		fmt.Println("category: ", category)
 		result[i] = *category
 	}
 	return result
 }
 
+// ListCategoriesPage pages the categories, they sort by name
+func (c *CategoriesService) ListCategoriesPage(options models.ListOptions) (*models.CategoryPage, error) {
+	return pageCategories(c.ListCategories(), options)
+}
+
+// pageCategories pages categories already listed, real and virtual ones
+func pageCategories(categories []models.Category, options models.ListOptions) (*models.CategoryPage, error) {
+	query, err := parseListOptions(options, []string{models.SortName}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.CategoryPage{
+		Items: pageOf(categories, query, map[string]func(a, b models.Category) int{
+			models.SortName: func(a, b models.Category) int { return compareFold(a.Name, b.Name) },
+		}),
+		Total: len(categories),
+	}, nil
+}
+
+// DeleteCategory deletes the category together with its folders in one
+// transaction, then removes the caches generated for those folders.
 func (c *CategoriesService) DeleteCategory(id int) error {
-	err := c.categoriesRepository.DeleteCategory(id)
+	tx, err := c.db.Begin()
+	if err != nil {
+		c.logger.Error("deleting category", "id", id, "err", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	foldersRepository := repositories.NewFoldersRepository(tx)
+	folderIds, err := foldersRepository.ListFolderIdsByCategory(id)
+	if err != nil {
+		c.logger.Error("deleting category", "id", id, "err", err)
+		return err
+	}
+
+	// The foreign key cascades as well, deleting explicitly keeps this
+	// correct for databases opened without foreign keys enforced
+	for _, folderId := range folderIds {
+		if err := foldersRepository.DeleteFolder(folderId); err != nil {
+			c.logger.Error("deleting category", "id", id, "err", err)
+			return err
+		}
+	}
+
+	err = repositories.NewCategoriesRepository(tx).DeleteCategory(id)
 	if err != nil {
-		fmt.Printf("error deleting category: %v\n", err)
+		c.logger.Error("deleting category", "id", id, "err", err)
 		return err
 	}
 
-	return nil
+	if err := tx.Commit(); err != nil {
+		c.logger.Error("deleting category", "id", id, "err", err)
+		return err
+	}
+
+	var cacheErrs []error
+	for _, folderId := range folderIds {
+		c.auditService.Record(models.AuditEvent{
+			Action:     models.AuditFolderRemove,
+			Actor:      c.auditActor(),
+			IP:         c.ip,
+			TargetType: "folder",
+			TargetID:   folderId,
+			Details:    fmt.Sprintf("category %d deleted", id),
+		})
+		if err := removeFolderCaches(c.dirs, folderId); err != nil {
+			c.logger.Error("removing folder caches", "folder_id", folderId, "err", err)
+			cacheErrs = append(cacheErrs, err)
+		}
+	}
+
+	c.auditService.Record(models.AuditEvent{
+		Action:     models.AuditCategoryDelete,
+		Actor:      c.auditActor(),
+		IP:         c.ip,
+		TargetType: "category",
+		TargetID:   id,
+	})
+	return errors.Join(cacheErrs...)
 }
 
+// UpdateCategory renames the category. Returns sql.ErrNoRows for an unknown
+// category.
 func (c *CategoriesService) UpdateCategory(id int, name string) (*models.Category, error) {
-	category, err := c.categoriesRepository.UpdateCategory(id, name)
+	name, err := c.checkName(name, id)
 	if err != nil {
-		fmt.Printf("error updating category: %v\n", err)
 		return nil, err
 	}
 
+	category, err := c.categoriesRepository.UpdateCategory(id, name)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			c.logger.Error("updating category", "id", id, "err", err)
+		}
+		return nil, err
+	}
+	c.logger.Info("category renamed", "id", category.ID, "name", category.Name)
+	c.auditService.Record(models.AuditEvent{
+		Action:     models.AuditCategoryUpdate,
+		Actor:      c.auditActor(),
+		IP:         c.ip,
+		TargetType: "category",
+		TargetID:   category.ID,
+		Details:    category.Name,
+	})
 	return category, nil
 }
+
+// checkName trims the name and makes sure no other category than id uses it
+func (c *CategoriesService) checkName(name string, id int) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("%w: name is required", ErrInvalidCategory)
+	}
+
+	existing, err := c.GetCategoryByName(name)
+	if err != nil {
+		return "", err
+	}
+	if existing != nil && existing.ID != id {
+		return "", fmt.Errorf("%w: category %q already exists", ErrInvalidCategory, existing.Name)
+	}
+
+	return name, nil
+}
+
+// WithActor returns a copy of the service that records actor and ip in the
+// audit log instead of the desktop app
+func (c CategoriesService) WithActor(actor string, ip string) *CategoriesService {
+	c.actor = actor
+	c.ip = ip
+	return &c
+}
+
+func (c *CategoriesService) auditActor() string {
+	if c.actor == "" {
+		return models.AuditActorDesktop
+	}
+
+	return c.actor
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"localflix-server/src/logging"
+	"localflix-server/src/models"
+	"testing"
+)
+
+func TestCreateCategory(t *testing.T) {
+	library := newTestLibrary(t)
+
+	category, err := library.categories.CreateCategory("  Movies ")
+	if err != nil {
+		t.Fatalf("CreateCategory: %v", err)
+	}
+	if category.Name != "Movies" {
+		t.Errorf("got name %q, want the trimmed name", category.Name)
+	}
+
+	if _, err := library.categories.CreateCategory("Movies"); err == nil {
+		t.Error("creating a duplicate category succeeded")
+	}
+	if _, err := library.categories.CreateCategory(" "); err == nil {
+		t.Error("creating a category without a name succeeded")
+	}
+
+	categories := library.categories.ListCategories()
+	if len(categories) != 1 || categories[0].ID != category.ID {
+		t.Errorf("ListCategories = %+v, want only Movies", categories)
+	}
+}
+
+func TestDeleteCategoryDeletesFolders(t *testing.T) {
+	library := newTestLibrary(t)
+	movies, err := library.categories.CreateCategory("Movies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	shows, err := library.categories.CreateCategory("Shows")
+	if err != nil {
+		t.Fatal(err)
+	}
+	movieFolder, err := library.folders.CreateFolder(makeDir(t), movies.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	showFolder, err := library.folders.CreateFolder(makeDir(t), shows.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := library.categories.DeleteCategory(movies.ID); err != nil {
+		t.Fatalf("DeleteCategory: %v", err)
+	}
+
+	if category, err := library.categories.GetCategory(movies.ID); err == nil {
+		t.Errorf("GetCategory found deleted category %+v", category)
+	}
+	folders := library.folders.ListFolders()
+	if len(folders) != 1 || folders[0].ID != showFolder.ID {
+		t.Errorf("ListFolders = %+v, want only the Shows folder", folders)
+	}
+	if exists(library.dirs.FolderSubtitles(movieFolder.ID)) {
+		t.Error("caches of the deleted folder were not removed")
+	}
+	if !exists(library.dirs.FolderSubtitles(showFolder.ID)) {
+		t.Error("caches of another category's folder were removed")
+	}
+}
+
+func TestCategoryAuditActor(t *testing.T) {
+	library := newTestLibrary(t)
+	audit := NewAuditService(context.Background(), library.db, logging.Discard())
+
+	if _, err := library.categories.CreateCategory("Movies"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := library.categories.WithActor("tv", "10.0.0.2").CreateCategory("Shows"); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := audit.ListAuditEvents(models.AuditFilter{Action: models.AuditCategoryCreate})
+	if err != nil {
+		t.Fatal(err)
+	}
+	actors := map[string]string{}
+	for _, event := range events {
+		actors[event.Actor] = event.IP
+	}
+	if _, ok := actors[models.AuditActorDesktop]; !ok {
+		t.Errorf("no event recorded for the desktop app in %+v", events)
+	}
+	if actors["tv"] != "10.0.0.2" {
+		t.Errorf("no event recorded for tv from 10.0.0.2 in %+v", events)
+	}
+}
+
+func TestUpdateCategory(t *testing.T) {
+	library := newTestLibrary(t)
+	movies, err := library.categories.CreateCategory("Movies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := library.categories.CreateCategory("Shows"); err != nil {
+		t.Fatal(err)
+	}
+
+	category, err := library.categories.WithActor("tv", "10.0.0.2").UpdateCategory(movies.ID, " Films ")
+	if err != nil {
+		t.Fatalf("UpdateCategory: %v", err)
+	}
+	if category.Name != "Films" {
+		t.Errorf("got name %q, want the trimmed name", category.Name)
+	}
+	// Keeping its own name isn't a duplicate
+	if _, err := library.categories.UpdateCategory(movies.ID, "Films"); err != nil {
+		t.Errorf("UpdateCategory with the same name: %v", err)
+	}
+
+	if _, err := library.categories.UpdateCategory(movies.ID, "Shows"); !errors.Is(err, ErrInvalidCategory) {
+		t.Errorf("renaming to an existing name = %v, want ErrInvalidCategory", err)
+	}
+	if _, err := library.categories.UpdateCategory(movies.ID, " "); !errors.Is(err, ErrInvalidCategory) {
+		t.Errorf("renaming to an empty name = %v, want ErrInvalidCategory", err)
+	}
+	if _, err := library.categories.UpdateCategory(movies.ID+100, "Other"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("renaming an unknown category = %v, want sql.ErrNoRows", err)
+	}
+
+	audit := NewAuditService(context.Background(), library.db, logging.Discard())
+	events, err := audit.ListAuditEvents(models.AuditFilter{Action: models.AuditCategoryUpdate})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[1].Actor != "tv" || events[1].Details != "Films" {
+		t.Errorf("got events %+v, want the renames audited", events)
+	}
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const selfSignedValidity = 365 * 24 * time.Hour
+
+type CertificateService struct {
+	dir    string
+	logger *slog.Logger
+}
+
+// NewCertificateService creates a new CertificateService storing generated
+// certificates in dir
+func NewCertificateService(dir string, logger *slog.Logger) *CertificateService {
+	return &CertificateService{
+		dir:    dir,
+		logger: logger,
+	}
+}
+
+// SelfSignedCertificate returns the paths of the self-signed certificate and
+// key, generating them when they are missing or about to expire.
+func (c *CertificateService) SelfSignedCertificate() (string, string, error) {
+	certFile := filepath.Join(c.dir, "cert.pem")
+	keyFile := filepath.Join(c.dir, "key.pem")
+
+	if c.isValid(certFile, keyFile) {
+		return certFile, keyFile, nil
+	}
+
+	if err := c.generate(certFile, keyFile); err != nil {
+		return "", "", err
+	}
+
+	return certFile, keyFile, nil
+}
+
+// RegenerateSelfSignedCertificate replaces the current self-signed
+// certificate, e.g. after the machine got a new IP address.
+func (c *CertificateService) RegenerateSelfSignedCertificate() error {
+	return c.generate(filepath.Join(c.dir, "cert.pem"), filepath.Join(c.dir, "key.pem"))
+}
+
+func (c *CertificateService) isValid(certFile, keyFile string) bool {
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return false
+	}
+
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return false
+	}
+
+	return time.Now().Add(24 * time.Hour).Before(cert.NotAfter)
+}
+
+func (c *CertificateService) generate(certFile, keyFile string) error {
+	c.logger.Info("generating self-signed certificate", "dir", c.dir)
+	if err := os.MkdirAll(c.dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create certificate dir: %w", err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return fmt.Errorf("failed to generate serial number: %w", err)
+	}
+
+	dnsNames, ips := certificateHosts()
+	now := time.Now()
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"LocalFlix"}, CommonName: "LocalFlix Server"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              dnsNames,
+		IPAddresses:           ips,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return fmt.Errorf("failed to create certificate: %w", err)
+	}
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("failed to encode key: %w", err)
+	}
+
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644); err != nil {
+		return fmt.Errorf("failed to write certificate: %w", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600); err != nil {
+		return fmt.Errorf("failed to write key: %w", err)
+	}
+
+	return nil
+}
+
+// certificateHosts lists the names clients on the LAN may use to reach the
+// server: localhost, the hostname and every local interface address.
+func certificateHosts() ([]string, []net.IP) {
+	dnsNames := []string{"localhost"}
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		dnsNames = append(dnsNames, hostname)
+	}
+
+	ips := []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return dnsNames, ips
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() {
+			ips = append(ips, ipNet.IP)
+		}
+	}
+
+	return dnsNames, ips
+}
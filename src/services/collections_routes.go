@@ -0,0 +1,293 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"localflix-server/src/models"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// registerCollectionRoutes adds the routes browsing tags and collections.
+// Changing them needs the admin scope, see registerAdminRoutes.
+func (s *StreamService) registerCollectionRoutes(app *fiber.App) {
+	app.Get("/tags", s.requireScope(models.ScopeLibraryRead), s.rateLimit, s.listTags)
+	app.Get("/collections", s.requireScope(models.ScopeLibraryRead), s.rateLimit, s.listCollections)
+	app.Get("/collections/:collectionId", s.requireScope(models.ScopeLibraryRead), s.rateLimit, s.getCollection)
+	app.Get("/collections/:collectionId/items", s.requireScope(models.ScopeLibraryRead), s.rateLimit, s.listCollectionItems)
+}
+
+type tagRequest struct {
+	Name string `json:"name"`
+}
+
+type mediaItemTagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+type collectionRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type collectionItemsRequest struct {
+	MediaItemIDs []int `json:"media_item_ids"`
+}
+
+// collectionItemRequest adds a media item at Position, or at the end without
+// one
+type collectionItemRequest struct {
+	MediaItemID int  `json:"media_item_id"`
+	Position    *int `json:"position"`
+}
+
+// listTags lists the tags of the kind query param, "tag" or "genre", or of
+// every kind without it
+func (s *StreamService) listTags(c *fiber.Ctx) error {
+	page, err := s.tagsService.ListTags(c.Query("kind"), listOptions(c))
+	if err != nil {
+		return sendListError(c, err, "Error listing tags")
+	}
+
+	return sendPage(c, page.Items, page.Total)
+}
+
+func (s *StreamService) createTag(c *fiber.Ctx) error {
+	var request tagRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid request body")
+	}
+
+	tag, err := s.tagsService.CreateTag(request.Name)
+	if err != nil {
+		return sendCollectionError(c, err, "Error creating tag")
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(tag)
+}
+
+func (s *StreamService) renameTag(c *fiber.Ctx) error {
+	tagId, err := strconv.Atoi(c.Params("tagId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid tag ID")
+	}
+
+	var request tagRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid request body")
+	}
+
+	tag, err := s.tagsService.RenameTag(tagId, request.Name)
+	if err != nil {
+		return sendCollectionError(c, err, "Error renaming tag")
+	}
+
+	return c.JSON(tag)
+}
+
+func (s *StreamService) deleteTag(c *fiber.Ctx) error {
+	tagId, err := strconv.Atoi(c.Params("tagId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid tag ID")
+	}
+
+	if err := s.tagsService.DeleteTag(tagId); err != nil {
+		return sendCollectionError(c, err, "Error deleting tag")
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// setMediaItemTags replaces the tags set by hand on the media item
+func (s *StreamService) setMediaItemTags(c *fiber.Ctx) error {
+	itemId, err := strconv.Atoi(c.Params("itemId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid item ID")
+	}
+
+	var request mediaItemTagsRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid request body")
+	}
+
+	tags, err := s.tagsService.SetMediaItemTags(itemId, request.Tags)
+	if err != nil {
+		return sendCollectionError(c, err, "Error setting tags")
+	}
+
+	return c.JSON(tags)
+}
+
+func (s *StreamService) listCollections(c *fiber.Ctx) error {
+	page, err := s.collectionsService.ListCollections(listOptions(c))
+	if err != nil {
+		return sendListError(c, err, "Error listing collections")
+	}
+
+	return sendPage(c, page.Items, page.Total)
+}
+
+func (s *StreamService) getCollection(c *fiber.Ctx) error {
+	collectionId, err := strconv.Atoi(c.Params("collectionId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid collection ID")
+	}
+
+	collection, err := s.collectionsService.GetCollection(collectionId)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("Error retrieving collection")
+	}
+	if collection == nil {
+		return c.Status(fiber.StatusNotFound).SendString("Collection not found")
+	}
+
+	return c.JSON(collection)
+}
+
+// listCollectionItems lists the media items of the collection like the
+// /files route, in the collection's order unless sorted otherwise
+func (s *StreamService) listCollectionItems(c *fiber.Ctx) error {
+	collectionId, err := strconv.Atoi(c.Params("collectionId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid collection ID")
+	}
+
+	collection, err := s.collectionsService.GetCollection(collectionId)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("Error retrieving collection")
+	}
+	if collection == nil {
+		return c.Status(fiber.StatusNotFound).SendString("Collection not found")
+	}
+
+	page, err := s.collectionsService.ListCollectionItems(collectionId, listOptions(c))
+	if err != nil {
+		return sendListError(c, err, "Error listing collection items")
+	}
+
+	files, err := s.mediaItemFiles(c, page.Items)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("Error retrieving folder")
+	}
+	return sendPage(c, files, page.Total)
+}
+
+func (s *StreamService) createCollection(c *fiber.Ctx) error {
+	var request collectionRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid request body")
+	}
+
+	collection, err := s.collectionsService.CreateCollection(request.Name, request.Description)
+	if err != nil {
+		return sendCollectionError(c, err, "Error creating collection")
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(collection)
+}
+
+func (s *StreamService) updateCollection(c *fiber.Ctx) error {
+	collectionId, err := strconv.Atoi(c.Params("collectionId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid collection ID")
+	}
+
+	var request collectionRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid request body")
+	}
+
+	collection, err := s.collectionsService.UpdateCollection(collectionId, request.Name, request.Description)
+	if err != nil {
+		return sendCollectionError(c, err, "Error updating collection")
+	}
+
+	return c.JSON(collection)
+}
+
+func (s *StreamService) deleteCollection(c *fiber.Ctx) error {
+	collectionId, err := strconv.Atoi(c.Params("collectionId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid collection ID")
+	}
+
+	if err := s.collectionsService.DeleteCollection(collectionId); err != nil {
+		return sendCollectionError(c, err, "Error deleting collection")
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// setCollectionItems replaces the media items of the collection, in the
+// order given
+func (s *StreamService) setCollectionItems(c *fiber.Ctx) error {
+	collectionId, err := strconv.Atoi(c.Params("collectionId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid collection ID")
+	}
+
+	var request collectionItemsRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid request body")
+	}
+
+	collection, err := s.collectionsService.SetCollectionItems(collectionId, request.MediaItemIDs)
+	if err != nil {
+		return sendCollectionError(c, err, "Error updating collection")
+	}
+
+	return c.JSON(collection)
+}
+
+func (s *StreamService) addCollectionItem(c *fiber.Ctx) error {
+	collectionId, err := strconv.Atoi(c.Params("collectionId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid collection ID")
+	}
+
+	var request collectionItemRequest
+	if err := c.BodyParser(&request); err != nil || request.MediaItemID == 0 {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid request body")
+	}
+	position := -1
+	if request.Position != nil {
+		position = *request.Position
+	}
+
+	collection, err := s.collectionsService.AddCollectionItem(collectionId, request.MediaItemID, position)
+	if err != nil {
+		return sendCollectionError(c, err, "Error updating collection")
+	}
+
+	return c.JSON(collection)
+}
+
+func (s *StreamService) removeCollectionItem(c *fiber.Ctx) error {
+	collectionId, err := strconv.Atoi(c.Params("collectionId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid collection ID")
+	}
+	itemId, err := strconv.Atoi(c.Params("itemId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid item ID")
+	}
+
+	collection, err := s.collectionsService.RemoveCollectionItem(collectionId, itemId)
+	if err != nil {
+		return sendCollectionError(c, err, "Error updating collection")
+	}
+
+	return c.JSON(collection)
+}
+
+func sendCollectionError(c *fiber.Ctx, err error, message string) error {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return c.Status(fiber.StatusNotFound).SendString("Not found")
+	case errors.Is(err, ErrInvalidTag), errors.Is(err, ErrInvalidCollection):
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	default:
+		return c.Status(fiber.StatusInternalServerError).SendString(message)
+	}
+}
@@ -0,0 +1,252 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"localflix-server/src/models"
+	"localflix-server/src/repositories"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+// ErrInvalidCollection is returned for collection changes that can't be
+// made, the wrapping error tells why
+var ErrInvalidCollection = errors.New("invalid collection")
+
+type CollectionsService struct {
+	ctx                   context.Context
+	db                    *sql.DB
+	collectionsRepository *repositories.CollectionsRepository
+	mediaItemsRepository  *repositories.MediaItemsRepository
+	logger                *slog.Logger
+}
+
+// NewCollectionsService creates a new CollectionsService struct
+func NewCollectionsService(ctx context.Context, db *sql.DB, logger *slog.Logger) *CollectionsService {
+	return &CollectionsService{
+		ctx:                   ctx,
+		db:                    db,
+		collectionsRepository: repositories.NewCollectionsRepository(db),
+		mediaItemsRepository:  repositories.NewMediaItemsRepository(db),
+		logger:                logger,
+	}
+}
+
+// ListCollections pages the collections, they sort by name
+func (c *CollectionsService) ListCollections(options models.ListOptions) (*models.CollectionPage, error) {
+	query, err := parseListOptions(options, []string{models.SortName, models.SortAddedAt}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	collections, err := c.collectionsRepository.ListCollections()
+	if err != nil {
+		c.logger.Error("listing collections", "err", err)
+		return nil, err
+	}
+
+	result := make([]models.Collection, len(collections))
+	for i, collection := range collections {
+		result[i] = *collection
+	}
+	return &models.CollectionPage{
+		Items: pageOf(result, query, map[string]func(a, b models.Collection) int{
+			models.SortName:    func(a, b models.Collection) int { return compareFold(a.Name, b.Name) },
+			models.SortAddedAt: func(a, b models.Collection) int { return a.CreatedAt.Compare(b.CreatedAt) },
+		}),
+		Total: len(result),
+	}, nil
+}
+
+// GetCollection returns nil for an unknown collection
+func (c *CollectionsService) GetCollection(id int) (*models.Collection, error) {
+	collection, err := c.collectionsRepository.GetCollection(id)
+	if err != nil {
+		c.logger.Error("getting collection", "id", id, "err", err)
+		return nil, err
+	}
+
+	return collection, nil
+}
+
+func (c *CollectionsService) CreateCollection(name string, description string) (*models.Collection, error) {
+	name, err := c.checkName(name, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	collection, err := c.collectionsRepository.CreateCollection(name, strings.TrimSpace(description))
+	if err != nil {
+		c.logger.Error("creating collection", "name", name, "err", err)
+		return nil, err
+	}
+
+	c.logger.Info("collection created", "id", collection.ID, "name", collection.Name)
+	return collection, nil
+}
+
+// UpdateCollection renames the collection and replaces its description.
+// Returns sql.ErrNoRows for an unknown collection.
+func (c *CollectionsService) UpdateCollection(id int, name string, description string) (*models.Collection, error) {
+	name, err := c.checkName(name, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.collectionsRepository.UpdateCollection(id, name, strings.TrimSpace(description)); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			c.logger.Error("updating collection", "id", id, "err", err)
+		}
+		return nil, err
+	}
+
+	return c.GetCollection(id)
+}
+
+// DeleteCollection deletes the collection, its media items stay in the
+// library. Returns sql.ErrNoRows for an unknown collection.
+func (c *CollectionsService) DeleteCollection(id int) error {
+	if err := c.collectionsRepository.DeleteCollection(id); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			c.logger.Error("deleting collection", "id", id, "err", err)
+		}
+		return err
+	}
+
+	c.logger.Info("collection deleted", "id", id)
+	return nil
+}
+
+// ListCollectionItems pages the media items of the collection, in the
+// collection's order unless sorted otherwise
+func (c *CollectionsService) ListCollectionItems(id int, options models.ListOptions) (*models.MediaItemPage, error) {
+	query, err := parseListOptions(options, mediaItemSorts, mediaItemFilters)
+	if err != nil {
+		return nil, err
+	}
+
+	items, total, err := c.collectionsRepository.ListItems(id, query)
+	if err != nil {
+		c.logger.Error("listing collection items", "collection_id", id, "err", err)
+		return nil, err
+	}
+
+	result := make([]models.MediaItem, len(items))
+	for i, item := range items {
+		result[i] = *item
+	}
+	return &models.MediaItemPage{Items: result, Total: total}, nil
+}
+
+// SetCollectionItems replaces the media items of the collection, in the
+// given order. Returns sql.ErrNoRows for an unknown collection.
+func (c *CollectionsService) SetCollectionItems(id int, mediaItemIds []int) (*models.Collection, error) {
+	return c.updateItems(id, func(ids []int) ([]int, error) {
+		return mediaItemIds, nil
+	})
+}
+
+// AddCollectionItem inserts the media item at position in the collection,
+// or appends it when position is negative or past the end. An item already
+// in the collection is moved.
+func (c *CollectionsService) AddCollectionItem(id int, mediaItemId int, position int) (*models.Collection, error) {
+	return c.updateItems(id, func(ids []int) ([]int, error) {
+		ids = slices.DeleteFunc(ids, func(id int) bool { return id == mediaItemId })
+		if position < 0 || position > len(ids) {
+			position = len(ids)
+		}
+		return slices.Insert(ids, position, mediaItemId), nil
+	})
+}
+
+// RemoveCollectionItem removes the media item from the collection. Returns
+// sql.ErrNoRows when the collection doesn't hold it.
+func (c *CollectionsService) RemoveCollectionItem(id int, mediaItemId int) (*models.Collection, error) {
+	return c.updateItems(id, func(ids []int) ([]int, error) {
+		index := slices.Index(ids, mediaItemId)
+		if index == -1 {
+			return nil, sql.ErrNoRows
+		}
+		return slices.Delete(ids, index, index+1), nil
+	})
+}
+
+// updateItems replaces the items of the collection with what update makes of
+// them, in one transaction, after checking every item exists once
+func (c *CollectionsService) updateItems(id int, update func(ids []int) ([]int, error)) (*models.Collection, error) {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	collectionsRepository := repositories.NewCollectionsRepository(tx)
+	collection, err := collectionsRepository.GetCollection(id)
+	if err != nil {
+		c.logger.Error("getting collection", "id", id, "err", err)
+		return nil, err
+	}
+	if collection == nil {
+		return nil, sql.ErrNoRows
+	}
+
+	ids, err := collectionsRepository.ListItemIds(id)
+	if err != nil {
+		c.logger.Error("listing collection items", "collection_id", id, "err", err)
+		return nil, err
+	}
+	ids, err = update(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	mediaItemsRepository := repositories.NewMediaItemsRepository(tx)
+	seen := map[int]bool{}
+	for _, mediaItemId := range ids {
+		if seen[mediaItemId] {
+			return nil, fmt.Errorf("%w: media item %d is listed twice", ErrInvalidCollection, mediaItemId)
+		}
+		seen[mediaItemId] = true
+
+		if _, err := mediaItemsRepository.GetMediaItem(mediaItemId); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, fmt.Errorf("%w: unknown media item %d", ErrInvalidCollection, mediaItemId)
+			}
+			c.logger.Error("getting media item", "id", mediaItemId, "err", err)
+			return nil, err
+		}
+	}
+
+	if err := collectionsRepository.ReplaceItems(id, ids); err != nil {
+		c.logger.Error("replacing collection items", "collection_id", id, "err", err)
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		c.logger.Error("replacing collection items", "collection_id", id, "err", err)
+		return nil, err
+	}
+
+	return c.GetCollection(id)
+}
+
+// checkName trims the name and checks no other collection has it
+func (c *CollectionsService) checkName(name string, id int) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("%w: name is required", ErrInvalidCollection)
+	}
+
+	existing, err := c.collectionsRepository.GetCollectionByName(name)
+	if err != nil {
+		c.logger.Error("getting collection by name", "err", err)
+		return "", err
+	}
+	if existing != nil && existing.ID != id {
+		return "", fmt.Errorf("%w: collection %q already exists", ErrInvalidCollection, existing.Name)
+	}
+
+	return name, nil
+}
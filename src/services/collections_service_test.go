@@ -0,0 +1,315 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"localflix-server/src/logging"
+	"localflix-server/src/models"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// taggedLibraryFiles are three movies, Inception with an NFO file giving its
+// genres
+var taggedLibraryFiles = []string{
+	"Iron Man (2008).mkv",
+	"Thor (2011).mkv",
+	"Inception (2010).mkv",
+	"Inception (2010).nfo=<movie><title>Inception</title><genre>Sci-Fi</genre><genre>Thriller</genre></movie>",
+}
+
+func tagNames(tags []models.Tag) []string {
+	var names []string
+	for _, tag := range tags {
+		names = append(names, tag.Kind+":"+tag.Name)
+	}
+	return names
+}
+
+func TestTags(t *testing.T) {
+	library, folder := newScannedLibrary(t, taggedLibraryFiles...)
+	ids := mediaItemIDs(t, library.db, folder.ID)
+	tags := NewTagsService(context.Background(), library.db, logging.Discard())
+	mediaItems := NewMediaItemsService(context.Background(), library.db, logging.Discard())
+
+	genres, err := tags.ListTags(models.TagKindGenre, models.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tagNames(genres.Items); !slices.Equal(got, []string{"genre:Sci-Fi", "genre:Thriller"}) || genres.Items[0].ItemCount != 1 {
+		t.Errorf("got genres %v, want the genres of the NFO file", genres.Items)
+	}
+
+	marvel, err := tags.CreateTag(" Marvel ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tags.CreateTag("marvel"); !errors.Is(err, ErrInvalidTag) {
+		t.Errorf("got error %v creating a tag twice, want %v", err, ErrInvalidTag)
+	}
+	if _, err := tags.SetMediaItemTags(ids["Iron Man"], []string{"MARVEL", "Favorites", " "}); err != nil {
+		t.Fatal(err)
+	}
+	itemTags, err := tags.SetMediaItemTags(ids["Thor"], []string{"Marvel"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tagNames(itemTags); !slices.Equal(got, []string{"tag:Marvel"}) {
+		t.Errorf("got tags %v, want the existing Marvel tag", got)
+	}
+	itemTags, err = tags.SetMediaItemTags(ids["Inception"], []string{"Favorites"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tagNames(itemTags); !slices.Equal(got, []string{"genre:Sci-Fi", "genre:Thriller", "tag:Favorites"}) {
+		t.Errorf("got tags %v, want the genres kept", got)
+	}
+	if _, err := tags.SetMediaItemTags(12345, []string{"Marvel"}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("got error %v tagging an unknown item, want %v", err, sql.ErrNoRows)
+	}
+
+	filters := []struct {
+		filters []string
+		want    []string
+	}{
+		{[]string{"tag:marvel"}, []string{"Iron Man", "Thor"}},
+		{[]string{"tag:favorites", "tag:marvel"}, []string{"Iron Man"}},
+		{[]string{"tag:thriller"}, []string{"Inception"}},
+		{[]string{"genre:thriller"}, []string{"Inception"}},
+		{[]string{"genre:marvel"}, nil},
+	}
+	for _, test := range filters {
+		page, err := mediaItems.ListMediaItems(folder.ID, models.ListOptions{Sort: models.SortName, Filters: test.filters})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, item := range page.Items {
+			got = append(got, item.Release.Title)
+		}
+		if !slices.Equal(got, test.want) {
+			t.Errorf("%v: got %v, want %v", test.filters, got, test.want)
+		}
+	}
+
+	if _, err := tags.RenameTag(marvel.ID, "Favorites"); !errors.Is(err, ErrInvalidTag) {
+		t.Errorf("got error %v renaming to a taken name, want %v", err, ErrInvalidTag)
+	}
+	renamed, err := tags.RenameTag(marvel.ID, "MCU")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renamed.Name != "MCU" || renamed.ItemCount != 2 {
+		t.Errorf("got %+v, want MCU on 2 items", renamed)
+	}
+	if err := tags.DeleteTag(genres.Items[0].ID); !errors.Is(err, ErrInvalidTag) {
+		t.Errorf("got error %v deleting a genre, want %v", err, ErrInvalidTag)
+	}
+	if err := tags.DeleteTag(marvel.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := tags.DeleteTag(marvel.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("got error %v deleting a deleted tag, want %v", err, sql.ErrNoRows)
+	}
+
+	all, err := tags.ListTags("", models.ListOptions{Sort: "-name"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tagNames(all.Items); !slices.Equal(got, []string{"genre:Thriller", "genre:Sci-Fi", "tag:Favorites"}) {
+		t.Errorf("got tags %v", got)
+	}
+	if _, err := tags.ListTags("mood", models.ListOptions{}); !errors.Is(err, ErrInvalidListOptions) {
+		t.Errorf("got error %v for an unknown kind, want %v", err, ErrInvalidListOptions)
+	}
+}
+
+func TestGenresFollowMetadata(t *testing.T) {
+	library, folder := newScannedLibrary(t, taggedLibraryFiles...)
+	ids := mediaItemIDs(t, library.db, folder.ID)
+	tags := NewTagsService(context.Background(), library.db, logging.Discard())
+
+	nfo := "<movie><title>Inception</title><genre>Action</genre></movie>"
+	if err := os.WriteFile(filepath.Join(folder.Path, "Inception (2010).nfo"), []byte(nfo), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(folder.Path, "Inception (2010).nfo"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewScanService(context.Background(), library.db, NewFakeMediaToolkit(), logging.Discard()).ScanFolder(folder.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	itemTags, err := tags.ListMediaItemTags(ids["Inception"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tagNames(itemTags); !slices.Equal(got, []string{"genre:Action"}) {
+		t.Errorf("got tags %v, want the new genres", got)
+	}
+	genres, err := tags.ListTags(models.TagKindGenre, models.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tagNames(genres.Items); !slices.Equal(got, []string{"genre:Action"}) {
+		t.Errorf("got genres %v, want genres without items skipped", got)
+	}
+}
+
+func TestCollections(t *testing.T) {
+	library, folder := newScannedLibrary(t, taggedLibraryFiles...)
+	ids := mediaItemIDs(t, library.db, folder.ID)
+	collections := NewCollectionsService(context.Background(), library.db, logging.Discard())
+
+	marvel, err := collections.CreateCollection("Marvel in order", "Watch order")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := collections.CreateCollection("MARVEL IN ORDER", ""); !errors.Is(err, ErrInvalidCollection) {
+		t.Errorf("got error %v creating a collection twice, want %v", err, ErrInvalidCollection)
+	}
+	if _, err := collections.CreateCollection(" ", ""); !errors.Is(err, ErrInvalidCollection) {
+		t.Errorf("got error %v creating a collection without name, want %v", err, ErrInvalidCollection)
+	}
+
+	itemTitles := func(options models.ListOptions) []string {
+		t.Helper()
+		page, err := collections.ListCollectionItems(marvel.ID, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var titles []string
+		for _, item := range page.Items {
+			titles = append(titles, item.Release.Title)
+		}
+		return titles
+	}
+
+	if _, err := collections.AddCollectionItem(marvel.ID, ids["Thor"], -1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := collections.AddCollectionItem(marvel.ID, ids["Iron Man"], 0); err != nil {
+		t.Fatal(err)
+	}
+	updated, err := collections.AddCollectionItem(marvel.ID, ids["Inception"], 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := itemTitles(models.ListOptions{}); !slices.Equal(got, []string{"Iron Man", "Inception", "Thor"}) || updated.ItemCount != 3 {
+		t.Errorf("got items %v, count %d", got, updated.ItemCount)
+	}
+
+	// Adding an item again moves it
+	if _, err := collections.AddCollectionItem(marvel.ID, ids["Iron Man"], 99); err != nil {
+		t.Fatal(err)
+	}
+	if got := itemTitles(models.ListOptions{}); !slices.Equal(got, []string{"Inception", "Thor", "Iron Man"}) {
+		t.Errorf("got items %v after moving Iron Man last", got)
+	}
+	if got := itemTitles(models.ListOptions{Sort: models.SortName}); !slices.Equal(got, []string{"Inception", "Iron Man", "Thor"}) {
+		t.Errorf("got items %v sorted by name", got)
+	}
+	if got := itemTitles(models.ListOptions{Offset: 1, Limit: 1}); !slices.Equal(got, []string{"Thor"}) {
+		t.Errorf("got page %v", got)
+	}
+
+	if _, err := collections.RemoveCollectionItem(marvel.ID, ids["Inception"]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := collections.RemoveCollectionItem(marvel.ID, ids["Inception"]); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("got error %v removing an item twice, want %v", err, sql.ErrNoRows)
+	}
+	if _, err := collections.SetCollectionItems(marvel.ID, []int{ids["Iron Man"], ids["Iron Man"]}); !errors.Is(err, ErrInvalidCollection) {
+		t.Errorf("got error %v for a repeated item, want %v", err, ErrInvalidCollection)
+	}
+	if _, err := collections.SetCollectionItems(marvel.ID, []int{12345}); !errors.Is(err, ErrInvalidCollection) {
+		t.Errorf("got error %v for an unknown item, want %v", err, ErrInvalidCollection)
+	}
+	if _, err := collections.SetCollectionItems(12345, nil); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("got error %v for an unknown collection, want %v", err, sql.ErrNoRows)
+	}
+	if _, err := collections.SetCollectionItems(marvel.ID, []int{ids["Iron Man"], ids["Thor"]}); err != nil {
+		t.Fatal(err)
+	}
+	if got := itemTitles(models.ListOptions{}); !slices.Equal(got, []string{"Iron Man", "Thor"}) {
+		t.Errorf("got items %v after reordering", got)
+	}
+
+	renamed, err := collections.UpdateCollection(marvel.ID, "MCU", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renamed.Name != "MCU" || renamed.Description != "" || renamed.ItemCount != 2 {
+		t.Errorf("got %+v after renaming", renamed)
+	}
+	if _, err := collections.UpdateCollection(12345, "Other", ""); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("got error %v updating an unknown collection, want %v", err, sql.ErrNoRows)
+	}
+
+	if err := collections.DeleteCollection(marvel.ID); err != nil {
+		t.Fatal(err)
+	}
+	page, err := collections.ListCollections(models.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 0 {
+		t.Errorf("got collections %+v after deleting", page.Items)
+	}
+	if deleted, err := collections.GetCollection(marvel.ID); err != nil || deleted != nil {
+		t.Errorf("got %+v, %v after deleting, want nil", deleted, err)
+	}
+}
+
+func TestCollectionRoutes(t *testing.T) {
+	library, folder := newScannedLibrary(t, taggedLibraryFiles...)
+	ids := mediaItemIDs(t, library.db, folder.ID)
+	collections := NewCollectionsService(context.Background(), library.db, logging.Discard())
+	collection, err := collections.CreateCollection("Marvel", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := collections.SetCollectionItems(collection.ID, []int{ids["Thor"], ids["Iron Man"]}); err != nil {
+		t.Fatal(err)
+	}
+	app := newTestStreamApp(t, library, NewFakeMediaToolkit())
+
+	status, body := get(t, app, "/collections/"+strconv.Itoa(collection.ID)+"/items")
+	if status != fiber.StatusOK {
+		t.Fatalf("got %d %s", status, body)
+	}
+	var files []models.File
+	if err := json.Unmarshal([]byte(body), &files); err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || files[0].Title != "Thor" || files[1].Title != "Iron Man" || files[0].URL == "" {
+		t.Errorf("got %s, want the items in the collection's order", body)
+	}
+
+	if status, body := get(t, app, "/collections"); status != fiber.StatusOK || !json.Valid([]byte(body)) {
+		t.Errorf("got %d %s", status, body)
+	}
+	if status, _ := get(t, app, "/collections/12345/items"); status != fiber.StatusNotFound {
+		t.Errorf("got status %d for an unknown collection, want 404", status)
+	}
+	status, body = get(t, app, "/tags?kind=genre")
+	var tags []models.Tag
+	if err := json.Unmarshal([]byte(body), &tags); err != nil {
+		t.Fatalf("got %d %s", status, body)
+	}
+	if got := tagNames(tags); !slices.Equal(got, []string{"genre:Sci-Fi", "genre:Thriller"}) {
+		t.Errorf("got tags %v", got)
+	}
+	if status, _ := get(t, app, "/tags?kind=mood"); status != fiber.StatusBadRequest {
+		t.Errorf("got status %d for an unknown kind, want 400", status)
+	}
+}
@@ -0,0 +1,187 @@
+package services
+
+import (
+	"localflix-server/src/models"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Patterns are tried in order on the file name without extension. Each one
+// starts at a separator or the start of the name, so the part before the
+// match is the series title and the part after it the episode title.
+var (
+	// Show.S02E05, Show S02E05E06, Show s2e5-6
+	seasonEpisodePattern = regexp.MustCompile(`(?i)(?:^|[ ._\-\[(])s(\d{1,2})[ ._-]?e(\d{1,3})(?:(?:-?e|-)(\d{1,3}))?(?:[ ._\-\])]|$)`)
+	// Show 2x05
+	crossPattern = regexp.MustCompile(`(?i)(?:^|[ ._\-\[(])(\d{1,2})x(\d{2,3})(?:[ ._\-\])]|$)`)
+	// Show.2024.01.05 for daily shows
+	datePattern = regexp.MustCompile(`(?:^|[ ._\-\[(])((?:19|20)\d{2})[ ._-](\d{2})[ ._-](\d{2})(?:[ ._\-\])]|$)`)
+	// [Group] Show - 012 [1080p], the usual anime release naming
+	absolutePattern = regexp.MustCompile(`^(?:\[[^\]]*\][ _]*)?(.+?)[ _]+-[ _]+(\d{1,4})(?:v\d)?(?:[ _\[(]|$)`)
+	// Show/Season 2/05 - Title.mkv when only the folders tell the season
+	seasonDirPattern     = regexp.MustCompile(`(?i)^(?:season|series|saison|staffel|s)[ ._-]*(\d{1,3})$`)
+	leadingNumberPattern = regexp.MustCompile(`(?i)^(?:e|ep|episode)?[ ._-]*(\d{1,3})(?:[ ._\-]|$)`)
+
+	bracketsPattern  = regexp.MustCompile(`\[[^\]]*\]`)
+	titleYearPattern = regexp.MustCompile(`^(.+?)[ ]+\(?((?:19|20)\d{2})\)?$`)
+)
+
+// ParseEpisodePath tells which episode relPath is from its name, or from the
+// folders it is in. folderName is the name of the library folder, used as the
+// series title when neither the file nor its folders name the series.
+func ParseEpisodePath(relPath string, folderName string) (*models.EpisodeInfo, bool) {
+	dirs := strings.Split(path.Dir(relPath), "/")
+	if dirs[0] == "." {
+		dirs = nil
+	}
+	name := path.Base(relPath)
+	name = strings.TrimSuffix(name, path.Ext(name))
+
+	info, prefix, suffix, ok := parseEpisodeName(name)
+	if !ok {
+		if len(dirs) == 0 {
+			return nil, false
+		}
+		season, isSeasonDir := parseSeasonDir(dirs[len(dirs)-1])
+		match := leadingNumberPattern.FindStringSubmatchIndex(name)
+		if !isSeasonDir || match == nil {
+			return nil, false
+		}
+		info = &models.EpisodeInfo{Season: season}
+		info.Number, _ = strconv.Atoi(name[match[2]:match[3]])
+		suffix = name[match[1]:]
+	}
+
+	// The season folder wins over absolute numbering, anime libraries are
+	// often split by season with episodes numbered from the first one
+	if info.AbsoluteNumber > 0 && len(dirs) > 0 {
+		if season, isSeasonDir := parseSeasonDir(dirs[len(dirs)-1]); isSeasonDir {
+			info.Season = season
+		}
+	}
+
+	info.SeriesTitle, info.SeriesYear = splitTitleYear(cleanTitle(prefix))
+	if info.SeriesTitle == "" {
+		info.SeriesTitle, info.SeriesYear = seriesTitleFromDirs(dirs, folderName)
+	}
+	if info.SeriesTitle == "" {
+		return nil, false
+	}
+	info.Title = episodeTitle(suffix)
+	return info, true
+}
+
+// parseEpisodeName matches the file name against the episode patterns,
+// returning the text before and after the match
+func parseEpisodeName(name string) (*models.EpisodeInfo, string, string, bool) {
+	if match := seasonEpisodePattern.FindStringSubmatchIndex(name); match != nil {
+		info := &models.EpisodeInfo{}
+		info.Season, _ = strconv.Atoi(name[match[2]:match[3]])
+		info.Number, _ = strconv.Atoi(name[match[4]:match[5]])
+		if match[6] >= 0 {
+			info.EndNumber, _ = strconv.Atoi(name[match[6]:match[7]])
+		}
+		if info.EndNumber <= info.Number {
+			info.EndNumber = 0
+		}
+		return info, name[:match[0]], name[match[1]:], true
+	}
+
+	if match := crossPattern.FindStringSubmatchIndex(name); match != nil {
+		info := &models.EpisodeInfo{}
+		info.Season, _ = strconv.Atoi(name[match[2]:match[3]])
+		info.Number, _ = strconv.Atoi(name[match[4]:match[5]])
+		return info, name[:match[0]], name[match[1]:], true
+	}
+
+	if match := datePattern.FindStringSubmatchIndex(name); match != nil {
+		airDate := name[match[2]:match[3]] + "-" + name[match[4]:match[5]] + "-" + name[match[6]:match[7]]
+		if date, err := time.Parse(time.DateOnly, airDate); err == nil {
+			return &models.EpisodeInfo{Season: date.Year(), AirDate: airDate}, name[:match[0]], name[match[1]:], true
+		}
+	}
+
+	if match := absolutePattern.FindStringSubmatchIndex(name); match != nil {
+		number, _ := strconv.Atoi(name[match[4]:match[5]])
+		// "Movie - 2019" is a year, not the 2019th episode
+		if len(name[match[4]:match[5]]) == 4 && number >= 1900 && number < 2100 {
+			return nil, "", "", false
+		}
+		info := &models.EpisodeInfo{Season: 1, Number: number, AbsoluteNumber: number}
+		return info, name[match[2]:match[3]], name[match[1]:], true
+	}
+
+	return nil, "", "", false
+}
+
+// parseSeasonDir reads the season number of folders like "Season 2", "S02"
+// or "Specials", which is season 0
+func parseSeasonDir(name string) (int, bool) {
+	if strings.EqualFold(name, "specials") {
+		return 0, true
+	}
+	match := seasonDirPattern.FindStringSubmatch(name)
+	if match == nil {
+		return 0, false
+	}
+
+	season, _ := strconv.Atoi(match[1])
+	return season, true
+}
+
+// seriesTitleFromDirs is the deepest folder that isn't a season folder
+func seriesTitleFromDirs(dirs []string, folderName string) (string, int) {
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if _, isSeasonDir := parseSeasonDir(dirs[i]); isSeasonDir {
+			continue
+		}
+		if title, year := splitTitleYear(cleanTitle(dirs[i])); title != "" {
+			return title, year
+		}
+	}
+
+	return splitTitleYear(cleanTitle(folderName))
+}
+
+func episodeTitle(suffix string) string {
+	return cleanTitle(suffix[:releaseDetailsStart(suffix)])
+}
+
+// cleanTitle turns a release name fragment like "[Group] Show.Name_-" into
+// "Show Name". Fragments cut before release details can end with the
+// bracket that opened them.
+func cleanTitle(s string) string {
+	s = bracketsPattern.ReplaceAllString(s, " ")
+	s = strings.NewReplacer(".", " ", "_", " ").Replace(s)
+	s = strings.Join(strings.Fields(s), " ")
+	return strings.TrimRight(strings.Trim(s, " -"), " -[(")
+}
+
+// splitTitleYear splits "Show (2019)" into "Show" and 2019. A title that is
+// only a year, like "1923", is kept as the title.
+func splitTitleYear(title string) (string, int) {
+	match := titleYearPattern.FindStringSubmatch(title)
+	if match == nil {
+		return title, 0
+	}
+
+	year, _ := strconv.Atoi(match[2])
+	return strings.Trim(match[1], " -"), year
+}
+
+// titleMatchKey identifies a title across differently written file names,
+// "The.Office.US" and "The Office (US)" are the same series
+func titleMatchKey(title string, year int) string {
+	words := strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	key := strings.Join(words, " ")
+	if year > 0 {
+		key += " " + strconv.Itoa(year)
+	}
+	return key
+}
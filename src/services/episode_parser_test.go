@@ -0,0 +1,53 @@
+package services
+
+import (
+	"localflix-server/src/models"
+	"testing"
+)
+
+func TestParseEpisodePath(t *testing.T) {
+	tests := []struct {
+		relPath string
+		want    *models.EpisodeInfo
+	}{
+		{"Show.S02E05.1080p.mkv", &models.EpisodeInfo{SeriesTitle: "Show", Season: 2, Number: 5}},
+		{"The.Office.US.S01E01.Pilot.720p.WEB-DL.x264.mkv", &models.EpisodeInfo{SeriesTitle: "The Office US", Season: 1, Number: 1, Title: "Pilot"}},
+		{"Show Name (2019) - s03e10e11 - Two Parter.mp4", &models.EpisodeInfo{SeriesTitle: "Show Name", SeriesYear: 2019, Season: 3, Number: 10, EndNumber: 11, Title: "Two Parter"}},
+		{"Show.S01E01-E02.mkv", &models.EpisodeInfo{SeriesTitle: "Show", Season: 1, Number: 1, EndNumber: 2}},
+		{"Show.S01E01-720p.mkv", &models.EpisodeInfo{SeriesTitle: "Show", Season: 1, Number: 1}},
+		{"Show 1x05 Title.avi", &models.EpisodeInfo{SeriesTitle: "Show", Season: 1, Number: 5, Title: "Title"}},
+		{"[SubGroup] Some Anime - 012 [1080p].mkv", &models.EpisodeInfo{SeriesTitle: "Some Anime", Season: 1, Number: 12, AbsoluteNumber: 12}},
+		{"Some Anime/Season 2/[SubGroup] Some Anime - 26v2 [720p].mkv", &models.EpisodeInfo{SeriesTitle: "Some Anime", Season: 2, Number: 26, AbsoluteNumber: 26}},
+		{"The.Daily.Show.2024.01.05.Guest.Name.mkv", &models.EpisodeInfo{SeriesTitle: "The Daily Show", Season: 2024, AirDate: "2024-01-05", Title: "Guest Name"}},
+		{"Show/Season 02/S02E03.mkv", &models.EpisodeInfo{SeriesTitle: "Show", Season: 2, Number: 3}},
+		{"Show (2005)/Season 1/05 - The Title.mkv", &models.EpisodeInfo{SeriesTitle: "Show", SeriesYear: 2005, Season: 1, Number: 5, Title: "The Title"}},
+		{"Show/Specials/Episode 1.mkv", &models.EpisodeInfo{SeriesTitle: "Show", Season: 0, Number: 1}},
+		{"S01E01.mkv", &models.EpisodeInfo{SeriesTitle: "TV", Season: 1, Number: 1}},
+		{"Movie Title (2019).mkv", nil},
+		{"Movie - 2019.mkv", nil},
+		{"Movie.2019.1920x1080.x264.mkv", nil},
+		{"Some Film 2013.2160p.mkv", nil},
+		{"Extras/Behind the scenes.mkv", nil},
+	}
+	for _, test := range tests {
+		got, ok := ParseEpisodePath(test.relPath, "TV")
+		if test.want == nil {
+			if ok {
+				t.Errorf("ParseEpisodePath(%q) = %+v, want no episode", test.relPath, got)
+			}
+			continue
+		}
+		if !ok || *got != *test.want {
+			t.Errorf("ParseEpisodePath(%q) = %+v, want %+v", test.relPath, got, test.want)
+		}
+	}
+}
+
+func TestTitleMatchKey(t *testing.T) {
+	if a, b := titleMatchKey("The.Office.US", 0), titleMatchKey("The Office (US)", 0); a != b {
+		t.Errorf("got keys %q and %q, want the same series", a, b)
+	}
+	if a, b := titleMatchKey("Doctor Who", 1963), titleMatchKey("Doctor Who", 2005); a == b {
+		t.Errorf("got key %q for both, want the years told apart", a)
+	}
+}
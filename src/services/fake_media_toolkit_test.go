@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"localflix-server/src/models"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// FakeMediaBytesPerSecond is how many bytes of a file FakeMediaToolkit counts
+// as one second of video, so tests control durations through file sizes
+const FakeMediaBytesPerSecond = 1000
+
+// FakeMediaToolkit is a deterministic MediaToolkit for tests. It never runs
+// ffmpeg, every output is derived from the input file's name and content.
+type FakeMediaToolkit struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func NewFakeMediaToolkit() *FakeMediaToolkit {
+	return &FakeMediaToolkit{}
+}
+
+// Calls lists the calls made so far, as "Method path"
+func (f *FakeMediaToolkit) Calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]string{}, f.calls...)
+}
+
+func (f *FakeMediaToolkit) record(method string, path string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls = append(f.calls, method+" "+path)
+}
+
+func (f *FakeMediaToolkit) Status() models.MediaToolkitStatus {
+	return models.MediaToolkitStatus{Available: true, Version: "fake"}
+}
+
+func (f *FakeMediaToolkit) Probe(path string) (*models.MediaProbe, error) {
+	f.record("Probe", path)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.MediaProbe{
+		FormatName: "fake",
+		Duration:   float64(info.Size()) / FakeMediaBytesPerSecond,
+		Streams: []models.MediaStream{
+			{Index: 0, CodecType: "video", CodecName: "h264", Width: 1920, Height: 1080},
+			{Index: 1, CodecType: "audio", CodecName: "aac", Language: "eng"},
+		},
+	}, nil
+}
+
+func (f *FakeMediaToolkit) Thumbnail(videoPath string, thumbnailPath string, position float64) error {
+	f.record("Thumbnail", videoPath)
+	if _, err := os.Stat(videoPath); err != nil {
+		return err
+	}
+
+	return os.WriteFile(thumbnailPath, []byte(fmt.Sprintf("thumbnail of %s at %.3f", filepath.Base(videoPath), position)), 0o644)
+}
+
+func (f *FakeMediaToolkit) ExtractSubtitles(videoPath string, outputDir string, name string) (string, error) {
+	f.record("ExtractSubtitles", videoPath)
+	if _, err := os.Stat(videoPath); err != nil {
+		return "", err
+	}
+
+	vttPath := filepath.Join(outputDir, name+".vtt")
+	content := fmt.Sprintf("WEBVTT\n\n00:00:00.000 --> 00:00:01.000\n%s\n", filepath.Base(videoPath))
+	return vttPath, os.WriteFile(vttPath, []byte(content), 0o644)
+}
+
+// Transcode copies the video unchanged, skipping Start seconds worth of bytes
+func (f *FakeMediaToolkit) Transcode(ctx context.Context, videoPath string, options models.TranscodeOptions, w io.Writer) error {
+	f.record("Transcode", videoPath)
+	file, err := os.Open(videoPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := file.Seek(int64(options.Start*FakeMediaBytesPerSecond), io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, file); err != nil {
+		return err
+	}
+
+	return ctx.Err()
+}
+
+// TranscodeParts copies the parts unchanged one after the other, skipping
+// Start seconds worth of bytes
+func (f *FakeMediaToolkit) TranscodeParts(ctx context.Context, videoPaths []string, options models.TranscodeOptions, w io.Writer) error {
+	f.record("TranscodeParts", strings.Join(videoPaths, " "))
+	return copyParts(ctx, videoPaths, options, w)
+}
+
+// TranscodeSegment copies Duration seconds worth of bytes of the parts from
+// Start, unchanged
+func (f *FakeMediaToolkit) TranscodeSegment(ctx context.Context, videoPaths []string, options models.TranscodeOptions, w io.Writer) error {
+	f.record("TranscodeSegment", strings.Join(videoPaths, " "))
+	return copyParts(ctx, videoPaths, options, w)
+}
+
+func copyParts(ctx context.Context, videoPaths []string, options models.TranscodeOptions, w io.Writer) error {
+	var readers []io.Reader
+	for _, videoPath := range videoPaths {
+		file, err := os.Open(videoPath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		readers = append(readers, file)
+	}
+
+	var parts io.Reader = io.MultiReader(readers...)
+	if _, err := io.CopyN(io.Discard, parts, int64(options.Start*FakeMediaBytesPerSecond)); err != nil {
+		return err
+	}
+	if options.Duration > 0 {
+		parts = io.LimitReader(parts, int64(options.Duration*FakeMediaBytesPerSecond))
+	}
+	if _, err := io.Copy(w, parts); err != nil {
+		return err
+	}
+
+	return ctx.Err()
+}
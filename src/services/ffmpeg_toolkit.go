@@ -0,0 +1,348 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"localflix-server/src/models"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+const (
+	// EnvFFmpeg and EnvFFprobe point to the binaries when they aren't on the
+	// PATH
+	EnvFFmpeg  = "LOCALFLIX_FFMPEG"
+	EnvFFprobe = "LOCALFLIX_FFPROBE"
+
+	minFFmpegMajorVersion = 4
+)
+
+var ffmpegVersionPattern = regexp.MustCompile(`version n?(\d+)\.(\d+)`)
+
+// FFmpegToolkit is the MediaToolkit running the ffmpeg and ffprobe binaries
+type FFmpegToolkit struct {
+	ffmpegPath  string
+	ffprobePath string
+	version     string
+	logger      *slog.Logger
+}
+
+// NewFFmpegToolkit finds ffmpeg and ffprobe and checks their version. It
+// fails when either is missing or older than ffmpeg 4.
+func NewFFmpegToolkit(logger *slog.Logger) (*FFmpegToolkit, error) {
+	ffmpegPath, err := findBinary("ffmpeg", EnvFFmpeg)
+	if err != nil {
+		return nil, err
+	}
+	ffprobePath, err := findBinary("ffprobe", EnvFFprobe)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := checkFFmpegVersion(ffmpegPath)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := checkFFmpegVersion(ffprobePath); err != nil {
+		return nil, err
+	}
+
+	return &FFmpegToolkit{
+		ffmpegPath:  ffmpegPath,
+		ffprobePath: ffprobePath,
+		version:     version,
+		logger:      logger,
+	}, nil
+}
+
+// findBinary looks for name in the path set in envVar, on the PATH, next to
+// the executable and in common install locations. Desktop apps started from
+// the Finder or a launcher don't get the shell's PATH, so Homebrew installs
+// wouldn't be found otherwise.
+func findBinary(name string, envVar string) (string, error) {
+	if path := os.Getenv(envVar); path != "" {
+		if !isExecutable(path) {
+			return "", fmt.Errorf("%s=%s is not an executable file", envVar, path)
+		}
+		return path, nil
+	}
+
+	if path, err := exec.LookPath(name); err == nil {
+		return path, nil
+	}
+
+	fileName := name
+	if runtime.GOOS == "windows" {
+		fileName += ".exe"
+	}
+	var candidates []string
+	if executable, err := os.Executable(); err == nil {
+		candidates = append(candidates, filepath.Dir(executable))
+	}
+	candidates = append(candidates, "/opt/homebrew/bin", "/usr/local/bin", "/usr/bin", "/snap/bin")
+	for _, dir := range candidates {
+		path := filepath.Join(dir, fileName)
+		if isExecutable(path) {
+			return path, nil
+		}
+	}
+
+	return "", fmt.Errorf("%s not found, install ffmpeg or set %s", name, envVar)
+}
+
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return false
+	}
+
+	return runtime.GOOS == "windows" || info.Mode()&0o111 != 0
+}
+
+// checkFFmpegVersion runs the binary with -version and returns the first line
+// of its output. Builds from git don't have a release number and are
+// accepted as recent enough.
+func checkFFmpegVersion(path string) (string, error) {
+	output, err := exec.Command(path, "-version").Output()
+	if err != nil {
+		return "", fmt.Errorf("running %s -version: %w", path, err)
+	}
+
+	version, _, _ := strings.Cut(string(output), "\n")
+	version = strings.TrimSpace(version)
+	if err := checkVersionLine(version); err != nil {
+		return "", fmt.Errorf("%s: %w", path, err)
+	}
+
+	return version, nil
+}
+
+func checkVersionLine(version string) error {
+	match := ffmpegVersionPattern.FindStringSubmatch(version)
+	if match == nil {
+		if !strings.Contains(version, " version ") {
+			return fmt.Errorf("unexpected version output %q", version)
+		}
+		return nil
+	}
+
+	major, _ := strconv.Atoi(match[1])
+	if major < minFFmpegMajorVersion {
+		return fmt.Errorf("version %s.%s is too old, %d.0 or newer is required", match[1], match[2], minFFmpegMajorVersion)
+	}
+
+	return nil
+}
+
+func (f *FFmpegToolkit) Status() models.MediaToolkitStatus {
+	return models.MediaToolkitStatus{
+		Available:   true,
+		FFmpegPath:  f.ffmpegPath,
+		FFprobePath: f.ffprobePath,
+		Version:     f.version,
+	}
+}
+
+func (f *FFmpegToolkit) Probe(path string) (*models.MediaProbe, error) {
+	f.logger.Debug("probing", "path", path)
+	cmd := exec.Command(f.ffprobePath, "-v", "quiet", "-print_format", "json", "-show_format", "-show_streams", path)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	err := cmd.Run()
+	if err != nil {
+		return nil, fmt.Errorf("probing %s: %w", path, err)
+	}
+
+	var ffprobeOutput models.FFprobeOutput
+	err = json.Unmarshal(out.Bytes(), &ffprobeOutput)
+	if err != nil {
+		return nil, err
+	}
+
+	if ffprobeOutput.Format.Duration == "" {
+		return nil, fmt.Errorf("duration not found")
+	}
+
+	probe := &models.MediaProbe{
+		FormatName: ffprobeOutput.Format.FormatName,
+		Streams:    []models.MediaStream{},
+	}
+	fmt.Sscanf(ffprobeOutput.Format.Duration, "%f", &probe.Duration)
+	for _, stream := range ffprobeOutput.Streams {
+		probe.Streams = append(probe.Streams, models.MediaStream{
+			Index:     stream.Index,
+			CodecType: stream.CodecType,
+			CodecName: stream.CodecName,
+			Language:  stream.Tags.Language,
+			Width:     stream.Width,
+			Height:    stream.Height,
+		})
+	}
+
+	return probe, nil
+}
+
+func (f *FFmpegToolkit) Thumbnail(videoPath string, thumbnailPath string, position float64) error {
+	cmd := exec.Command(
+		f.ffmpegPath,
+		"-y",
+		"-ss", strconv.FormatFloat(position, 'f', 3, 64), // Seek before the input, it's much faster
+		"-i", videoPath,
+		"-vframes", "1", // Extract only one frame
+		"-q:v", "2", // Set image quality (lower is better)
+		thumbnailPath,
+	)
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to generate thumbnail: %w: %s", err, lastLine(output))
+	}
+	f.logger.Info("thumbnail generated", "path", videoPath, "output", thumbnailPath)
+
+	return nil
+}
+
+func (f *FFmpegToolkit) ExtractSubtitles(videoPath string, outputDir string, name string) (string, error) {
+	f.logger.Debug("extracting subtitles", "path", videoPath)
+	vttPath := filepath.Join(outputDir, name+".vtt")
+
+	// ffmpeg converts the subtitle stream to WebVTT on the way out, no
+	// intermediate .srt is needed
+	cmd := exec.Command(f.ffmpegPath, "-y", "-i", videoPath, "-map", "0:s:0", "-c:s", "webvtt", vttPath)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		os.Remove(vttPath)
+		return "", fmt.Errorf("failed to extract subtitles: %w: %s", err, lastLine(output))
+	}
+
+	f.logger.Info("subtitles extracted", "path", videoPath, "output", vttPath)
+	return vttPath, nil
+}
+
+func (f *FFmpegToolkit) Transcode(ctx context.Context, videoPath string, options models.TranscodeOptions, w io.Writer) error {
+	f.logger.Debug("transcoding", "path", videoPath, "start", options.Start, "max_height", options.MaxHeight)
+	return f.transcode(ctx, []string{"-i", videoPath}, videoPath, options, fragmentedMP4, w)
+}
+
+// TranscodeParts feeds the parts to the concat demuxer through a list file,
+// which seeks across parts like in a single file
+func (f *FFmpegToolkit) TranscodeParts(ctx context.Context, videoPaths []string, options models.TranscodeOptions, w io.Writer) error {
+	list, err := concatList(videoPaths)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(list)
+
+	f.logger.Debug("transcoding parts", "paths", videoPaths, "start", options.Start, "max_height", options.MaxHeight)
+	return f.transcode(ctx, []string{"-f", "concat", "-safe", "0", "-i", list}, videoPaths[0], options, fragmentedMP4, w)
+}
+
+// TranscodeSegment seeks like TranscodeParts, then shifts the timestamps
+// back by Start since seeking restarts them from 0
+func (f *FFmpegToolkit) TranscodeSegment(ctx context.Context, videoPaths []string, options models.TranscodeOptions, w io.Writer) error {
+	list, err := concatList(videoPaths)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(list)
+
+	f.logger.Debug("transcoding segment", "paths", videoPaths, "start", options.Start, "duration", options.Duration, "max_height", options.MaxHeight)
+	output := []string{"-output_ts_offset", strconv.FormatFloat(options.Start, 'f', 3, 64), "-muxdelay", "0", "-f", "mpegts"}
+	return f.transcode(ctx, []string{"-f", "concat", "-safe", "0", "-i", list}, videoPaths[0], options, output, w)
+}
+
+// fragmentedMP4 is the output of the streamed transcodes, playable while it
+// is written
+var fragmentedMP4 = []string{"-movflags", "frag_keyframe+empty_moov+default_base_moof", "-f", "mp4"}
+
+// concatList writes the list file of the concat demuxer, the caller removes
+// it once done
+func concatList(videoPaths []string) (string, error) {
+	list, err := os.CreateTemp("", "localflix-parts-*.txt")
+	if err != nil {
+		return "", err
+	}
+
+	for _, videoPath := range videoPaths {
+		// Quotes are closed, escaped and reopened in the concat list syntax
+		if _, err := fmt.Fprintf(list, "file '%s'\n", strings.ReplaceAll(videoPath, "'", `'\''`)); err != nil {
+			list.Close()
+			os.Remove(list.Name())
+			return "", err
+		}
+	}
+	if err := list.Close(); err != nil {
+		os.Remove(list.Name())
+		return "", err
+	}
+
+	return list.Name(), nil
+}
+
+// transcode runs ffmpeg on the input arguments and writes the output format
+// to w, name is the video reported in errors
+func (f *FFmpegToolkit) transcode(ctx context.Context, input []string, name string, options models.TranscodeOptions, output []string, w io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	args := []string{"-v", "error"}
+	if options.Start > 0 {
+		args = append(args, "-ss", strconv.FormatFloat(options.Start, 'f', 3, 64))
+	}
+	args = append(args, input...)
+	args = append(args, "-map", "0:v:0", "-map", "0:a:0?")
+	if options.MaxHeight > 0 {
+		args = append(args, "-vf", fmt.Sprintf("scale=-2:'min(%d,ih)'", options.MaxHeight))
+	}
+	if options.Duration > 0 {
+		args = append(args, "-t", strconv.FormatFloat(options.Duration, 'f', 3, 64))
+	}
+	args = append(args, "-c:v", "libx264", "-preset", "veryfast", "-c:a", "aac", "-ac", "2")
+	args = append(args, output...)
+	args = append(args, "pipe:1")
+
+	cmd := exec.CommandContext(ctx, f.ffmpegPath, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("starting ffmpeg: %w", err)
+	}
+
+	// Copying here instead of handing w to cmd lets a failed write, like a
+	// disconnected player, kill ffmpeg instead of leaving it blocked
+	_, copyErr := io.Copy(w, bufio.NewReader(stdout))
+	if copyErr != nil {
+		cancel()
+	}
+	waitErr := cmd.Wait()
+
+	switch {
+	case copyErr != nil:
+		return fmt.Errorf("writing transcoded video: %w", copyErr)
+	case ctx.Err() != nil:
+		return ctx.Err()
+	case waitErr != nil:
+		return fmt.Errorf("transcoding %s: %w: %s", name, waitErr, lastLine(stderr.Bytes()))
+	}
+
+	return nil
+}
+
+// lastLine returns the last non-empty line of ffmpeg's output, where it puts
+// the reason it failed
+func lastLine(output []byte) string {
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
@@ -0,0 +1,44 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckVersionLine(t *testing.T) {
+	tests := []struct {
+		line string
+		ok   bool
+	}{
+		{"ffmpeg version 6.1.1-3ubuntu5 Copyright (c) 2000-2023 the FFmpeg developers", true},
+		{"ffprobe version n7.0 Copyright (c) 2007-2024 the FFmpeg developers", true},
+		{"ffmpeg version N-113465-g2ae1a5a2f4-20240127 Copyright (c) 2000-2024", true},
+		{"ffmpeg version 4.0 Copyright (c) 2000-2018 the FFmpeg developers", true},
+		{"ffmpeg version 3.4.8 Copyright (c) 2000-2020 the FFmpeg developers", false},
+		{"Usage: something else", false},
+	}
+	for _, test := range tests {
+		if err := checkVersionLine(test.line); (err == nil) != test.ok {
+			t.Errorf("checkVersionLine(%q) = %v, want ok %v", test.line, err, test.ok)
+		}
+	}
+}
+
+func TestFindBinaryFromEnvironment(t *testing.T) {
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "my-ffmpeg")
+	if err := os.WriteFile(binary, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(EnvFFmpeg, binary)
+	if path, err := findBinary("ffmpeg", EnvFFmpeg); err != nil || path != binary {
+		t.Errorf("findBinary = %q, %v, want %s", path, err, binary)
+	}
+
+	t.Setenv(EnvFFmpeg, filepath.Join(dir, "missing"))
+	if path, err := findBinary("ffmpeg", EnvFFmpeg); err == nil {
+		t.Errorf("findBinary with a missing override = %q, want an error", path)
+	}
+}
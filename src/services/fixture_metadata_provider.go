@@ -0,0 +1,164 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"localflix-server/src/models"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// EnvMetadataFixtures points to a fixture file to register as a metadata
+// provider, for trying matching without an external database
+const EnvMetadataFixtures = "LOCALFLIX_METADATA_FIXTURES"
+
+// FixtureMetadataProvider serves metadata from a JSON file, so matching can be
+// tested offline. The file looks like:
+//
+//	{
+//	  "name": "fixture",
+//	  "entries": [{
+//	    "id": "603", "title": "The Matrix", "year": 1999, "plot": "...",
+//	    "genres": ["Action"], "cast": [{"name": "Keanu Reeves", "role": "Neo"}],
+//	    "rating": 8.7, "external_ids": {"imdb": "tt0133093"},
+//	    "images": [{"kind": "poster", "url": "images/matrix-poster.jpg"}]
+//	  }]
+//	}
+//
+// Image urls are http(s) urls or paths relative to the file, inside its
+// directory.
+type FixtureMetadataProvider struct {
+	name    string
+	dir     string
+	entries []fixtureEntry
+}
+
+type fixtureFile struct {
+	Name    string         `json:"name"`
+	Entries []fixtureEntry `json:"entries"`
+}
+
+type fixtureEntry struct {
+	ID          string               `json:"id"`
+	Title       string               `json:"title"`
+	Year        int                  `json:"year"`
+	Plot        string               `json:"plot"`
+	Genres      []string             `json:"genres"`
+	Cast        []models.CastMember  `json:"cast"`
+	Rating      float64              `json:"rating"`
+	ExternalIDs map[string]string    `json:"external_ids"`
+	Images      []models.RemoteImage `json:"images"`
+}
+
+func NewFixtureMetadataProvider(path string) (*FixtureMetadataProvider, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file fixtureFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("reading metadata fixtures %s: %w", path, err)
+	}
+	if file.Name == "" {
+		file.Name = "fixture"
+	}
+
+	for i, entry := range file.Entries {
+		if entry.ID == "" {
+			return nil, fmt.Errorf("reading metadata fixtures %s: entry %d has no id", path, i)
+		}
+	}
+
+	return &FixtureMetadataProvider{name: file.Name, dir: filepath.Dir(path), entries: file.Entries}, nil
+}
+
+func (f *FixtureMetadataProvider) Name() string {
+	return f.name
+}
+
+// Search returns the entries sharing a word with the title or an external
+// id with the query
+func (f *FixtureMetadataProvider) Search(ctx context.Context, query models.MetadataQuery) ([]models.MetadataMatch, error) {
+	words := strings.Fields(titleMatchKey(query.Title, 0))
+
+	matches := []models.MetadataMatch{}
+	for _, entry := range f.entries {
+		found := query.ExternalIDs[f.name] == entry.ID
+		for idType, id := range entry.ExternalIDs {
+			found = found || (id != "" && query.ExternalIDs[idType] == id)
+		}
+		for _, word := range strings.Fields(titleMatchKey(entry.Title, 0)) {
+			found = found || slices.Contains(words, word)
+		}
+		if !found {
+			continue
+		}
+
+		matches = append(matches, models.MetadataMatch{
+			Provider:    f.name,
+			ID:          entry.ID,
+			Title:       entry.Title,
+			Year:        entry.Year,
+			Overview:    entry.Plot,
+			ExternalIDs: entry.ExternalIDs,
+		})
+	}
+
+	return matches, nil
+}
+
+func (f *FixtureMetadataProvider) Fetch(ctx context.Context, id string) (*models.Metadata, error) {
+	entry, err := f.entry(id)
+	if err != nil {
+		return nil, err
+	}
+
+	externalIds := map[string]string{f.name: entry.ID}
+	for idType, value := range entry.ExternalIDs {
+		externalIds[idType] = value
+	}
+	return &models.Metadata{
+		Source:      f.name,
+		Title:       entry.Title,
+		Year:        entry.Year,
+		Plot:        entry.Plot,
+		Rating:      entry.Rating,
+		Genres:      slices.Clone(entry.Genres),
+		Cast:        slices.Clone(entry.Cast),
+		ExternalIDs: externalIds,
+	}, nil
+}
+
+func (f *FixtureMetadataProvider) Images(ctx context.Context, id string) ([]models.RemoteImage, error) {
+	entry, err := f.entry(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return slices.Clone(entry.Images), nil
+}
+
+// OpenImage opens an image url that is a path, relative to the fixture file.
+// Paths leaving its directory are rejected.
+func (f *FixtureMetadataProvider) OpenImage(ctx context.Context, url string) (io.ReadCloser, error) {
+	if filepath.IsAbs(url) || !filepath.IsLocal(filepath.FromSlash(url)) {
+		return nil, fmt.Errorf("image path %q is outside the fixtures directory", url)
+	}
+
+	return os.Open(filepath.Join(f.dir, filepath.FromSlash(url)))
+}
+
+func (f *FixtureMetadataProvider) entry(id string) (*fixtureEntry, error) {
+	for i := range f.entries {
+		if f.entries[i].ID == id {
+			return &f.entries[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("%s id %s: %w", f.name, id, ErrMetadataNotFound)
+}
@@ -4,80 +4,118 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"localflix-server/src/appdata"
 	"localflix-server/src/models"
 	"localflix-server/src/repositories"
+	"log/slog"
 	"os"
-
-	"github.com/wailsapp/wails/v2/pkg/runtime"
+	"path/filepath"
+	"strings"
 )
 
 type FoldersService struct {
 	ctx               context.Context
+	db                *sql.DB
 	foldersRepository *repositories.FoldersRepository
+	auditService      *AuditService
+	dirs              *appdata.Dirs
+	actor             string
+	ip                string
+	logger            *slog.Logger
 }
 
 // NewApp creates a new App application struct
-func NewFoldersService(ctx context.Context, db *sql.DB) *FoldersService {
+func NewFoldersService(ctx context.Context, db *sql.DB, dirs *appdata.Dirs, logger *slog.Logger) *FoldersService {
 	return &FoldersService{
 		ctx:               ctx,
+		db:                db,
 		foldersRepository: repositories.NewFoldersRepository(db),
+		auditService:      NewAuditService(ctx, db, logger),
+		dirs:              dirs,
+		logger:            logger,
 	}
 }
 
-func (f *FoldersService) SelectFolderSource() string {
-	selectedFolderPath, err := runtime.OpenDirectoryDialog(f.ctx, runtime.OpenDialogOptions{
-		CanCreateDirectories: true,
-		Title:                "Select a source folder",
-	})
-
+// CreateFolder registers folderPath as a source of the category. The row is
+// only committed once the folder's cache dirs exist, so a failure never
+// leaves a half registered folder behind.
+func (f *FoldersService) CreateFolder(folderPath string, categoryId int) (*models.Folder, error) {
+	folderPath, err := validateFolderPath(f.foldersRepository, folderPath)
 	if err != nil {
-		fmt.Println("error/canceled selecting folder", err)
+		f.logger.Warn("rejected folder", "path", folderPath, "err", err)
+		return nil, err
 	}
 
-	return selectedFolderPath
-}
-
-func (f *FoldersService) CreateFolder(folderPath string, categoryId int) (*models.Folder, error) {
-	folder, err := f.foldersRepository.CreateFolder(folderPath, categoryId)
+	tx, err := f.db.Begin()
 	if err != nil {
-		fmt.Printf("error creating folder: %v\n", err)
+		f.logger.Error("creating folder", "path", folderPath, "err", err)
 		return nil, err
 	}
+	defer tx.Rollback()
 
-	err = os.Mkdir(fmt.Sprintf("./tmp/subtitles/%d", folder.ID), os.ModePerm)
+	folder, err := repositories.NewFoldersRepository(tx).CreateFolder(folderPath, categoryId)
 	if err != nil {
-		fmt.Printf("error creating folder: %v\n", err)
+		f.logger.Error("creating folder", "path", folderPath, "err", err)
 		return nil, err
 	}
 
-	err = os.Mkdir(fmt.Sprintf("./tmp/thumbnails/%d", folder.ID), os.ModePerm)
-	if err != nil {
-		fmt.Printf("error creating folder: %v\n", err)
+	committed := false
+	defer func() {
+		if !committed {
+			removeFolderCaches(f.dirs, folder.ID)
+		}
+	}()
+
+	for _, dir := range []string{f.dirs.FolderSubtitles(folder.ID), f.dirs.FolderThumbnails(folder.ID)} {
+		// Leftovers of a folder that had this id before are stale
+		if err := os.RemoveAll(dir); err != nil {
+			f.logger.Error("creating folder", "path", folderPath, "err", err)
+			return nil, err
+		}
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			f.logger.Error("creating folder", "path", folderPath, "err", err)
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		f.logger.Error("creating folder", "path", folderPath, "err", err)
 		return nil, err
 	}
+	committed = true
+	f.logger.Info("folder added", "id", folder.ID, "path", folder.Path, "category_id", categoryId)
 
+	f.auditService.Record(models.AuditEvent{
+		Action:     models.AuditFolderAdd,
+		Actor:      f.auditActor(),
+		IP:         f.ip,
+		TargetType: "folder",
+		TargetID:   folder.ID,
+		Details:    folder.Path,
+	})
 	return folder, nil
 }
 
 func (f *FoldersService) DeleteFolder(id int) error {
 	err := f.foldersRepository.DeleteFolder(id)
 	if err != nil {
-		fmt.Printf("error deleting folder: %v\n", err)
-		return err
-	}
-
-	err = os.RemoveAll(fmt.Sprintf("./tmp/subtitles/%d", id))
-	if err != nil {
-		fmt.Printf("error excluding folder: %v\n", err)
+		f.logger.Error("deleting folder", "id", id, "err", err)
 		return err
 	}
 
-	err = os.RemoveAll(fmt.Sprintf("./tmp/thumbnails/%d", id))
+	err = removeFolderCaches(f.dirs, id)
 	if err != nil {
-		fmt.Printf("error excluding folder: %v\n", err)
+		f.logger.Error("removing folder caches", "id", id, "err", err)
 		return err
 	}
 
+	f.auditService.Record(models.AuditEvent{
+		Action:     models.AuditFolderRemove,
+		Actor:      f.auditActor(),
+		IP:         f.ip,
+		TargetType: "folder",
+		TargetID:   id,
+	})
 	return nil
 }
 
@@ -90,10 +128,15 @@ func (f *FoldersService) ListFolders() []models.Folder {
 	return result
 }
 
+// ListFoldersPage pages the folders, they sort by path
+func (f *FoldersService) ListFoldersPage(options models.ListOptions) (*models.FolderPage, error) {
+	return pageFolders(f.ListFolders(), options)
+}
+
 func (f *FoldersService) GetFolderById(folderId int) (*models.Folder, error) {
 	folder, err := f.foldersRepository.GetFolderById(folderId)
 	if err != nil {
-		fmt.Printf("error getting folder: %v\n", err)
+		f.logger.Error("getting folder", "id", folderId, "err", err)
 		return nil, err
 	}
 
@@ -108,3 +151,111 @@ func (f *FoldersService) ListFolderByCategory(categoryId int) []models.Folder {
 	}
 	return result
 }
+
+func (f *FoldersService) ListFolderByCategoryPage(categoryId int, options models.ListOptions) (*models.FolderPage, error) {
+	return pageFolders(f.ListFolderByCategory(categoryId), options)
+}
+
+func pageFolders(folders []models.Folder, options models.ListOptions) (*models.FolderPage, error) {
+	query, err := parseListOptions(options, []string{models.SortName}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.FolderPage{
+		Items: pageOf(folders, query, map[string]func(a, b models.Folder) int{
+			models.SortName: func(a, b models.Folder) int { return compareFold(a.Path, b.Path) },
+		}),
+		Total: len(folders),
+	}, nil
+}
+
+// validateFolderPath makes sure folderPath is a readable directory that isn't
+// registered in foldersRepository yet, nor inside or around a registered
+// folder. It returns the cleaned absolute path to store.
+func validateFolderPath(foldersRepository *repositories.FoldersRepository, folderPath string) (string, error) {
+	if folderPath == "" {
+		return "", fmt.Errorf("no folder selected")
+	}
+
+	folderPath, err := filepath.Abs(folderPath)
+	if err != nil {
+		return "", err
+	}
+
+	info, err := os.Stat(folderPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("folder %s does not exist", folderPath)
+		}
+		return "", fmt.Errorf("folder %s is not accessible: %w", folderPath, err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("%s is not a folder", folderPath)
+	}
+	if _, err := os.ReadDir(folderPath); err != nil {
+		return "", fmt.Errorf("folder %s is not readable: %w", folderPath, err)
+	}
+
+	resolvedPath := resolveSymlinks(folderPath)
+	for _, folder := range foldersRepository.ListFolders() {
+		registeredPath := resolveSymlinks(folder.Path)
+		switch {
+		case registeredPath == resolvedPath:
+			return "", fmt.Errorf("folder %s is already registered", folderPath)
+		case isInside(registeredPath, resolvedPath):
+			return "", fmt.Errorf("folder %s is inside the registered folder %s", folderPath, folder.Path)
+		case isInside(resolvedPath, registeredPath):
+			return "", fmt.Errorf("folder %s contains the registered folder %s", folderPath, folder.Path)
+		}
+	}
+
+	return folderPath, nil
+}
+
+func resolveSymlinks(path string) string {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+
+	return resolved
+}
+
+// isInside reports whether path is a descendant of dir
+func isInside(dir string, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// removeFolderCaches deletes the subtitles, thumbnails and artwork generated
+// or downloaded for a folder's videos
+func removeFolderCaches(dirs *appdata.Dirs, folderId int) error {
+	err := os.RemoveAll(dirs.FolderSubtitles(folderId))
+	if err != nil {
+		return err
+	}
+
+	err = os.RemoveAll(dirs.FolderArtwork(folderId))
+	if err != nil {
+		return err
+	}
+
+	return os.RemoveAll(dirs.FolderThumbnails(folderId))
+}
+
+// WithActor returns a copy of the service that records actor and ip in the
+// audit log instead of the desktop app
+func (f FoldersService) WithActor(actor string, ip string) *FoldersService {
+	f.actor = actor
+	f.ip = ip
+	return &f
+}
+
+func (f *FoldersService) auditActor() string {
+	if f.actor == "" {
+		return models.AuditActorDesktop
+	}
+
+	return f.actor
+}
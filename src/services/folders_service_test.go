@@ -0,0 +1,130 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCreateFolder(t *testing.T) {
+	library := newTestLibrary(t)
+	category, err := library.categories.CreateCategory("Movies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := makeDir(t)
+
+	folder, err := library.folders.CreateFolder(dir, category.ID)
+	if err != nil {
+		t.Fatalf("CreateFolder: %v", err)
+	}
+	if folder.Path != dir || folder.CategoryID != category.ID {
+		t.Errorf("got folder %+v, want path %s in category %d", folder, dir, category.ID)
+	}
+	if !exists(library.dirs.FolderSubtitles(folder.ID)) || !exists(library.dirs.FolderThumbnails(folder.ID)) {
+		t.Error("folder caches were not created")
+	}
+
+	folders := library.folders.ListFolderByCategory(category.ID)
+	if len(folders) != 1 || folders[0].ID != folder.ID {
+		t.Errorf("ListFolderByCategory = %+v, want the new folder", folders)
+	}
+}
+
+func TestCreateFolderRejectsInvalidPaths(t *testing.T) {
+	library := newTestLibrary(t)
+	category, err := library.categories.CreateCategory("Movies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := makeDir(t, "file.mkv", "nested/inner/video.mp4")
+	if _, err := library.folders.CreateFolder(filepath.Join(root, "nested"), category.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		path string
+	}{
+		{"empty", ""},
+		{"missing", filepath.Join(root, "missing")},
+		{"file", filepath.Join(root, "file.mkv")},
+		{"registered", filepath.Join(root, "nested")},
+		{"registered with trailing slash", filepath.Join(root, "nested") + string(filepath.Separator)},
+		{"inside registered", filepath.Join(root, "nested", "inner")},
+		{"around registered", root},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if folder, err := library.folders.CreateFolder(test.path, category.ID); err == nil {
+				t.Errorf("CreateFolder(%q) = %+v, want an error", test.path, folder)
+			}
+		})
+	}
+
+	if folders := library.folders.ListFolders(); len(folders) != 1 {
+		t.Errorf("got %d folders, want only the first one", len(folders))
+	}
+}
+
+func TestCreateFolderUnknownCategoryLeavesNothingBehind(t *testing.T) {
+	library := newTestLibrary(t)
+
+	if _, err := library.folders.CreateFolder(makeDir(t), 42); err == nil {
+		t.Fatal("CreateFolder in a missing category succeeded")
+	}
+
+	if folders := library.folders.ListFolders(); len(folders) != 0 {
+		t.Errorf("got folders %+v, want none", folders)
+	}
+	entries, err := os.ReadDir(library.dirs.Subtitles())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("subtitles cache has %d entries, want none", len(entries))
+	}
+}
+
+func TestDeleteFolder(t *testing.T) {
+	library := newTestLibrary(t)
+	category, err := library.categories.CreateCategory("Movies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	folder, err := library.folders.CreateFolder(makeDir(t), category.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := library.folders.DeleteFolder(folder.ID); err != nil {
+		t.Fatalf("DeleteFolder: %v", err)
+	}
+
+	if folders := library.folders.ListFolders(); len(folders) != 0 {
+		t.Errorf("got folders %+v, want none", folders)
+	}
+	if exists(library.dirs.FolderSubtitles(folder.ID)) || exists(library.dirs.FolderThumbnails(folder.ID)) {
+		t.Error("folder caches were not removed")
+	}
+}
+
+func TestIsInside(t *testing.T) {
+	tests := []struct {
+		dir  string
+		path string
+		want bool
+	}{
+		{"/media", "/media/movies", true},
+		{"/media", "/media/movies/a.mkv", true},
+		{"/media", "/media", false},
+		{"/media", "/media-old", false},
+		{"/media", "/", false},
+		{"/media/movies", "/media/movies/../shows", false},
+	}
+	for _, test := range tests {
+		if got := isInside(filepath.FromSlash(test.dir), filepath.FromSlash(test.path)); got != test.want {
+			t.Errorf("isInside(%q, %q) = %v, want %v", test.dir, test.path, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"localflix-server/src/models"
+	"localflix-server/src/repositories"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// headerTotalCount tells clients of list routes how many items all pages
+// hold together
+const headerTotalCount = "X-Total-Count"
+
+// maxListLimit bounds a page, lists without a limit are returned whole
+const maxListLimit = 500
+
+// mediaItemSorts and mediaItemFilters are what lists of media items support
+var (
+	mediaItemSorts   = []string{models.SortName, models.SortAddedAt, models.SortYear, models.SortDuration, models.SortSize, models.SortLastWatched}
+	mediaItemFilters = []string{models.FilterUnwatched, models.FilterResolution, models.FilterCodec, models.FilterTag, models.FilterGenre}
+)
+
+// ErrInvalidListOptions is returned for list options the list doesn't
+// support, the wrapping error tells which
+var ErrInvalidListOptions = errors.New("invalid list options")
+
+// parseListOptions validates options against the sort fields and filters
+// the list supports
+func parseListOptions(options models.ListOptions, sorts []string, filters []string) (repositories.ListQuery, error) {
+	if options.Offset < 0 || options.Limit < 0 {
+		return repositories.ListQuery{}, fmt.Errorf("%w: offset and limit can't be negative", ErrInvalidListOptions)
+	}
+	query := repositories.ListQuery{UserID: options.UserID, Offset: options.Offset, Limit: min(options.Limit, maxListLimit)}
+
+	query.Sort, query.Descending = strings.CutPrefix(options.Sort, "-")
+	if query.Sort != "" && !slices.Contains(sorts, query.Sort) {
+		return query, fmt.Errorf("%w: unknown sort %q, expected one of %s", ErrInvalidListOptions, query.Sort, strings.Join(sorts, ", "))
+	}
+
+	for _, filter := range options.Filters {
+		name, value, _ := strings.Cut(filter, ":")
+		if !slices.Contains(filters, name) {
+			return query, fmt.Errorf("%w: unknown filter %q, expected one of %s", ErrInvalidListOptions, name, strings.Join(filters, ", "))
+		}
+		if (name == models.FilterUnwatched) != (value == "") {
+			return query, fmt.Errorf("%w: invalid filter %q", ErrInvalidListOptions, filter)
+		}
+
+		switch name {
+		case models.FilterUnwatched:
+			query.Unwatched = true
+		case models.FilterResolution:
+			query.Resolution = value
+		case models.FilterCodec:
+			query.Codec = value
+		case models.FilterTag:
+			query.Tags = append(query.Tags, value)
+		case models.FilterGenre:
+			query.Genres = append(query.Genres, value)
+		}
+	}
+
+	return query, nil
+}
+
+// pageOf sorts the whole list with the comparison of the query's sort field
+// and returns the query's page, for lists small enough to be loaded whole.
+// Without a sort the items keep their order.
+func pageOf[T any](items []T, query repositories.ListQuery, compare map[string]func(a, b T) int) []T {
+	if compare := compare[query.Sort]; compare != nil {
+		slices.SortStableFunc(items, func(a, b T) int {
+			if query.Descending {
+				return compare(b, a)
+			}
+			return compare(a, b)
+		})
+	}
+
+	start := min(query.Offset, len(items))
+	end := len(items)
+	if query.Limit > 0 {
+		end = min(start+query.Limit, end)
+	}
+	return items[start:end]
+}
+
+// compareFold compares strings ignoring case
+func compareFold(a string, b string) int {
+	return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+}
+
+// listOptions reads the offset, limit, sort and filter query params of list
+// routes. Filters can be repeated or comma separated:
+// ?filter=unwatched,resolution:1080p
+func listOptions(c *fiber.Ctx) models.ListOptions {
+	options := models.ListOptions{
+		UserID: userID(c),
+		Offset: c.QueryInt("offset"),
+		Limit:  c.QueryInt("limit"),
+		Sort:   c.Query("sort"),
+	}
+	for _, value := range c.Context().QueryArgs().PeekMulti("filter") {
+		for _, filter := range strings.Split(string(value), ",") {
+			if filter = strings.TrimSpace(filter); filter != "" {
+				options.Filters = append(options.Filters, filter)
+			}
+		}
+	}
+
+	return options
+}
+
+// sendPage sends the items of a page, with the total count in a header
+func sendPage(c *fiber.Ctx, items any, total int) error {
+	c.Set(headerTotalCount, strconv.Itoa(total))
+	return c.JSON(items)
+}
+
+// sendListError answers 400 for list options the list doesn't support
+func sendListError(c *fiber.Ctx, err error, message string) error {
+	if errors.Is(err, ErrInvalidListOptions) {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
+
+	return c.Status(fiber.StatusInternalServerError).SendString(message)
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"localflix-server/src/models"
+	"localflix-server/src/repositories"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var artworkExtensions = []string{".jpg", ".jpeg", ".png", ".webp"}
+
+// artworkNames are the Kodi file names of each artwork kind without
+// extension. "<video>-poster" applies to the video, "poster" to the only
+// video of its folder.
+var artworkNames = map[string]struct {
+	video  []string
+	folder []string
+}{
+	models.ArtworkPoster: {video: []string{"-poster"}, folder: []string{"poster", "folder", "cover"}},
+	models.ArtworkFanart: {video: []string{"-fanart"}, folder: []string{"fanart", "backdrop"}},
+	models.ArtworkLogo:   {video: []string{"-clearlogo", "-logo"}, folder: []string{"clearlogo", "logo"}},
+	models.ArtworkThumb:  {video: []string{"-thumb"}},
+}
+
+// syncLocalMetadata reads the NFO files and artwork found next to the media
+// items of the folder, in the scan's transaction. NFO files are only read
+// again when they changed, unreadable ones are returned as warnings.
+func syncLocalMetadata(tx repositories.DBTX, folder *models.Folder) ([]string, error) {
+	items, err := repositories.NewMediaItemsRepository(tx).ListMediaItemsByFolder(folder.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	videosByDir := map[string]int{}
+	for _, item := range items {
+		videosByDir[path.Dir(item.RelPath)]++
+	}
+	dirFiles := map[string]map[string]string{}
+
+	metadataRepository := repositories.NewMetadataRepository(tx)
+	var warnings []string
+	for _, item := range items {
+		relDir := path.Dir(item.RelPath)
+		dir := filepath.Join(folder.Path, filepath.FromSlash(relDir))
+		files, ok := dirFiles[relDir]
+		if !ok {
+			files = listFilesByLowerName(dir)
+			dirFiles[relDir] = files
+		}
+		stem := strings.TrimSuffix(item.Name, filepath.Ext(item.Name))
+		onlyVideo := videosByDir[relDir] == 1
+
+		nfoNames := []string{stem + ".nfo"}
+		if onlyVideo {
+			nfoNames = append(nfoNames, "movie.nfo")
+		}
+		nfoPath := findLocalFile(dir, files, nfoNames, []string{""})
+		warning, err := syncNFO(metadataRepository, item.ID, nfoPath)
+		if err != nil {
+			return nil, err
+		}
+		if warning != "" {
+			warnings = append(warnings, warning)
+		}
+
+		var artwork []models.Artwork
+		for _, kind := range models.ArtworkKinds {
+			var names []string
+			for _, suffix := range artworkNames[kind].video {
+				names = append(names, stem+suffix)
+			}
+			if onlyVideo {
+				names = append(names, artworkNames[kind].folder...)
+			}
+			if imagePath := findLocalFile(dir, files, names, artworkExtensions); imagePath != "" {
+				artwork = append(artwork, models.Artwork{MediaItemID: item.ID, Kind: kind, Path: imagePath})
+			}
+		}
+		if err := metadataRepository.ReplaceLocalArtwork(item.ID, artwork); err != nil {
+			return nil, err
+		}
+	}
+
+	return warnings, nil
+}
+
+// syncNFO stores the metadata of the NFO file at nfoPath, or removes the NFO
+// metadata of the media item when nfoPath is empty. An unreadable file is
+// returned as a warning, the error is for the database.
+func syncNFO(metadataRepository *repositories.MetadataRepository, mediaItemId int, nfoPath string) (string, error) {
+	existing, err := metadataRepository.GetMetadata(mediaItemId)
+	if err != nil {
+		return "", err
+	}
+	// A match fixed by hand wins over the NFO
+	if existing != nil && existing.Locked {
+		return "", nil
+	}
+	fromNFO := existing != nil && existing.Source == models.MetadataSourceNFO
+
+	if nfoPath == "" {
+		if fromNFO {
+			return "", metadataRepository.DeleteMetadata(mediaItemId)
+		}
+		return "", nil
+	}
+
+	info, err := os.Stat(nfoPath)
+	if err != nil {
+		return err.Error(), nil
+	}
+	modifiedAt := info.ModTime().UTC()
+	if fromNFO && existing.SourcePath == nfoPath && existing.SourceModifiedAt.Equal(modifiedAt) {
+		return "", nil
+	}
+
+	metadata, err := ReadNFO(nfoPath)
+	if err != nil {
+		return err.Error(), nil
+	}
+	metadata.MediaItemID = mediaItemId
+	metadata.SourcePath = nfoPath
+	metadata.SourceModifiedAt = modifiedAt
+	metadata.UpdatedAt = time.Now().UTC()
+	return "", metadataRepository.UpsertMetadata(*metadata)
+}
+
+// listFilesByLowerName maps the lowercased names of the files in dir to
+// their actual names, Kodi libraries copied from Windows mix cases
+func listFilesByLowerName(dir string) map[string]string {
+	files := map[string]string{}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return files
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			files[strings.ToLower(entry.Name())] = entry.Name()
+		}
+	}
+	return files
+}
+
+// findLocalFile returns the path of the first of names, with one of
+// extensions, found in files
+func findLocalFile(dir string, files map[string]string, names []string, extensions []string) string {
+	for _, name := range names {
+		for _, extension := range extensions {
+			if actual, ok := files[strings.ToLower(name+extension)]; ok {
+				return filepath.Join(dir, actual)
+			}
+		}
+	}
+
+	return ""
+}
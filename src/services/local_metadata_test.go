@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"localflix-server/src/logging"
+	"localflix-server/src/models"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestScanReadsLocalMetadata(t *testing.T) {
+	library := newTestLibrary(t)
+	category, err := library.categories.CreateCategory("Movies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := makeDir(t,
+		"The Matrix (1999)/The Matrix (1999).mkv",
+		"The Matrix (1999)/poster.jpg",
+		"The Matrix (1999)/fanart.jpg",
+		"Show.S01E01.mkv",
+		"Show.S01E01-thumb.jpg",
+		"Show.S01E02.mkv",
+		// Folder artwork only applies when the video is alone in its folder
+		"poster.jpg",
+	)
+	nfoPath := filepath.Join(dir, "The Matrix (1999)", "movie.nfo")
+	if err := os.WriteFile(nfoPath, []byte("<movie><title>The Matrix</title><year>1999</year></movie>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	folder, err := library.folders.CreateFolder(dir, category.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scan := NewScanService(context.Background(), library.db, NewFakeMediaToolkit(), logging.Discard())
+	metadataService := NewMetadataService(context.Background(), library.db, NewMetadataProviderRegistry(), library.dirs, logging.Discard())
+
+	if _, err := scan.ScanFolder(folder.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	movie := findScannedItem(t, metadataService, folder.ID, "The Matrix (1999)/The Matrix (1999).mkv")
+	if movie.Metadata == nil || movie.Metadata.Title != "The Matrix" || movie.Metadata.Source != models.MetadataSourceNFO {
+		t.Errorf("got metadata %+v, want the NFO", movie.Metadata)
+	}
+	if len(movie.Artwork) != 2 || movie.Artwork[models.ArtworkPoster] == "" || movie.Artwork[models.ArtworkFanart] == "" {
+		t.Errorf("got artwork %v, want poster and fanart", movie.Artwork)
+	}
+	episode := findScannedItem(t, metadataService, folder.ID, "Show.S01E01.mkv")
+	if len(episode.Artwork) != 1 || episode.Artwork[models.ArtworkThumb] == "" {
+		t.Errorf("got artwork %v, want only the thumb", episode.Artwork)
+	}
+
+	// Changed NFO files are read again, removed ones take their metadata
+	later := time.Now().Add(time.Hour)
+	if err := os.WriteFile(nfoPath, []byte("<movie><title>The Matrix Reloaded</title></movie>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(nfoPath, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := scan.ScanFolder(folder.ID); err != nil {
+		t.Fatal(err)
+	}
+	movie = findScannedItem(t, metadataService, folder.ID, "The Matrix (1999)/The Matrix (1999).mkv")
+	if movie.Metadata == nil || movie.Metadata.Title != "The Matrix Reloaded" {
+		t.Errorf("got metadata %+v, want the changed NFO", movie.Metadata)
+	}
+
+	if err := os.Remove(nfoPath); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := scan.ScanFolder(folder.ID); err != nil {
+		t.Fatal(err)
+	}
+	movie = findScannedItem(t, metadataService, folder.ID, "The Matrix (1999)/The Matrix (1999).mkv")
+	if movie.Metadata != nil {
+		t.Errorf("got metadata %+v of a removed NFO", movie.Metadata)
+	}
+}
+
+func TestArtworkRoutes(t *testing.T) {
+	library := newTestLibrary(t)
+	category, err := library.categories.CreateCategory("Series")
+	if err != nil {
+		t.Fatal(err)
+	}
+	folder, err := library.folders.CreateFolder(makeDir(t, "Show.S01E01.mkv", "Show.S01E01-thumb.jpg", "Show.S01E02.mkv"), category.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewScanService(context.Background(), library.db, NewFakeMediaToolkit(), logging.Discard()).ScanFolder(folder.ID); err != nil {
+		t.Fatal(err)
+	}
+	metadataService := NewMetadataService(context.Background(), library.db, NewMetadataProviderRegistry(), library.dirs, logging.Discard())
+	first := findScannedItem(t, metadataService, folder.ID, "Show.S01E01.mkv")
+	second := findScannedItem(t, metadataService, folder.ID, "Show.S01E02.mkv")
+	toolkit := NewFakeMediaToolkit()
+	app := newTestStreamApp(t, library, toolkit)
+
+	status, body := get(t, app, "/items/"+strconv.Itoa(first.MediaItem.ID))
+	var details models.MediaItemDetails
+	if status != fiber.StatusOK || json.Unmarshal([]byte(body), &details) != nil {
+		t.Fatalf("/items/:id = %d %q", status, body)
+	}
+	if !strings.HasSuffix(details.Artwork[models.ArtworkThumb], "/artwork/"+strconv.Itoa(first.MediaItem.ID)+"/thumb") {
+		t.Errorf("got artwork %v, want the thumb url", details.Artwork)
+	}
+
+	// The local thumb wins over a generated one, on both routes
+	if status, body := get(t, app, "/artwork/"+strconv.Itoa(first.MediaItem.ID)+"/thumb"); status != fiber.StatusOK || body != "Show.S01E01-thumb.jpg" {
+		t.Errorf("local thumb = %d %q", status, body)
+	}
+	if status, body := get(t, app, "/thumbnails/1/Show.S01E01.png"); status != fiber.StatusOK || body != "Show.S01E01-thumb.jpg" {
+		t.Errorf("thumbnail with a local thumb = %d %q", status, body)
+	}
+	if calls := toolkit.Calls(); len(calls) != 0 {
+		t.Errorf("got toolkit calls %v, want none", calls)
+	}
+
+	if status, body := get(t, app, "/artwork/"+strconv.Itoa(second.MediaItem.ID)+"/thumb"); status != fiber.StatusOK || !strings.Contains(body, "thumbnail of Show.S01E02.mkv") {
+		t.Errorf("generated thumb = %d %q", status, body)
+	}
+	if status, _ := get(t, app, "/artwork/"+strconv.Itoa(second.MediaItem.ID)+"/poster"); status != fiber.StatusNotFound {
+		t.Errorf("missing poster = %d, want 404", status)
+	}
+	if status, _ := get(t, app, "/artwork/"+strconv.Itoa(second.MediaItem.ID)+"/banner"); status != fiber.StatusBadRequest {
+		t.Errorf("unknown kind = %d, want 400", status)
+	}
+	if status, _ := get(t, app, "/items/999"); status != fiber.StatusNotFound {
+		t.Errorf("unknown item = %d, want 404", status)
+	}
+}
+
+func findScannedItem(t *testing.T, metadataService *MetadataService, folderId int, relPath string) *models.MediaItemDetails {
+	t.Helper()
+
+	item, err := metadataService.FindMediaItem(folderId, relPath)
+	if err != nil || item == nil {
+		t.Fatalf("FindMediaItem(%q) = %v, %v", relPath, item, err)
+	}
+	details, err := metadataService.GetMediaItemDetails(item.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return details
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"localflix-server/src/models"
+	"localflix-server/src/repositories"
+	"log/slog"
+)
+
+type MediaItemsService struct {
+	ctx                  context.Context
+	db                   *sql.DB
+	mediaItemsRepository *repositories.MediaItemsRepository
+	logger               *slog.Logger
+}
+
+// NewMediaItemsService creates a new MediaItemsService struct
+func NewMediaItemsService(ctx context.Context, db *sql.DB, logger *slog.Logger) *MediaItemsService {
+	return &MediaItemsService{
+		ctx:                  ctx,
+		db:                   db,
+		mediaItemsRepository: repositories.NewMediaItemsRepository(db),
+		logger:               logger,
+	}
+}
+
+// ListMediaItems pages the scanned media items of the folder, by path unless
+// sorted otherwise
+func (m *MediaItemsService) ListMediaItems(folderId int, options models.ListOptions) (*models.MediaItemPage, error) {
+	query, err := parseListOptions(options, mediaItemSorts, mediaItemFilters)
+	if err != nil {
+		return nil, err
+	}
+
+	items, total, err := m.mediaItemsRepository.ListMediaItemsPage(folderId, query)
+	if err != nil {
+		m.logger.Error("listing media items", "folder_id", folderId, "err", err)
+		return nil, err
+	}
+
+	result := make([]models.MediaItem, len(items))
+	for i, item := range items {
+		result[i] = *item
+	}
+	return &models.MediaItemPage{Items: result, Total: total}, nil
+}
+
+// GetMediaItemByPath returns nil when the file of the folder wasn't scanned
+func (m *MediaItemsService) GetMediaItemByPath(folderId int, relPath string) (*models.MediaItem, error) {
+	item, err := m.mediaItemsRepository.GetMediaItemByPath(folderId, relPath)
+	if err != nil {
+		m.logger.Error("getting media item by path", "folder_id", folderId, "err", err)
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// ListVersions lists the versions of the media item, itself included, in
+// any folder. It returns sql.ErrNoRows when the item doesn't exist.
+func (m *MediaItemsService) ListVersions(id int) ([]models.MediaItem, error) {
+	versions, err := m.mediaItemsRepository.ListVersions(id)
+	if err != nil {
+		m.logger.Error("listing versions", "media_item_id", id, "err", err)
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	result := make([]models.MediaItem, len(versions))
+	for i, version := range versions {
+		result[i] = *version
+	}
+	return result, nil
+}
+
+// ListParts lists the parts of the stacked media item in order, or the item
+// alone when it is a single file. It returns sql.ErrNoRows when the item
+// doesn't exist.
+func (m *MediaItemsService) ListParts(id int) ([]models.MediaItem, error) {
+	parts, err := m.mediaItemsRepository.ListParts(id)
+	if err != nil {
+		m.logger.Error("listing parts", "media_item_id", id, "err", err)
+		return nil, err
+	}
+	if len(parts) == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	result := make([]models.MediaItem, len(parts))
+	for i, part := range parts {
+		result[i] = *part
+	}
+	return result, nil
+}
+
+// ChooseVersion picks the version of the media item the client plays best,
+// and whether it must be transcoded to play at all. It returns sql.ErrNoRows
+// when the item doesn't exist.
+func (m *MediaItemsService) ChooseVersion(id int, client models.PlaybackClient) (*models.MediaItem, bool, error) {
+	versions, err := m.mediaItemsRepository.ListVersions(id)
+	if err != nil {
+		m.logger.Error("listing versions", "media_item_id", id, "err", err)
+		return nil, false, err
+	}
+	if len(versions) == 0 {
+		return nil, false, sql.ErrNoRows
+	}
+
+	version, transcode := chooseVersion(versions, client)
+	return version, transcode, nil
+}
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"localflix-server/src/logging"
+	"localflix-server/src/models"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Sizes are the length of the names
+var mediaItemFiles = []string{
+	"Zodiac.2007.720p.x264.mkv",
+	"Alien (1979).mkv",
+	"Memento.2000.1080p.BluRay.x265.mkv",
+}
+
+func TestListMediaItems(t *testing.T) {
+	library, folder := newScannedLibrary(t, mediaItemFiles...)
+	mediaItems := NewMediaItemsService(context.Background(), library.db, logging.Discard())
+	markWatched(t, library.db, 0, folder.ID, "Alien (1979).mkv")
+
+	tests := []struct {
+		name      string
+		options   models.ListOptions
+		want      []string
+		wantTotal int
+	}{
+		{"default", models.ListOptions{}, []string{"Alien", "Memento", "Zodiac"}, 3},
+		{"name", models.ListOptions{Sort: "-name"}, []string{"Zodiac", "Memento", "Alien"}, 3},
+		{"size", models.ListOptions{Sort: "-size"}, []string{"Memento", "Zodiac", "Alien"}, 3},
+		{"year", models.ListOptions{Sort: "year"}, []string{"Alien", "Memento", "Zodiac"}, 3},
+		{"last watched", models.ListOptions{Sort: "-last_watched"}, []string{"Alien", "Memento", "Zodiac"}, 3},
+		{"page", models.ListOptions{Sort: "name", Offset: 1, Limit: 1}, []string{"Memento"}, 3},
+		{"past the end", models.ListOptions{Offset: 5}, nil, 3},
+		{"unwatched", models.ListOptions{Filters: []string{"unwatched"}}, []string{"Memento", "Zodiac"}, 2},
+		{"unwatched by another user", models.ListOptions{UserID: 1, Filters: []string{"unwatched"}}, []string{"Alien", "Memento", "Zodiac"}, 3},
+		{"resolution", models.ListOptions{Filters: []string{"resolution:1080P"}}, []string{"Memento"}, 1},
+		{"codec", models.ListOptions{Filters: []string{"codec:H.264"}}, []string{"Zodiac"}, 1},
+		{"both", models.ListOptions{Filters: []string{"unwatched", "resolution:720p"}}, []string{"Zodiac"}, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			page, err := mediaItems.ListMediaItems(folder.ID, test.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, item := range page.Items {
+				got = append(got, item.Release.Title)
+			}
+			if !slices.Equal(got, test.want) || page.Total != test.wantTotal {
+				t.Errorf("got %v of %d, want %v of %d", got, page.Total, test.want, test.wantTotal)
+			}
+		})
+	}
+
+	page, err := mediaItems.ListMediaItems(folder.ID, models.ListOptions{Filters: []string{"resolution:720p"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item := page.Items[0]; item.LastWatchedAt != nil {
+		t.Errorf("got last watched %v for an unwatched item", item.LastWatchedAt)
+	}
+	page, err = mediaItems.ListMediaItems(folder.ID, models.ListOptions{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item := page.Items[0]; item.LastWatchedAt == nil {
+		t.Errorf("got no last watched time for %s", item.RelPath)
+	}
+}
+
+func TestListMediaItemsInvalidOptions(t *testing.T) {
+	library, folder := newScannedLibrary(t, mediaItemFiles...)
+	mediaItems := NewMediaItemsService(context.Background(), library.db, logging.Discard())
+
+	for _, options := range []models.ListOptions{
+		{Sort: "rating"},
+		{Sort: "-number"},
+		{Offset: -1},
+		{Filters: []string{"rating:5"}},
+		{Filters: []string{"tag:"}},
+		{Filters: []string{"unwatched:yes"}},
+		{Filters: []string{"codec"}},
+	} {
+		if _, err := mediaItems.ListMediaItems(folder.ID, options); !errors.Is(err, ErrInvalidListOptions) {
+			t.Errorf("%+v: got error %v, want %v", options, err, ErrInvalidListOptions)
+		}
+	}
+}
+
+func TestListFilesRoute(t *testing.T) {
+	library := newTestLibrary(t)
+	category, err := library.categories.CreateCategory("Movies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	folder, err := library.folders.CreateFolder(makeDir(t, "Zodiac (2007).mkv", "Alien.1979.Directors.Cut.mkv", "Memento/Memento (2000).mkv"), category.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestStreamService(t, library, NewFakeMediaToolkit())
+	app := serveTestRoutes(s)
+
+	files := "/files/" + strconv.Itoa(folder.ID)
+
+	// The folder was never scanned, listing it scans it in the background
+	if status, body := get(t, app, files); status != fiber.StatusAccepted {
+		t.Errorf("got %d %s, want 202 until the folder is scanned", status, body)
+	}
+	waitScanned(t, s, folder.ID)
+	response, err := app.Test(httptest.NewRequest("GET", files+"?sort=-size&limit=1", nil), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total := response.Header.Get(headerTotalCount); response.StatusCode != fiber.StatusOK || total != "3" {
+		t.Fatalf("got status %d with total %q, want 200 with 3", response.StatusCode, total)
+	}
+	var page []models.File
+	if err := json.Unmarshal(body, &page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 1 || page[0].Title != "Alien" || !strings.HasSuffix(page[0].URL, "/stream/"+strconv.Itoa(folder.ID)+"/Alien.1979.Directors.Cut.mkv") {
+		t.Errorf("got %s, want the biggest file", body)
+	}
+
+	if status, body := get(t, app, files+"?filter=resolution:1080p,unwatched"); status != fiber.StatusOK || body != "[]" {
+		t.Errorf("got %d %s, want an empty page", status, body)
+	}
+	if status, _ := get(t, app, files+"?sort=rating"); status != fiber.StatusBadRequest {
+		t.Errorf("got status %d for an unknown sort, want 400", status)
+	}
+}
+
+func TestListFilesOnlyScansNeverScannedFolders(t *testing.T) {
+	library := newTestLibrary(t)
+	category, err := library.categories.CreateCategory("Movies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := makeDir(t)
+	folder, err := library.folders.CreateFolder(dir, category.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestStreamService(t, library, NewFakeMediaToolkit())
+	app := serveTestRoutes(s)
+
+	files := "/files/" + strconv.Itoa(folder.ID)
+	listed := func() int {
+		t.Helper()
+		status, body := get(t, app, files)
+		var page []models.File
+		if err := json.Unmarshal([]byte(body), &page); err != nil || status != fiber.StatusOK {
+			t.Fatalf("got %d %s", status, body)
+		}
+		return len(page)
+	}
+
+	// Listing the empty folder scans it once, a file added later waits for
+	// the next rescan instead of a scan on every listing
+	if status, _ := get(t, app, files); status != fiber.StatusAccepted {
+		t.Errorf("got status %d for the first listing, want 202", status)
+	}
+	waitScanned(t, s, folder.ID)
+	if count := listed(); count != 0 {
+		t.Fatalf("got %d files in the empty folder", count)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "Zodiac (2007).mkv"), []byte("Zodiac (2007).mkv"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if count := listed(); count != 0 {
+		t.Errorf("got %d files, want the scanned folder listed without rescanning", count)
+	}
+
+	now := time.Now().UTC()
+	s.scanService.rescanStaleFolders(now)
+	if count := listed(); count != 0 {
+		t.Errorf("got %d files, want the folder rescanned only once stale", count)
+	}
+	s.scanService.rescanStaleFolders(now.Add(time.Duration(defaultRescanMinutes+1) * time.Minute))
+	if count := listed(); count != 1 {
+		t.Errorf("got %d files after the rescan, want 1", count)
+	}
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"localflix-server/src/models"
+)
+
+// ErrMediaToolkitUnavailable is returned by every MediaToolkit call when
+// ffmpeg or ffprobe couldn't be found or are too old
+var ErrMediaToolkitUnavailable = errors.New("ffmpeg is not available")
+
+// MediaToolkit inspects and converts media files. FFmpegToolkit is the real
+// implementation, FakeMediaToolkit stands in for it in tests.
+type MediaToolkit interface {
+	// Probe reads the duration and streams of a media file
+	Probe(path string) (*models.MediaProbe, error)
+	// Thumbnail writes a frame taken position seconds into the video to
+	// thumbnailPath, the image format follows its extension
+	Thumbnail(videoPath string, thumbnailPath string, position float64) error
+	// ExtractSubtitles writes the first subtitle stream to outputDir as
+	// name.vtt and returns its path
+	ExtractSubtitles(videoPath string, outputDir string, name string) (string, error)
+	// Transcode writes the video to w as fragmented MP4 until it ends, w
+	// fails or ctx is canceled
+	Transcode(ctx context.Context, videoPath string, options models.TranscodeOptions, w io.Writer) error
+	// TranscodeParts transcodes the parts of a stacked video one after the
+	// other as a single video, Start being counted from the first part
+	TranscodeParts(ctx context.Context, videoPaths []string, options models.TranscodeOptions, w io.Writer) error
+	// TranscodeSegment transcodes Duration seconds of the parts from Start as
+	// an MPEG-TS segment of an HLS playlist. Its timestamps are counted from
+	// the start of the first part, so the segments play back to back.
+	TranscodeSegment(ctx context.Context, videoPaths []string, options models.TranscodeOptions, w io.Writer) error
+	Status() models.MediaToolkitStatus
+}
+
+// unavailableMediaToolkit replaces FFmpegToolkit when the tools are missing,
+// so features needing them fail with the reason instead of the app failing
+// to start
+type unavailableMediaToolkit struct {
+	err error
+}
+
+// NewUnavailableMediaToolkit returns a MediaToolkit failing every call with
+// ErrMediaToolkitUnavailable wrapping reason
+func NewUnavailableMediaToolkit(reason error) MediaToolkit {
+	return &unavailableMediaToolkit{
+		err: fmt.Errorf("%w: %v", ErrMediaToolkitUnavailable, reason),
+	}
+}
+
+func (u *unavailableMediaToolkit) Probe(path string) (*models.MediaProbe, error) {
+	return nil, u.err
+}
+
+func (u *unavailableMediaToolkit) Thumbnail(videoPath string, thumbnailPath string, position float64) error {
+	return u.err
+}
+
+func (u *unavailableMediaToolkit) ExtractSubtitles(videoPath string, outputDir string, name string) (string, error) {
+	return "", u.err
+}
+
+func (u *unavailableMediaToolkit) Transcode(ctx context.Context, videoPath string, options models.TranscodeOptions, w io.Writer) error {
+	return u.err
+}
+
+func (u *unavailableMediaToolkit) TranscodeParts(ctx context.Context, videoPaths []string, options models.TranscodeOptions, w io.Writer) error {
+	return u.err
+}
+
+func (u *unavailableMediaToolkit) TranscodeSegment(ctx context.Context, videoPaths []string, options models.TranscodeOptions, w io.Writer) error {
+	return u.err
+}
+
+func (u *unavailableMediaToolkit) Status() models.MediaToolkitStatus {
+	return models.MediaToolkitStatus{Error: u.err.Error()}
+}
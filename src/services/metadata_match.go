@@ -0,0 +1,91 @@
+package services
+
+import (
+	"localflix-server/src/models"
+	"sort"
+)
+
+// Weights of the match confidence, the title decides most of it
+const (
+	titleWeight = 0.8
+	yearWeight  = 0.2
+)
+
+// ScoreMatch rates how likely candidate is the item described by query, from
+// 0 to 1. A shared external id is a certain match. Otherwise titles are
+// compared ignoring case and punctuation, and the year counts when both are
+// known.
+func ScoreMatch(query models.MetadataQuery, candidate models.MetadataMatch) float64 {
+	for idType, id := range query.ExternalIDs {
+		if id == "" {
+			continue
+		}
+		if candidate.ExternalIDs[idType] == id || (idType == candidate.Provider && candidate.ID == id) {
+			return 1
+		}
+	}
+
+	titleScore := similarity(titleMatchKey(query.Title, 0), titleMatchKey(candidate.Title, 0))
+	yearScore := 0.5
+	if query.Year > 0 && candidate.Year > 0 {
+		switch diff := query.Year - candidate.Year; {
+		case diff == 0:
+			yearScore = 1
+		case diff == 1 || diff == -1:
+			// Release dates differ between countries
+			yearScore = 0.5
+		default:
+			yearScore = 0
+		}
+	}
+
+	return titleWeight*titleScore + yearWeight*yearScore
+}
+
+// rankMatches scores the candidates and sorts them best first
+func rankMatches(query models.MetadataQuery, candidates []models.MetadataMatch) []models.MetadataMatch {
+	for i := range candidates {
+		candidates[i].Confidence = ScoreMatch(query, candidates[i])
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Confidence > candidates[j].Confidence
+	})
+	return candidates
+}
+
+// similarity is 1 minus the edit distance of a and b relative to the longer
+// one
+func similarity(a string, b string) float64 {
+	longest := max(len([]rune(a)), len([]rune(b)))
+	if longest == 0 {
+		return 1
+	}
+
+	return 1 - float64(editDistance(a, b))/float64(longest)
+}
+
+// editDistance is the Levenshtein distance of a and b, in runes
+func editDistance(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	// Keeping a single row of the matrix
+	row := make([]int, len(rb)+1)
+	for j := range row {
+		row[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		previous := row[0]
+		row[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current := min(row[j]+1, row[j-1]+1, previous+cost)
+			previous = row[j]
+			row[j] = current
+		}
+	}
+
+	return row[len(rb)]
+}
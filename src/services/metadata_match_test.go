@@ -0,0 +1,56 @@
+package services
+
+import (
+	"localflix-server/src/models"
+	"testing"
+)
+
+func TestScoreMatch(t *testing.T) {
+	query := models.MetadataQuery{Title: "The.Matrix", Year: 1999}
+	tests := []struct {
+		candidate models.MetadataMatch
+		min, max  float64
+	}{
+		{models.MetadataMatch{Title: "The Matrix", Year: 1999}, 1, 1},
+		{models.MetadataMatch{Title: "The Matrix", Year: 2000}, 0.85, 0.95},
+		{models.MetadataMatch{Title: "The Matrix"}, 0.85, 0.95},
+		{models.MetadataMatch{Title: "The Matrix Reloaded", Year: 2003}, 0, 0.6},
+		{models.MetadataMatch{Title: "Matrix", Year: 1999}, 0.6, 0.85},
+	}
+	for _, test := range tests {
+		if got := ScoreMatch(query, test.candidate); got < test.min || got > test.max {
+			t.Errorf("ScoreMatch(%+v) = %.2f, want between %.2f and %.2f", test.candidate, got, test.min, test.max)
+		}
+	}
+
+	// A shared id wins over any title
+	query.ExternalIDs = map[string]string{"imdb": "tt0133093"}
+	candidate := models.MetadataMatch{Title: "Matrix, The", ExternalIDs: map[string]string{"imdb": "tt0133093"}}
+	if got := ScoreMatch(query, candidate); got != 1 {
+		t.Errorf("ScoreMatch with a shared id = %.2f, want 1", got)
+	}
+}
+
+func TestMetadataProviderRegistry(t *testing.T) {
+	registry := NewMetadataProviderRegistry()
+	fixtures := &FixtureMetadataProvider{name: "fixture"}
+	if err := registry.Register(fixtures); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.Register(fixtures); err == nil {
+		t.Error("registering a name twice succeeded")
+	}
+	if err := registry.Register(&FixtureMetadataProvider{name: models.MetadataSourceNFO}); err == nil {
+		t.Error("registering a reserved name succeeded")
+	}
+
+	if provider, err := registry.Get("fixture"); err != nil || provider != fixtures {
+		t.Errorf("Get(fixture) = %v, %v", provider, err)
+	}
+	if _, err := registry.Get("unknown"); err == nil {
+		t.Error("Get(unknown) succeeded")
+	}
+	if names := registry.Names(); len(names) != 1 || names[0] != "fixture" {
+		t.Errorf("got names %v", names)
+	}
+}
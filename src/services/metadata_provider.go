@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"localflix-server/src/models"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// ErrMetadataNotFound is returned by providers asked for an id they don't know
+var ErrMetadataNotFound = errors.New("metadata not found")
+
+// ErrUnknownMetadataProvider is returned for a provider name that isn't
+// registered
+var ErrUnknownMetadataProvider = errors.New("unknown metadata provider")
+
+// MetadataProvider looks up metadata in an external database. IDs are the
+// provider's own, the provider's name is used as the metadata source and as
+// the key of its ids in ExternalIDs.
+type MetadataProvider interface {
+	Name() string
+	// Search returns the candidates for the query, unscored
+	Search(ctx context.Context, query models.MetadataQuery) ([]models.MetadataMatch, error)
+	Fetch(ctx context.Context, id string) (*models.Metadata, error)
+	Images(ctx context.Context, id string) ([]models.RemoteImage, error)
+}
+
+// ImageOpener is implemented by providers serving images themselves, like
+// the fixture provider reading local files. Images of other providers are
+// only downloaded over http(s).
+type ImageOpener interface {
+	OpenImage(ctx context.Context, url string) (io.ReadCloser, error)
+}
+
+// MetadataProviderRegistry holds the providers available to matching, by
+// name
+type MetadataProviderRegistry struct {
+	mu        sync.RWMutex
+	providers map[string]MetadataProvider
+}
+
+func NewMetadataProviderRegistry() *MetadataProviderRegistry {
+	return &MetadataProviderRegistry{
+		providers: map[string]MetadataProvider{},
+	}
+}
+
+func (r *MetadataProviderRegistry) Register(provider MetadataProvider) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	name := provider.Name()
+	if name == "" || slices.Contains([]string{models.MetadataSourceNFO, models.ArtworkSourceLocal}, name) {
+		return fmt.Errorf("invalid metadata provider name %q", name)
+	}
+	if _, ok := r.providers[name]; ok {
+		return fmt.Errorf("metadata provider %q is already registered", name)
+	}
+
+	r.providers[name] = provider
+	return nil
+}
+
+func (r *MetadataProviderRegistry) Get(name string) (MetadataProvider, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	provider, ok := r.providers[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownMetadataProvider, name)
+	}
+
+	return provider, nil
+}
+
+// Names returns the names of the registered providers, sorted
+func (r *MetadataProviderRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := []string{}
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,464 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"localflix-server/src/appdata"
+	"localflix-server/src/models"
+	"localflix-server/src/repositories"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// providerTimeout bounds every call to a metadata provider and every image
+// download
+const providerTimeout = 30 * time.Second
+
+// maxImageSize caps the size of a downloaded image
+const maxImageSize = 20 << 20
+
+// imageExtensions are the extensions of the image types kept as artwork
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+}
+
+// ErrNoMetadataProvider is returned when matching without a provider set in
+// the metadata settings
+var ErrNoMetadataProvider = errors.New("no metadata provider configured")
+
+type MetadataService struct {
+	ctx                  context.Context
+	db                   *sql.DB
+	mediaItemsRepository *repositories.MediaItemsRepository
+	metadataRepository   *repositories.MetadataRepository
+	tagsRepository       *repositories.TagsRepository
+	settingsService      *SettingsService
+	providers            *MetadataProviderRegistry
+	dirs                 *appdata.Dirs
+	logger               *slog.Logger
+}
+
+// NewMetadataService creates a new MetadataService struct
+func NewMetadataService(ctx context.Context, db *sql.DB, providers *MetadataProviderRegistry, dirs *appdata.Dirs, logger *slog.Logger) *MetadataService {
+	return &MetadataService{
+		ctx:                  ctx,
+		db:                   db,
+		mediaItemsRepository: repositories.NewMediaItemsRepository(db),
+		metadataRepository:   repositories.NewMetadataRepository(db),
+		tagsRepository:       repositories.NewTagsRepository(db),
+		settingsService:      NewSettingsService(ctx, db, logger),
+		providers:            providers,
+		dirs:                 dirs,
+		logger:               logger,
+	}
+}
+
+// GetMediaItemDetails returns the media item with its metadata, tags and artwork.
+// Artwork is returned by kind with the path of the image, callers serving
+// it over HTTP replace the paths with URLs.
+func (m *MetadataService) GetMediaItemDetails(id int) (*models.MediaItemDetails, error) {
+	item, err := m.mediaItemsRepository.GetMediaItem(id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		m.logger.Error("getting media item", "id", id, "err", err)
+		return nil, err
+	}
+
+	metadata, err := m.metadataRepository.GetMetadata(id)
+	if err != nil {
+		m.logger.Error("getting metadata", "media_item_id", id, "err", err)
+		return nil, err
+	}
+
+	tags, err := m.tagsRepository.ListItemTags(id)
+	if err != nil {
+		m.logger.Error("listing media item tags", "media_item_id", id, "err", err)
+		return nil, err
+	}
+
+	artwork, err := m.metadataRepository.ListArtwork(id)
+	if err != nil {
+		m.logger.Error("listing artwork", "media_item_id", id, "err", err)
+		return nil, err
+	}
+
+	details := &models.MediaItemDetails{MediaItem: *item, Metadata: metadata, Tags: []models.Tag{}, Artwork: map[string]string{}}
+	for _, tag := range tags {
+		details.Tags = append(details.Tags, *tag)
+	}
+	for _, image := range artwork {
+		details.Artwork[image.Kind] = image.Path
+	}
+	return details, nil
+}
+
+// GetArtwork returns the path of the local image of the given kind, or "" when
+// the media item has none
+func (m *MetadataService) GetArtwork(mediaItemId int, kind string) (string, error) {
+	artwork, err := m.metadataRepository.ListArtwork(mediaItemId)
+	if err != nil {
+		m.logger.Error("listing artwork", "media_item_id", mediaItemId, "err", err)
+		return "", err
+	}
+
+	for _, image := range artwork {
+		if image.Kind == kind {
+			return image.Path, nil
+		}
+	}
+	return "", nil
+}
+
+// FindMediaItem returns the media item at relPath in the folder, or nil when
+// it wasn't scanned
+func (m *MetadataService) FindMediaItem(folderId int, relPath string) (*models.MediaItem, error) {
+	item, err := m.mediaItemsRepository.GetMediaItemByPath(folderId, relPath)
+	if err != nil {
+		m.logger.Error("getting media item by path", "folder_id", folderId, "err", err)
+		return nil, err
+	}
+
+	return item, nil
+}
+
+func (m *MetadataService) ListMetadataProviders() []string {
+	return m.providers.Names()
+}
+
+// SearchMatches returns the candidates of the provider for the media item,
+// best first. An empty provider uses the one from the metadata settings, an
+// empty query title searches for the title parsed from the file name.
+func (m *MetadataService) SearchMatches(mediaItemId int, providerName string, query models.MetadataQuery) ([]models.MetadataMatch, error) {
+	item, err := m.mediaItem(mediaItemId)
+	if err != nil {
+		return nil, err
+	}
+	provider, err := m.provider(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSpace(query.Title) == "" {
+		query, err = m.itemQuery(item)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return m.search(provider, query)
+}
+
+// FixMatch sets the metadata of the media item to the provider's entry with
+// id and locks it, so neither automatic matching nor NFO files replace it.
+func (m *MetadataService) FixMatch(mediaItemId int, providerName string, id string) (*models.MediaItemDetails, error) {
+	item, err := m.mediaItem(mediaItemId)
+	if err != nil {
+		return nil, err
+	}
+	provider, err := m.provider(providerName)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(id) == "" {
+		return nil, fmt.Errorf("a %s id is required", provider.Name())
+	}
+
+	if err := m.applyMatch(item, provider, id, 1, true); err != nil {
+		return nil, err
+	}
+	m.logger.Info("metadata match fixed", "media_item_id", mediaItemId, "provider", provider.Name(), "provider_id", id)
+	return m.GetMediaItemDetails(mediaItemId)
+}
+
+// MatchMediaItem looks the media item up with the provider from the metadata
+// settings, applying the best match when it is confident enough. Items with
+// an NFO file or a fixed match are left alone.
+func (m *MetadataService) MatchMediaItem(mediaItemId int) (*models.MatchResult, error) {
+	item, err := m.mediaItem(mediaItemId)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := m.settingsService.GetMetadataSettings()
+	if err != nil {
+		return nil, err
+	}
+	provider, err := m.provider(settings.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.matchMediaItem(item, provider, settings.MinConfidence)
+}
+
+// MatchFolder runs MatchMediaItem on every media item of the folder. A
+// failing item doesn't stop the others, its error is in its result.
+func (m *MetadataService) MatchFolder(folderId int) ([]models.MatchResult, error) {
+	items, err := m.mediaItemsRepository.ListMediaItemsByFolder(folderId)
+	if err != nil {
+		m.logger.Error("listing media items", "folder_id", folderId, "err", err)
+		return nil, err
+	}
+	settings, err := m.settingsService.GetMetadataSettings()
+	if err != nil {
+		return nil, err
+	}
+	provider, err := m.provider(settings.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []models.MatchResult{}
+	matched := 0
+	for _, item := range items {
+		result, err := m.matchMediaItem(item, provider, settings.MinConfidence)
+		if err != nil {
+			result = &models.MatchResult{MediaItemID: item.ID, Reason: err.Error()}
+		}
+		if result.Matched {
+			matched++
+		}
+		results = append(results, *result)
+	}
+
+	m.logger.Info("matched folder metadata", "folder_id", folderId, "provider", provider.Name(), "items", len(items), "matched", matched)
+	return results, nil
+}
+
+func (m *MetadataService) matchMediaItem(item *models.MediaItem, provider MetadataProvider, minConfidence float64) (*models.MatchResult, error) {
+	result := &models.MatchResult{MediaItemID: item.ID}
+	existing, err := m.metadataRepository.GetMetadata(item.ID)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case existing != nil && existing.Locked:
+		result.Reason = "match was fixed by hand"
+		return result, nil
+	case existing != nil && existing.Source == models.MetadataSourceNFO && existing.Title != "":
+		result.Reason = "metadata comes from an NFO file"
+		return result, nil
+	}
+
+	query, err := m.itemQuery(item)
+	if err != nil {
+		return nil, err
+	}
+	matches, err := m.search(provider, query)
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		result.Reason = "no match found"
+		return result, nil
+	}
+
+	best := matches[0]
+	result.Match = &best
+	if best.Confidence < minConfidence {
+		result.Reason = fmt.Sprintf("best match confidence %.2f is below %.2f", best.Confidence, minConfidence)
+		return result, nil
+	}
+
+	if err := m.applyMatch(item, provider, best.ID, best.Confidence, false); err != nil {
+		return nil, err
+	}
+	result.Matched = true
+	return result, nil
+}
+
+// itemQuery describes the media item from its file name, and the ids of a
+// link-only NFO file
+func (m *MetadataService) itemQuery(item *models.MediaItem) (models.MetadataQuery, error) {
+	query := models.MetadataQuery{Title: item.Release.Title, Year: item.Release.Year}
+	existing, err := m.metadataRepository.GetMetadata(item.ID)
+	if err != nil {
+		return query, err
+	}
+	if existing != nil {
+		query.ExternalIDs = existing.ExternalIDs
+	}
+
+	return query, nil
+}
+
+func (m *MetadataService) search(provider MetadataProvider, query models.MetadataQuery) ([]models.MetadataMatch, error) {
+	ctx, cancel := context.WithTimeout(m.ctx, providerTimeout)
+	defer cancel()
+
+	matches, err := provider.Search(ctx, query)
+	if err != nil {
+		m.logger.Error("searching metadata", "provider", provider.Name(), "title", query.Title, "err", err)
+		return nil, err
+	}
+
+	return rankMatches(query, matches), nil
+}
+
+// applyMatch stores the provider's metadata and artwork for the media item.
+// Images that fail to download are skipped.
+func (m *MetadataService) applyMatch(item *models.MediaItem, provider MetadataProvider, id string, confidence float64, locked bool) error {
+	ctx, cancel := context.WithTimeout(m.ctx, providerTimeout)
+	defer cancel()
+
+	metadata, err := provider.Fetch(ctx, id)
+	if err != nil {
+		m.logger.Error("fetching metadata", "provider", provider.Name(), "provider_id", id, "err", err)
+		return err
+	}
+	metadata.MediaItemID = item.ID
+	metadata.Source = provider.Name()
+	metadata.Confidence = confidence
+	metadata.Locked = locked
+	metadata.UpdatedAt = time.Now().UTC()
+
+	images, err := provider.Images(ctx, id)
+	if err != nil {
+		m.logger.Warn("listing images", "provider", provider.Name(), "provider_id", id, "err", err)
+	}
+	var artwork []models.Artwork
+	for _, image := range images {
+		if !slices.Contains(models.ArtworkKinds, image.Kind) || slices.ContainsFunc(artwork, func(a models.Artwork) bool { return a.Kind == image.Kind }) {
+			continue
+		}
+		imagePath, err := m.downloadImage(ctx, provider, item, image)
+		if err != nil {
+			m.logger.Warn("downloading image", "media_item_id", item.ID, "url", image.URL, "err", err)
+			continue
+		}
+		artwork = append(artwork, models.Artwork{MediaItemID: item.ID, Kind: image.Kind, Path: imagePath, Source: provider.Name()})
+	}
+
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	metadataRepository := repositories.NewMetadataRepository(tx)
+	if err := metadataRepository.UpsertMetadata(*metadata); err != nil {
+		m.logger.Error("storing metadata", "media_item_id", item.ID, "err", err)
+		return err
+	}
+	if err := metadataRepository.ReplaceDownloadedArtwork(item.ID, artwork); err != nil {
+		m.logger.Error("storing artwork", "media_item_id", item.ID, "err", err)
+		return err
+	}
+	if err := indexMediaItem(tx, item.ID); err != nil {
+		m.logger.Error("indexing media item", "media_item_id", item.ID, "err", err)
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// downloadImage saves a provider image in the folder's artwork cache. Only
+// JPEG, PNG and WebP images up to maxImageSize are kept.
+func (m *MetadataService) downloadImage(ctx context.Context, provider MetadataProvider, item *models.MediaItem, image models.RemoteImage) (string, error) {
+	body, err := openImage(ctx, provider, image.URL)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	// Reading one byte over the limit tells a larger image from one of
+	// exactly the limit
+	content, err := io.ReadAll(io.LimitReader(body, maxImageSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(content) > maxImageSize {
+		return "", fmt.Errorf("image is larger than %d bytes", maxImageSize)
+	}
+	contentType := http.DetectContentType(content)
+	extension, ok := imageExtensions[contentType]
+	if !ok {
+		return "", fmt.Errorf("unsupported image type %s", contentType)
+	}
+
+	dir := filepath.Join(m.dirs.FolderArtwork(item.FolderID), strconv.Itoa(item.ID))
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return "", err
+	}
+
+	// Written aside and renamed, the previous image is served meanwhile
+	imagePath := filepath.Join(dir, image.Kind+extension)
+	tmpPath := filepath.Join(dir, "."+image.Kind+extension)
+	if err := os.WriteFile(tmpPath, content, 0o644); err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+
+	return imagePath, os.Rename(tmpPath, imagePath)
+}
+
+// openImage requests an http(s) image url. Other urls are only opened by
+// providers serving their images themselves.
+func openImage(ctx context.Context, provider MetadataProvider, imageURL string) (io.ReadCloser, error) {
+	parsed, err := url.Parse(imageURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		if opener, ok := provider.(ImageOpener); ok {
+			return opener.OpenImage(ctx, imageURL)
+		}
+		return nil, fmt.Errorf("unsupported image url %q", imageURL)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, imageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		response.Body.Close()
+		return nil, fmt.Errorf("unexpected status %s", response.Status)
+	}
+	if contentType := response.Header.Get("Content-Type"); contentType != "" && !strings.HasPrefix(contentType, "image/") {
+		response.Body.Close()
+		return nil, fmt.Errorf("unexpected content type %s", contentType)
+	}
+
+	return response.Body, nil
+}
+
+// mediaItem returns sql.ErrNoRows when there is no media item with the id
+func (m *MetadataService) mediaItem(id int) (*models.MediaItem, error) {
+	item, err := m.mediaItemsRepository.GetMediaItem(id)
+	if err != nil && err != sql.ErrNoRows {
+		m.logger.Error("getting media item", "id", id, "err", err)
+	}
+
+	return item, err
+}
+
+// provider returns the named provider, or the one from the metadata settings
+// when name is empty
+func (m *MetadataService) provider(name string) (MetadataProvider, error) {
+	if name == "" {
+		settings, err := m.settingsService.GetMetadataSettings()
+		if err != nil {
+			return nil, err
+		}
+		name = settings.Provider
+	}
+	if name == "" {
+		return nil, ErrNoMetadataProvider
+	}
+
+	return m.providers.Get(name)
+}
@@ -0,0 +1,208 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"localflix-server/src/logging"
+	"localflix-server/src/models"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testFixtures = `{
+  "name": "fixture",
+  "entries": [
+    {"id": "603", "title": "The Matrix", "year": 1999, "plot": "A hacker learns the truth.",
+     "genres": ["Action"], "external_ids": {"imdb": "tt0133093"},
+     "images": [{"kind": "poster", "url": "images/matrix.jpg"}, {"kind": "banner", "url": "images/matrix.jpg"}]},
+    {"id": "604", "title": "The Matrix Reloaded", "year": 2003},
+    {"id": "1891", "title": "The Empire Strikes Back", "year": 1980}
+  ]
+}`
+
+// testImage is a 1x1 PNG
+var testImage = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x06\x00\x00\x00\x1f\x15\xc4\x89\x00\x00\x00\rIDATx\x9cc\xf8\x0f\x00\x00\x01\x01\x00\x05\x18\xd8N\x00\x00\x00\x00IEND\xaeB`\x82")
+
+func newTestMetadataService(t *testing.T, library *testLibrary) *MetadataService {
+	t.Helper()
+
+	dir := makeDir(t)
+	if err := os.MkdirAll(filepath.Join(dir, "images"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "images", "matrix.jpg"), testImage, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fixturesPath := filepath.Join(dir, "fixtures.json")
+	if err := os.WriteFile(fixturesPath, []byte(testFixtures), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	provider, err := NewFixtureMetadataProvider(fixturesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	providers := NewMetadataProviderRegistry()
+	if err := providers.Register(provider); err != nil {
+		t.Fatal(err)
+	}
+
+	settings := NewSettingsService(context.Background(), library.db, logging.Discard())
+	if _, err := settings.UpdateMetadataSettings(models.MetadataSettings{Provider: "fixture", MinConfidence: defaultMinConfidence}); err != nil {
+		t.Fatal(err)
+	}
+	return NewMetadataService(context.Background(), library.db, providers, library.dirs, logging.Discard())
+}
+
+func TestMatchFolder(t *testing.T) {
+	library := newTestLibrary(t)
+	category, err := library.categories.CreateCategory("Movies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := makeDir(t, "The.Matrix.1999.1080p.BluRay.x264.mkv", "Empire.1980.mkv", "Other/Unknown Film (2010).mkv")
+	folder, err := library.folders.CreateFolder(dir, category.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewScanService(context.Background(), library.db, NewFakeMediaToolkit(), logging.Discard()).ScanFolder(folder.ID); err != nil {
+		t.Fatal(err)
+	}
+	metadataService := newTestMetadataService(t, library)
+
+	results, err := metadataService.MatchFolder(folder.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+
+	movie := findScannedItem(t, metadataService, folder.ID, "The.Matrix.1999.1080p.BluRay.x264.mkv")
+	if movie.Metadata == nil || movie.Metadata.Title != "The Matrix" || movie.Metadata.Source != "fixture" || movie.Metadata.ExternalIDs["imdb"] != "tt0133093" {
+		t.Errorf("got metadata %+v, want The Matrix from the fixtures", movie.Metadata)
+	}
+	if movie.Metadata != nil && (movie.Metadata.Confidence != 1 || movie.Metadata.Locked) {
+		t.Errorf("got confidence %.2f locked %v, want a certain unlocked match", movie.Metadata.Confidence, movie.Metadata.Locked)
+	}
+	poster, err := os.ReadFile(movie.Artwork[models.ArtworkPoster])
+	if err != nil || !bytes.Equal(poster, testImage) || filepath.Ext(movie.Artwork[models.ArtworkPoster]) != ".png" {
+		t.Errorf("got poster %q, %v, want the downloaded fixture image", poster, err)
+	}
+	if len(movie.Artwork) != 1 {
+		t.Errorf("got artwork %v, want only the poster", movie.Artwork)
+	}
+
+	// Too far from "Empire" to be applied, but offered as a candidate
+	empire := findScannedItem(t, metadataService, folder.ID, "Empire.1980.mkv")
+	if empire.Metadata != nil {
+		t.Errorf("got metadata %+v from a weak match", empire.Metadata)
+	}
+	matches, err := metadataService.SearchMatches(empire.MediaItem.ID, "", models.MetadataQuery{})
+	if err != nil || len(matches) != 1 || matches[0].ID != "1891" {
+		t.Fatalf("SearchMatches = %+v, %v", matches, err)
+	}
+
+	details, err := metadataService.FixMatch(empire.MediaItem.ID, "fixture", "1891")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if details.Metadata == nil || details.Metadata.Title != "The Empire Strikes Back" || !details.Metadata.Locked {
+		t.Errorf("got metadata %+v, want the fixed match", details.Metadata)
+	}
+
+	// Fixed matches survive matching again
+	result, err := metadataService.MatchMediaItem(empire.MediaItem.ID)
+	if err != nil || result.Matched {
+		t.Errorf("MatchMediaItem of a fixed match = %+v, %v", result, err)
+	}
+	if _, err := metadataService.FixMatch(empire.MediaItem.ID, "fixture", "9999"); err == nil {
+		t.Error("fixing an unknown id succeeded")
+	}
+	if _, err := metadataService.FixMatch(empire.MediaItem.ID, "unknown", "1891"); err == nil {
+		t.Error("fixing with an unknown provider succeeded")
+	}
+}
+
+func TestMatchSkipsNFOMetadata(t *testing.T) {
+	library := newTestLibrary(t)
+	category, err := library.categories.CreateCategory("Movies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := makeDir(t, "The Matrix (1999)/The Matrix (1999).mkv")
+	nfo := "<movie><title>My Matrix</title></movie>"
+	if err := os.WriteFile(filepath.Join(dir, "The Matrix (1999)", "movie.nfo"), []byte(nfo), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	folder, err := library.folders.CreateFolder(dir, category.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewScanService(context.Background(), library.db, NewFakeMediaToolkit(), logging.Discard()).ScanFolder(folder.ID); err != nil {
+		t.Fatal(err)
+	}
+	metadataService := newTestMetadataService(t, library)
+
+	movie := findScannedItem(t, metadataService, folder.ID, "The Matrix (1999)/The Matrix (1999).mkv")
+	result, err := metadataService.MatchMediaItem(movie.MediaItem.ID)
+	if err != nil || result.Matched {
+		t.Errorf("MatchMediaItem = %+v, %v, want the NFO kept", result, err)
+	}
+	movie = findScannedItem(t, metadataService, folder.ID, "The Matrix (1999)/The Matrix (1999).mkv")
+	if movie.Metadata == nil || movie.Metadata.Title != "My Matrix" {
+		t.Errorf("got metadata %+v, want the NFO", movie.Metadata)
+	}
+}
+
+func TestDownloadImageChecksImages(t *testing.T) {
+	library := newTestLibrary(t)
+	metadataService := newTestMetadataService(t, library)
+	fixtures, err := metadataService.providers.Get("fixture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/poster.png":
+			w.Write(testImage)
+		case "/page.png":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html></html>"))
+		case "/text.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("not an image"))
+		case "/huge.png":
+			w.Write(testImage)
+			w.Write(make([]byte, maxImageSize))
+		}
+	}))
+	defer server.Close()
+	item := &models.MediaItem{ID: 1, FolderID: 1}
+
+	imagePath, err := metadataService.downloadImage(context.Background(), fixtures, item, models.RemoteImage{Kind: models.ArtworkPoster, URL: server.URL + "/poster.png"})
+	if err != nil || filepath.Base(imagePath) != "poster.png" {
+		t.Errorf("downloadImage = %q, %v, want the poster", imagePath, err)
+	}
+
+	rejected := []string{
+		server.URL + "/page.png",
+		server.URL + "/text.png",
+		server.URL + "/huge.png",
+		"../" + filepath.Base(library.dirs.Database()),
+		"/etc/passwd",
+	}
+	for _, url := range rejected {
+		if _, err := metadataService.downloadImage(context.Background(), fixtures, item, models.RemoteImage{Kind: models.ArtworkPoster, URL: url}); err == nil {
+			t.Errorf("downloadImage(%s) succeeded", url)
+		}
+	}
+
+	// Providers not serving images themselves only get http(s)
+	var other MetadataProvider = struct{ MetadataProvider }{fixtures}
+	if _, err := metadataService.downloadImage(context.Background(), other, item, models.RemoteImage{Kind: models.ArtworkPoster, URL: "images/matrix.jpg"}); err == nil {
+		t.Error("downloadImage of a local path succeeded without an ImageOpener")
+	}
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"encoding/xml"
+	"fmt"
+	"localflix-server/src/models"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// nfoDocument covers the fields of Kodi's movie, episodedetails and tvshow
+// NFO files we use. See https://kodi.wiki/view/NFO_files
+type nfoDocument struct {
+	XMLName       xml.Name
+	Title         string        `xml:"title"`
+	OriginalTitle string        `xml:"originaltitle"`
+	Plot          string        `xml:"plot"`
+	Outline       string        `xml:"outline"`
+	Year          string        `xml:"year"`
+	Premiered     string        `xml:"premiered"`
+	Aired         string        `xml:"aired"`
+	Genres        []string      `xml:"genre"`
+	Rating        string        `xml:"rating"`
+	Ratings       []nfoRating   `xml:"ratings>rating"`
+	UniqueIDs     []nfoUniqueID `xml:"uniqueid"`
+	ID            string        `xml:"id"`
+	IMDbID        string        `xml:"imdbid"`
+	TMDbID        string        `xml:"tmdbid"`
+	Actors        []nfoActor    `xml:"actor"`
+}
+
+type nfoRating struct {
+	Name    string `xml:"name,attr"`
+	Default bool   `xml:"default,attr"`
+	Value   string `xml:"value"`
+}
+
+type nfoUniqueID struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type nfoActor struct {
+	Name  string `xml:"name"`
+	Role  string `xml:"role"`
+	Order string `xml:"order"`
+}
+
+// Kodi also accepts NFO files holding nothing but a link to the movie's page
+var imdbIDPattern = regexp.MustCompile(`\btt\d{7,}\b`)
+
+// ReadNFO reads the metadata of a Kodi NFO file. Files that aren't XML are
+// only searched for an IMDb id.
+func ReadNFO(path string) (*models.Metadata, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var document nfoDocument
+	if err := xml.Unmarshal(content, &document); err != nil {
+		imdbId := imdbIDPattern.FindString(string(content))
+		if imdbId == "" {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+		return &models.Metadata{Source: models.MetadataSourceNFO, ExternalIDs: map[string]string{"imdb": imdbId}}, nil
+	}
+
+	metadata := &models.Metadata{
+		Source:        models.MetadataSourceNFO,
+		Title:         strings.TrimSpace(document.Title),
+		OriginalTitle: strings.TrimSpace(document.OriginalTitle),
+		Plot:          strings.TrimSpace(document.Plot),
+		Genres:        []string{},
+		Cast:          []models.CastMember{},
+		ExternalIDs:   map[string]string{},
+	}
+	if metadata.Plot == "" {
+		metadata.Plot = strings.TrimSpace(document.Outline)
+	}
+
+	metadata.Year, _ = strconv.Atoi(strings.TrimSpace(document.Year))
+	for _, date := range []string{document.Premiered, document.Aired} {
+		if date = strings.TrimSpace(date); metadata.Year == 0 && len(date) >= 4 {
+			metadata.Year, _ = strconv.Atoi(date[:4])
+		}
+	}
+
+	// Older scrapers write every genre in one tag, "Action / Adventure"
+	for _, genre := range document.Genres {
+		for _, name := range strings.Split(genre, "/") {
+			if name = strings.TrimSpace(name); name != "" {
+				metadata.Genres = append(metadata.Genres, name)
+			}
+		}
+	}
+
+	metadata.Rating = nfoRatingValue(document)
+
+	for _, id := range document.UniqueIDs {
+		idType := strings.ToLower(strings.TrimSpace(id.Type))
+		if value := strings.TrimSpace(id.Value); value != "" && idType != "" {
+			metadata.ExternalIDs[idType] = value
+		}
+	}
+	legacyIds := map[string]string{"imdb": document.IMDbID, "tmdb": document.TMDbID}
+	if id := strings.TrimSpace(document.ID); strings.HasPrefix(id, "tt") {
+		legacyIds["imdb"] = id
+	}
+	for idType, value := range legacyIds {
+		if value = strings.TrimSpace(value); value != "" && metadata.ExternalIDs[idType] == "" {
+			metadata.ExternalIDs[idType] = value
+		}
+	}
+
+	for i, actor := range document.Actors {
+		name := strings.TrimSpace(actor.Name)
+		if name == "" {
+			continue
+		}
+		order, err := strconv.Atoi(strings.TrimSpace(actor.Order))
+		if err != nil {
+			order = i
+		}
+		metadata.Cast = append(metadata.Cast, models.CastMember{Name: name, Role: strings.TrimSpace(actor.Role), Order: order})
+	}
+
+	return metadata, nil
+}
+
+// nfoRatingValue prefers the default of the <ratings> list, then its first
+// entry, then the older single <rating> tag
+func nfoRatingValue(document nfoDocument) float64 {
+	ratings := document.Ratings
+	for _, rating := range ratings {
+		if rating.Default {
+			ratings = []nfoRating{rating}
+			break
+		}
+	}
+	for _, rating := range ratings {
+		if value, err := strconv.ParseFloat(strings.TrimSpace(rating.Value), 64); err == nil {
+			return value
+		}
+	}
+
+	value, _ := strconv.ParseFloat(strings.TrimSpace(document.Rating), 64)
+	return value
+}
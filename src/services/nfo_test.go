@@ -0,0 +1,90 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeNFO(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "movie.nfo")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadNFO(t *testing.T) {
+	path := writeNFO(t, `<?xml version="1.0" encoding="UTF-8" standalone="yes" ?>
+<movie>
+    <title>The Matrix</title>
+    <originaltitle>The Matrix</originaltitle>
+    <ratings>
+        <rating name="themoviedb" max="10"><value>8.2</value></rating>
+        <rating name="imdb" max="10" default="true"><value>8.7</value></rating>
+    </ratings>
+    <plot>A hacker learns the truth.</plot>
+    <uniqueid type="imdb" default="true">tt0133093</uniqueid>
+    <uniqueid type="tmdb">603</uniqueid>
+    <genre>Action</genre>
+    <genre>Science Fiction</genre>
+    <premiered>1999-03-30</premiered>
+    <actor><name>Keanu Reeves</name><role>Neo</role><order>0</order></actor>
+    <actor><name>Laurence Fishburne</name><role>Morpheus</role><order>1</order></actor>
+</movie>`)
+
+	metadata, err := ReadNFO(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.Title != "The Matrix" || metadata.Year != 1999 || metadata.Rating != 8.7 || metadata.Plot != "A hacker learns the truth." {
+		t.Errorf("got %+v", metadata)
+	}
+	if !reflect.DeepEqual(metadata.Genres, []string{"Action", "Science Fiction"}) {
+		t.Errorf("got genres %v", metadata.Genres)
+	}
+	if !reflect.DeepEqual(metadata.ExternalIDs, map[string]string{"imdb": "tt0133093", "tmdb": "603"}) {
+		t.Errorf("got ids %v", metadata.ExternalIDs)
+	}
+	if len(metadata.Cast) != 2 || metadata.Cast[1].Name != "Laurence Fishburne" || metadata.Cast[1].Role != "Morpheus" || metadata.Cast[1].Order != 1 {
+		t.Errorf("got cast %+v", metadata.Cast)
+	}
+}
+
+func TestReadLegacyNFO(t *testing.T) {
+	path := writeNFO(t, `<movie>
+    <title>Alien</title>
+    <year>1979</year>
+    <rating>8.5</rating>
+    <id>tt0078748</id>
+    <genre>Horror / Science Fiction</genre>
+</movie>`)
+
+	metadata, err := ReadNFO(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.Year != 1979 || metadata.Rating != 8.5 || metadata.ExternalIDs["imdb"] != "tt0078748" {
+		t.Errorf("got %+v", metadata)
+	}
+	if !reflect.DeepEqual(metadata.Genres, []string{"Horror", "Science Fiction"}) {
+		t.Errorf("got genres %v", metadata.Genres)
+	}
+}
+
+func TestReadLinkNFO(t *testing.T) {
+	metadata, err := ReadNFO(writeNFO(t, "https://www.imdb.com/title/tt0133093/\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.ExternalIDs["imdb"] != "tt0133093" {
+		t.Errorf("got %+v, want the IMDb id of the link", metadata)
+	}
+
+	if _, err := ReadNFO(writeNFO(t, "not an nfo")); err == nil {
+		t.Error("got no error for a file without XML or link")
+	}
+}
@@ -0,0 +1,219 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"localflix-server/src/models"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// hlsSegmentSeconds is the length of the segments of the HLS playlists,
+// each one transcoded when it's requested
+const hlsSegmentSeconds = 6
+
+// registerPartRoutes adds the routes playing the parts of a stacked video as
+// one, through an HLS playlist or transcoded together
+func (s *StreamService) registerPartRoutes(app *fiber.App) {
+	app.Get("/items/:itemId/playlist.m3u8", s.requireScope(models.ScopeStream), s.rateLimit, s.getPartsPlaylist)
+	app.Get("/items/:itemId/segments/:segment", s.requireScope(models.ScopeStream), s.transcodeSegment)
+	app.Get("/items/:itemId/transcode", s.requireScope(models.ScopeStream), s.transcodeParts)
+}
+
+// getPartsPlaylist serves a VOD HLS playlist of the parts of the media item
+// played as one. Its MPEG-TS segments are transcoded by the segments route,
+// which needs the durations of the parts.
+func (s *StreamService) getPartsPlaylist(c *fiber.Ctx) error {
+	parts, _, err := s.itemParts(c)
+	if err != nil {
+		return err
+	}
+	if parts == nil {
+		return nil
+	}
+	if status := s.mediaToolkit.Status(); !status.Available {
+		return c.Status(fiber.StatusServiceUnavailable).SendString(status.Error)
+	}
+
+	total := partsDuration(parts)
+	if total == 0 {
+		return c.Status(fiber.StatusConflict).SendString(fmt.Sprintf("Duration unknown, play /items/%d/transcode instead", parts[0].ID))
+	}
+	maxHeight := c.QueryInt("max_height")
+	if maxHeight < 0 {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid max_height")
+	}
+
+	var playlist strings.Builder
+	fmt.Fprintf(&playlist, "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-PLAYLIST-TYPE:VOD\n#EXT-X-TARGETDURATION:%d\n#EXT-X-MEDIA-SEQUENCE:0\n", hlsSegmentSeconds)
+	for i := 0; float64(i*hlsSegmentSeconds) < total; i++ {
+		link := fmt.Sprintf("%s/items/%d/segments/%d.ts", c.BaseURL(), parts[0].ID, i)
+		if maxHeight > 0 {
+			link += "?max_height=" + strconv.Itoa(maxHeight)
+		}
+		fmt.Fprintf(&playlist, "#EXTINF:%.3f,\n%s\n", min(hlsSegmentSeconds, total-float64(i*hlsSegmentSeconds)), withApiKey(c, link))
+	}
+	playlist.WriteString("#EXT-X-ENDLIST\n")
+
+	c.Set(fiber.HeaderContentType, "application/vnd.apple.mpegurl")
+	return c.SendString(playlist.String())
+}
+
+// transcodeSegment streams a segment of the playlist of the media item, the
+// segment param being its index followed by .ts
+func (s *StreamService) transcodeSegment(c *fiber.Ctx) error {
+	index, err := strconv.Atoi(strings.TrimSuffix(c.Params("segment"), ".ts"))
+	if err != nil || index < 0 {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid segment")
+	}
+	parts, folder, err := s.itemParts(c)
+	if err != nil {
+		return err
+	}
+	if parts == nil {
+		return nil
+	}
+
+	options := models.TranscodeOptions{
+		Start:     float64(index * hlsSegmentSeconds),
+		MaxHeight: c.QueryInt("max_height"),
+	}
+	total := partsDuration(parts)
+	if options.Start >= total {
+		return c.Status(fiber.StatusNotFound).SendString("Segment not found")
+	}
+	options.Duration = min(hlsSegmentSeconds, total-options.Start)
+	if options.MaxHeight < 0 {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid max_height")
+	}
+
+	videoPaths, err := s.partPaths(c, folder, parts)
+	if err != nil || videoPaths == nil {
+		return err
+	}
+
+	if !s.acquireTranscode() {
+		return sendTooManyTranscodes(c)
+	}
+	tracker := s.tracker()
+	stream := tracker.startTranscode(c, folder.ID, parts[0].RelPath, options.Start)
+	client := clientKey(c)
+	logger := s.requestLogger(c)
+	c.Set(fiber.HeaderContentType, "video/mp2t")
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer s.releaseTranscode()
+		defer tracker.endTranscode(stream)
+		err := s.mediaToolkit.TranscodeSegment(context.Background(), videoPaths, options, s.rateLimitService.ThrottledWriter(client, w))
+		if err != nil {
+			logger.Warn("transcoding segment stopped", "media_item_id", parts[0].ID, "segment", index, "err", err)
+			return
+		}
+		w.Flush()
+	})
+	return nil
+}
+
+// transcodeParts streams the parts of the media item transcoded as a single
+// video, like the /transcode route does for one file. A single file is
+// transcoded alone.
+func (s *StreamService) transcodeParts(c *fiber.Ctx) error {
+	parts, folder, err := s.itemParts(c)
+	if err != nil {
+		return err
+	}
+	if parts == nil {
+		return nil
+	}
+
+	options := models.TranscodeOptions{
+		Start:     c.QueryFloat("start"),
+		MaxHeight: c.QueryInt("max_height"),
+	}
+	if options.Start < 0 || options.MaxHeight < 0 {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid start or max_height")
+	}
+
+	videoPaths, err := s.partPaths(c, folder, parts)
+	if err != nil || videoPaths == nil {
+		return err
+	}
+
+	if !s.acquireTranscode() {
+		return sendTooManyTranscodes(c)
+	}
+	// Watching the parts counts as watching the first one, which stands for
+	// the stack in listings
+	tracker := s.tracker()
+	stream := tracker.startTranscode(c, folder.ID, parts[0].RelPath, options.Start)
+	client := clientKey(c)
+	logger := s.requestLogger(c)
+	c.Set(fiber.HeaderContentType, "video/mp4")
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer s.releaseTranscode()
+		defer tracker.endTranscode(stream)
+		err := s.mediaToolkit.TranscodeParts(context.Background(), videoPaths, options, s.rateLimitService.ThrottledWriter(client, w))
+		if err != nil {
+			logger.Warn("transcoding stopped", "media_item_id", parts[0].ID, "err", err)
+			return
+		}
+		w.Flush()
+	})
+	return nil
+}
+
+// partPaths returns the paths of the parts once they are found and the
+// media toolkit is available. Otherwise the error response is sent and the
+// paths are nil.
+func (s *StreamService) partPaths(c *fiber.Ctx, folder *models.Folder, parts []models.MediaItem) ([]string, error) {
+	videoPaths := make([]string, len(parts))
+	for i, part := range parts {
+		videoPaths[i] = filepath.Join(folder.Path, filepath.FromSlash(part.RelPath))
+		if _, err := os.Stat(videoPaths[i]); err != nil {
+			return nil, c.Status(fiber.StatusNotFound).SendString("Video not found")
+		}
+	}
+	if status := s.mediaToolkit.Status(); !status.Available {
+		return nil, c.Status(fiber.StatusServiceUnavailable).SendString(status.Error)
+	}
+
+	return videoPaths, nil
+}
+
+// partsDuration adds up the durations of the parts, in seconds
+func partsDuration(parts []models.MediaItem) float64 {
+	total := 0.0
+	for _, part := range parts {
+		total += part.Duration
+	}
+	return total
+}
+
+// itemParts returns the parts of the itemId param with their folder. When
+// they can't be found the error response is sent and the parts are nil.
+func (s *StreamService) itemParts(c *fiber.Ctx) ([]models.MediaItem, *models.Folder, error) {
+	itemId, err := strconv.Atoi(c.Params("itemId"))
+	if err != nil {
+		return nil, nil, c.Status(fiber.StatusBadRequest).SendString("Invalid item ID")
+	}
+
+	parts, err := s.mediaItemsService.ListParts(itemId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, c.Status(fiber.StatusNotFound).SendString("Item not found")
+	}
+	if err != nil {
+		return nil, nil, c.Status(fiber.StatusInternalServerError).SendString("Error listing parts")
+	}
+
+	folder, err := s.foldersService.GetFolderById(parts[0].FolderID)
+	if err != nil {
+		return nil, nil, c.Status(fiber.StatusInternalServerError).SendString("Error retrieving folder")
+	}
+	return parts, folder, nil
+}
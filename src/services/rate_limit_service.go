@@ -0,0 +1,249 @@
+package services
+
+import (
+	"io"
+	"localflix-server/src/models"
+	"sync"
+	"time"
+)
+
+const (
+	// throttleChunkSize is how many bytes a throttled writer sends per token
+	// reservation, small enough to keep the output smooth
+	throttleChunkSize = 32 * 1024
+	// idleBucketTTL is how long an unused client bucket is kept around
+	idleBucketTTL = 10 * time.Minute
+	// ipRequestFactor is how many clients' worth of requests an IP can make,
+	// several devices behind the same router share it
+	ipRequestFactor = 10
+)
+
+// RateLimitService keeps a request bucket and a bandwidth bucket per client,
+// and a request bucket per IP checked before the client is authenticated.
+// Limits can be changed while the server runs with Apply.
+type RateLimitService struct {
+	mu               sync.Mutex
+	settings         models.RateLimitSettings
+	requestBuckets   map[string]*tokenBucket
+	bandwidthBuckets map[string]*tokenBucket
+	ipBuckets        map[string]*tokenBucket
+	lastPruneAt      time.Time
+}
+
+// NewRateLimitService creates a new RateLimitService struct
+func NewRateLimitService(settings models.RateLimitSettings) *RateLimitService {
+	return &RateLimitService{
+		settings:         settings,
+		requestBuckets:   map[string]*tokenBucket{},
+		bandwidthBuckets: map[string]*tokenBucket{},
+		ipBuckets:        map[string]*tokenBucket{},
+		lastPruneAt:      time.Now(),
+	}
+}
+
+// Apply swaps the limits used for every client, including the ones with
+// requests in flight.
+func (r *RateLimitService) Apply(settings models.RateLimitSettings) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.settings = settings
+	requestRate, requestBurst := r.requestRate()
+	for _, bucket := range r.requestBuckets {
+		bucket.setRate(requestRate, requestBurst)
+	}
+	bandwidthRate, bandwidthBurst := r.bandwidthRate()
+	for _, bucket := range r.bandwidthBuckets {
+		bucket.setRate(bandwidthRate, bandwidthBurst)
+	}
+	ipRate, ipBurst := r.ipRequestRate()
+	for _, bucket := range r.ipBuckets {
+		bucket.setRate(ipRate, ipBurst)
+	}
+}
+
+// AllowRequest takes a request token from the client's bucket.
+func (r *RateLimitService) AllowRequest(client string) bool {
+	r.mu.Lock()
+	if r.settings.RequestsPerMinute <= 0 {
+		r.mu.Unlock()
+		return true
+	}
+	bucket := r.bucket(r.requestBuckets, client, r.requestRate)
+	r.mu.Unlock()
+
+	return bucket.allow()
+}
+
+// AllowIPRequest takes a request token from the IP's bucket. It runs before
+// authentication so requests with missing or guessed keys are limited too.
+func (r *RateLimitService) AllowIPRequest(ip string) bool {
+	r.mu.Lock()
+	if r.settings.RequestsPerMinute <= 0 {
+		r.mu.Unlock()
+		return true
+	}
+	bucket := r.bucket(r.ipBuckets, ip, r.ipRequestRate)
+	r.mu.Unlock()
+
+	return bucket.allow()
+}
+
+// ThrottledWriter wraps w so writes are paced by the client's bandwidth
+// bucket. Concurrent streams of the same client share the bucket.
+func (r *RateLimitService) ThrottledWriter(client string, w io.Writer) io.Writer {
+	r.mu.Lock()
+	bucket := r.bucket(r.bandwidthBuckets, client, r.bandwidthRate)
+	r.mu.Unlock()
+
+	return &throttledWriter{w: w, bucket: bucket}
+}
+
+// requestRate is the request bucket rate per second and its burst, a full
+// minute worth of requests can be made at once.
+func (r *RateLimitService) requestRate() (float64, float64) {
+	perMinute := float64(r.settings.RequestsPerMinute)
+	return perMinute / 60, perMinute
+}
+
+// ipRequestRate is the IP bucket rate per second and its burst, the client's
+// ones times ipRequestFactor.
+func (r *RateLimitService) ipRequestRate() (float64, float64) {
+	rate, burst := r.requestRate()
+	return rate * ipRequestFactor, burst * ipRequestFactor
+}
+
+// bandwidthRate is the bandwidth bucket rate in bytes per second and its
+// burst, about a quarter of a second of data.
+func (r *RateLimitService) bandwidthRate() (float64, float64) {
+	bytesPerSecond := r.settings.MaxBandwidthMbps * 1000 * 1000 / 8
+	return bytesPerSecond, max(bytesPerSecond/4, throttleChunkSize)
+}
+
+// bucket returns the client's bucket from buckets, creating it when needed.
+// Callers must hold r.mu.
+func (r *RateLimitService) bucket(buckets map[string]*tokenBucket, client string, rate func() (float64, float64)) *tokenBucket {
+	now := time.Now()
+	if now.Sub(r.lastPruneAt) > idleBucketTTL {
+		r.prune(now)
+	}
+
+	bucket, ok := buckets[client]
+	if !ok {
+		bucket = newTokenBucket(rate())
+		buckets[client] = bucket
+	}
+
+	return bucket
+}
+
+func (r *RateLimitService) prune(now time.Time) {
+	for _, buckets := range []map[string]*tokenBucket{r.requestBuckets, r.bandwidthBuckets, r.ipBuckets} {
+		for client, bucket := range buckets {
+			if bucket.idleSince(now) > idleBucketTTL {
+				delete(buckets, client)
+			}
+		}
+	}
+	r.lastPruneAt = now
+}
+
+// tokenBucket refills rate tokens per second up to burst. A rate of zero
+// means unlimited.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst float64) *tokenBucket {
+	return &tokenBucket{
+		rate:   rate,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+func (b *tokenBucket) setRate(rate float64, burst float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(time.Now())
+	b.rate = rate
+	b.burst = burst
+	b.tokens = min(b.tokens, burst)
+}
+
+// allow takes one token if it is available right now.
+func (b *tokenBucket) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(time.Now())
+	if b.rate <= 0 {
+		return true
+	}
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// reserve takes n tokens, going into debt if needed, and returns how long the
+// caller has to wait before using them.
+func (b *tokenBucket) reserve(n float64) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(time.Now())
+	if b.rate <= 0 {
+		return 0
+	}
+	b.tokens -= n
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// refill also marks the bucket as used, unlimited ones included, so prune
+// keeps the buckets of streams still playing.
+func (b *tokenBucket) refill(now time.Time) {
+	if b.rate > 0 {
+		b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+}
+
+func (b *tokenBucket) idleSince(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return now.Sub(b.last)
+}
+
+type throttledWriter struct {
+	w      io.Writer
+	bucket *tokenBucket
+}
+
+func (t *throttledWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		chunk := p[:min(len(p), throttleChunkSize)]
+		time.Sleep(t.bucket.reserve(float64(len(chunk))))
+
+		n, err := t.w.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[len(chunk):]
+	}
+
+	return written, nil
+}
@@ -0,0 +1,28 @@
+package services
+
+import (
+	"io"
+	"localflix-server/src/models"
+	"testing"
+	"time"
+)
+
+func TestPlayingUnlimitedStreamsGetNewLimits(t *testing.T) {
+	r := NewRateLimitService(models.RateLimitSettings{})
+	writer := r.ThrottledWriter("client", io.Discard)
+	bucket := r.bandwidthBuckets["client"]
+	bucket.last = time.Now().Add(-2 * idleBucketTTL)
+
+	if _, err := writer.Write([]byte("data")); err != nil {
+		t.Fatal(err)
+	}
+	r.prune(time.Now())
+	if r.bandwidthBuckets["client"] != bucket {
+		t.Fatal("expected the bucket of the playing stream to be kept")
+	}
+
+	r.Apply(models.RateLimitSettings{MaxBandwidthMbps: 8})
+	if bucket.rate != 1000*1000 {
+		t.Errorf("expected the playing stream to be limited to 1MB/s, got %v", bucket.rate)
+	}
+}
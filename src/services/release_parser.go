@@ -0,0 +1,198 @@
+package services
+
+import (
+	"localflix-server/src/models"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// Tokens are split on separators but not on hyphens, so "WEB-DL" and
+	// "x265-GROUP" stay whole
+	releaseWordPattern = regexp.MustCompile(`[^ ._\[\]()]+`)
+	// h.264 would otherwise be split in two tokens
+	dottedCodecPattern = regexp.MustCompile(`(?i)\bh\.(26[45])\b`)
+	editionPattern     = regexp.MustCompile(`(?i)(?:^|[ ._\-\[(])(director'?s[ ._]cut|extended(?:[ ._](?:cut|edition))?|unrated|theatrical(?:[ ._]cut)?|remastered|special[ ._]edition|ultimate[ ._](?:cut|edition)|final[ ._]cut|imax|criterion)(?:[ ._\-\])]|$)`)
+	yearPattern        = regexp.MustCompile(`^(?:19|20)\d{2}$`)
+	groupSuffixPattern = regexp.MustCompile(`-([A-Za-z0-9]+)$`)
+	groupPrefixPattern = regexp.MustCompile(`^\[([^\]]+)\]`)
+
+	resolutions = map[string]string{
+		"2160p": "2160p", "4k": "2160p", "uhd": "2160p",
+		"1080p": "1080p", "1080i": "1080p",
+		"720p": "720p",
+		"576p": "576p",
+		"480p": "480p",
+	}
+	sources = map[string]string{
+		"bluray": "BluRay", "blu-ray": "BluRay", "bdrip": "BluRay", "brrip": "BluRay", "bdremux": "BluRay", "remux": "BluRay",
+		"web": "WEB-DL", "web-dl": "WEB-DL", "webdl": "WEB-DL",
+		"webrip": "WEBRip", "web-rip": "WEBRip",
+		"hdtv": "HDTV",
+		"dvd":  "DVD", "dvdrip": "DVD", "dvd-rip": "DVD",
+		"hdrip": "HDRip",
+	}
+	codecs = map[string]string{
+		"x264": "H.264", "h264": "H.264", "avc": "H.264",
+		"x265": "H.265", "h265": "H.265", "hevc": "H.265",
+		"xvid": "XviD", "divx": "DivX",
+		"av1": "AV1",
+		"vp9": "VP9",
+	}
+	// Other tokens only found in the release details, after the title
+	releaseNoise = map[string]bool{
+		"proper": true, "repack": true, "internal": true, "limited": true, "multi": true,
+		"hdr": true, "hdr10": true, "dv": true, "10bit": true, "8bit": true,
+		"aac": true, "ac3": true, "dts": true, "ddp": true, "dd5": true, "atmos": true, "truehd": true, "dts-hd": true,
+		"amzn": true, "nf": true, "dsnp": true, "hmax": true,
+	}
+	editions = map[string]string{
+		"directors cut": "Director's Cut", "director's cut": "Director's Cut",
+		"extended": "Extended", "extended cut": "Extended", "extended edition": "Extended",
+		"unrated":    "Unrated",
+		"theatrical": "Theatrical", "theatrical cut": "Theatrical",
+		"remastered":      "Remastered",
+		"special edition": "Special Edition",
+		"ultimate cut":    "Ultimate Edition", "ultimate edition": "Ultimate Edition",
+		"final cut": "Final Cut",
+		"imax":      "IMAX",
+		"criterion": "Criterion",
+	}
+)
+
+// ParseReleaseName reads the title, year and release details out of a file
+// name like "The.Matrix.1999.2160p.UHD.BluRay.x265-GROUP.mkv". Names that
+// are already clean, like "The Matrix (1999).mkv", only give a title and year.
+func ParseReleaseName(name string) models.ReleaseInfo {
+	if isVideoFile(name) {
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	name = dottedCodecPattern.ReplaceAllString(name, "h$1")
+
+	var info models.ReleaseInfo
+	if match := groupPrefixPattern.FindStringSubmatch(name); match != nil {
+		info.Group = match[1]
+	}
+
+	titleEnd := len(name)
+	details := false
+	var lastToken string
+	for i, match := range releaseWordPattern.FindAllStringIndex(name, -1) {
+		token := strings.ToLower(name[match[0]:match[1]])
+		lastToken = token
+		// The last year before the details is the release year, earlier ones
+		// belong to the title, like in "Blade Runner 2049 (2017)"
+		if !details && i > 0 && yearPattern.MatchString(token) {
+			info.Year, _ = strconv.Atoi(token)
+			titleEnd = match[0]
+			continue
+		}
+		if readReleaseToken(&info, token) && !details {
+			details = true
+			if info.Year == 0 {
+				titleEnd = match[0]
+			}
+		}
+	}
+
+	if match := editionPattern.FindStringSubmatchIndex(name); match != nil {
+		edition := strings.ToLower(strings.NewReplacer(".", " ", "_", " ").Replace(name[match[2]:match[3]]))
+		info.Edition = editions[edition]
+		titleEnd = min(titleEnd, match[0])
+	}
+	// Episodes are titled by their series, see ParseEpisodePath
+	for _, pattern := range []*regexp.Regexp{seasonEpisodePattern, crossPattern} {
+		if match := pattern.FindStringIndex(name); match != nil {
+			titleEnd = min(titleEnd, match[0])
+		}
+	}
+
+	// A group suffix is only told apart from a hyphenated title, like
+	// "Spider-Man", once the release details started
+	if details && info.Group == "" && !isReleaseToken(lastToken) {
+		if match := groupSuffixPattern.FindStringSubmatch(name); match != nil && !isReleaseToken(strings.ToLower(match[1])) {
+			info.Group = match[1]
+		}
+	}
+
+	info.Title = cleanTitle(name[:titleEnd])
+	if info.Title == "" {
+		info.Title = cleanTitle(name)
+	}
+	return info
+}
+
+// releaseDetailsStart is where the release details start in name, or its
+// length when it has none
+func releaseDetailsStart(name string) int {
+	var info models.ReleaseInfo
+	start := len(name)
+	for _, match := range releaseWordPattern.FindAllStringIndex(name, -1) {
+		if readReleaseToken(&info, strings.ToLower(name[match[0]:match[1]])) {
+			start = match[0]
+			break
+		}
+	}
+	if match := editionPattern.FindStringIndex(name); match != nil {
+		start = min(start, match[0])
+	}
+
+	return start
+}
+
+// readReleaseToken fills in the first resolution, source and codec found,
+// reporting whether the lowercased token is a release detail
+func readReleaseToken(info *models.ReleaseInfo, token string) bool {
+	if resolution, ok := resolutions[token]; ok {
+		if info.Resolution == "" {
+			info.Resolution = resolution
+		}
+		return true
+	}
+	if source, ok := sources[token]; ok {
+		if info.Source == "" {
+			info.Source = source
+		}
+		return true
+	}
+	if codec, ok := codecs[token]; ok {
+		if info.Codec == "" {
+			info.Codec = codec
+		}
+		return true
+	}
+	if releaseNoise[token] {
+		return true
+	}
+
+	// Parts of "x265-GROUP" or "DDP5-1"
+	known := false
+	if strings.Contains(token, "-") {
+		for _, part := range strings.Split(token, "-") {
+			known = readReleaseToken(info, part) || known
+		}
+	}
+	return known
+}
+
+func isReleaseToken(token string) bool {
+	_, resolution := resolutions[token]
+	_, source := sources[token]
+	_, codec := codecs[token]
+	return resolution || source || codec || releaseNoise[token]
+}
+
+// sortTitle orders titles without their leading article, "The Matrix" sorts
+// as "matrix"
+func sortTitle(title string) string {
+	title = strings.ToLower(title)
+	for _, article := range []string{"the ", "a ", "an "} {
+		if rest, ok := strings.CutPrefix(title, article); ok && rest != "" {
+			return rest
+		}
+	}
+
+	return title
+}
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"localflix-server/src/models"
+	"testing"
+)
+
+func TestParseReleaseName(t *testing.T) {
+	tests := []struct {
+		name string
+		want models.ReleaseInfo
+	}{
+		{"The.Matrix.1999.2160p.UHD.BluRay.x265.mkv", models.ReleaseInfo{Title: "The Matrix", Year: 1999, Resolution: "2160p", Source: "BluRay", Codec: "H.265"}},
+		{"The Matrix (1999).mkv", models.ReleaseInfo{Title: "The Matrix", Year: 1999}},
+		{"Blade.Runner.2049.2017.1080p.WEB-DL.H.264-GROUP.mkv", models.ReleaseInfo{Title: "Blade Runner 2049", Year: 2017, Resolution: "1080p", Source: "WEB-DL", Codec: "H.264", Group: "GROUP"}},
+		{"Apocalypse.Now.1979.Directors.Cut.720p.BRRip.XviD.avi", models.ReleaseInfo{Title: "Apocalypse Now", Year: 1979, Resolution: "720p", Source: "BluRay", Codec: "XviD", Edition: "Director's Cut"}},
+		{"Aliens (1986) Extended Edition [1080p].mp4", models.ReleaseInfo{Title: "Aliens", Year: 1986, Resolution: "1080p", Edition: "Extended"}},
+		{"2001.A.Space.Odyssey.1968.mkv", models.ReleaseInfo{Title: "2001 A Space Odyssey", Year: 1968}},
+		{"1917.mkv", models.ReleaseInfo{Title: "1917"}},
+		{"Spider-Man.Into.the.Spider-Verse.2018.mkv", models.ReleaseInfo{Title: "Spider-Man Into the Spider-Verse", Year: 2018}},
+		{"Some.Movie.1080p.WEB-DL.mkv", models.ReleaseInfo{Title: "Some Movie", Resolution: "1080p", Source: "WEB-DL"}},
+		{"[Group] Movie Title [1080p].mkv", models.ReleaseInfo{Title: "Movie Title", Resolution: "1080p", Group: "Group"}},
+		{"Show.S01E02.Title.720p.HDTV.mkv", models.ReleaseInfo{Title: "Show", Resolution: "720p", Source: "HDTV"}},
+		{"home video.mp4", models.ReleaseInfo{Title: "home video"}},
+	}
+	for _, test := range tests {
+		if got := ParseReleaseName(test.name); got != test.want {
+			t.Errorf("ParseReleaseName(%q) = %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestSortTitle(t *testing.T) {
+	tests := map[string]string{
+		"The Matrix":    "matrix",
+		"A Quiet Place": "quiet place",
+		"Annie Hall":    "annie hall",
+		"The":           "the",
+	}
+	for title, want := range tests {
+		if got := sortTitle(title); got != want {
+			t.Errorf("sortTitle(%q) = %q, want %q", title, got, want)
+		}
+	}
+}
@@ -0,0 +1,413 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"localflix-server/src/models"
+	"localflix-server/src/repositories"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var videoExtensions = []string{".mp4", ".m4v", ".mkv", ".avi", ".mov", ".webm", ".wmv", ".mpg", ".mpeg", ".ts", ".m2ts"}
+
+// rescanCheckInterval is how often RescanStaleFolders looks for folders to
+// scan again
+const rescanCheckInterval = time.Minute
+
+type ScanService struct {
+	ctx               context.Context
+	db                *sql.DB
+	foldersRepository *repositories.FoldersRepository
+	settingsService   *SettingsService
+	mediaToolkit      MediaToolkit
+	// scanning runs scans one at a time, it's shared by the copies of the
+	// service
+	scanning *sync.Mutex
+	// queued holds the folders with a background scan waiting or running
+	queued *sync.Map
+	logger *slog.Logger
+}
+
+// NewScanService creates a new ScanService struct
+func NewScanService(ctx context.Context, db *sql.DB, mediaToolkit MediaToolkit, logger *slog.Logger) *ScanService {
+	return &ScanService{
+		ctx:               ctx,
+		db:                db,
+		foldersRepository: repositories.NewFoldersRepository(db),
+		settingsService:   NewSettingsService(ctx, db, logger),
+		mediaToolkit:      mediaToolkit,
+		scanning:          &sync.Mutex{},
+		queued:            &sync.Map{},
+		logger:            logger,
+	}
+}
+
+// ScanLibrary scans every registered folder, then groups the versions of
+// titles across the library once. It stops at the first folder failing,
+// the folders scanned before it are kept.
+func (s *ScanService) ScanLibrary() ([]models.ScanResult, error) {
+	s.scanning.Lock()
+	defer s.scanning.Unlock()
+
+	var results []models.ScanResult
+	var scanErr error
+	for _, folder := range s.foldersRepository.ListFolders() {
+		result, err := s.scanFolder(folder.ID, false)
+		if err != nil {
+			scanErr = err
+			break
+		}
+		results = append(results, *result)
+	}
+	if len(results) == 0 {
+		return results, scanErr
+	}
+
+	versions, err := s.groupVersions()
+	if err != nil {
+		return results, err
+	}
+	for i := range results {
+		results[i].Versions = versions
+	}
+	return results, scanErr
+}
+
+// RescanStaleFolders scans the folders again once their last scan is older
+// than the rescan interval of the scan settings, until ctx is done. Files
+// added or removed outside the app show up without a manual scan.
+func (s *ScanService) RescanStaleFolders(ctx context.Context) {
+	ticker := time.NewTicker(rescanCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.rescanStaleFolders(now.UTC())
+		}
+	}
+}
+
+// rescanStaleFolders scans the folders last scanned before the rescan
+// interval, or never
+func (s *ScanService) rescanStaleFolders(now time.Time) {
+	settings, err := s.settingsService.GetScanSettings()
+	if err != nil {
+		s.logger.Error("loading scan settings", "err", err)
+		return
+	}
+	if settings.RescanMinutes == 0 {
+		return
+	}
+
+	s.scanning.Lock()
+	defer s.scanning.Unlock()
+
+	staleBefore := now.Add(-time.Duration(settings.RescanMinutes) * time.Minute)
+	scanned := 0
+	for _, folder := range s.foldersRepository.ListFolders() {
+		if folder.LastScannedAt != nil && folder.LastScannedAt.After(staleBefore) {
+			continue
+		}
+		// Errors are logged by scanFolder, the other folders are still
+		// scanned
+		if _, err := s.scanFolder(folder.ID, false); err == nil {
+			scanned++
+		}
+	}
+	if scanned > 0 {
+		s.groupVersions()
+	}
+}
+
+// ScanFolderInBackground scans the folder without waiting for the scan,
+// unless one of its background scans is queued already. Errors are logged.
+func (s *ScanService) ScanFolderInBackground(folderId int) {
+	if _, queued := s.queued.LoadOrStore(folderId, true); queued {
+		return
+	}
+
+	go func() {
+		defer s.queued.Delete(folderId)
+		s.ScanFolder(folderId)
+	}()
+}
+
+// ScanFolder walks the folder and syncs its media items with the video files
+// found, probing only new and changed files. The changes are applied in one
+// transaction, with the stacks, series, local metadata, search index and
+// versions derived from them. Content hashes are computed when enabled in
+// the scan settings.
+func (s *ScanService) ScanFolder(folderId int) (*models.ScanResult, error) {
+	s.scanning.Lock()
+	defer s.scanning.Unlock()
+
+	return s.scanFolder(folderId, true)
+}
+
+// scanFolder scans the folder, grouping versions in the same transaction
+// when asked to. Scanning several folders groups them once at the end
+// instead, going through the whole library every time would be wasted.
+func (s *ScanService) scanFolder(folderId int, groupVersions bool) (*models.ScanResult, error) {
+	folder, err := s.foldersRepository.GetFolderById(folderId)
+	if err != nil {
+		s.logger.Error("scanning folder", "folder_id", folderId, "err", err)
+		return nil, err
+	}
+
+	settings, err := s.settingsService.GetScanSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := repositories.NewMediaItemsRepository(s.db).ListMediaItemsByFolder(folderId)
+	if err != nil {
+		return nil, err
+	}
+	known := map[string]*models.MediaItem{}
+	for _, item := range existing {
+		known[item.RelPath] = item
+	}
+
+	result := &models.ScanResult{FolderID: folderId, Errors: []string{}}
+	now := time.Now().UTC()
+	var added, updated, reparsed, hashed []models.MediaItem
+	seen := map[string]bool{}
+	// unreadable are the paths that failed to read, the media items under
+	// them are kept as they were
+	var unreadable []string
+	var probeErr error
+
+	err = filepath.WalkDir(folder.Path, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			// An unmounted share reads as an empty folder, removing every
+			// item of the folder along with its watch progress
+			if path == folder.Path {
+				return err
+			}
+			result.Errors = append(result.Errors, err.Error())
+			if rel, relErr := filepath.Rel(folder.Path, path); relErr == nil {
+				unreadable = append(unreadable, filepath.ToSlash(rel))
+			}
+			if entry != nil && entry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if strings.HasPrefix(entry.Name(), ".") && path != folder.Path {
+			if entry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() || !isVideoFile(entry.Name()) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(folder.Path, path)
+		if err != nil {
+			return err
+		}
+		relPath := filepath.ToSlash(rel)
+		seen[relPath] = true
+
+		info, err := entry.Info()
+		if err != nil {
+			result.Errors = append(result.Errors, err.Error())
+			return nil
+		}
+		release := ParseReleaseName(entry.Name())
+
+		item, ok := known[relPath]
+		// Parts are titled without their part number, see syncStacks
+		if stackName, _, stacked := ParseStackPart(entry.Name()); ok && item.Part > 0 && stacked {
+			release = ParseReleaseName(stackName)
+		}
+		if ok && item.Size == info.Size() && item.ModifiedAt.Equal(info.ModTime().UTC()) {
+			// Names are parsed again, items scanned by an older parser pick
+			// up what it missed
+			if item.Release != release {
+				item.Release = release
+				item.SortTitle = sortTitle(release.Title)
+				reparsed = append(reparsed, *item)
+			}
+			if settings.HashContent && item.ContentHash == "" {
+				if item.ContentHash, err = partialContentHash(path); err != nil {
+					result.Errors = append(result.Errors, fmt.Sprintf("hashing %s: %v", relPath, err))
+				} else {
+					hashed = append(hashed, *item)
+				}
+			}
+			result.Unchanged++
+			return nil
+		}
+
+		scanned := models.MediaItem{
+			FolderID:   folderId,
+			RelPath:    relPath,
+			Name:       entry.Name(),
+			Size:       info.Size(),
+			ModifiedAt: info.ModTime().UTC(),
+			AddedAt:    now,
+			ScannedAt:  now,
+			SortTitle:  sortTitle(release.Title),
+			Release:    release,
+		}
+		if probeErr == nil {
+			probe, err := s.mediaToolkit.Probe(path)
+			switch {
+			case errors.Is(err, ErrMediaToolkitUnavailable):
+				// Reported once, the files are still added without a duration
+				probeErr = err
+				result.Errors = append(result.Errors, err.Error())
+			case err != nil:
+				result.Errors = append(result.Errors, fmt.Sprintf("probing %s: %v", relPath, err))
+			default:
+				scanned.Duration = probe.Duration
+			}
+		}
+		if settings.HashContent {
+			if scanned.ContentHash, err = partialContentHash(path); err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("hashing %s: %v", relPath, err))
+			}
+		}
+
+		if ok {
+			scanned.ID = item.ID
+			scanned.AddedAt = item.AddedAt
+			updated = append(updated, scanned)
+		} else {
+			added = append(added, scanned)
+		}
+		return nil
+	})
+	if err != nil {
+		s.logger.Error("scanning folder", "folder_id", folderId, "err", err)
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	mediaItemsRepository := repositories.NewMediaItemsRepository(tx)
+	for _, item := range added {
+		if _, err := mediaItemsRepository.CreateMediaItem(item); err != nil {
+			return nil, err
+		}
+		result.Added++
+	}
+	for _, item := range updated {
+		if err := mediaItemsRepository.UpdateMediaItem(item); err != nil {
+			return nil, err
+		}
+		result.Updated++
+	}
+	for _, item := range reparsed {
+		if err := mediaItemsRepository.UpdateMediaItemRelease(item); err != nil {
+			return nil, err
+		}
+	}
+	for _, item := range hashed {
+		if err := mediaItemsRepository.UpdateContentHash(item.ID, item.ContentHash); err != nil {
+			return nil, err
+		}
+	}
+	for relPath, item := range known {
+		if seen[relPath] || isUnder(relPath, unreadable) {
+			continue
+		}
+		if err := mediaItemsRepository.DeleteMediaItem(item.ID); err != nil {
+			return nil, err
+		}
+		result.Removed++
+	}
+
+	result.Stacks, err = syncStacks(tx, folder)
+	if err != nil {
+		s.logger.Error("stacking parts", "folder_id", folderId, "err", err)
+		return nil, err
+	}
+	result.Episodes, err = syncEpisodes(tx, folder)
+	if err != nil {
+		s.logger.Error("grouping episodes", "folder_id", folderId, "err", err)
+		return nil, err
+	}
+	warnings, err := syncLocalMetadata(tx, folder)
+	if err != nil {
+		s.logger.Error("reading local metadata", "folder_id", folderId, "err", err)
+		return nil, err
+	}
+	result.Errors = append(result.Errors, warnings...)
+	if err := syncSearchIndex(tx, folder); err != nil {
+		s.logger.Error("indexing folder", "folder_id", folderId, "err", err)
+		return nil, err
+	}
+	if groupVersions {
+		result.Versions, err = syncVersions(tx)
+		if err != nil {
+			s.logger.Error("grouping versions", "folder_id", folderId, "err", err)
+			return nil, err
+		}
+	}
+
+	if err := repositories.NewFoldersRepository(tx).MarkFolderScanned(folderId, now); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("scanned folder", "folder_id", folderId, "added", result.Added, "updated", result.Updated, "removed", result.Removed, "stacks", result.Stacks, "episodes", result.Episodes, "versions", result.Versions, "errors", len(result.Errors))
+	return result, nil
+}
+
+// groupVersions groups the versions of titles across the library, after
+// scanning several folders
+func (s *ScanService) groupVersions() (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	versions, err := syncVersions(tx)
+	if err != nil {
+		s.logger.Error("grouping versions", "err", err)
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	s.logger.Info("grouped versions", "versions", versions)
+	return versions, nil
+}
+
+// isUnder reports whether the slash separated relPath is one of dirs or
+// inside one of them
+func isUnder(relPath string, dirs []string) bool {
+	for _, dir := range dirs {
+		if relPath == dir || strings.HasPrefix(relPath, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func isVideoFile(name string) bool {
+	return slices.Contains(videoExtensions, strings.ToLower(filepath.Ext(name)))
+}
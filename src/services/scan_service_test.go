@@ -0,0 +1,210 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"localflix-server/src/logging"
+	"localflix-server/src/repositories"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestScanFolder(t *testing.T) {
+	library := newTestLibrary(t)
+	category, err := library.categories.CreateCategory("Movies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := makeDir(t, "a.mkv", "sub/b.MP4", "notes.txt", ".hidden/c.mkv", "d.avi")
+	folder, err := library.folders.CreateFolder(dir, category.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scan := NewScanService(context.Background(), library.db, NewFakeMediaToolkit(), logging.Discard())
+
+	result, err := scan.ScanFolder(folder.ID)
+	if err != nil {
+		t.Fatalf("ScanFolder: %v", err)
+	}
+	if result.Added != 3 || result.Updated != 0 || result.Removed != 0 {
+		t.Errorf("first scan got %+v, want 3 added", result)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "d.avi")); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "a.mkv"), later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err = scan.ScanFolder(folder.ID)
+	if err != nil {
+		t.Fatalf("ScanFolder: %v", err)
+	}
+	if result.Added != 0 || result.Updated != 1 || result.Removed != 1 || result.Unchanged != 1 {
+		t.Errorf("second scan got %+v, want 1 updated, removed and unchanged", result)
+	}
+
+	items, err := repositories.NewMediaItemsRepository(library.db).ListMediaItemsByFolder(folder.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := map[string]bool{}
+	for _, item := range items {
+		paths[item.RelPath] = true
+	}
+	if len(paths) != 2 || !paths["a.mkv"] || !paths["sub/b.MP4"] {
+		t.Errorf("got media items %v, want a.mkv and sub/b.MP4", paths)
+	}
+}
+
+func TestDeleteFolderDeletesMediaItems(t *testing.T) {
+	library := newTestLibrary(t)
+	category, err := library.categories.CreateCategory("Movies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	folder, err := library.folders.CreateFolder(makeDir(t, "a.mkv"), category.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewScanService(context.Background(), library.db, NewFakeMediaToolkit(), logging.Discard()).ScanFolder(folder.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := library.categories.DeleteCategory(category.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := repositories.NewMediaItemsRepository(library.db).ListMediaItemsByFolder(folder.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 0 {
+		t.Errorf("got media items %+v of a deleted folder", items)
+	}
+}
+
+func TestScanMissingFolderKeepsMediaItems(t *testing.T) {
+	library, folder := newScannedLibrary(t, "a.mkv", "sub/b.mkv")
+	markWatched(t, library.db, 0, folder.ID, "a.mkv")
+
+	// Like an unmounted share
+	if err := os.Rename(folder.Path, folder.Path+"-moved"); err != nil {
+		t.Fatal(err)
+	}
+	result, err := NewScanService(context.Background(), library.db, NewFakeMediaToolkit(), logging.Discard()).ScanFolder(folder.ID)
+	if err == nil {
+		t.Errorf("got %+v, want an error for the missing folder", result)
+	}
+
+	items, err := repositories.NewMediaItemsRepository(library.db).ListMediaItemsByFolder(folder.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 {
+		t.Errorf("got media items %+v, want both kept", items)
+	}
+	progress, err := repositories.NewWatchProgressRepository(library.db).ListProgress(0)
+	if err != nil || len(progress) != 1 {
+		t.Errorf("got progress %+v, %v, want it kept", progress, err)
+	}
+}
+
+func TestScanFolderRecordsDuration(t *testing.T) {
+	library := newTestLibrary(t)
+	category, err := library.categories.CreateCategory("Movies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.mkv"), make([]byte, 90*FakeMediaBytesPerSecond), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	folder, err := library.folders.CreateFolder(dir, category.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	toolkit := NewFakeMediaToolkit()
+	scan := NewScanService(context.Background(), library.db, toolkit, logging.Discard())
+
+	if _, err := scan.ScanFolder(folder.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := scan.ScanFolder(folder.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := repositories.NewMediaItemsRepository(library.db).ListMediaItemsByFolder(folder.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Duration != 90 {
+		t.Errorf("got media items %+v, want a.mkv lasting 90s", items)
+	}
+	// Unchanged files aren't probed again
+	if calls := toolkit.Calls(); len(calls) != 1 {
+		t.Errorf("got toolkit calls %v, want a single probe", calls)
+	}
+}
+
+func TestScanFolderWithoutMediaToolkit(t *testing.T) {
+	library := newTestLibrary(t)
+	category, err := library.categories.CreateCategory("Movies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	folder, err := library.folders.CreateFolder(makeDir(t, "a.mkv", "b.mkv"), category.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scan := NewScanService(context.Background(), library.db, NewUnavailableMediaToolkit(errors.New("ffmpeg not found")), logging.Discard())
+
+	result, err := scan.ScanFolder(folder.ID)
+	if err != nil {
+		t.Fatalf("ScanFolder: %v", err)
+	}
+	if result.Added != 2 || len(result.Errors) != 1 {
+		t.Errorf("got %+v, want both files added and the missing toolkit reported once", result)
+	}
+}
+
+func TestScanFolderParsesReleaseNames(t *testing.T) {
+	library := newTestLibrary(t)
+	category, err := library.categories.CreateCategory("Movies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	folder, err := library.folders.CreateFolder(makeDir(t, "The.Matrix.1999.2160p.UHD.BluRay.x265.mkv"), category.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scan := NewScanService(context.Background(), library.db, NewFakeMediaToolkit(), logging.Discard())
+	if _, err := scan.ScanFolder(folder.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Items scanned before names were parsed are filled in by the next scan
+	// even when the file didn't change
+	if _, err := library.db.Exec("UPDATE media_items SET title = '', year = 0"); err != nil {
+		t.Fatal(err)
+	}
+	result, err := scan.ScanFolder(folder.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Unchanged != 1 {
+		t.Errorf("got %+v, want the file unchanged", result)
+	}
+
+	items, err := repositories.NewMediaItemsRepository(library.db).ListMediaItemsByFolder(folder.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Release.Title != "The Matrix" || items[0].Release.Year != 1999 || items[0].Release.Codec != "H.265" || items[0].SortTitle != "matrix" {
+		t.Errorf("got media items %+v, want The Matrix parsed", items)
+	}
+}
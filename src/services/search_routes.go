@@ -0,0 +1,47 @@
+package services
+
+import (
+	"errors"
+	"localflix-server/src/models"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// registerSearchRoutes adds the full-text search of the library
+func (s *StreamService) registerSearchRoutes(app *fiber.App) {
+	app.Get("/search", s.requireScope(models.ScopeLibraryRead), s.rateLimit, s.search)
+}
+
+// search takes the query in q, and optionally category_id, year, genre,
+// watched (true or false) and limit
+func (s *StreamService) search(c *fiber.Ctx) error {
+	filter := models.SearchFilter{
+		UserID:     userID(c),
+		Query:      c.Query("q"),
+		CategoryID: c.QueryInt("category_id"),
+		Year:       c.QueryInt("year"),
+		Genre:      c.Query("genre"),
+		Limit:      c.QueryInt("limit"),
+	}
+	if watched := c.Query("watched"); watched != "" {
+		value, err := strconv.ParseBool(watched)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString("Invalid watched, expected true or false")
+		}
+		filter.Watched = &value
+	}
+
+	results, err := s.searchService.Search(filter)
+	if err != nil {
+		if errors.Is(err, ErrEmptySearch) {
+			return c.Status(fiber.StatusBadRequest).SendString("Missing search query")
+		}
+		return c.Status(fiber.StatusInternalServerError).SendString("Error searching")
+	}
+
+	for i, result := range results {
+		results[i].URL = streamURL(c, result.MediaItem.FolderID, result.MediaItem.RelPath)
+	}
+	return c.JSON(results)
+}
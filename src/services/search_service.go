@@ -0,0 +1,403 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"localflix-server/src/models"
+	"localflix-server/src/repositories"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	defaultSearchLimit = 50
+	maxSearchLimit     = 200
+	// maxSearchTerms bounds the size of the FTS query
+	maxSearchTerms = 10
+	// maxSubtitleText is how much of the subtitles of a video is indexed
+	maxSubtitleText = 256 << 10
+)
+
+// subtitleExtensions are the sidecar subtitle files indexed for search,
+// named like the video with an optional language: "Movie.en.srt"
+var subtitleExtensions = []string{".srt", ".vtt"}
+
+// searchFieldWeights rank results by where the query terms were found.
+// Terms only found in the plot or the subtitles count 1.
+var searchFieldWeights = []struct {
+	field  func(document *models.SearchDocument) string
+	weight float64
+}{
+	{func(d *models.SearchDocument) string { return d.Title }, 10},
+	{func(d *models.SearchDocument) string { return d.OriginalTitle }, 6},
+	{func(d *models.SearchDocument) string { return d.Series }, 6},
+	{func(d *models.SearchDocument) string { return d.FileName }, 3},
+	{func(d *models.SearchDocument) string { return d.Cast }, 3},
+	{func(d *models.SearchDocument) string { return d.Genres }, 2},
+}
+
+// ErrEmptySearch is returned for a search without any word to look for
+var ErrEmptySearch = errors.New("search query is empty")
+
+type SearchService struct {
+	ctx               context.Context
+	db                *sql.DB
+	searchRepository  *repositories.SearchRepository
+	foldersRepository *repositories.FoldersRepository
+	logger            *slog.Logger
+}
+
+// NewSearchService creates a new SearchService struct
+func NewSearchService(ctx context.Context, db *sql.DB, logger *slog.Logger) *SearchService {
+	return &SearchService{
+		ctx:               ctx,
+		db:                db,
+		searchRepository:  repositories.NewSearchRepository(db),
+		foldersRepository: repositories.NewFoldersRepository(db),
+		logger:            logger,
+	}
+}
+
+// searchTerm is a word of the query, with the indexed words it may be a typo
+// of when nothing starts with it
+type searchTerm struct {
+	word        string
+	corrections []string
+}
+
+// Search finds the media items matching every word of the query, as a prefix
+// of an indexed word or with a typo or two, and ranks them by where they
+// matched: titles first, then file names and cast, then plots and subtitles.
+func (s *SearchService) Search(filter models.SearchFilter) ([]models.SearchResult, error) {
+	words := searchWords(filter.Query)
+	if len(words) == 0 {
+		return nil, ErrEmptySearch
+	}
+	if len(words) > maxSearchTerms {
+		words = words[:maxSearchTerms]
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	limit = min(limit, maxSearchLimit)
+
+	terms := make([]searchTerm, len(words))
+	expressions := make([]string, len(words))
+	for i, word := range words {
+		term, err := s.searchTerm(word)
+		if err != nil {
+			s.logger.Error("listing search terms", "word", word, "err", err)
+			return nil, err
+		}
+		terms[i] = term
+		expressions[i] = term.expression()
+	}
+
+	hits, err := s.searchRepository.Search(strings.Join(expressions, " AND "), filter)
+	if err != nil {
+		s.logger.Error("searching", "query", filter.Query, "err", err)
+		return nil, err
+	}
+
+	query := strings.Join(words, " ")
+	for _, hit := range hits {
+		hit.Result.Score = scoreSearchHit(query, terms, &hit.Document)
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		a, b := hits[i].Result, hits[j].Result
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.MediaItem.SortTitle != b.MediaItem.SortTitle {
+			return a.MediaItem.SortTitle < b.MediaItem.SortTitle
+		}
+		return a.MediaItem.ID < b.MediaItem.ID
+	})
+
+	results := []models.SearchResult{}
+	for _, hit := range hits[:min(limit, len(hits))] {
+		results = append(results, hit.Result)
+	}
+	s.logger.Debug("searched", "query", filter.Query, "hits", len(hits))
+	return results, nil
+}
+
+// searchTerm looks for the indexed words the query word may be a typo of,
+// when no indexed word starts with it
+func (s *SearchService) searchTerm(word string) (searchTerm, error) {
+	term := searchTerm{word: word}
+	found, err := s.searchRepository.ListTerms(word, 1)
+	if err != nil || len(found) > 0 {
+		return term, err
+	}
+
+	maxTypos := maxSearchTypos(word)
+	if maxTypos == 0 {
+		return term, nil
+	}
+	// Typos in the first letter are rare enough not to look at every word
+	candidates, err := s.searchRepository.ListTerms(string([]rune(word)[:1]), 5000)
+	if err != nil {
+		return term, err
+	}
+
+	best := maxTypos + 1
+	length := len([]rune(word))
+	for _, candidate := range candidates {
+		if diff := len([]rune(candidate)) - length; diff > maxTypos || diff < -maxTypos {
+			continue
+		}
+		distance := editDistance(word, candidate)
+		switch {
+		case distance < best:
+			best = distance
+			term.corrections = []string{candidate}
+		case distance == best && len(term.corrections) < 3:
+			term.corrections = append(term.corrections, candidate)
+		}
+	}
+
+	return term, nil
+}
+
+// expression is the FTS query of the term. Words only hold letters and
+// digits, so they are safe as FTS4 and FTS5 barewords.
+func (t searchTerm) expression() string {
+	if len(t.corrections) == 0 {
+		return t.word + "*"
+	}
+
+	return "(" + strings.Join(t.corrections, " OR ") + ")"
+}
+
+// maxSearchTypos is how many typos a word of the query may have, none for
+// short words as they'd match too much
+func maxSearchTypos(word string) int {
+	switch length := len([]rune(word)); {
+	case length < 4:
+		return 0
+	case length < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// scoreSearchHit adds up, for every term, the weight of the best field it was
+// found in. Whole words count more than prefixes, corrected typos less.
+// Titles equal to the query, or starting with it, get a bonus.
+func scoreSearchHit(query string, terms []searchTerm, document *models.SearchDocument) float64 {
+	fieldWords := make([][]string, len(searchFieldWeights))
+	for i, field := range searchFieldWeights {
+		fieldWords[i] = searchWords(field.field(document))
+	}
+
+	score := 0.0
+	for _, term := range terms {
+		candidates := []string{term.word}
+		factor := 1.0
+		if len(term.corrections) > 0 {
+			candidates = term.corrections
+			factor = 0.7
+		}
+
+		best := 1.0
+		for i, field := range searchFieldWeights {
+			for _, word := range fieldWords[i] {
+				for _, candidate := range candidates {
+					switch {
+					case word == candidate:
+						best = max(best, field.weight*factor)
+					case strings.HasPrefix(word, candidate):
+						best = max(best, field.weight*factor*0.8)
+					}
+				}
+			}
+		}
+		score += best
+	}
+
+	title := strings.Join(searchWords(document.Title), " ")
+	switch {
+	case title == query:
+		score += 10
+	case strings.HasPrefix(title, query):
+		score += 3
+	}
+	return score
+}
+
+// searchWords splits text into lowercased words of letters and digits, like
+// the index tokenizer
+func searchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// EnsureIndex fills the search index again when media items are missing
+// from it, e.g. when it was just created
+func (s *SearchService) EnsureIndex() error {
+	complete, err := s.searchRepository.IsComplete()
+	if err != nil {
+		s.logger.Error("checking search index", "err", err)
+		return err
+	}
+	if complete {
+		return nil
+	}
+
+	return s.RebuildIndex()
+}
+
+// RebuildIndex indexes every media item of the library again
+func (s *SearchService) RebuildIndex() error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	folders := s.foldersRepository.ListFolders()
+	for _, folder := range folders {
+		if err := syncSearchIndex(tx, folder); err != nil {
+			s.logger.Error("indexing folder", "folder_id", folder.ID, "err", err)
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	s.logger.Info("rebuilt search index", "folders", len(folders))
+	return nil
+}
+
+// syncSearchIndex indexes the media items of the folder in the scan's
+// transaction, together with the sidecar subtitles of their videos, and
+// drops the documents of removed items
+func syncSearchIndex(tx repositories.DBTX, folder *models.Folder) error {
+	searchRepository := repositories.NewSearchRepository(tx)
+	documents, err := searchRepository.ListDocuments(folder.ID)
+	if err != nil {
+		return err
+	}
+
+	dirFiles := map[string]map[string]string{}
+	for _, document := range documents {
+		dir := filepath.Dir(document.Path)
+		files, ok := dirFiles[dir]
+		if !ok {
+			files = listFilesByLowerName(dir)
+			dirFiles[dir] = files
+		}
+		document.Subtitles = readSidecarSubtitles(dir, files, path.Base(document.FileName))
+
+		if err := searchRepository.ReplaceDocument(*document); err != nil {
+			return err
+		}
+	}
+
+	return searchRepository.DeleteOrphans()
+}
+
+// indexMediaItem indexes the media item again, after its metadata changed
+func indexMediaItem(tx repositories.DBTX, mediaItemId int) error {
+	searchRepository := repositories.NewSearchRepository(tx)
+	document, err := searchRepository.GetDocument(mediaItemId)
+	if err != nil || document == nil {
+		return err
+	}
+
+	dir := filepath.Dir(document.Path)
+	document.Subtitles = readSidecarSubtitles(dir, listFilesByLowerName(dir), path.Base(document.FileName))
+	return searchRepository.ReplaceDocument(*document)
+}
+
+// readSidecarSubtitles returns the text of the subtitle files next to the
+// video, up to maxSubtitleText. Unreadable files are skipped, search works
+// without them.
+func readSidecarSubtitles(dir string, files map[string]string, videoName string) string {
+	prefix := strings.ToLower(strings.TrimSuffix(videoName, filepath.Ext(videoName))) + "."
+
+	var names []string
+	for lowerName, name := range files {
+		if strings.HasPrefix(lowerName, prefix) && slices.Contains(subtitleExtensions, filepath.Ext(lowerName)) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var text strings.Builder
+	for _, name := range names {
+		if text.Len() >= maxSubtitleText {
+			break
+		}
+		if err := appendSubtitleText(&text, filepath.Join(dir, name)); err != nil {
+			continue
+		}
+	}
+
+	return text.String()
+}
+
+// appendSubtitleText appends the dialog lines of an SRT or WebVTT file,
+// without cue numbers, timings and formatting tags
+func appendSubtitleText(text *strings.Builder, subtitlesPath string) error {
+	file, err := os.Open(subtitlesPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() && text.Len() < maxSubtitleText {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		if line == "" || line == "WEBVTT" || strings.Contains(line, "-->") || isDigits(line) {
+			continue
+		}
+		line = stripSubtitleTags(line)
+		if line == "" {
+			continue
+		}
+		fmt.Fprintln(text, line)
+	}
+
+	return scanner.Err()
+}
+
+// stripSubtitleTags removes <i>-style and {\an8}-style tags
+func stripSubtitleTags(line string) string {
+	var stripped strings.Builder
+	closing := rune(0)
+	for _, r := range line {
+		switch {
+		case closing != 0:
+			if r == closing {
+				closing = 0
+			}
+		case r == '<':
+			closing = '>'
+		case r == '{':
+			closing = '}'
+		default:
+			stripped.WriteRune(r)
+		}
+	}
+
+	return strings.TrimSpace(stripped.String())
+}
+
+func isDigits(text string) bool {
+	return strings.IndexFunc(text, func(r rune) bool { return r < '0' || r > '9' }) == -1
+}
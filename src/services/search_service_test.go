@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"localflix-server/src/logging"
+	"localflix-server/src/models"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var searchFiles = []string{
+	"The.Matrix.1999.1080p.BluRay.x264.mkv",
+	"The Matrix Reloaded (2003).mkv",
+	"Inception (2010)/Inception (2010).mkv",
+	`Inception (2010)/Inception (2010).nfo=<movie><title>Inception</title><year>2010</year><genre>Sci-Fi</genre>
+		<actor><name>Leonardo DiCaprio</name><role>Cobb</role></actor></movie>`,
+	"Inception (2010)/Inception (2010).en.srt=1\n00:00:01,000 --> 00:00:03,000\n<i>You mustn't be afraid to dream a little bigger, darling.</i>\n",
+	"Show/Season 1/Show.S01E01.Pilot.mkv",
+}
+
+func TestSearch(t *testing.T) {
+	library, folder := newScannedLibrary(t, searchFiles...)
+	search := NewSearchService(context.Background(), library.db, logging.Discard())
+	markWatched(t, library.db, 0, folder.ID, "The.Matrix.1999.1080p.BluRay.x264.mkv")
+	watched, unwatched := true, false
+
+	tests := []struct {
+		name   string
+		filter models.SearchFilter
+		want   []string
+	}{
+		{"title", models.SearchFilter{Query: "matrix"}, []string{"The Matrix", "The Matrix Reloaded"}},
+		{"prefix", models.SearchFilter{Query: "Matr rel"}, []string{"The Matrix Reloaded"}},
+		{"typo", models.SearchFilter{Query: "matrx"}, []string{"The Matrix", "The Matrix Reloaded"}},
+		{"cast", models.SearchFilter{Query: "dicaprio"}, []string{"Inception"}},
+		{"subtitles", models.SearchFilter{Query: "darling"}, []string{"Inception"}},
+		{"series", models.SearchFilter{Query: "show pilot"}, []string{"Show"}},
+		{"year", models.SearchFilter{Query: "matrix", Year: 2003}, []string{"The Matrix Reloaded"}},
+		{"genre", models.SearchFilter{Query: "inception", Genre: "sci-fi"}, []string{"Inception"}},
+		{"other genre", models.SearchFilter{Query: "inception", Genre: "Drama"}, nil},
+		{"watched", models.SearchFilter{Query: "matrix", Watched: &watched}, []string{"The Matrix"}},
+		{"unwatched", models.SearchFilter{Query: "matrix", Watched: &unwatched}, []string{"The Matrix Reloaded"}},
+		{"category", models.SearchFilter{Query: "matrix", CategoryID: folder.CategoryID + 1}, nil},
+		{"limit", models.SearchFilter{Query: "matrix", Limit: 1}, []string{"The Matrix"}},
+		{"no match", models.SearchFilter{Query: "zzzz"}, nil},
+	}
+	for _, test := range tests {
+		results, err := search.Search(test.filter)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		var titles []string
+		for _, result := range results {
+			titles = append(titles, result.Title)
+		}
+		if strings.Join(titles, "|") != strings.Join(test.want, "|") {
+			t.Errorf("%s: got %q, want %q", test.name, titles, test.want)
+		}
+	}
+
+	if _, err := search.Search(models.SearchFilter{Query: " - "}); !errors.Is(err, ErrEmptySearch) {
+		t.Errorf("empty search returned %v, want ErrEmptySearch", err)
+	}
+}
+
+func TestSearchIndexFollowsTheLibrary(t *testing.T) {
+	library, folder := newScannedLibrary(t, searchFiles...)
+	search := NewSearchService(context.Background(), library.db, logging.Discard())
+
+	// Removed files leave the index on the next scan
+	if err := os.Remove(filepath.Join(folder.Path, "The Matrix Reloaded (2003).mkv")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewScanService(context.Background(), library.db, NewFakeMediaToolkit(), logging.Discard()).ScanFolder(folder.ID); err != nil {
+		t.Fatal(err)
+	}
+	if results, err := search.Search(models.SearchFilter{Query: "reloaded"}); err != nil || len(results) != 0 {
+		t.Errorf("got %+v, %v for a removed file", results, err)
+	}
+
+	// A dropped index is filled again
+	if _, err := library.db.Exec("DELETE FROM search_index"); err != nil {
+		t.Fatal(err)
+	}
+	if err := search.EnsureIndex(); err != nil {
+		t.Fatal(err)
+	}
+	if results, err := search.Search(models.SearchFilter{Query: "matrix"}); err != nil || len(results) != 1 {
+		t.Errorf("got %+v, %v after rebuilding", results, err)
+	}
+}
+
+func TestSearchRoute(t *testing.T) {
+	library, _ := newScannedLibrary(t, searchFiles...)
+	app := newTestStreamApp(t, library, NewFakeMediaToolkit())
+
+	status, body := get(t, app, "/search?q=inception")
+	var results []models.SearchResult
+	if status != fiber.StatusOK || json.Unmarshal([]byte(body), &results) != nil || len(results) != 1 {
+		t.Fatalf("/search = %d %q", status, body)
+	}
+	if !strings.HasSuffix(results[0].URL, "/stream/1/Inception%20%282010%29%2FInception%20%282010%29.mkv") {
+		t.Errorf("got url %q", results[0].URL)
+	}
+	if status, _ := get(t, app, "/search"); status != fiber.StatusBadRequest {
+		t.Errorf("search without a query = %d, want 400", status)
+	}
+	if status, _ := get(t, app, "/search?q=matrix&watched=maybe"); status != fiber.StatusBadRequest {
+		t.Errorf("invalid watched = %d, want 400", status)
+	}
+}
+
+func TestReadSidecarSubtitles(t *testing.T) {
+	dir := t.TempDir()
+	vtt := "WEBVTT\n\n00:01.000 --> 00:02.000\n{\\an8}Hello <b>there</b>\n"
+	if err := os.WriteFile(filepath.Join(dir, "Movie.fr.VTT"), []byte(vtt), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "Movie 2.srt"), []byte("1\nOther movie\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readSidecarSubtitles(dir, listFilesByLowerName(dir), "Movie.mkv"); got != "Hello there\n" {
+		t.Errorf("got %q", got)
+	}
+}
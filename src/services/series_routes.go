@@ -0,0 +1,74 @@
+package services
+
+import (
+	"localflix-server/src/models"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// registerSeriesRoutes adds the routes browsing the episodes found by the
+// scanner by series and season
+func (s *StreamService) registerSeriesRoutes(app *fiber.App) {
+	app.Get("/series", s.requireScope(models.ScopeLibraryRead), s.rateLimit, s.listSeries)
+	app.Get("/series/:seriesId/seasons", s.requireScope(models.ScopeLibraryRead), s.rateLimit, s.listSeasons)
+	app.Get("/seasons/:seasonId/episodes", s.requireScope(models.ScopeLibraryRead), s.rateLimit, s.listEpisodes)
+}
+
+func (s *StreamService) listSeries(c *fiber.Ctx) error {
+	page, err := s.seriesService.ListSeries(listOptions(c))
+	if err != nil {
+		return sendListError(c, err, "Error listing series")
+	}
+
+	return sendPage(c, page.Items, page.Total)
+}
+
+func (s *StreamService) listSeasons(c *fiber.Ctx) error {
+	seriesId, err := strconv.Atoi(c.Params("seriesId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid series ID")
+	}
+
+	series, err := s.seriesService.GetSeries(seriesId)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("Error retrieving series")
+	}
+	if series == nil {
+		return c.Status(fiber.StatusNotFound).SendString("Series not found")
+	}
+
+	page, err := s.seriesService.ListSeasons(seriesId, listOptions(c))
+	if err != nil {
+		return sendListError(c, err, "Error listing seasons")
+	}
+
+	return sendPage(c, page.Items, page.Total)
+}
+
+func (s *StreamService) listEpisodes(c *fiber.Ctx) error {
+	seasonId, err := strconv.Atoi(c.Params("seasonId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid season ID")
+	}
+
+	season, err := s.seriesService.GetSeason(seasonId)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("Error retrieving season")
+	}
+	if season == nil {
+		return c.Status(fiber.StatusNotFound).SendString("Season not found")
+	}
+
+	page, err := s.seriesService.ListEpisodes(seasonId, listOptions(c))
+	if err != nil {
+		return sendListError(c, err, "Error listing episodes")
+	}
+
+	// The rel path is escaped whole, slashes included, so it stays a single
+	// fileName param of the stream route
+	for i, episode := range page.Items {
+		page.Items[i].URL = streamURL(c, episode.MediaItem.FolderID, episode.MediaItem.RelPath)
+	}
+	return sendPage(c, page.Items, page.Total)
+}
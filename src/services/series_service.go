@@ -0,0 +1,178 @@
+package services
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"localflix-server/src/models"
+	"localflix-server/src/repositories"
+	"log/slog"
+	"path/filepath"
+)
+
+type SeriesService struct {
+	ctx              context.Context
+	db               *sql.DB
+	seriesRepository *repositories.SeriesRepository
+	logger           *slog.Logger
+}
+
+// NewSeriesService creates a new SeriesService struct
+func NewSeriesService(ctx context.Context, db *sql.DB, logger *slog.Logger) *SeriesService {
+	return &SeriesService{
+		ctx:              ctx,
+		db:               db,
+		seriesRepository: repositories.NewSeriesRepository(db),
+		logger:           logger,
+	}
+}
+
+// ListSeries pages the series that have episodes, by name unless sorted
+// otherwise
+func (s *SeriesService) ListSeries(options models.ListOptions) (*models.SeriesPage, error) {
+	query, err := parseListOptions(options, []string{models.SortName, models.SortAddedAt, models.SortYear}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	series, err := s.seriesRepository.ListSeries()
+	if err != nil {
+		s.logger.Error("listing series", "err", err)
+		return nil, err
+	}
+
+	result := make([]models.Series, len(series))
+	for i, item := range series {
+		result[i] = *item
+	}
+	return &models.SeriesPage{
+		Items: pageOf(result, query, map[string]func(a, b models.Series) int{
+			models.SortName:    func(a, b models.Series) int { return compareFold(a.Title, b.Title) },
+			models.SortAddedAt: func(a, b models.Series) int { return a.CreatedAt.Compare(b.CreatedAt) },
+			models.SortYear:    func(a, b models.Series) int { return cmp.Compare(a.Year, b.Year) },
+		}),
+		Total: len(result),
+	}, nil
+}
+
+// GetSeries returns nil when there is no series with episodes with the id
+func (s *SeriesService) GetSeries(id int) (*models.Series, error) {
+	series, err := s.seriesRepository.GetSeries(id)
+	if err != nil {
+		s.logger.Error("getting series", "id", id, "err", err)
+		return nil, err
+	}
+
+	return series, nil
+}
+
+// ListSeasons pages the seasons of the series, in season order
+func (s *SeriesService) ListSeasons(seriesId int, options models.ListOptions) (*models.SeasonPage, error) {
+	query, err := parseListOptions(options, []string{models.SortNumber}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	seasons, err := s.seriesRepository.ListSeasons(seriesId)
+	if err != nil {
+		s.logger.Error("listing seasons", "series_id", seriesId, "err", err)
+		return nil, err
+	}
+
+	result := make([]models.Season, len(seasons))
+	for i, season := range seasons {
+		result[i] = *season
+	}
+	return &models.SeasonPage{
+		Items: pageOf(result, query, map[string]func(a, b models.Season) int{
+			models.SortNumber: func(a, b models.Season) int { return cmp.Compare(a.Number, b.Number) },
+		}),
+		Total: len(result),
+	}, nil
+}
+
+// GetSeason returns nil when there is no season with episodes with the id
+func (s *SeriesService) GetSeason(id int) (*models.Season, error) {
+	season, err := s.seriesRepository.GetSeason(id)
+	if err != nil {
+		s.logger.Error("getting season", "id", id, "err", err)
+		return nil, err
+	}
+
+	return season, nil
+}
+
+// ListEpisodes pages the episodes of the season, in episode order unless
+// sorted otherwise. They filter like media items.
+func (s *SeriesService) ListEpisodes(seasonId int, options models.ListOptions) (*models.EpisodePage, error) {
+	query, err := parseListOptions(options, append([]string{models.SortNumber}, mediaItemSorts...), mediaItemFilters)
+	if err != nil {
+		return nil, err
+	}
+
+	episodes, total, err := s.seriesRepository.ListEpisodes(seasonId, query)
+	if err != nil {
+		s.logger.Error("listing episodes", "season_id", seasonId, "err", err)
+		return nil, err
+	}
+
+	result := make([]models.Episode, len(episodes))
+	for i, episode := range episodes {
+		result[i] = *episode
+	}
+	return &models.EpisodePage{Items: result, Total: total}, nil
+}
+
+// syncEpisodes places the media items of the folder into series and seasons
+// from their paths. It runs in the scan's transaction, items that no longer
+// parse as episodes are taken out and emptied seasons and series removed.
+func syncEpisodes(tx repositories.DBTX, folder *models.Folder) (int, error) {
+	items, err := repositories.NewMediaItemsRepository(tx).ListMediaItemsByFolder(folder.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	seriesRepository := repositories.NewSeriesRepository(tx)
+	seriesIds := map[string]int{}
+	episodes := 0
+	for _, item := range items {
+		info, ok := ParseEpisodePath(item.RelPath, filepath.Base(folder.Path))
+		if !ok {
+			if err := seriesRepository.DeleteEpisodeByMediaItem(item.ID); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		key := titleMatchKey(info.SeriesTitle, info.SeriesYear)
+		seriesId, ok := seriesIds[key]
+		if !ok {
+			seriesId, err = seriesRepository.FindOrCreateSeries(info.SeriesTitle, info.SeriesYear, key)
+			if err != nil {
+				return 0, err
+			}
+			seriesIds[key] = seriesId
+		}
+
+		seasonId, err := seriesRepository.FindOrCreateSeason(seriesId, info.Season)
+		if err != nil {
+			return 0, err
+		}
+
+		err = seriesRepository.UpsertEpisode(models.Episode{
+			SeasonID:       seasonId,
+			MediaItemID:    item.ID,
+			Number:         info.Number,
+			EndNumber:      info.EndNumber,
+			AbsoluteNumber: info.AbsoluteNumber,
+			AirDate:        info.AirDate,
+			Title:          info.Title,
+		})
+		if err != nil {
+			return 0, err
+		}
+		episodes++
+	}
+
+	return episodes, seriesRepository.DeleteEmpty()
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"localflix-server/src/logging"
+	"localflix-server/src/models"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestScanGroupsEpisodes(t *testing.T) {
+	library := newTestLibrary(t)
+	category, err := library.categories.CreateCategory("Series")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := makeDir(t,
+		"Show.S01E02.mkv",
+		"Show.S01E01.Pilot.mkv",
+		"show s02e01.mkv",
+		"Other Show/Season 1/01.mkv",
+		"Some Movie (2019).mkv",
+	)
+	folder, err := library.folders.CreateFolder(dir, category.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scan := NewScanService(context.Background(), library.db, NewFakeMediaToolkit(), logging.Discard())
+	series := NewSeriesService(context.Background(), library.db, logging.Discard())
+
+	result, err := scan.ScanFolder(folder.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Added != 5 || result.Episodes != 4 {
+		t.Errorf("got %+v, want 5 added and 4 episodes", result)
+	}
+
+	page, err := series.ListSeries(models.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := page.Items
+	if len(list) != 2 || list[0].Title != "Other Show" || list[1].Title != "Show" || list[1].SeasonCount != 2 || list[1].EpisodeCount != 3 {
+		t.Fatalf("got series %+v, want Other Show and Show with 2 seasons", list)
+	}
+
+	seasonPage, err := series.ListSeasons(list[1].ID, models.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	seasons := seasonPage.Items
+	if len(seasons) != 2 || seasons[0].Number != 1 || seasons[1].Number != 2 {
+		t.Fatalf("got seasons %+v, want 1 and 2", seasons)
+	}
+	episodePage, err := series.ListEpisodes(seasons[0].ID, models.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	episodes := episodePage.Items
+	if len(episodes) != 2 || episodes[0].Number != 1 || episodes[0].Title != "Pilot" || episodes[1].MediaItem.RelPath != "Show.S01E02.mkv" {
+		t.Errorf("got episodes %+v, want 1 and 2 in order", episodes)
+	}
+
+	// Removed episodes take their emptied season and series with them
+	for _, file := range []string{"show s02e01.mkv", "Other Show/Season 1/01.mkv"} {
+		if err := os.Remove(filepath.Join(dir, filepath.FromSlash(file))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := scan.ScanFolder(folder.ID); err != nil {
+		t.Fatal(err)
+	}
+	page, err = series.ListSeries(models.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	list = page.Items
+	if len(list) != 1 || list[0].SeasonCount != 1 || list[0].EpisodeCount != 2 {
+		t.Errorf("got series %+v after removing files, want Show with one season", list)
+	}
+	var seriesRows int
+	if err := library.db.QueryRow("SELECT COUNT(*) FROM series").Scan(&seriesRows); err != nil {
+		t.Fatal(err)
+	}
+	if seriesRows != 1 {
+		t.Errorf("got %d series rows, want the empty one deleted", seriesRows)
+	}
+}
+
+func TestSeriesRoutes(t *testing.T) {
+	library := newTestLibrary(t)
+	category, err := library.categories.CreateCategory("Series")
+	if err != nil {
+		t.Fatal(err)
+	}
+	folder, err := library.folders.CreateFolder(makeDir(t, "Show/Season 1/Show - S01E01.mkv"), category.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewScanService(context.Background(), library.db, NewFakeMediaToolkit(), logging.Discard()).ScanFolder(folder.ID); err != nil {
+		t.Fatal(err)
+	}
+	app := newTestStreamApp(t, library, NewFakeMediaToolkit())
+
+	status, body := get(t, app, "/series")
+	var series []models.Series
+	if status != fiber.StatusOK || json.Unmarshal([]byte(body), &series) != nil || len(series) != 1 {
+		t.Fatalf("/series = %d %q", status, body)
+	}
+
+	status, body = get(t, app, "/series/"+strconv.Itoa(series[0].ID)+"/seasons")
+	var seasons []models.Season
+	if status != fiber.StatusOK || json.Unmarshal([]byte(body), &seasons) != nil || len(seasons) != 1 {
+		t.Fatalf("/series/:id/seasons = %d %q", status, body)
+	}
+
+	status, body = get(t, app, "/seasons/"+strconv.Itoa(seasons[0].ID)+"/episodes")
+	var episodes []models.Episode
+	if status != fiber.StatusOK || json.Unmarshal([]byte(body), &episodes) != nil || len(episodes) != 1 {
+		t.Fatalf("/seasons/:id/episodes = %d %q", status, body)
+	}
+	if !strings.HasSuffix(episodes[0].URL, "/stream/1/Show%2FSeason%201%2FShow%20-%20S01E01.mkv") {
+		t.Errorf("got episode url %q, want the escaped rel path", episodes[0].URL)
+	}
+
+	if status, _ := get(t, app, "/series/999/seasons"); status != fiber.StatusNotFound {
+		t.Errorf("unknown series = %d, want 404", status)
+	}
+	if status, _ := get(t, app, "/seasons/999/episodes"); status != fiber.StatusNotFound {
+		t.Errorf("unknown season = %d, want 404", status)
+	}
+}
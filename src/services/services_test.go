@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"localflix-server/src/appdata"
+	"localflix-server/src/db"
+	"localflix-server/src/logging"
+	"localflix-server/src/models"
+	"localflix-server/src/repositories"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestDatabase opens a migrated database private to the test. It's a
+// file like the app's, so a scan writing in the background makes readers
+// wait instead of failing like a shared in-memory database does.
+func newTestDatabase(t *testing.T) *sql.DB {
+	t.Helper()
+
+	appDatabase, err := db.OpenAppDatabase(filepath.Join(t.TempDir(), "localflix.db"), logging.Discard())
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	t.Cleanup(func() { appDatabase.Db.Close() })
+
+	return appDatabase.Db
+}
+
+func newTestDirs(t *testing.T) *appdata.Dirs {
+	t.Helper()
+
+	dirs := &appdata.Dirs{Root: t.TempDir()}
+	if err := dirs.Ensure(); err != nil {
+		t.Fatalf("creating data dir: %v", err)
+	}
+
+	return dirs
+}
+
+// testLibrary bundles the library services of one test database
+type testLibrary struct {
+	db         *sql.DB
+	dirs       *appdata.Dirs
+	categories *CategoriesService
+	folders    *FoldersService
+}
+
+func newTestLibrary(t *testing.T) *testLibrary {
+	t.Helper()
+
+	database := newTestDatabase(t)
+	dirs := newTestDirs(t)
+	return &testLibrary{
+		db:         database,
+		dirs:       dirs,
+		categories: NewCategoriesService(context.Background(), database, dirs, logging.Discard()),
+		folders:    NewFoldersService(context.Background(), database, dirs, logging.Discard()),
+	}
+}
+
+// makeDir creates a directory with the given files, relative to a new
+// temporary directory, and returns its path. A file given as path=content
+// holds that content, the others hold their path.
+func makeDir(t *testing.T, files ...string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for _, file := range files {
+		name, content, ok := strings.Cut(file, "=")
+		if !ok {
+			content = file
+		}
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+// newScannedLibrary creates a library with a folder of the given files, as
+// makeDir does, in a Movies category, and scans it with the fake toolkit
+func newScannedLibrary(t *testing.T, files ...string) (*testLibrary, *models.Folder) {
+	t.Helper()
+
+	library := newTestLibrary(t)
+	category, err := library.categories.CreateCategory("Movies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	folder, err := library.folders.CreateFolder(makeDir(t, files...), category.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewScanService(context.Background(), library.db, NewFakeMediaToolkit(), logging.Discard()).ScanFolder(folder.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	return library, folder
+}
+
+// mediaItemIDs maps the titles of the scanned media items of the folder to
+// their IDs
+func mediaItemIDs(t *testing.T, db *sql.DB, folderId int) map[string]int {
+	t.Helper()
+
+	items, err := repositories.NewMediaItemsRepository(db).ListMediaItemsByFolder(folderId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := map[string]int{}
+	for _, item := range items {
+		ids[item.Release.Title] = item.ID
+	}
+	return ids
+}
+
+// markWatched stores that the user watched the scanned file of the folder to
+// the end
+func markWatched(t *testing.T, db *sql.DB, userId int, folderId int, relPath string) {
+	t.Helper()
+
+	item, err := repositories.NewMediaItemsRepository(db).GetMediaItemByPath(folderId, relPath)
+	if err != nil || item == nil {
+		t.Fatalf("getting media item %s: %v", relPath, err)
+	}
+	err = repositories.NewWatchProgressRepository(db).SaveProgress(models.WatchProgress{
+		UserID:      userId,
+		MediaItemID: item.ID,
+		Position:    item.Duration,
+		Duration:    item.Duration,
+		Watched:     true,
+		UpdatedAt:   time.Now().UTC(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,362 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"localflix-server/src/logging"
+	"localflix-server/src/models"
+	"localflix-server/src/repositories"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+const (
+	corsSettingsKey      = "cors"
+	tlsSettingsKey       = "tls"
+	rateLimitSettingsKey = "rate_limit"
+	logSettingsKey       = "logging"
+	metadataSettingsKey  = "metadata"
+	scanSettingsKey      = "scan"
+)
+
+const defaultRedirectPort = 3080
+
+// defaultMinConfidence only lets automatic matching through when the title
+// matches almost exactly and the year doesn't contradict it
+const defaultMinConfidence = 0.85
+
+// defaultRescanMinutes picks up files added outside the app within the hour
+const defaultRescanMinutes = 60
+
+// apiMethods are the HTTP methods the streaming API actually serves, the CORS
+// policy can't allow anything outside of them.
+var apiMethods = []string{"GET", "HEAD", "POST", "PATCH", "DELETE", "OPTIONS"}
+
+type SettingsService struct {
+	ctx                context.Context
+	settingsRepository *repositories.SettingsRepository
+	logger             *slog.Logger
+}
+
+// NewSettingsService creates a new SettingsService struct
+func NewSettingsService(ctx context.Context, db *sql.DB, logger *slog.Logger) *SettingsService {
+	return &SettingsService{
+		ctx:                ctx,
+		settingsRepository: repositories.NewSettingsRepository(db),
+		logger:             logger,
+	}
+}
+
+// GetCorsSettings returns the stored CORS policy. Until one is saved no
+// origin is allowed, so browsers are limited to same-origin requests.
+func (s *SettingsService) GetCorsSettings() (*models.CorsSettings, error) {
+	settings := &models.CorsSettings{
+		AllowOrigins: []string{},
+		AllowMethods: slices.Clone(apiMethods),
+	}
+	if err := s.getJSON(corsSettingsKey, settings); err != nil {
+		return nil, err
+	}
+
+	return settings, nil
+}
+
+func (s *SettingsService) UpdateCorsSettings(settings models.CorsSettings) (*models.CorsSettings, error) {
+	origins := []string{}
+	for _, origin := range settings.AllowOrigins {
+		origin = strings.TrimRight(strings.TrimSpace(origin), "/")
+		if origin == "" {
+			continue
+		}
+		if origin != "*" && !strings.HasPrefix(origin, "http://") && !strings.HasPrefix(origin, "https://") {
+			return nil, fmt.Errorf("invalid origin: %s", origin)
+		}
+		origins = append(origins, origin)
+	}
+	if settings.AllowCredentials && slices.Contains(origins, "*") {
+		return nil, fmt.Errorf("credentials can't be allowed for the * origin")
+	}
+
+	methods := []string{}
+	for _, method := range settings.AllowMethods {
+		method = strings.ToUpper(strings.TrimSpace(method))
+		if !slices.Contains(apiMethods, method) {
+			return nil, fmt.Errorf("method not used by the api: %s", method)
+		}
+		if !slices.Contains(methods, method) {
+			methods = append(methods, method)
+		}
+	}
+	if len(methods) == 0 {
+		methods = slices.Clone(apiMethods)
+	}
+
+	result := &models.CorsSettings{
+		AllowOrigins:     origins,
+		AllowMethods:     methods,
+		AllowCredentials: settings.AllowCredentials,
+	}
+	if err := s.setJSON(corsSettingsKey, result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *SettingsService) GetTlsSettings() (*models.TlsSettings, error) {
+	settings := &models.TlsSettings{
+		RedirectPort: defaultRedirectPort,
+	}
+	if err := s.getJSON(tlsSettingsKey, settings); err != nil {
+		return nil, err
+	}
+
+	return settings, nil
+}
+
+func (s *SettingsService) UpdateTlsSettings(settings models.TlsSettings) (*models.TlsSettings, error) {
+	if (settings.CertFile == "") != (settings.KeyFile == "") {
+		return nil, fmt.Errorf("both a certificate and a key file are required")
+	}
+	if settings.CertFile != "" {
+		if _, err := tls.LoadX509KeyPair(settings.CertFile, settings.KeyFile); err != nil {
+			return nil, fmt.Errorf("invalid certificate/key pair: %w", err)
+		}
+	}
+	if settings.RedirectPort == 0 {
+		settings.RedirectPort = defaultRedirectPort
+	}
+	if settings.RedirectPort < 1 || settings.RedirectPort > 65535 || settings.RedirectPort == serverPort {
+		return nil, fmt.Errorf("invalid redirect port: %d", settings.RedirectPort)
+	}
+
+	if err := s.setJSON(tlsSettingsKey, settings); err != nil {
+		return nil, err
+	}
+
+	return &settings, nil
+}
+
+func (s *SettingsService) GetRateLimitSettings() (*models.RateLimitSettings, error) {
+	settings := &models.RateLimitSettings{}
+	if err := s.getJSON(rateLimitSettingsKey, settings); err != nil {
+		return nil, err
+	}
+
+	return settings, nil
+}
+
+func (s *SettingsService) UpdateRateLimitSettings(settings models.RateLimitSettings) (*models.RateLimitSettings, error) {
+	if settings.RequestsPerMinute < 0 {
+		return nil, fmt.Errorf("requests per minute can't be negative")
+	}
+	if settings.MaxBandwidthMbps < 0 {
+		return nil, fmt.Errorf("max bandwidth can't be negative")
+	}
+
+	if err := s.setJSON(rateLimitSettingsKey, settings); err != nil {
+		return nil, err
+	}
+
+	return &settings, nil
+}
+
+func (s *SettingsService) GetLogSettings() (*models.LogSettings, error) {
+	settings := &models.LogSettings{
+		Level:      "info",
+		Subsystems: map[string]string{},
+	}
+	if err := s.getJSON(logSettingsKey, settings); err != nil {
+		return nil, err
+	}
+
+	return settings, nil
+}
+
+func (s *SettingsService) UpdateLogSettings(settings models.LogSettings) (*models.LogSettings, error) {
+	result := &models.LogSettings{
+		Level:      strings.ToLower(strings.TrimSpace(settings.Level)),
+		Subsystems: map[string]string{},
+	}
+	if result.Level == "" {
+		result.Level = "info"
+	}
+	if _, err := logging.ParseLevel(result.Level); err != nil {
+		return nil, err
+	}
+	for subsystem, level := range settings.Subsystems {
+		if !slices.Contains(models.LogSubsystems, subsystem) {
+			return nil, fmt.Errorf("unknown log subsystem %q, expected one of %s", subsystem, strings.Join(models.LogSubsystems, ", "))
+		}
+		level = strings.ToLower(strings.TrimSpace(level))
+		if level == "" {
+			continue
+		}
+		if _, err := logging.ParseLevel(level); err != nil {
+			return nil, err
+		}
+		result.Subsystems[subsystem] = level
+	}
+
+	if err := s.setJSON(logSettingsKey, result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *SettingsService) GetMetadataSettings() (*models.MetadataSettings, error) {
+	settings := &models.MetadataSettings{
+		MinConfidence: defaultMinConfidence,
+	}
+	if err := s.getJSON(metadataSettingsKey, settings); err != nil {
+		return nil, err
+	}
+
+	return settings, nil
+}
+
+func (s *SettingsService) UpdateMetadataSettings(settings models.MetadataSettings) (*models.MetadataSettings, error) {
+	settings.Provider = strings.TrimSpace(settings.Provider)
+	// A confidence of 0 would match every search result to the first file
+	if settings.MinConfidence <= 0 || settings.MinConfidence > 1 {
+		return nil, fmt.Errorf("min confidence must be above 0 and at most 1")
+	}
+
+	if err := s.setJSON(metadataSettingsKey, settings); err != nil {
+		return nil, err
+	}
+
+	return &settings, nil
+}
+
+func (s *SettingsService) GetScanSettings() (*models.ScanSettings, error) {
+	settings := &models.ScanSettings{RescanMinutes: defaultRescanMinutes}
+	if err := s.getJSON(scanSettingsKey, settings); err != nil {
+		return nil, err
+	}
+
+	return settings, nil
+}
+
+func (s *SettingsService) UpdateScanSettings(settings models.ScanSettings) (*models.ScanSettings, error) {
+	if settings.RescanMinutes < 0 {
+		return nil, fmt.Errorf("rescan minutes can't be negative")
+	}
+
+	if err := s.setJSON(scanSettingsKey, settings); err != nil {
+		return nil, err
+	}
+
+	return &settings, nil
+}
+
+// SettingsGroups are the names GetSettingsGroup and UpdateSettingsGroup accept
+var SettingsGroups = []string{corsSettingsKey, tlsSettingsKey, rateLimitSettingsKey, logSettingsKey, metadataSettingsKey, scanSettingsKey}
+
+// GetSettingsGroup returns one of the SettingsGroups by name, for generic
+// tools like the command line.
+func (s *SettingsService) GetSettingsGroup(name string) (any, error) {
+	switch name {
+	case corsSettingsKey:
+		return s.GetCorsSettings()
+	case tlsSettingsKey:
+		return s.GetTlsSettings()
+	case rateLimitSettingsKey:
+		return s.GetRateLimitSettings()
+	case logSettingsKey:
+		return s.GetLogSettings()
+	case metadataSettingsKey:
+		return s.GetMetadataSettings()
+	case scanSettingsKey:
+		return s.GetScanSettings()
+	}
+
+	return nil, fmt.Errorf("unknown settings group %q, expected one of %s", name, strings.Join(SettingsGroups, ", "))
+}
+
+// UpdateSettingsGroup decodes data into the group's settings and saves them
+// through the same validation as the typed update methods.
+func (s *SettingsService) UpdateSettingsGroup(name string, data []byte) (any, error) {
+	decode := func(target any) error {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(target); err != nil {
+			return fmt.Errorf("invalid %s settings: %w", name, err)
+		}
+		return nil
+	}
+
+	switch name {
+	case corsSettingsKey:
+		var settings models.CorsSettings
+		if err := decode(&settings); err != nil {
+			return nil, err
+		}
+		return s.UpdateCorsSettings(settings)
+	case tlsSettingsKey:
+		var settings models.TlsSettings
+		if err := decode(&settings); err != nil {
+			return nil, err
+		}
+		return s.UpdateTlsSettings(settings)
+	case rateLimitSettingsKey:
+		var settings models.RateLimitSettings
+		if err := decode(&settings); err != nil {
+			return nil, err
+		}
+		return s.UpdateRateLimitSettings(settings)
+	case logSettingsKey:
+		var settings models.LogSettings
+		if err := decode(&settings); err != nil {
+			return nil, err
+		}
+		return s.UpdateLogSettings(settings)
+	case metadataSettingsKey:
+		var settings models.MetadataSettings
+		if err := decode(&settings); err != nil {
+			return nil, err
+		}
+		return s.UpdateMetadataSettings(settings)
+	case scanSettingsKey:
+		var settings models.ScanSettings
+		if err := decode(&settings); err != nil {
+			return nil, err
+		}
+		return s.UpdateScanSettings(settings)
+	}
+
+	return nil, fmt.Errorf("unknown settings group %q, expected one of %s", name, strings.Join(SettingsGroups, ", "))
+}
+
+func (s *SettingsService) getJSON(key string, target any) error {
+	value, err := s.settingsRepository.GetSetting(key)
+	if err != nil {
+		return err
+	}
+	if value == "" {
+		return nil
+	}
+
+	if err := json.Unmarshal([]byte(value), target); err != nil {
+		s.logger.Error("decoding setting", "key", key, "err", err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *SettingsService) setJSON(key string, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		s.logger.Error("encoding setting", "key", key, "err", err)
+		return err
+	}
+
+	return s.settingsRepository.SetSetting(key, string(data))
+}
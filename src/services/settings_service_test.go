@@ -0,0 +1,174 @@
+package services
+
+import (
+	"context"
+	"localflix-server/src/logging"
+	"localflix-server/src/models"
+	"slices"
+	"testing"
+)
+
+func TestCorsSettings(t *testing.T) {
+	settings := NewSettingsService(context.Background(), newTestDatabase(t), logging.Discard())
+
+	defaults, err := settings.GetCorsSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(defaults.AllowOrigins) != 0 || !slices.Equal(defaults.AllowMethods, apiMethods) {
+		t.Errorf("got defaults %+v, want no origins and every api method", defaults)
+	}
+
+	updated, err := settings.UpdateCorsSettings(models.CorsSettings{
+		AllowOrigins: []string{" http://tv.local/ ", ""},
+		AllowMethods: []string{"get", "GET", "head"},
+	})
+	if err != nil {
+		t.Fatalf("UpdateCorsSettings: %v", err)
+	}
+	if !slices.Equal(updated.AllowOrigins, []string{"http://tv.local"}) || !slices.Equal(updated.AllowMethods, []string{"GET", "HEAD"}) {
+		t.Errorf("got %+v, want the normalized origins and methods", updated)
+	}
+
+	stored, err := settings.GetCorsSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(stored.AllowOrigins, updated.AllowOrigins) || !slices.Equal(stored.AllowMethods, updated.AllowMethods) {
+		t.Errorf("stored %+v, want %+v", stored, updated)
+	}
+
+	invalid := []models.CorsSettings{
+		{AllowOrigins: []string{"tv.local"}},
+		{AllowOrigins: []string{"*"}, AllowCredentials: true},
+		{AllowMethods: []string{"PUT"}},
+	}
+	for _, cors := range invalid {
+		if _, err := settings.UpdateCorsSettings(cors); err == nil {
+			t.Errorf("UpdateCorsSettings(%+v) succeeded", cors)
+		}
+	}
+}
+
+func TestStoredCorsMethodsLeaveTheApiMethods(t *testing.T) {
+	settings := NewSettingsService(context.Background(), newTestDatabase(t), logging.Discard())
+	want := slices.Clone(apiMethods)
+
+	if _, err := settings.UpdateCorsSettings(models.CorsSettings{AllowMethods: []string{"DELETE"}}); err != nil {
+		t.Fatal(err)
+	}
+	// Reading the stored methods decodes them over the defaults
+	if _, err := settings.GetCorsSettings(); err != nil {
+		t.Fatal(err)
+	}
+	defaults, err := settings.UpdateCorsSettings(models.CorsSettings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaults.AllowMethods[0] = "PUT"
+
+	if !slices.Equal(apiMethods, want) {
+		t.Fatalf("got api methods %v, want %v", apiMethods, want)
+	}
+	if _, err := settings.UpdateCorsSettings(models.CorsSettings{AllowMethods: []string{"GET"}}); err != nil {
+		t.Errorf("got %v, want GET allowed", err)
+	}
+}
+
+func TestTlsSettingsValidation(t *testing.T) {
+	settings := NewSettingsService(context.Background(), newTestDatabase(t), logging.Discard())
+
+	invalid := []models.TlsSettings{
+		{CertFile: "cert.pem"},
+		{CertFile: "missing.pem", KeyFile: "missing.key"},
+		{RedirectPort: serverPort},
+		{RedirectPort: 70000},
+	}
+	for _, tls := range invalid {
+		if _, err := settings.UpdateTlsSettings(tls); err == nil {
+			t.Errorf("UpdateTlsSettings(%+v) succeeded", tls)
+		}
+	}
+
+	updated, err := settings.UpdateTlsSettings(models.TlsSettings{Enabled: true})
+	if err != nil {
+		t.Fatalf("UpdateTlsSettings: %v", err)
+	}
+	if updated.RedirectPort != defaultRedirectPort {
+		t.Errorf("got redirect port %d, want the default %d", updated.RedirectPort, defaultRedirectPort)
+	}
+}
+
+func TestUpdateSettingsGroup(t *testing.T) {
+	settings := NewSettingsService(context.Background(), newTestDatabase(t), logging.Discard())
+
+	if _, err := settings.UpdateSettingsGroup(rateLimitSettingsKey, []byte(`{"requests_per_minute": 60}`)); err != nil {
+		t.Fatalf("UpdateSettingsGroup: %v", err)
+	}
+	rateLimit, err := settings.GetRateLimitSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rateLimit.RequestsPerMinute != 60 {
+		t.Errorf("got %+v, want 60 requests per minute", rateLimit)
+	}
+
+	invalid := map[string]string{
+		rateLimitSettingsKey: `{"requests_per_minute": -1}`,
+		corsSettingsKey:      `{"allow_everything": true}`,
+		"unknown":            `{}`,
+	}
+	for group, data := range invalid {
+		if _, err := settings.UpdateSettingsGroup(group, []byte(data)); err == nil {
+			t.Errorf("UpdateSettingsGroup(%s, %s) succeeded", group, data)
+		}
+	}
+}
+
+func TestLogSettings(t *testing.T) {
+	settings := NewSettingsService(context.Background(), newTestDatabase(t), logging.Discard())
+
+	updated, err := settings.UpdateLogSettings(models.LogSettings{
+		Level:      " WARN ",
+		Subsystems: map[string]string{models.LogSubsystemHttp: "debug", models.LogSubsystemMedia: ""},
+	})
+	if err != nil {
+		t.Fatalf("UpdateLogSettings: %v", err)
+	}
+	if updated.Level != "warn" || len(updated.Subsystems) != 1 || updated.Subsystems[models.LogSubsystemHttp] != "debug" {
+		t.Errorf("got %+v, want warn with debug for http", updated)
+	}
+
+	invalid := []models.LogSettings{
+		{Level: "verbose"},
+		{Subsystems: map[string]string{"player": "debug"}},
+		{Subsystems: map[string]string{models.LogSubsystemHttp: "trace"}},
+	}
+	for _, log := range invalid {
+		if _, err := settings.UpdateLogSettings(log); err == nil {
+			t.Errorf("UpdateLogSettings(%+v) succeeded", log)
+		}
+	}
+}
+
+func TestMetadataSettings(t *testing.T) {
+	settings := NewSettingsService(context.Background(), newTestDatabase(t), logging.Discard())
+
+	defaults, err := settings.GetMetadataSettings()
+	if err != nil || defaults.MinConfidence != defaultMinConfidence {
+		t.Errorf("GetMetadataSettings = %+v, %v, want the default min confidence", defaults, err)
+	}
+	updated, err := settings.UpdateMetadataSettings(models.MetadataSettings{Provider: " tmdb ", MinConfidence: 0.5})
+	if err != nil {
+		t.Fatalf("UpdateMetadataSettings: %v", err)
+	}
+	if updated.Provider != "tmdb" || updated.MinConfidence != 0.5 {
+		t.Errorf("got %+v, want tmdb with a 0.5 min confidence", updated)
+	}
+
+	for _, minConfidence := range []float64{0, -0.1, 1.5} {
+		if _, err := settings.UpdateMetadataSettings(models.MetadataSettings{MinConfidence: minConfidence}); err == nil {
+			t.Errorf("UpdateMetadataSettings with a %v min confidence succeeded", minConfidence)
+		}
+	}
+}
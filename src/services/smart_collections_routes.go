@@ -0,0 +1,154 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"localflix-server/src/models"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// registerSmartCollectionRoutes adds the routes browsing smart collections.
+// /categories lists them as well, changing them needs the admin scope.
+func (s *StreamService) registerSmartCollectionRoutes(app *fiber.App) {
+	app.Get("/smart-collections", s.requireScope(models.ScopeLibraryRead), s.rateLimit, s.listSmartCollections)
+	app.Get("/smart-collections/:smartCollectionId", s.requireScope(models.ScopeLibraryRead), s.rateLimit, s.getSmartCollection)
+	app.Get("/smart-collections/:smartCollectionId/items", s.requireScope(models.ScopeLibraryRead), s.rateLimit, s.listSmartCollectionItems)
+}
+
+func (s *StreamService) listSmartCollections(c *fiber.Ctx) error {
+	page, err := s.smartCollectionsService.ListSmartCollections(listOptions(c))
+	if err != nil {
+		return sendListError(c, err, "Error listing smart collections")
+	}
+
+	return sendPage(c, page.Items, page.Total)
+}
+
+func (s *StreamService) getSmartCollection(c *fiber.Ctx) error {
+	collectionId, err := strconv.Atoi(c.Params("smartCollectionId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid smart collection ID")
+	}
+
+	collection, err := s.smartCollectionsService.GetSmartCollection(collectionId)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("Error retrieving smart collection")
+	}
+	if collection == nil {
+		return c.Status(fiber.StatusNotFound).SendString("Smart collection not found")
+	}
+
+	return c.JSON(collection)
+}
+
+// listSmartCollectionItems lists the media items matching the rules of the
+// smart collection like the /files route
+func (s *StreamService) listSmartCollectionItems(c *fiber.Ctx) error {
+	collectionId, err := strconv.Atoi(c.Params("smartCollectionId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid smart collection ID")
+	}
+
+	page, err := s.smartCollectionsService.ListSmartCollectionItems(collectionId, listOptions(c))
+	if err != nil {
+		return sendSmartCollectionError(c, err, "Error listing smart collection items")
+	}
+
+	files, err := s.mediaItemFiles(c, page.Items)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("Error retrieving folder")
+	}
+	return sendPage(c, files, page.Total)
+}
+
+func (s *StreamService) createSmartCollection(c *fiber.Ctx) error {
+	var request models.SmartCollection
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid request body")
+	}
+
+	collection, err := s.smartCollectionsService.CreateSmartCollection(request)
+	if err != nil {
+		return sendSmartCollectionError(c, err, "Error creating smart collection")
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(collection)
+}
+
+// previewSmartCollection lists the media items matching the rules of the
+// body, without saving them
+func (s *StreamService) previewSmartCollection(c *fiber.Ctx) error {
+	var request models.SmartCollection
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid request body")
+	}
+
+	options := listOptions(c)
+	if options.Sort == "" {
+		options.Sort = request.Sort
+	}
+	page, err := s.smartCollectionsService.PreviewSmartRules(request.Rules, options)
+	if err != nil {
+		return sendSmartCollectionError(c, err, "Error listing smart collection items")
+	}
+
+	files, err := s.mediaItemFiles(c, page.Items)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("Error retrieving folder")
+	}
+	return sendPage(c, files, page.Total)
+}
+
+func (s *StreamService) updateSmartCollection(c *fiber.Ctx) error {
+	collectionId, err := strconv.Atoi(c.Params("smartCollectionId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid smart collection ID")
+	}
+
+	var request models.SmartCollection
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid request body")
+	}
+
+	collection, err := s.smartCollectionsService.UpdateSmartCollection(collectionId, request)
+	if err != nil {
+		return sendSmartCollectionError(c, err, "Error updating smart collection")
+	}
+
+	return c.JSON(collection)
+}
+
+func (s *StreamService) deleteSmartCollection(c *fiber.Ctx) error {
+	collectionId, err := strconv.Atoi(c.Params("smartCollectionId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid smart collection ID")
+	}
+
+	if err := s.smartCollectionsService.DeleteSmartCollection(collectionId); err != nil {
+		return sendSmartCollectionError(c, err, "Error deleting smart collection")
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func sendSmartCollectionError(c *fiber.Ctx, err error, message string) error {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return c.Status(fiber.StatusNotFound).SendString("Smart collection not found")
+	case errors.Is(err, ErrInvalidSmartCollection), errors.Is(err, ErrInvalidListOptions):
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	default:
+		return c.Status(fiber.StatusInternalServerError).SendString(message)
+	}
+}
+
+// sendVirtualCategory answers the category routes given the negative ID of a
+// smart collection listed by /categories. It has no folders to browse and no
+// category to change, the error points to route, formatted with its ID.
+func sendVirtualCategory(c *fiber.Ctx, categoryId int, route string) error {
+	collectionId := -categoryId
+	return c.Status(fiber.StatusBadRequest).SendString(fmt.Sprintf("Category %d is smart collection %d, use "+route, categoryId, collectionId, collectionId))
+}
@@ -0,0 +1,391 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"localflix-server/src/models"
+	"localflix-server/src/repositories"
+	"log/slog"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// maxSmartRuleDepth and maxSmartRules bound the size of rule trees, and of
+	// the queries they make
+	maxSmartRuleDepth = 8
+	maxSmartRules     = 100
+)
+
+// ErrInvalidSmartCollection is returned for smart collections with invalid
+// rules, name or sort, the wrapping error tells why
+var ErrInvalidSmartCollection = errors.New("invalid smart collection")
+
+// smartValueKind is what a field of smart rules compares with
+type smartValueKind int
+
+const (
+	smartText smartValueKind = iota
+	// smartName is text only compared whole, like tag names
+	smartName
+	smartNumber
+	smartID
+	smartDate
+	smartBool
+	smartType
+)
+
+var smartFieldKinds = map[string]smartValueKind{
+	models.SmartFieldTitle:         smartText,
+	models.SmartFieldResolution:    smartText,
+	models.SmartFieldCodec:         smartText,
+	models.SmartFieldTag:           smartName,
+	models.SmartFieldGenre:         smartName,
+	models.SmartFieldYear:          smartNumber,
+	models.SmartFieldDuration:      smartNumber,
+	models.SmartFieldSize:          smartNumber,
+	models.SmartFieldCategoryID:    smartID,
+	models.SmartFieldFolderID:      smartID,
+	models.SmartFieldAddedAt:       smartDate,
+	models.SmartFieldLastWatchedAt: smartDate,
+	models.SmartFieldWatched:       smartBool,
+	models.SmartFieldType:          smartType,
+}
+
+// smartKindOps are the operators supported by each kind of field
+var smartKindOps = map[smartValueKind][]string{
+	smartText:   {models.SmartOpEquals, models.SmartOpNotEquals, models.SmartOpContains},
+	smartName:   {models.SmartOpEquals, models.SmartOpNotEquals},
+	smartNumber: {models.SmartOpEquals, models.SmartOpNotEquals, models.SmartOpLess, models.SmartOpLessOrEqual, models.SmartOpGreater, models.SmartOpGreaterOrEqual},
+	smartID:     {models.SmartOpEquals, models.SmartOpNotEquals},
+	smartDate:   {models.SmartOpBefore, models.SmartOpAfter, models.SmartOpInLast},
+	smartBool:   {models.SmartOpEquals, models.SmartOpNotEquals},
+	smartType:   {models.SmartOpEquals, models.SmartOpNotEquals},
+}
+
+type SmartCollectionsService struct {
+	ctx                        context.Context
+	db                         *sql.DB
+	smartCollectionsRepository *repositories.SmartCollectionsRepository
+	logger                     *slog.Logger
+}
+
+// NewSmartCollectionsService creates a new SmartCollectionsService struct
+func NewSmartCollectionsService(ctx context.Context, db *sql.DB, logger *slog.Logger) *SmartCollectionsService {
+	return &SmartCollectionsService{
+		ctx:                        ctx,
+		db:                         db,
+		smartCollectionsRepository: repositories.NewSmartCollectionsRepository(db),
+		logger:                     logger,
+	}
+}
+
+// ListSmartCollections pages the smart collections, they sort by name
+func (s *SmartCollectionsService) ListSmartCollections(options models.ListOptions) (*models.SmartCollectionPage, error) {
+	query, err := parseListOptions(options, []string{models.SortName, models.SortAddedAt}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	collections, err := s.listSmartCollections()
+	if err != nil {
+		return nil, err
+	}
+	return &models.SmartCollectionPage{
+		Items: pageOf(collections, query, map[string]func(a, b models.SmartCollection) int{
+			models.SortName:    func(a, b models.SmartCollection) int { return compareFold(a.Name, b.Name) },
+			models.SortAddedAt: func(a, b models.SmartCollection) int { return a.CreatedAt.Compare(b.CreatedAt) },
+		}),
+		Total: len(collections),
+	}, nil
+}
+
+// VirtualCategories describes the smart collections as categories, listed
+// after the real ones by the /categories route
+func (s *SmartCollectionsService) VirtualCategories() ([]models.Category, error) {
+	collections, err := s.listSmartCollections()
+	if err != nil {
+		return nil, err
+	}
+
+	categories := make([]models.Category, len(collections))
+	for i, collection := range collections {
+		categories[i] = models.Category{ID: -collection.ID, Name: collection.Name, SmartCollectionID: collection.ID}
+	}
+	return categories, nil
+}
+
+func (s *SmartCollectionsService) listSmartCollections() ([]models.SmartCollection, error) {
+	collections, err := s.smartCollectionsRepository.ListSmartCollections()
+	if err != nil {
+		s.logger.Error("listing smart collections", "err", err)
+		return nil, err
+	}
+
+	result := make([]models.SmartCollection, len(collections))
+	for i, collection := range collections {
+		result[i] = *collection
+	}
+	return result, nil
+}
+
+// GetSmartCollection returns nil for an unknown smart collection
+func (s *SmartCollectionsService) GetSmartCollection(id int) (*models.SmartCollection, error) {
+	collection, err := s.smartCollectionsRepository.GetSmartCollection(id)
+	if err != nil {
+		s.logger.Error("getting smart collection", "id", id, "err", err)
+		return nil, err
+	}
+
+	return collection, nil
+}
+
+func (s *SmartCollectionsService) CreateSmartCollection(collection models.SmartCollection) (*models.SmartCollection, error) {
+	collection, err := s.checkSmartCollection(collection, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := s.smartCollectionsRepository.CreateSmartCollection(collection)
+	if err != nil {
+		s.logger.Error("creating smart collection", "name", collection.Name, "err", err)
+		return nil, err
+	}
+
+	s.logger.Info("smart collection created", "id", created.ID, "name", created.Name)
+	return created, nil
+}
+
+// UpdateSmartCollection replaces the name, rules and sort of the smart
+// collection. Returns sql.ErrNoRows for an unknown smart collection.
+func (s *SmartCollectionsService) UpdateSmartCollection(id int, collection models.SmartCollection) (*models.SmartCollection, error) {
+	collection, err := s.checkSmartCollection(collection, id)
+	if err != nil {
+		return nil, err
+	}
+
+	collection.ID = id
+	if err := s.smartCollectionsRepository.UpdateSmartCollection(collection); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			s.logger.Error("updating smart collection", "id", id, "err", err)
+		}
+		return nil, err
+	}
+
+	return s.GetSmartCollection(id)
+}
+
+// DeleteSmartCollection returns sql.ErrNoRows for an unknown smart
+// collection
+func (s *SmartCollectionsService) DeleteSmartCollection(id int) error {
+	if err := s.smartCollectionsRepository.DeleteSmartCollection(id); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			s.logger.Error("deleting smart collection", "id", id, "err", err)
+		}
+		return err
+	}
+
+	s.logger.Info("smart collection deleted", "id", id)
+	return nil
+}
+
+// ListSmartCollectionItems evaluates the rules of the smart collection and
+// pages the media items matching them, sorted by the collection's sort
+// unless options sort them otherwise. Returns sql.ErrNoRows for an unknown
+// smart collection.
+func (s *SmartCollectionsService) ListSmartCollectionItems(id int, options models.ListOptions) (*models.MediaItemPage, error) {
+	collection, err := s.GetSmartCollection(id)
+	if err != nil {
+		return nil, err
+	}
+	if collection == nil {
+		return nil, sql.ErrNoRows
+	}
+
+	if options.Sort == "" {
+		options.Sort = collection.Sort
+	}
+	return s.listMatchingItems(collection.Rules, options)
+}
+
+// PreviewSmartRules pages the media items matching rules, for editing a
+// smart collection before saving it
+func (s *SmartCollectionsService) PreviewSmartRules(rules models.SmartRule, options models.ListOptions) (*models.MediaItemPage, error) {
+	rules, err := normalizeSmartRule(rules)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.listMatchingItems(rules, options)
+}
+
+func (s *SmartCollectionsService) listMatchingItems(rules models.SmartRule, options models.ListOptions) (*models.MediaItemPage, error) {
+	query, err := parseListOptions(options, mediaItemSorts, mediaItemFilters)
+	if err != nil {
+		return nil, err
+	}
+
+	items, total, err := s.smartCollectionsRepository.ListMatchingItems(rules, query)
+	if err != nil {
+		s.logger.Error("listing smart collection items", "err", err)
+		return nil, err
+	}
+
+	result := make([]models.MediaItem, len(items))
+	for i, item := range items {
+		result[i] = *item
+	}
+	return &models.MediaItemPage{Items: result, Total: total}, nil
+}
+
+// checkSmartCollection trims the name, checks no other smart collection has
+// it and normalizes the rules and sort
+func (s *SmartCollectionsService) checkSmartCollection(collection models.SmartCollection, id int) (models.SmartCollection, error) {
+	collection.Name = strings.TrimSpace(collection.Name)
+	if collection.Name == "" {
+		return collection, fmt.Errorf("%w: name is required", ErrInvalidSmartCollection)
+	}
+	if _, err := parseListOptions(models.ListOptions{Sort: collection.Sort}, mediaItemSorts, nil); err != nil {
+		return collection, fmt.Errorf("%w: %w", ErrInvalidSmartCollection, err)
+	}
+
+	rules, err := normalizeSmartRule(collection.Rules)
+	if err != nil {
+		return collection, err
+	}
+	collection.Rules = rules
+
+	existing, err := s.smartCollectionsRepository.GetSmartCollectionByName(collection.Name)
+	if err != nil {
+		s.logger.Error("getting smart collection by name", "err", err)
+		return collection, err
+	}
+	if existing != nil && existing.ID != id {
+		return collection, fmt.Errorf("%w: smart collection %q already exists", ErrInvalidSmartCollection, existing.Name)
+	}
+
+	return collection, nil
+}
+
+// normalizeSmartRule checks the rule tree and gives its values the type of
+// their field: numbers are float64, dates RFC 3339 strings in UTC. A rule
+// without field nor match is a group matching all its rules.
+func normalizeSmartRule(rule models.SmartRule) (models.SmartRule, error) {
+	count := 0
+	return normalizeSmartRuleNode(rule, 1, &count)
+}
+
+func normalizeSmartRuleNode(rule models.SmartRule, depth int, count *int) (models.SmartRule, error) {
+	if *count++; *count > maxSmartRules {
+		return rule, fmt.Errorf("%w: more than %d rules", ErrInvalidSmartCollection, maxSmartRules)
+	}
+
+	if rule.Field == "" {
+		if rule.Match == "" {
+			rule.Match = models.SmartMatchAll
+		}
+		if rule.Match != models.SmartMatchAll && rule.Match != models.SmartMatchAny {
+			return rule, fmt.Errorf("%w: unknown match %q, expected all or any", ErrInvalidSmartCollection, rule.Match)
+		}
+		if rule.Op != "" || rule.Value != nil {
+			return rule, fmt.Errorf("%w: groups of rules have no op nor value", ErrInvalidSmartCollection)
+		}
+		if depth > maxSmartRuleDepth && len(rule.Rules) > 0 {
+			return rule, fmt.Errorf("%w: rules nested deeper than %d", ErrInvalidSmartCollection, maxSmartRuleDepth)
+		}
+
+		rules := make([]models.SmartRule, len(rule.Rules))
+		for i, child := range rule.Rules {
+			normalized, err := normalizeSmartRuleNode(child, depth+1, count)
+			if err != nil {
+				return rule, err
+			}
+			rules[i] = normalized
+		}
+		rule.Rules = rules
+		return rule, nil
+	}
+
+	if rule.Match != "" || len(rule.Rules) > 0 {
+		return rule, fmt.Errorf("%w: conditions on %s have no match nor rules", ErrInvalidSmartCollection, rule.Field)
+	}
+	kind, ok := smartFieldKinds[rule.Field]
+	if !ok {
+		return rule, fmt.Errorf("%w: unknown field %q", ErrInvalidSmartCollection, rule.Field)
+	}
+	if !slices.Contains(smartKindOps[kind], rule.Op) {
+		return rule, fmt.Errorf("%w: %s can't be compared with %q, expected one of %s", ErrInvalidSmartCollection, rule.Field, rule.Op, strings.Join(smartKindOps[kind], ", "))
+	}
+
+	value, err := normalizeSmartValue(kind, rule.Op, rule.Value)
+	if err != nil {
+		return rule, fmt.Errorf("%w: %s %s: %w", ErrInvalidSmartCollection, rule.Field, rule.Op, err)
+	}
+	rule.Value = value
+	return rule, nil
+}
+
+func normalizeSmartValue(kind smartValueKind, op string, value any) (any, error) {
+	switch kind {
+	case smartText, smartName:
+		text, _ := value.(string)
+		if text = strings.TrimSpace(text); text == "" {
+			return nil, errors.New("expected text")
+		}
+		return text, nil
+	case smartNumber, smartID:
+		number, ok := smartNumberValue(value)
+		if !ok || (kind == smartID && (number <= 0 || number != math.Trunc(number))) {
+			return nil, errors.New("expected a number")
+		}
+		return number, nil
+	case smartDate:
+		text, _ := value.(string)
+		if op == models.SmartOpInLast {
+			if _, err := models.SmartPeriodStart(text, time.Now()); err != nil {
+				return nil, err
+			}
+			return text, nil
+		}
+		for _, layout := range []string{time.RFC3339, time.DateOnly} {
+			if date, err := time.Parse(layout, text); err == nil {
+				return date.UTC().Format(time.RFC3339), nil
+			}
+		}
+		return nil, fmt.Errorf("expected a date like 2006-01-02, got %v", value)
+	case smartBool:
+		if _, ok := value.(bool); !ok {
+			return nil, errors.New("expected true or false")
+		}
+		return value, nil
+	case smartType:
+		if value != models.MediaTypeMovie && value != models.MediaTypeEpisode {
+			return nil, fmt.Errorf("expected %s or %s", models.MediaTypeMovie, models.MediaTypeEpisode)
+		}
+		return value, nil
+	}
+
+	return nil, errors.New("unsupported field")
+}
+
+// smartNumberValue reads numbers given in JSON or from Go
+func smartNumberValue(value any) (float64, bool) {
+	switch number := value.(type) {
+	case float64:
+		return number, true
+	case int:
+		return float64(number), true
+	case int64:
+		return float64(number), true
+	case json.Number:
+		parsed, err := number.Float64()
+		return parsed, err == nil
+	}
+
+	return 0, false
+}
@@ -0,0 +1,248 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"localflix-server/src/logging"
+	"localflix-server/src/models"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var smartLibraryFiles = []string{
+	"Dune.2021.2160p.x265.mkv",
+	"Alien.1979.2160p.x265.mkv",
+	"Heat.1995.720p.x264.mkv",
+	"Show/Season 1/Show.S01E01.mkv",
+	"Show/Season 1/Show.S01E02.mkv",
+}
+
+// ageSmartLibrary marks Alien watched, Heat added two months ago and the
+// second episode 45 minutes long, the others 20 minutes
+func ageSmartLibrary(t *testing.T, library *testLibrary, folder *models.Folder) {
+	t.Helper()
+
+	updates := []struct {
+		query string
+		args  []any
+	}{
+		{"UPDATE media_items SET duration = 20 * 60", nil},
+		{"UPDATE media_items SET duration = 45 * 60 WHERE name = ?", []any{"Show.S01E02.mkv"}},
+		{"UPDATE media_items SET added_at = ? WHERE name = ?", []any{time.Now().UTC().AddDate(0, -2, 0), "Heat.1995.720p.x264.mkv"}},
+	}
+	for _, update := range updates {
+		if _, err := library.db.Exec(update.query, update.args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	markWatched(t, library.db, 0, folder.ID, "Alien.1979.2160p.x265.mkv")
+}
+
+func condition(field string, op string, value any) models.SmartRule {
+	return models.SmartRule{Field: field, Op: op, Value: value}
+}
+
+func itemNames(page *models.MediaItemPage) []string {
+	var names []string
+	for _, item := range page.Items {
+		names = append(names, item.Name)
+	}
+	return names
+}
+
+func TestSmartRules(t *testing.T) {
+	library, folder := newScannedLibrary(t, smartLibraryFiles...)
+	ageSmartLibrary(t, library, folder)
+	smart := NewSmartCollectionsService(context.Background(), library.db, logging.Discard())
+	tags := NewTagsService(context.Background(), library.db, logging.Discard())
+	items, err := NewMediaItemsService(context.Background(), library.db, logging.Discard()).ListMediaItems(folder.ID, models.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range items.Items {
+		if item.Release.Title == "Dune" {
+			if _, err := tags.SetMediaItemTags(item.ID, []string{"Sci-Fi"}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	tests := []struct {
+		name string
+		rule models.SmartRule
+		want []string
+	}{
+		{"everything", models.SmartRule{}, []string{"Alien.1979.2160p.x265.mkv", "Dune.2021.2160p.x265.mkv", "Heat.1995.720p.x264.mkv", "Show.S01E01.mkv", "Show.S01E02.mkv"}},
+		{"unwatched 4K movies added this month", models.SmartRule{Match: models.SmartMatchAll, Rules: []models.SmartRule{
+			condition(models.SmartFieldWatched, models.SmartOpEquals, false),
+			condition(models.SmartFieldResolution, models.SmartOpEquals, "2160P"),
+			condition(models.SmartFieldType, models.SmartOpEquals, models.MediaTypeMovie),
+			condition(models.SmartFieldAddedAt, models.SmartOpInLast, "1m"),
+		}}, []string{"Dune.2021.2160p.x265.mkv"}},
+		{"episodes under 25 minutes", models.SmartRule{Rules: []models.SmartRule{
+			condition(models.SmartFieldType, models.SmartOpEquals, models.MediaTypeEpisode),
+			condition(models.SmartFieldDuration, models.SmartOpLess, 25*60),
+		}}, []string{"Show.S01E01.mkv"}},
+		{"any", models.SmartRule{Match: models.SmartMatchAny, Rules: []models.SmartRule{
+			condition(models.SmartFieldYear, models.SmartOpLess, 1980),
+			condition(models.SmartFieldTitle, models.SmartOpContains, "ea"),
+		}}, []string{"Alien.1979.2160p.x265.mkv", "Heat.1995.720p.x264.mkv"}},
+		{"nested", models.SmartRule{Rules: []models.SmartRule{
+			condition(models.SmartFieldType, models.SmartOpNotEquals, models.MediaTypeEpisode),
+			{Match: models.SmartMatchAny, Rules: []models.SmartRule{
+				condition(models.SmartFieldWatched, models.SmartOpEquals, true),
+				condition(models.SmartFieldCodec, models.SmartOpEquals, "H.264"),
+			}},
+		}}, []string{"Alien.1979.2160p.x265.mkv", "Heat.1995.720p.x264.mkv"}},
+		{"added before", condition(models.SmartFieldAddedAt, models.SmartOpBefore, time.Now().UTC().AddDate(0, -1, 0).Format(time.DateOnly)), []string{"Heat.1995.720p.x264.mkv"}},
+		{"watched after", condition(models.SmartFieldLastWatchedAt, models.SmartOpAfter, "2000-01-01"), []string{"Alien.1979.2160p.x265.mkv"}},
+		{"tag", condition(models.SmartFieldTag, models.SmartOpEquals, "sci-fi"), []string{"Dune.2021.2160p.x265.mkv"}},
+		{"genre", condition(models.SmartFieldGenre, models.SmartOpEquals, "sci-fi"), nil},
+		{"folder", condition(models.SmartFieldFolderID, models.SmartOpNotEquals, folder.ID), nil},
+		{"category", models.SmartRule{Rules: []models.SmartRule{
+			condition(models.SmartFieldCategoryID, models.SmartOpEquals, folder.CategoryID),
+			condition(models.SmartFieldSize, models.SmartOpGreaterOrEqual, len("Show/Season 1/Show.S01E01.mkv")),
+		}}, []string{"Show.S01E01.mkv", "Show.S01E02.mkv"}},
+		{"wildcards are literal", condition(models.SmartFieldTitle, models.SmartOpContains, "%"), nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			page, err := smart.PreviewSmartRules(test.rule, models.ListOptions{Sort: models.SortName})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := itemNames(page); !slices.Equal(got, test.want) || page.Total != len(test.want) {
+				t.Errorf("got %v of %d, want %v", got, page.Total, test.want)
+			}
+		})
+	}
+}
+
+func TestInvalidSmartRules(t *testing.T) {
+	library, _ := newScannedLibrary(t, smartLibraryFiles...)
+	smart := NewSmartCollectionsService(context.Background(), library.db, logging.Discard())
+
+	deep := models.SmartRule{Rules: []models.SmartRule{condition(models.SmartFieldYear, models.SmartOpEquals, 2000)}}
+	for i := 0; i < maxSmartRuleDepth; i++ {
+		deep = models.SmartRule{Rules: []models.SmartRule{deep}}
+	}
+	for _, rule := range []models.SmartRule{
+		{Match: "most"},
+		condition("rating", models.SmartOpEquals, 5),
+		condition(models.SmartFieldYear, models.SmartOpContains, "19"),
+		condition(models.SmartFieldYear, models.SmartOpEquals, "1999"),
+		condition(models.SmartFieldTitle, models.SmartOpEquals, " "),
+		condition(models.SmartFieldWatched, models.SmartOpEquals, "no"),
+		condition(models.SmartFieldType, models.SmartOpEquals, "documentary"),
+		condition(models.SmartFieldAddedAt, models.SmartOpInLast, "3 days"),
+		condition(models.SmartFieldAddedAt, models.SmartOpBefore, "yesterday"),
+		condition(models.SmartFieldFolderID, models.SmartOpEquals, 1.5),
+		{Field: models.SmartFieldYear, Op: models.SmartOpEquals, Value: 2000, Rules: []models.SmartRule{{}}},
+		deep,
+	} {
+		if _, err := smart.PreviewSmartRules(rule, models.ListOptions{}); !errors.Is(err, ErrInvalidSmartCollection) {
+			t.Errorf("%+v: got error %v, want %v", rule, err, ErrInvalidSmartCollection)
+		}
+	}
+}
+
+func TestSmartCollections(t *testing.T) {
+	library, folder := newScannedLibrary(t, smartLibraryFiles...)
+	ageSmartLibrary(t, library, folder)
+	smart := NewSmartCollectionsService(context.Background(), library.db, logging.Discard())
+
+	collection, err := smart.CreateSmartCollection(models.SmartCollection{
+		Name:  " Short episodes ",
+		Rules: models.SmartRule{Rules: []models.SmartRule{condition(models.SmartFieldType, models.SmartOpEquals, models.MediaTypeEpisode)}},
+		Sort:  "-duration",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if collection.Name != "Short episodes" || collection.Rules.Match != models.SmartMatchAll {
+		t.Errorf("got %+v, want the name trimmed and the rules normalized", collection)
+	}
+	for _, invalid := range []models.SmartCollection{
+		{Name: "short EPISODES"},
+		{Name: ""},
+		{Name: "Sorted", Sort: "rating"},
+	} {
+		if _, err := smart.CreateSmartCollection(invalid); !errors.Is(err, ErrInvalidSmartCollection) {
+			t.Errorf("%+v: got error %v, want %v", invalid, err, ErrInvalidSmartCollection)
+		}
+	}
+
+	// The rules are read back from JSON, numbers come back as float64
+	page, err := smart.ListSmartCollectionItems(collection.ID, models.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := itemNames(page); !slices.Equal(got, []string{"Show.S01E02.mkv", "Show.S01E01.mkv"}) {
+		t.Errorf("got %v, want the episodes longest first", got)
+	}
+	updated, err := smart.UpdateSmartCollection(collection.ID, models.SmartCollection{
+		Name: "Short episodes",
+		Rules: models.SmartRule{Rules: []models.SmartRule{
+			condition(models.SmartFieldType, models.SmartOpEquals, models.MediaTypeEpisode),
+			condition(models.SmartFieldDuration, models.SmartOpLess, 25*60),
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err = smart.ListSmartCollectionItems(updated.ID, models.ListOptions{Filters: []string{models.FilterUnwatched}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := itemNames(page); !slices.Equal(got, []string{"Show.S01E01.mkv"}) {
+		t.Errorf("got %v after updating the rules", got)
+	}
+
+	app := newTestStreamApp(t, library, NewFakeMediaToolkit())
+	status, body := get(t, app, "/categories")
+	var categories []models.Category
+	if err := json.Unmarshal([]byte(body), &categories); err != nil {
+		t.Fatalf("got %d %s", status, body)
+	}
+	want := []models.Category{{ID: 1, Name: "Movies"}, {ID: -collection.ID, Name: "Short episodes", SmartCollectionID: collection.ID}}
+	if !slices.Equal(categories, want) {
+		t.Errorf("got categories %+v, want %+v", categories, want)
+	}
+	status, body = get(t, app, "/smart-collections/"+strconv.Itoa(collection.ID)+"/items")
+	var files []models.File
+	if err := json.Unmarshal([]byte(body), &files); err != nil || status != fiber.StatusOK {
+		t.Fatalf("got %d %s", status, body)
+	}
+	if len(files) != 1 || files[0].Name != "Show.S01E01.mkv" || files[0].URL == "" {
+		t.Errorf("got %s", body)
+	}
+
+	// The virtual category has no folders, browsing it points to its items
+	virtual := strconv.Itoa(-collection.ID)
+	items := "/smart-collections/" + strconv.Itoa(collection.ID) + "/items"
+	if status, body := get(t, app, "/folders/"+virtual); status != fiber.StatusBadRequest || !strings.Contains(body, items) {
+		t.Errorf("got %d %q browsing the virtual category, want 400 pointing to %s", status, body, items)
+	}
+	response, err := app.Test(httptest.NewRequest("DELETE", "/admin/categories/"+virtual, nil), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != fiber.StatusBadRequest {
+		t.Errorf("got status %d deleting the virtual category, want 400", response.StatusCode)
+	}
+
+	if err := smart.DeleteSmartCollection(collection.ID); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := get(t, app, "/smart-collections/"+strconv.Itoa(collection.ID)+"/items"); status != fiber.StatusNotFound {
+		t.Errorf("got status %d for a deleted smart collection, want 404", status)
+	}
+}
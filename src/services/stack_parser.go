@@ -0,0 +1,45 @@
+package services
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// stackPartPattern finds the part of a video split in several files, like
+// "-cd1" in "Movie-cd1.avi" or " (Part 2)" in "Movie (1999) (Part 2).mkv".
+// The part must follow a separator, "Hardcd1" is no part.
+var stackPartPattern = regexp.MustCompile(`(?i)(?:[ ._-]+[\[(]?|[\[(])(?:cd|dvd|dis[ck]|part|pt)[ ._-]?(\d{1,2})([\])]?)(?:[ ._\-\[(]|$)`)
+
+// ParseStackPart reads the part number out of the name of a stacked video
+// file, and returns the name without it, which is the same for every part.
+// "Movie-cd2.avi" is part 2 of "Movie.avi".
+func ParseStackPart(name string) (string, int, bool) {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+
+	// The last part token wins, "Part.1" of a title comes before the disc.
+	// Matches are searched again from the end of the previous part so the
+	// separator following it can start the next one.
+	var match []int
+	for offset := 0; ; {
+		next := stackPartPattern.FindStringSubmatchIndex(base[offset:])
+		if next == nil {
+			break
+		}
+		for i := range next {
+			next[i] += offset
+		}
+		match, offset = next, next[5]
+	}
+	if match == nil || match[0] == 0 {
+		return "", 0, false
+	}
+	part, err := strconv.Atoi(base[match[2]:match[3]])
+	if err != nil || part == 0 {
+		return "", 0, false
+	}
+
+	return base[:match[0]] + base[match[5]:] + ext, part, true
+}
@@ -0,0 +1,32 @@
+package services
+
+import "testing"
+
+func TestParseStackPart(t *testing.T) {
+	tests := []struct {
+		name  string
+		stack string
+		part  int
+	}{
+		{"Movie-cd1.avi", "Movie.avi", 1},
+		{"Movie (1999) CD2.avi", "Movie (1999).avi", 2},
+		{"Movie.1999.part1.DVDRip.XviD.avi", "Movie.1999.DVDRip.XviD.avi", 1},
+		{"Movie [cd1].avi", "Movie.avi", 1},
+		{"Movie - Part 2.mkv", "Movie.mkv", 2},
+		{"Movie (Disc 1).mkv", "Movie.mkv", 1},
+		{"Movie.Disk-02.mkv", "Movie.mkv", 2},
+		{"Movie.pt3.avi", "Movie.avi", 3},
+		{"Saga.Part.1.cd2.avi", "Saga.Part.1.avi", 2},
+		{"Movie.mkv", "", 0},
+		{"cd1.avi", "", 0},
+		{"Hardcd1.avi", "", 0},
+		{"Movie.cd0.avi", "", 0},
+		{"Apartment 2.mkv", "", 0},
+	}
+	for _, test := range tests {
+		stack, part, ok := ParseStackPart(test.name)
+		if ok != (test.part != 0) || stack != test.stack || part != test.part {
+			t.Errorf("ParseStackPart(%q) = %q, %d, %v, want %q, %d", test.name, stack, part, ok, test.stack, test.part)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"localflix-server/src/models"
+	"localflix-server/src/repositories"
+	"path"
+	"slices"
+	"strings"
+)
+
+// stackedPart is a media item named like a part of a stacked video
+type stackedPart struct {
+	item *models.MediaItem
+	name string
+	part int
+}
+
+// syncStacks finds the media items of the folder that are parts of the same
+// video, named alike in the same directory and numbered from 1 without
+// gaps. Parts are titled by the name without their part number. It returns
+// how many stacks the folder has.
+func syncStacks(tx repositories.DBTX, folder *models.Folder) (int, error) {
+	mediaItemsRepository := repositories.NewMediaItemsRepository(tx)
+	items, err := mediaItemsRepository.ListMediaItemsByFolder(folder.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	candidates := map[string][]stackedPart{}
+	for _, item := range items {
+		name, part, ok := ParseStackPart(item.Name)
+		if !ok {
+			continue
+		}
+		key := path.Join(path.Dir(item.RelPath), strings.ToLower(name))
+		candidates[key] = append(candidates[key], stackedPart{item: item, name: name, part: part})
+	}
+
+	stacked := map[int]stackedPart{}
+	stackIds := map[int]int{}
+	stacks := 0
+	for _, parts := range candidates {
+		slices.SortFunc(parts, func(a, b stackedPart) int { return a.part - b.part })
+		complete := len(parts) > 1
+		for i, part := range parts {
+			complete = complete && part.part == i+1
+		}
+		if !complete {
+			continue
+		}
+
+		stacks++
+		for _, part := range parts {
+			stacked[part.item.ID] = part
+			stackIds[part.item.ID] = parts[0].item.ID
+		}
+	}
+
+	for _, item := range items {
+		part, ok := stacked[item.ID]
+		release := ParseReleaseName(item.Name)
+		if ok {
+			release = ParseReleaseName(part.name)
+		}
+
+		if item.StackID != stackIds[item.ID] || item.Part != part.part {
+			if err := mediaItemsRepository.UpdateStack(item.ID, stackIds[item.ID], part.part); err != nil {
+				return 0, err
+			}
+		}
+		if item.Release != release {
+			item.Release = release
+			item.SortTitle = sortTitle(release.Title)
+			if err := mediaItemsRepository.UpdateMediaItemRelease(*item); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	return stacks, nil
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"localflix-server/src/logging"
+	"localflix-server/src/models"
+	"localflix-server/src/repositories"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestScanStacksParts(t *testing.T) {
+	library := newTestLibrary(t)
+	category, err := library.categories.CreateCategory("Movies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := makeDir(t,
+		"Movie-cd1.avi",
+		"Movie-cd2.avi",
+		"Other.Part.1.2010.mkv",
+		"Other.Part.2.2011.mkv",
+		"Gap-cd1.avi",
+		"Gap-cd3.avi",
+		"Dir/Film (1999) CD1.avi",
+		"Dir/Film (1999) CD2.avi",
+	)
+	folder, err := library.folders.CreateFolder(dir, category.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scan := NewScanService(context.Background(), library.db, NewFakeMediaToolkit(), logging.Discard())
+	result, err := scan.ScanFolder(folder.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Stacks != 2 {
+		t.Errorf("got %d stacks, want 2", result.Stacks)
+	}
+
+	app := newTestStreamApp(t, library, NewFakeMediaToolkit())
+	status, body := get(t, app, "/files/"+strconv.Itoa(folder.ID))
+	var files []models.File
+	if err := json.Unmarshal([]byte(body), &files); err != nil || status != fiber.StatusOK {
+		t.Fatalf("got %d %s", status, body)
+	}
+	stacked := map[string]models.File{}
+	for _, file := range files {
+		stacked[file.Name] = file
+	}
+	if len(files) != 6 {
+		t.Errorf("got %d files, want the later parts hidden", len(files))
+	}
+	movie, ok := stacked["Movie-cd1.avi"]
+	if !ok || movie.Title != "Movie" || len(movie.Parts) != 2 || movie.Parts[1].Name != "Movie-cd2.avi" {
+		t.Fatalf("got %+v, want Movie stacked", movie)
+	}
+	if movie.ContentLength != 26 || movie.Duration != 0.026 || !strings.HasSuffix(movie.PlaylistURL, "/items/"+strconv.Itoa(movie.MediaItemID)+"/playlist.m3u8") {
+		t.Errorf("got %+v, want the totals of the parts and a playlist", movie)
+	}
+	if film := stacked["Film (1999) CD1.avi"]; film.Title != "Film" || film.Year != 1999 || len(film.Parts) != 2 {
+		t.Errorf("got %+v, want Film stacked", film)
+	}
+	for _, name := range []string{"Other.Part.1.2010.mkv", "Gap-cd1.avi", "Gap-cd3.avi"} {
+		if file, ok := stacked[name]; !ok || file.Parts != nil {
+			t.Errorf("got %+v, want %s alone", file, name)
+		}
+	}
+
+	item := strconv.Itoa(movie.MediaItemID)
+	status, body = get(t, app, "/items/"+item+"/playlist.m3u8?api_key=secret")
+	if status != fiber.StatusOK || !strings.HasPrefix(body, "#EXTM3U\n") || !strings.HasSuffix(body, "#EXT-X-ENDLIST\n") {
+		t.Fatalf("got %d %s", status, body)
+	}
+	segment := "/items/" + item + "/segments/0.ts"
+	if want := "#EXTINF:0.026,\nhttp://example.com" + segment + "?api_key=secret\n#EXT-X-ENDLIST\n"; !strings.HasSuffix(body, want) {
+		t.Errorf("got playlist %s, want the parts in one segment", body)
+	}
+	if status, body := get(t, app, segment); status != fiber.StatusOK || body != "Movie-cd1.aviMovie-cd2.avi" {
+		t.Errorf("got %d %q, want the parts transcoded in the segment", status, body)
+	}
+
+	status, body = get(t, app, "/items/"+item+"/transcode?start=0.005")
+	if status != fiber.StatusOK || body != "-cd1.aviMovie-cd2.avi" {
+		t.Errorf("got %d %q, want the parts transcoded together", status, body)
+	}
+	if status, _ := get(t, app, "/items/999/transcode"); status != fiber.StatusNotFound {
+		t.Errorf("got status %d for an unknown item, want 404", status)
+	}
+
+	// Rescans keep the stacks, removing a part unstacks the others
+	result, err = scan.ScanFolder(folder.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Stacks != 2 || result.Unchanged != 8 {
+		t.Errorf("got %+v on rescan, want 2 stacks and 8 unchanged items", result)
+	}
+	if err := os.Remove(filepath.Join(dir, "Movie-cd2.avi")); err != nil {
+		t.Fatal(err)
+	}
+	if result, err = scan.ScanFolder(folder.ID); err != nil || result.Stacks != 1 {
+		t.Fatalf("got %+v, %v, want 1 stack", result, err)
+	}
+	status, body = get(t, app, "/files/"+strconv.Itoa(folder.ID))
+	var rescanned []models.File
+	if err := json.Unmarshal([]byte(body), &rescanned); err != nil || status != fiber.StatusOK {
+		t.Fatalf("got %d %s", status, body)
+	}
+	var file models.File
+	for _, f := range rescanned {
+		if f.Name == "Movie-cd1.avi" {
+			file = f
+		}
+	}
+	if file.Title != "Movie-cd1" || file.Parts != nil || file.PlaylistURL != "" {
+		t.Errorf("got %+v, want Movie-cd1 unstacked", file)
+	}
+}
+
+func TestPartsPlaylistSegments(t *testing.T) {
+	// Two parts of 8 seconds, the second segment spans both
+	first, second := strings.Repeat("1", 8*FakeMediaBytesPerSecond), strings.Repeat("2", 8*FakeMediaBytesPerSecond)
+	library, folder := newScannedLibrary(t, "Movie-cd1.avi="+first, "Movie-cd2.avi="+second)
+	app := newTestStreamApp(t, library, NewFakeMediaToolkit())
+	stack, err := repositories.NewMediaItemsRepository(library.db).GetMediaItemByPath(folder.ID, "Movie-cd1.avi")
+	if err != nil || stack == nil {
+		t.Fatalf("got %+v, %v", stack, err)
+	}
+	item := strconv.Itoa(stack.ID)
+
+	status, body := get(t, app, "/items/"+item+"/playlist.m3u8?max_height=480")
+	if status != fiber.StatusOK {
+		t.Fatalf("got %d %s", status, body)
+	}
+	for _, want := range []string{
+		"#EXT-X-TARGETDURATION:6\n",
+		"#EXTINF:6.000,\nhttp://example.com/items/" + item + "/segments/1.ts?max_height=480\n",
+		"#EXTINF:4.000,\nhttp://example.com/items/" + item + "/segments/2.ts?max_height=480\n#EXT-X-ENDLIST\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("got playlist %s, want %q", body, want)
+		}
+	}
+
+	status, body = get(t, app, "/items/"+item+"/segments/1.ts")
+	if want := first[6000:] + second[:4000]; status != fiber.StatusOK || body != want {
+		t.Errorf("got %d with %d bytes, want the 6 seconds across both parts", status, len(body))
+	}
+	if status, body = get(t, app, "/items/"+item+"/segments/2.ts"); status != fiber.StatusOK || body != second[4000:] {
+		t.Errorf("got %d with %d bytes, want the last 4 seconds", status, len(body))
+	}
+	for path, want := range map[string]int{
+		"/items/" + item + "/segments/3.ts":  fiber.StatusNotFound,
+		"/items/" + item + "/segments/-1.ts": fiber.StatusBadRequest,
+		"/items/999/segments/0.ts":           fiber.StatusNotFound,
+	} {
+		if status, _ := get(t, app, path); status != want {
+			t.Errorf("%s = %d, want %d", path, status, want)
+		}
+	}
+}
@@ -1,98 +1,463 @@
 package services
 
 import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"localflix-server/src/appdata"
+	"localflix-server/src/logging"
 	"localflix-server/src/models"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
 )
 
+const serverPort = 3001
+
+const (
+	requestIDLocal = "requestId"
+	loggerLocal    = "logger"
+)
+
+// thumbnailPosition is how many seconds into a video its thumbnail is taken,
+// late enough to skip most intros' black frames
+const thumbnailPosition = 5
+
+// maxTranscodes caps the transcodes running at once, each ffmpeg process
+// keeps a CPU core busy
+const maxTranscodes = 4
+
 type StreamService struct {
-	app               *fiber.App
-	foldersService    FoldersService
-	videoFileService  VideoFileService
-	categoriesService CategoriesService
+	// mu guards app, redirectServer, streamTracker and stopRescans, set by
+	// Serve while StopServer can be called from another goroutine
+	mu                      sync.Mutex
+	app                     *fiber.App
+	redirectServer          *http.Server
+	foldersService          FoldersService
+	mediaToolkit            MediaToolkit
+	categoriesService       CategoriesService
+	apiKeysService          ApiKeysService
+	settingsService         SettingsService
+	certificateService      CertificateService
+	rateLimitService        *RateLimitService
+	auditService            AuditService
+	scanService             ScanService
+	seriesService           SeriesService
+	metadataService         MetadataService
+	searchService           SearchService
+	mediaItemsService       MediaItemsService
+	tagsService             TagsService
+	collectionsService      CollectionsService
+	smartCollectionsService SmartCollectionsService
+	watchProgressService    WatchProgressService
+	streamTracker           *streamTracker
+	stopRescans             context.CancelFunc
+	// transcodes holds a token per running transcode
+	transcodes chan struct{}
+	dirs       *appdata.Dirs
+	logger     *slog.Logger
 }
 
-func NewStreamService(foldersService FoldersService, videoFileService VideoFileService, categoriesService CategoriesService) *StreamService {
+func NewStreamService(foldersService FoldersService, mediaToolkit MediaToolkit, categoriesService CategoriesService, apiKeysService ApiKeysService, settingsService SettingsService, certificateService CertificateService, rateLimitService *RateLimitService, auditService AuditService, scanService ScanService, seriesService SeriesService, metadataService MetadataService, searchService SearchService, mediaItemsService MediaItemsService, tagsService TagsService, collectionsService CollectionsService, smartCollectionsService SmartCollectionsService, watchProgressService WatchProgressService, dirs *appdata.Dirs, logger *slog.Logger) *StreamService {
 	return &StreamService{
-		app: fiber.New(fiber.Config{
-			IdleTimeout:  10 * time.Minute,
-			ReadTimeout:  10 * time.Minute, // Increase the read timeout
-			WriteTimeout: 10 * time.Minute,
-		}),
-		foldersService:    foldersService,
-		videoFileService:  videoFileService,
-		categoriesService: categoriesService,
+		foldersService:          foldersService,
+		mediaToolkit:            mediaToolkit,
+		categoriesService:       categoriesService,
+		apiKeysService:          apiKeysService,
+		settingsService:         settingsService,
+		certificateService:      certificateService,
+		rateLimitService:        rateLimitService,
+		auditService:            auditService,
+		scanService:             scanService,
+		seriesService:           seriesService,
+		metadataService:         metadataService,
+		searchService:           searchService,
+		mediaItemsService:       mediaItemsService,
+		tagsService:             tagsService,
+		collectionsService:      collectionsService,
+		smartCollectionsService: smartCollectionsService,
+		watchProgressService:    watchProgressService,
+		dirs:                    dirs,
+		transcodes:              make(chan struct{}, maxTranscodes),
+		logger:                  logger,
 	}
 }
 
 func (s *StreamService) StartServer() {
-	app := s.app
-	app.Use(cors.New(cors.Config{
-		AllowOrigins: "*", // Allow requests from all origins
-		AllowMethods: "*", // Allow specific HTTP methods
-		AllowHeaders: "*", // Allow specific headers
-	}))
+	if err := s.Serve(); err != nil {
+		s.logger.Error("starting server", "err", err)
+	}
+}
+
+// Serve runs the server until StopServer is called. Unlike StartServer it
+// returns the error that stopped it from starting.
+func (s *StreamService) Serve() error {
+	// A fresh fiber app is built on every start so settings changes made while
+	// the server was stopped are picked up
+	app := fiber.New(fiber.Config{
+		IdleTimeout:  10 * time.Minute,
+		ReadTimeout:  10 * time.Minute, // Increase the read timeout
+		WriteTimeout: 10 * time.Minute,
+	})
+	app.Use(requestid.New(requestid.Config{ContextKey: requestIDLocal}))
+	app.Use(s.logRequests)
+	app.Use(s.rateLimitIP)
+
+	corsSettings, err := s.settingsService.GetCorsSettings()
+	if err != nil {
+		return fmt.Errorf("loading CORS settings: %w", err)
+	}
+	// Without allowed origins no CORS headers are sent and browsers fall back
+	// to same-origin only. An empty AllowOrigins would make fiber allow "*".
+	if len(corsSettings.AllowOrigins) > 0 {
+		app.Use(cors.New(cors.Config{
+			AllowOrigins:     strings.Join(corsSettings.AllowOrigins, ","),
+			AllowMethods:     strings.Join(corsSettings.AllowMethods, ","),
+			AllowHeaders:     "Origin, Content-Type, Accept, Range, X-Api-Key",
+			ExposeHeaders:    "Content-Range, Content-Length, Accept-Ranges, " + headerTotalCount,
+			AllowCredentials: corsSettings.AllowCredentials,
+		}))
+	}
+
+	// Stream and subtitle requests skip the per key limit and are limited by
+	// bandwidth, players send lots of range requests while seeking
+	app.Get("/categories", s.requireScope(models.ScopeLibraryRead), s.rateLimit, s.ListCategories)
+	app.Get("/folders/:categoryId", s.requireScope(models.ScopeLibraryRead), s.rateLimit, s.ListFolderByCategory)
+	app.Get("/stream/:folderId/:fileName", s.requireScope(models.ScopeStream), s.streamVideo)
+	app.Get("/transcode/:folderId/:fileName", s.requireScope(models.ScopeStream), s.transcodeVideo)
+	app.Get("/files/:folderId", s.requireScope(models.ScopeLibraryRead), s.rateLimit, s.listFiles)
+	app.Get("/subtitles/:folderId/:fileName", s.requireScope(models.ScopeStream), s.getSubtitles)
+	app.Get("/thumbnails/:folderId/:fileName", s.requireScope(models.ScopeLibraryRead), s.rateLimit, s.getThumbnail)
+	s.registerSeriesRoutes(app)
+	s.registerArtworkRoutes(app)
+	s.registerSearchRoutes(app)
+	s.registerCollectionRoutes(app)
+	s.registerSmartCollectionRoutes(app)
+	s.registerVersionRoutes(app)
+	s.registerPartRoutes(app)
+	s.registerAdminRoutes(app)
+
+	if status := s.mediaToolkit.Status(); !status.Available {
+		s.logger.Warn("thumbnails, subtitles and transcoding are disabled", "reason", status.Error)
+	}
+
+	tlsSettings, err := s.settingsService.GetTlsSettings()
+	if err != nil {
+		return fmt.Errorf("loading TLS settings: %w", err)
+	}
+
+	listener, err := s.listen(tlsSettings)
+	if err != nil {
+		return err
+	}
+
+	rescans, stopRescans := context.WithCancel(context.Background())
+	s.mu.Lock()
+	s.app = app
+	s.streamTracker = newStreamTracker(&s.auditService, &s.watchProgressService, s.streamedFile)
+	s.stopRescans = stopRescans
+	s.mu.Unlock()
+	go s.scanService.RescanStaleFolders(rescans)
+	// Stops what was started above when the app stops on its own
+	defer s.stop(app)
+
+	if tlsSettings.Enabled && tlsSettings.RedirectHTTP {
+		s.startRedirectServer(tlsSettings.RedirectPort)
+	}
+
+	return app.Listener(listener)
+}
+
+// listen binds the server port, wrapped in TLS when enabled. The certificate
+// is loaded first so a bad one fails the start before anything else runs.
+func (s *StreamService) listen(tlsSettings *models.TlsSettings) (net.Listener, error) {
+	addr := fmt.Sprintf("0.0.0.0:%d", serverPort)
+	if !tlsSettings.Enabled {
+		s.logger.Info("starting server", "port", serverPort)
+		return net.Listen("tcp", addr)
+	}
+
+	certFile, keyFile := tlsSettings.CertFile, tlsSettings.KeyFile
+	if certFile == "" {
+		var err error
+		certFile, keyFile, err = s.certificateService.SelfSignedCertificate()
+		if err != nil {
+			return nil, fmt.Errorf("preparing self-signed certificate: %w", err)
+		}
+	}
+	certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading TLS certificate: %w", err)
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
 
-	app.Get("/categories", s.ListCategories)
-	app.Get("/folders/:categoryId", s.ListFolderByCategory)
-	app.Get("/stream/:folderId/:fileName", s.streamVideo)
-	app.Get("/files/:folderId", s.listFiles)
-	app.Get("/subtitles/:folderId/:fileName", s.getSubtitles)
-	app.Get("/thumbnails/:folderId/:fileName", s.getThumbnail)
+	s.logger.Info("starting HTTPS server", "port", serverPort)
+	return tls.NewListener(listener, &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{certificate},
+	}), nil
+}
 
-	fmt.Printf("Starting server on port 3001")
+// startRedirectServer listens for plain HTTP on port and sends every request
+// to the same path on the HTTPS server.
+func (s *StreamService) startRedirectServer(port int) {
+	server := &http.Server{
+		Addr:              fmt.Sprintf("0.0.0.0:%d", port),
+		ReadHeaderTimeout: 10 * time.Second,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host := r.Host
+			if h, _, err := net.SplitHostPort(r.Host); err == nil {
+				host = h
+			}
+			target := url.URL{
+				Scheme:   "https",
+				Host:     net.JoinHostPort(host, strconv.Itoa(serverPort)),
+				Path:     r.URL.Path,
+				RawQuery: r.URL.RawQuery,
+			}
+			http.Redirect(w, r, target.String(), http.StatusPermanentRedirect)
+		}),
+	}
 
-	if err := app.Listen("0.0.0.0:3001"); err != nil {
-		log.Printf("Error starting server: %v", err)
+	// Bound here so a port in use is logged before HTTPS starts serving, the
+	// HTTPS server still runs without the redirect
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		s.logger.Error("starting redirect server", "err", err)
+		return
 	}
+
+	s.mu.Lock()
+	s.redirectServer = server
+	s.mu.Unlock()
+
+	s.logger.Info("redirecting HTTP to HTTPS", "port", port)
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			s.logger.Error("redirect server stopped", "err", err)
+		}
+	}()
 }
 
+// StopServer waits for the requests in flight before stopping the stream
+// tracker, so the streams they touch are audited as stopped
 func (s *StreamService) StopServer() {
-	fmt.Printf("Stopping server")
-	s.app.Shutdown()
+	s.logger.Info("stopping server")
+	s.stop(nil)
+}
+
+// stop shuts down the running app, the redirect server, the rescans and the
+// stream tracker. Given an app, it only does so while that app is the
+// running one.
+func (s *StreamService) stop(running *fiber.App) {
+	s.mu.Lock()
+	if running != nil && s.app != running {
+		s.mu.Unlock()
+		return
+	}
+	app, redirectServer, tracker, stopRescans := s.app, s.redirectServer, s.streamTracker, s.stopRescans
+	s.app, s.redirectServer, s.stopRescans = nil, nil, nil
+	s.mu.Unlock()
+
+	if stopRescans != nil {
+		stopRescans()
+	}
+	if app != nil {
+		app.Shutdown()
+	}
+	if redirectServer != nil {
+		redirectServer.Close()
+	}
+	if tracker == nil {
+		return
+	}
+
+	s.mu.Lock()
+	if s.streamTracker == tracker {
+		s.streamTracker = nil
+	}
+	s.mu.Unlock()
+	tracker.stop()
+}
+
+// streamedFile looks up the media item streamed by the file of the folder,
+// for the watch progress. The parts of a stack stream the stack.
+func (s *StreamService) streamedFile(folderId int, fileName string) streamedFile {
+	item, err := s.mediaItemsService.GetMediaItemByPath(folderId, filepath.ToSlash(fileName))
+	if err != nil || item == nil {
+		return streamedFile{}
+	}
+
+	file := streamedFile{mediaItemId: item.ID, duration: item.Duration, total: item.Duration}
+	if item.StackID == 0 {
+		return file
+	}
+	parts, err := s.mediaItemsService.ListParts(item.ID)
+	if err != nil {
+		return file
+	}
+	file.mediaItemId, file.total = parts[0].ID, 0
+	for _, part := range parts {
+		if part.Part < item.Part {
+			file.offset += part.Duration
+		}
+		file.total += part.Duration
+	}
+	return file
+}
+
+// tracker returns the stream tracker of the running server, nil once it is
+// stopped
+func (s *StreamService) tracker() *streamTracker {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.streamTracker
+}
+
+// logRequests gives the request a logger carrying its request id and logs
+// the request once handled. Successful requests are only logged at debug
+// level, players send one per range while seeking.
+func (s *StreamService) logRequests(c *fiber.Ctx) error {
+	start := time.Now()
+	requestId, _ := c.Locals(requestIDLocal).(string)
+	logger := s.logger.With(logging.RequestIDKey, requestId)
+	c.Locals(loggerLocal, logger)
+
+	err := c.Next()
+
+	status := c.Response().StatusCode()
+	if err != nil {
+		status = fiber.StatusInternalServerError
+		if fiberErr, ok := err.(*fiber.Error); ok {
+			status = fiberErr.Code
+		}
+	}
+	level := slog.LevelDebug
+	switch {
+	case status >= 500:
+		level = slog.LevelError
+	case status >= 400 && status != fiber.StatusNotFound:
+		// Not found is left at debug, players ask for subtitles of videos
+		// that have none
+		level = slog.LevelInfo
+	}
+
+	// The query is left out, it can hold the api key
+	logger.Log(context.Background(), level, "request",
+		"method", c.Method(),
+		"path", c.Path(),
+		"status", status,
+		"duration", time.Since(start),
+		"ip", c.IP(),
+		"actor", actorName(c),
+	)
+	return err
+}
+
+// requestLogger returns the logger of the request, see logRequests
+func (s *StreamService) requestLogger(c *fiber.Ctx) *slog.Logger {
+	if logger, ok := c.Locals(loggerLocal).(*slog.Logger); ok {
+		return logger
+	}
+
+	return s.logger
+}
+
+// requireScope authenticates the request with an api key sent in the
+// X-Api-Key header or the api_key query param. The query param is there for
+// clients like <video> tags that can't set headers.
+func (s *StreamService) requireScope(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get("X-Api-Key")
+		if key == "" {
+			key = c.Query("api_key")
+		}
+		if key == "" {
+			return c.Status(fiber.StatusUnauthorized).SendString("Missing API key")
+		}
+
+		apiKey, err := s.apiKeysService.Authenticate(key, c.IP())
+		if err != nil {
+			s.requestLogger(c).Error("authenticating api key", "err", err)
+			return c.Status(fiber.StatusInternalServerError).SendString("Error authenticating API key")
+		}
+		if apiKey == nil {
+			return c.Status(fiber.StatusUnauthorized).SendString("Invalid API key")
+		}
+		if !apiKey.HasScope(scope) {
+			return c.Status(fiber.StatusForbidden).SendString("API key is missing the " + scope + " scope")
+		}
+
+		c.Locals("apiKey", apiKey)
+		return c.Next()
+	}
 }
 
 func (s *StreamService) getThumbnail(c *fiber.Ctx) error {
 	fileName := c.Params("fileName")
 	fileName, err := url.QueryUnescape(fileName)
-	log.Default().Printf("Getting thumbnail for %s...", fileName)
 	if err != nil {
-		fmt.Printf("Error unescaping file name: %v", err)
 		return c.Status(fiber.StatusBadRequest).SendString("Invalid file name")
 	}
 
 	folderId := c.Params("folderId")
 	folderIdInt, err := strconv.Atoi(folderId)
 	if err != nil {
-		fmt.Printf("Error converting folder ID to int: %v", err)
 		return c.Status(fiber.StatusBadRequest).SendString("Invalid folder ID")
 	}
 
-	file, err := os.Open(fmt.Sprintf("./tmp/thumbnails/%d/%s", folderIdInt, fileName))
+	thumbnailPath, ok := s.resolveInside(c, s.dirs.FolderThumbnails(folderIdInt), fileName)
+	if !ok {
+		return c.Status(fiber.StatusForbidden).SendString("Invalid file name")
+	}
+
+	// Local artwork, like Kodi's episode thumbs, wins over generated frames
+	if imagePath := s.localThumbnail(folderIdInt, fileName); imagePath != "" {
+		return s.sendImage(c, imagePath)
+	}
+
+	err = s.generateCached(folderIdInt, fileName, thumbnailPath, func(videoPath string, tmpPath string) error {
+		return s.mediaToolkit.Thumbnail(videoPath, tmpPath, thumbnailPosition)
+	})
+	if err != nil {
+		s.requestLogger(c).Error("generating thumbnail", "file", fileName, "err", err)
+		return sendMediaError(c, err)
+	}
+
+	file, err := os.Open(thumbnailPath)
 	if err != nil {
-		fmt.Printf("Error opening file: %v", err)
+		s.requestLogger(c).Error("opening file", "file", fileName, "err", err)
 		return c.Status(fiber.StatusInternalServerError).SendString("Error opening file")
 	}
 
 	defer file.Close()
 
-	c.Set(fiber.HeaderContentType, "image/jpeg")
+	c.Type(strings.TrimPrefix(filepath.Ext(fileName), "."))
 	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%s", fileName))
 	_, copyErr := io.Copy(c.Response().BodyWriter(), file)
 	if copyErr != nil {
-		log.Println("Error copying entire file to response:", copyErr)
+		s.requestLogger(c).Error("sending file", "file", fileName, "err", copyErr)
 		return c.Status(fiber.StatusInternalServerError).SendString("Internal Server Error")
 	}
 
@@ -102,22 +467,33 @@ func (s *StreamService) getThumbnail(c *fiber.Ctx) error {
 func (s *StreamService) getSubtitles(c *fiber.Ctx) error {
 	fileName := c.Params("fileName")
 	fileName, err := url.QueryUnescape(fileName)
-	log.Default().Printf("Getting subtitles for %s...", fileName)
 	if err != nil {
-		fmt.Printf("Error unescaping file name: %v", err)
 		return c.Status(fiber.StatusBadRequest).SendString("Invalid file name")
 	}
 
 	folderId := c.Params("folderId")
 	folderIdInt, err := strconv.Atoi(folderId)
 	if err != nil {
-		fmt.Printf("Error converting folder ID to int: %v", err)
 		return c.Status(fiber.StatusBadRequest).SendString("Invalid folder ID")
 	}
 
-	file, err := os.Open(fmt.Sprintf("./tmp/subtitles/%d/%s", folderIdInt, fileName))
+	subtitlesPath, ok := s.resolveInside(c, s.dirs.FolderSubtitles(folderIdInt), fileName)
+	if !ok {
+		return c.Status(fiber.StatusForbidden).SendString("Invalid file name")
+	}
+
+	err = s.generateCached(folderIdInt, fileName, subtitlesPath, func(videoPath string, tmpPath string) error {
+		_, err := s.mediaToolkit.ExtractSubtitles(videoPath, filepath.Dir(tmpPath), strings.TrimSuffix(filepath.Base(tmpPath), filepath.Ext(tmpPath)))
+		return err
+	})
+	if err != nil {
+		s.requestLogger(c).Error("extracting subtitles", "file", fileName, "err", err)
+		return sendMediaError(c, err)
+	}
+
+	file, err := os.Open(subtitlesPath)
 	if err != nil {
-		fmt.Printf("Error opening file: %v", err)
+		s.requestLogger(c).Error("opening file", "file", fileName, "err", err)
 		return c.Status(fiber.StatusInternalServerError).SendString("Error opening file")
 	}
 
@@ -127,7 +503,7 @@ func (s *StreamService) getSubtitles(c *fiber.Ctx) error {
 	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%s", fileName))
 	_, copyErr := io.Copy(c.Response().BodyWriter(), file)
 	if copyErr != nil {
-		log.Println("Error copying entire file to response:", copyErr)
+		s.requestLogger(c).Error("sending file", "file", fileName, "err", copyErr)
 		return c.Status(fiber.StatusInternalServerError).SendString("Internal Server Error")
 	}
 
@@ -138,75 +514,65 @@ func (s *StreamService) streamVideo(c *fiber.Ctx) error {
 	fileName := c.Params("fileName")
 	fileName, err := url.QueryUnescape(fileName)
 	if err != nil {
-		log.Default().Printf("Error unescaping file name: %v", err)
 		return c.Status(fiber.StatusBadRequest).SendString("Invalid file name")
 	}
 	folderId := c.Params("folderId")
 	folderIdInt, err := strconv.Atoi(folderId)
 	if err != nil {
-		log.Default().Printf("Error converting folder ID to int: %v", err)
 		return c.Status(fiber.StatusBadRequest).SendString("Invalid folder ID")
 	}
 	folder, err := s.foldersService.GetFolderById(folderIdInt)
 	if err != nil {
-		log.Default().Printf("Error getting folder: %v", err)
+		s.requestLogger(c).Error("getting folder", "folder_id", folderIdInt, "err", err)
 		return c.Status(fiber.StatusInternalServerError).SendString("Error retrieving folder")
 	}
 
-	filePath := fmt.Sprintf("%s/%s", folder.Path, fileName)
+	filePath, ok := s.resolveInside(c, folder.Path, fileName)
+	if !ok {
+		return c.Status(fiber.StatusForbidden).SendString("Invalid file name")
+	}
 
 	file, err := os.Open(filePath)
 	if err != nil {
-		log.Default().Printf("Error opening file: %v", err)
+		s.requestLogger(c).Error("opening file", "file", fileName, "err", err)
 		return c.Status(fiber.StatusInternalServerError).SendString("Error opening file")
 	}
-	defer file.Close()
+	// The file is closed by sendFileRange once the body was streamed
 
 	fileInfo, err := file.Stat()
 	if err != nil {
-		fmt.Printf("Error getting file info: %v", err)
+		file.Close()
+		s.requestLogger(c).Error("getting file info", "file", fileName, "err", err)
 		return c.Status(fiber.StatusInternalServerError).SendString("Error getting file info")
 	}
 	fileSize := fileInfo.Size()
 
 	rangeHeader := c.Get("Range")
 
-	// If no Range header, serve the whole file
+	// Without a Range header the whole file is sent, telling the player it
+	// can ask for ranges to seek
 	if rangeHeader == "" {
-		log.Default().Printf("Serving default chunk size...")
-		const defaultChunkSize int64 = 10 * 1024 * 1024 // 1 MB
-		chunkSize := defaultChunkSize
-		if fileSize < chunkSize {
-			chunkSize = fileSize
-		}
-
-		c.Status(http.StatusPartialContent)
-		c.Set("Content-Range", fmt.Sprintf("bytes 0-%d/%d", chunkSize-1, fileSize))
-		c.Set("Content-Length", strconv.FormatInt(chunkSize, 10))
-		c.Set("Content-Type", "video/mp4") // Set the correct MIME type
+		c.Status(http.StatusOK)
+		c.Set("Accept-Ranges", "bytes")
+		c.Set("Content-Type", "video/mp4")
 
-		log.Default().Printf("Serving file...")
-		_, err = io.CopyN(c.Response().BodyWriter(), file, chunkSize)
-		log.Default().Printf("File served")
-		return err
+		s.tracker().touchRange(c, folderIdInt, fileName, 0)
+		s.sendFileRange(c, file, fileSize)
+		return nil
 	}
 
 	// Parse Range header
-	log.Default().Printf("Parsing range header...")
 	var start, end int64
 	_, err = fmt.Sscanf(rangeHeader, "bytes=%d-%d", &start, &end)
 	if err != nil || end == 0 {
-		log.Default().Printf("Error parsing range header: %v", err)
 		end = fileSize - 1
 	}
 
 	if start < 0 || end >= fileSize || start > end {
-		log.Default().Printf("Invalid range")
+		file.Close()
 		return c.Status(http.StatusRequestedRangeNotSatisfiable).SendString("Invalid range")
 	}
 
-	log.Default().Printf("Serving range...")
-	log.Default().Printf("Range: %d-%d", start, end)
 	contentLength := end - start + 1
 	c.Status(http.StatusPartialContent)
 	c.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, fileSize))
@@ -214,75 +580,458 @@ func (s *StreamService) streamVideo(c *fiber.Ctx) error {
 	c.Set("Content-Type", "video/mp4")
 
 	file.Seek(start, io.SeekStart)
-	log.Default().Printf("Serving file...")
-	_, err = io.CopyN(c.Response().BodyWriter(), file, contentLength)
-	log.Default().Printf("Range served")
-	return err
+	s.tracker().touchRange(c, folderIdInt, fileName, float64(start)/float64(fileSize))
+	s.requestLogger(c).Debug("serving range", "file", fileName, "start", start, "end", end, "size", fileSize)
+	s.sendFileRange(c, file, contentLength)
+	return nil
 }
 
+// transcodeVideo streams the video converted to fragmented MP4 for players
+// that can't play the original. Seeking is done by requesting again with the
+// start query parameter in seconds.
+func (s *StreamService) transcodeVideo(c *fiber.Ctx) error {
+	fileName, err := url.QueryUnescape(c.Params("fileName"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid file name")
+	}
+	folderId, err := strconv.Atoi(c.Params("folderId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid folder ID")
+	}
+	folder, err := s.foldersService.GetFolderById(folderId)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("Error retrieving folder")
+	}
+
+	filePath, ok := s.resolveInside(c, folder.Path, fileName)
+	if !ok {
+		return c.Status(fiber.StatusForbidden).SendString("Invalid file name")
+	}
+	if _, err := os.Stat(filePath); err != nil {
+		return c.Status(fiber.StatusNotFound).SendString("Video not found")
+	}
+	if status := s.mediaToolkit.Status(); !status.Available {
+		return c.Status(fiber.StatusServiceUnavailable).SendString(status.Error)
+	}
+
+	options := models.TranscodeOptions{
+		Start:     c.QueryFloat("start"),
+		MaxHeight: c.QueryInt("max_height"),
+	}
+	if options.Start < 0 || options.MaxHeight < 0 {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid start or max_height")
+	}
+
+	if !s.acquireTranscode() {
+		return sendTooManyTranscodes(c)
+	}
+	tracker := s.tracker()
+	stream := tracker.startTranscode(c, folderId, fileName, options.Start)
+	client := clientKey(c)
+	logger := s.requestLogger(c)
+	c.Set(fiber.HeaderContentType, "video/mp4")
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer s.releaseTranscode()
+		defer tracker.endTranscode(stream)
+		err := s.mediaToolkit.Transcode(context.Background(), filePath, options, s.rateLimitService.ThrottledWriter(client, w))
+		if err != nil {
+			logger.Warn("transcoding stopped", "file", fileName, "err", err)
+			return
+		}
+		w.Flush()
+	})
+	return nil
+}
+
+// acquireTranscode takes a transcode token, false when maxTranscodes are
+// already running. The token is given back with releaseTranscode once the
+// transcode stops.
+func (s *StreamService) acquireTranscode() bool {
+	select {
+	case s.transcodes <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *StreamService) releaseTranscode() {
+	<-s.transcodes
+}
+
+func sendTooManyTranscodes(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderRetryAfter, "10")
+	return c.Status(fiber.StatusServiceUnavailable).SendString("Too many transcodes running, try again later")
+}
+
+// generateCached makes sure cachePath exists, generating it from the video
+// named like fileName in the folder. generate writes to a temporary path
+// renamed once complete, so concurrent requests never serve a partial file.
+func (s *StreamService) generateCached(folderId int, fileName string, cachePath string, generate func(videoPath string, tmpPath string) error) error {
+	if _, err := os.Stat(cachePath); err == nil {
+		return nil
+	}
+
+	folder, err := s.foldersService.GetFolderById(folderId)
+	if err != nil {
+		return err
+	}
+	videoPath, err := findVideo(filepath.Join(folder.Path, strings.TrimSuffix(fileName, filepath.Ext(fileName))))
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cachePath), os.ModePerm); err != nil {
+		return err
+	}
+	tmpPath := filepath.Join(filepath.Dir(cachePath), "."+filepath.Base(cachePath))
+	if err := generate(videoPath, tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return os.Rename(tmpPath, cachePath)
+}
+
+// findVideo returns the video file whose path without extension is
+// pathWithoutExt
+func findVideo(pathWithoutExt string) (string, error) {
+	entries, err := os.ReadDir(filepath.Dir(pathWithoutExt))
+	if err != nil {
+		return "", err
+	}
+
+	base := filepath.Base(pathWithoutExt)
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && isVideoFile(name) && strings.TrimSuffix(name, filepath.Ext(name)) == base {
+			return filepath.Join(filepath.Dir(pathWithoutExt), name), nil
+		}
+	}
+
+	return "", fmt.Errorf("no video named %s: %w", base, fs.ErrNotExist)
+}
+
+// sendMediaError answers a failed thumbnail or subtitles generation. Missing
+// media tools are reported as such, so clients can tell it apart from a
+// broken file.
+func sendMediaError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, ErrMediaToolkitUnavailable):
+		return c.Status(fiber.StatusServiceUnavailable).SendString(err.Error())
+	case errors.Is(err, fs.ErrNotExist):
+		return c.Status(fiber.StatusNotFound).SendString("Video not found")
+	}
+
+	return c.Status(fiber.StatusInternalServerError).SendString("Error processing video")
+}
+
+// sendFileRange streams length bytes from the file's current offset, paced by
+// the client's bandwidth bucket. The body is written after the handler
+// returns, so the file is closed from the stream writer.
+func (s *StreamService) sendFileRange(c *fiber.Ctx, file *os.File, length int64) {
+	client := clientKey(c)
+	logger := s.requestLogger(c)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer file.Close()
+		_, err := io.CopyN(s.rateLimitService.ThrottledWriter(client, w), file, length)
+		if err != nil {
+			// Players drop connections all the time when seeking
+			logger.Debug("streaming stopped", "err", err)
+			return
+		}
+		w.Flush()
+	})
+	// SetBodyStreamWriter switches to chunked encoding, players expect the
+	// exact length of the range
+	c.Response().Header.SetContentLength(int(length))
+}
+
+// resolveInside joins name to dir and rejects names escaping dir, like
+// "../../etc/passwd". Rejections are audited.
+func (s *StreamService) resolveInside(c *fiber.Ctx, dir string, name string) (string, bool) {
+	resolved := filepath.Join(dir, name)
+	if isInside(dir, resolved) {
+		return resolved, true
+	}
+
+	s.requestLogger(c).Warn("rejected access outside of dir", "name", name, "dir", dir)
+	s.auditService.Record(models.AuditEvent{
+		Action:  models.AuditPathRejected,
+		Actor:   actorName(c),
+		IP:      c.IP(),
+		Details: fmt.Sprintf("%s: %s", c.Path(), name),
+	})
+	return "", false
+}
+
+func (s *StreamService) listAuditEvents(c *fiber.Ctx) error {
+	filter := models.AuditFilter{
+		Action:     c.Query("action"),
+		Actor:      c.Query("actor"),
+		TargetType: c.Query("target_type"),
+		TargetID:   c.QueryInt("target_id"),
+		Limit:      c.QueryInt("limit"),
+		Offset:     c.QueryInt("offset"),
+	}
+	for param, target := range map[string]**time.Time{"since": &filter.Since, "until": &filter.Until} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString("Invalid " + param + ", expected an RFC 3339 date")
+		}
+		*target = &parsed
+	}
+
+	events, err := s.auditService.ListAuditEvents(filter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("Error listing audit events")
+	}
+	total, err := s.auditService.CountAuditEvents(filter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("Error listing audit events")
+	}
+
+	return sendPage(c, events, total)
+}
+
+// rateLimit rejects requests once the client used up its requests per minute.
+func (s *StreamService) rateLimit(c *fiber.Ctx) error {
+	if !s.rateLimitService.AllowRequest(clientKey(c)) {
+		return c.Status(fiber.StatusTooManyRequests).SendString("Too many requests")
+	}
+
+	return c.Next()
+}
+
+// rateLimitIP rejects requests once the IP used up its requests per minute,
+// before the api key is checked. rateLimit still limits each key after it.
+func (s *StreamService) rateLimitIP(c *fiber.Ctx) error {
+	if !s.rateLimitService.AllowIPRequest(c.IP()) {
+		return c.Status(fiber.StatusTooManyRequests).SendString("Too many requests")
+	}
+
+	return c.Next()
+}
+
+// actorName is the name of the api key that authenticated the request
+func actorName(c *fiber.Ctx) string {
+	if apiKey, ok := c.Locals("apiKey").(*models.ApiKey); ok {
+		return apiKey.Name
+	}
+
+	return ""
+}
+
+// userID is the user of the request's api key, 0 for the keys of shared
+// clients
+func userID(c *fiber.Ctx) int {
+	if apiKey, ok := c.Locals("apiKey").(*models.ApiKey); ok {
+		return apiKey.UserID
+	}
+
+	return 0
+}
+
+// clientKey identifies the client for rate limiting, by api key when the
+// request was authenticated and by IP otherwise.
+func clientKey(c *fiber.Ctx) string {
+	if apiKey, ok := c.Locals("apiKey").(*models.ApiKey); ok {
+		return fmt.Sprintf("key:%d", apiKey.ID)
+	}
+
+	return "ip:" + c.IP()
+}
+
+// listFiles pages the scanned videos of the folder, by title unless sorted
+// otherwise. A folder never scanned gets scanned in the background, its
+// page is answered with 202 Accepted until then. Later changes are picked
+// up by the rescans.
 func (s *StreamService) listFiles(c *fiber.Ctx) error {
-	folderId := c.Params("folderId")
-	folderIdInt, err := strconv.Atoi(folderId)
+	folderId, err := strconv.Atoi(c.Params("folderId"))
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).SendString("Invalid folder ID")
 	}
 
-	folder, err := s.foldersService.GetFolderById(folderIdInt)
+	folder, err := s.foldersService.GetFolderById(folderId)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).SendString("Error retrieving folder")
 	}
-	fmt.Printf("Listing files for folder: %v", folder.Path)
+	if folder.LastScannedAt == nil {
+		s.scanService.ScanFolderInBackground(folderId)
+		c.Status(fiber.StatusAccepted)
+	}
 
-	entries, err := os.ReadDir(folder.Path)
+	options := listOptions(c)
+	if options.Sort == "" {
+		options.Sort = models.SortName
+	}
+	page, err := s.mediaItemsService.ListMediaItems(folderId, options)
 	if err != nil {
-		fmt.Printf("Error reading directory: %v", err)
+		return sendListError(c, err, "Error listing files")
 	}
 
-	var files []models.File
-	for _, entry := range entries {
-		// videoFileDuration, err := s.videoFileService.GetVideoDurationInSeconds(fmt.Sprintf("%s/%s", folder.Path, entry.Name()))
-		// if err != nil {
-		// 	fmt.Printf("Error getting video duration: %v", err)
-		// 	return err
-		// }
-
-		fileNameWithoutExt := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
-		// subtitlePath := fmt.Sprintf("./tmp/subtitles/%d/%s.vtt", folderIdInt, fileNameWithoutExt)
-		// if _, err := os.Stat(subtitlePath); os.IsNotExist(err) {
-		// 	// s.videoFileService.ExtractAndConvertSubtitles(fmt.Sprintf("%s/%s", folder.Path, entry.Name()), fmt.Sprintf("./tmp/subtitles/%d", folderIdInt), fileNameWithoutExt)
-		// }
-
-		// thumbnailPath := fmt.Sprintf("./tmp/thumbnails/%d/%s.png", folderIdInt, fileNameWithoutExt)
-		// if _, err := os.Stat(thumbnailPath); os.IsNotExist(err) {
-		// 	// err = s.videoFileService.GenerateThumbnail(fmt.Sprintf("%s/%s", folder.Path, entry.Name()), thumbnailPath, "00:00:05")
-		// 	if err != nil {
-		// 		fmt.Printf("Error generating thumbnail: %v", err)
-		// 		return c.Status(fiber.StatusInternalServerError).SendString("Error generating thumbnail")
-		// 	}
-		// }
-
-		contentLength :=
-
-		file := models.File{
-			Name:         entry.Name(),
-			URL:          fmt.Sprintf("%s/stream/%d/%s", "http://192.168.1.195:3001", folderIdInt, url.PathEscape(entry.Name())),
-			SubtitlesURL: fmt.Sprintf("%s/subtitles/%d/%s", "http://192.168.1.195:3001", folderIdInt, fmt.Sprintf("%s.%s", url.PathEscape(fileNameWithoutExt), "vtt")),
-			ThumbnailURL: fmt.Sprintf("%s/thumbnails/%d/%s", "http://192.168.1.195:3001", folderIdInt, fmt.Sprintf("%s.%s", url.PathEscape(fileNameWithoutExt), "png")),
-			FolderID:     folderIdInt,
-			Path:         fmt.Sprintf("%s/%s", folder.Path, entry.Name()),
-			CategoryID:   folder.CategoryID,
-			Duration:     10000,
-			ContentLength: ,
+	files, err := s.mediaItemFiles(c, page.Items)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("Error retrieving folder")
+	}
+	return sendPage(c, files, page.Total)
+}
+
+// mediaItemFiles describes media items the way the /files route does, with
+// the URLs to stream them, their parts and their other versions
+func (s *StreamService) mediaItemFiles(c *fiber.Ctx, items []models.MediaItem) ([]models.File, error) {
+	folders := map[int]*models.Folder{}
+	folderOf := func(id int) (*models.Folder, error) {
+		folder, ok := folders[id]
+		if !ok {
+			var err error
+			if folder, err = s.foldersService.GetFolderById(id); err != nil {
+				return nil, err
+			}
+			folders[id] = folder
 		}
-		files = append(files, file)
+		return folder, nil
 	}
 
-	return c.JSON(files)
+	groups := map[int][]models.MediaItem{}
+	files := make([]models.File, len(items))
+	for i, item := range items {
+		folder, err := folderOf(item.FolderID)
+		if err != nil {
+			return nil, err
+		}
+		files[i] = mediaItemFile(c, folder, item)
+		if item.StackID != 0 {
+			if err := s.addParts(c, folder, &files[i]); err != nil {
+				return nil, err
+			}
+		}
+		if item.VersionGroup == 0 {
+			continue
+		}
+
+		versions, ok := groups[item.VersionGroup]
+		if !ok {
+			if versions, err = s.mediaItemsService.ListVersions(item.ID); err != nil {
+				return nil, err
+			}
+			groups[item.VersionGroup] = versions
+		}
+		for _, version := range versions {
+			if version.ID == item.ID {
+				continue
+			}
+			versionFolder, err := folderOf(version.FolderID)
+			if err != nil {
+				return nil, err
+			}
+			files[i].Versions = append(files[i].Versions, models.FileVersion{
+				MediaItemID:   version.ID,
+				Name:          version.Name,
+				URL:           streamURL(c, versionFolder.ID, version.RelPath),
+				FolderID:      versionFolder.ID,
+				Edition:       version.Release.Edition,
+				Resolution:    version.Release.Resolution,
+				Codec:         version.Release.Codec,
+				Duration:      version.Duration,
+				ContentLength: version.Size,
+			})
+		}
+	}
+
+	return files, nil
+}
+
+// addParts lists the parts of the stacked file, which is described as a
+// whole. Parts are always in the folder of their first part.
+func (s *StreamService) addParts(c *fiber.Ctx, folder *models.Folder, file *models.File) error {
+	parts, err := s.mediaItemsService.ListParts(file.MediaItemID)
+	if err != nil {
+		return err
+	}
+
+	file.Duration, file.ContentLength = 0, 0
+	for _, part := range parts {
+		file.Parts = append(file.Parts, models.FilePart{
+			MediaItemID:   part.ID,
+			Part:          part.Part,
+			Name:          part.Name,
+			URL:           streamURL(c, folder.ID, part.RelPath),
+			Duration:      part.Duration,
+			ContentLength: part.Size,
+		})
+		file.Duration += part.Duration
+		file.ContentLength += part.Size
+	}
+	file.PlaylistURL = withApiKey(c, fmt.Sprintf("%s/items/%d/playlist.m3u8", c.BaseURL(), file.MediaItemID))
+	return nil
+}
+
+// mediaItemFile describes a media item of the folder, without its versions
+func mediaItemFile(c *fiber.Ctx, folder *models.Folder, item models.MediaItem) models.File {
+	// Paths are escaped whole, slashes included, so they stay a single
+	// fileName param
+	withoutExt := url.PathEscape(strings.TrimSuffix(item.RelPath, path.Ext(item.RelPath)))
+	return models.File{
+		MediaItemID:   item.ID,
+		Name:          item.Name,
+		Title:         item.Release.Title,
+		Year:          item.Release.Year,
+		Edition:       item.Release.Edition,
+		URL:           streamURL(c, folder.ID, item.RelPath),
+		SubtitlesURL:  withApiKey(c, fmt.Sprintf("%s/subtitles/%d/%s.vtt", c.BaseURL(), folder.ID, withoutExt)),
+		ThumbnailURL:  withApiKey(c, fmt.Sprintf("%s/thumbnails/%d/%s.png", c.BaseURL(), folder.ID, withoutExt)),
+		FolderID:      folder.ID,
+		Path:          filepath.Join(folder.Path, filepath.FromSlash(item.RelPath)),
+		CategoryID:    folder.CategoryID,
+		Duration:      item.Duration,
+		ContentLength: item.Size,
+		AddedAt:       item.AddedAt,
+		Resolution:    item.Release.Resolution,
+		Codec:         item.Release.Codec,
+		LastWatchedAt: item.LastWatchedAt,
+	}
+}
+
+// streamURL is the /stream URL of the video at relPath in the folder
+func streamURL(c *fiber.Ctx, folderId int, relPath string) string {
+	return withApiKey(c, fmt.Sprintf("%s/stream/%d/%s", c.BaseURL(), folderId, url.PathEscape(relPath)))
 }
 
+// withApiKey passes the api_key query param of the request on to link.
+// Clients sending it are the ones that can't set headers, like <video> and
+// <img> tags, and they follow the links as they are.
+func withApiKey(c *fiber.Ctx, link string) string {
+	key := c.Query("api_key")
+	if key == "" {
+		return link
+	}
+
+	separator := "?"
+	if strings.Contains(link, "?") {
+		separator = "&"
+	}
+	return link + separator + "api_key=" + url.QueryEscape(key)
+}
+
+// ListCategories lists the categories followed by the smart collections as
+// virtual categories
 func (s *StreamService) ListCategories(c *fiber.Ctx) error {
-	categories := s.categoriesService.ListCategories()
-	return c.JSON(categories)
+	virtual, err := s.smartCollectionsService.VirtualCategories()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("Error listing categories")
+	}
+
+	page, err := pageCategories(append(s.categoriesService.ListCategories(), virtual...), listOptions(c))
+	if err != nil {
+		return sendListError(c, err, "Error listing categories")
+	}
+
+	return sendPage(c, page.Items, page.Total)
 }
 
 func (s *StreamService) ListFolderByCategory(c *fiber.Ctx) error {
@@ -290,7 +1039,14 @@ func (s *StreamService) ListFolderByCategory(c *fiber.Ctx) error {
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).SendString("Invalid category ID")
 	}
+	if categoryId < 0 {
+		return sendVirtualCategory(c, categoryId, "/smart-collections/%d/items")
+	}
+
+	page, err := s.foldersService.ListFolderByCategoryPage(categoryId, listOptions(c))
+	if err != nil {
+		return sendListError(c, err, "Error listing folders")
+	}
 
-	folders := s.foldersService.ListFolderByCategory(categoryId)
-	return c.JSON(folders)
+	return sendPage(c, page.Items, page.Total)
 }
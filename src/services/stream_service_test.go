@@ -0,0 +1,440 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"localflix-server/src/logging"
+	"localflix-server/src/models"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+)
+
+// newTestStreamApp serves the media routes of a StreamService without auth
+func newTestStreamApp(t *testing.T, library *testLibrary, toolkit MediaToolkit) *fiber.App {
+	t.Helper()
+
+	return serveTestRoutes(newTestStreamService(t, library, toolkit))
+}
+
+func newTestStreamService(t *testing.T, library *testLibrary, toolkit MediaToolkit) *StreamService {
+	t.Helper()
+
+	s := &StreamService{
+		foldersService:          *library.folders,
+		categoriesService:       *library.categories,
+		mediaToolkit:            toolkit,
+		auditService:            *NewAuditService(context.Background(), library.db, logging.Discard()),
+		seriesService:           *NewSeriesService(context.Background(), library.db, logging.Discard()),
+		metadataService:         *NewMetadataService(context.Background(), library.db, NewMetadataProviderRegistry(), library.dirs, logging.Discard()),
+		searchService:           *NewSearchService(context.Background(), library.db, logging.Discard()),
+		scanService:             *NewScanService(context.Background(), library.db, toolkit, logging.Discard()),
+		mediaItemsService:       *NewMediaItemsService(context.Background(), library.db, logging.Discard()),
+		tagsService:             *NewTagsService(context.Background(), library.db, logging.Discard()),
+		collectionsService:      *NewCollectionsService(context.Background(), library.db, logging.Discard()),
+		smartCollectionsService: *NewSmartCollectionsService(context.Background(), library.db, logging.Discard()),
+		watchProgressService:    *NewWatchProgressService(context.Background(), library.db, logging.Discard()),
+		rateLimitService:        NewRateLimitService(models.RateLimitSettings{}),
+		transcodes:              make(chan struct{}, maxTranscodes),
+		dirs:                    library.dirs,
+		logger:                  logging.Discard(),
+	}
+	s.streamTracker = newStreamTracker(&s.auditService, &s.watchProgressService, s.streamedFile)
+	t.Cleanup(func() { s.stop(nil) })
+
+	return s
+}
+
+func serveTestRoutes(s *StreamService) *fiber.App {
+	app := fiber.New()
+	app.Use(requestid.New(requestid.Config{ContextKey: requestIDLocal}))
+	app.Use(s.logRequests)
+	app.Get("/thumbnails/:folderId/:fileName", s.getThumbnail)
+	app.Get("/subtitles/:folderId/:fileName", s.getSubtitles)
+	app.Get("/stream/:folderId/:fileName", s.streamVideo)
+	app.Get("/transcode/:folderId/:fileName", s.transcodeVideo)
+	app.Get("/files/:folderId", s.listFiles)
+	app.Get("/items/:itemId", s.getMediaItem)
+	app.Get("/items/:itemId/versions", s.listVersions)
+	app.Get("/items/:itemId/play", s.decidePlayback)
+	app.Get("/items/:itemId/playlist.m3u8", s.getPartsPlaylist)
+	app.Get("/items/:itemId/segments/:segment", s.transcodeSegment)
+	app.Get("/items/:itemId/transcode", s.transcodeParts)
+	app.Get("/artwork/:itemId/:kind", s.getArtwork)
+	app.Get("/search", s.search)
+	app.Get("/series", s.listSeries)
+	app.Get("/series/:seriesId/seasons", s.listSeasons)
+	app.Get("/seasons/:seasonId/episodes", s.listEpisodes)
+	app.Get("/tags", s.listTags)
+	app.Get("/collections", s.listCollections)
+	app.Get("/collections/:collectionId/items", s.listCollectionItems)
+	app.Get("/categories", s.ListCategories)
+	app.Get("/folders/:categoryId", s.ListFolderByCategory)
+	app.Delete("/admin/categories/:categoryId", s.deleteCategory)
+	app.Get("/smart-collections/:smartCollectionId/items", s.listSmartCollectionItems)
+	return app
+}
+
+func get(t *testing.T, app *fiber.App, path string) (int, string) {
+	t.Helper()
+
+	response, err := app.Test(httptest.NewRequest("GET", path, nil), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return response.StatusCode, string(body)
+}
+
+// waitScanned waits for the background scan of the folder started by a
+// listing
+func waitScanned(t *testing.T, s *StreamService, folderId int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, queued := s.scanService.queued.Load(folderId); !queued {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the folder scan never ended")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestMediaRoutesGenerateOnDemand(t *testing.T) {
+	library := newTestLibrary(t)
+	category, err := library.categories.CreateCategory("Movies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	folder, err := library.folders.CreateFolder(makeDir(t, "Movie One.mkv"), category.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	toolkit := NewFakeMediaToolkit()
+	app := newTestStreamApp(t, library, toolkit)
+
+	status, body := get(t, app, "/thumbnails/1/Movie%20One.png")
+	if status != fiber.StatusOK || !strings.Contains(body, "thumbnail of Movie One.mkv") {
+		t.Errorf("thumbnail = %d %q", status, body)
+	}
+	if !exists(filepath.Join(library.dirs.FolderThumbnails(folder.ID), "Movie One.png")) {
+		t.Error("thumbnail was not cached")
+	}
+	// The cached thumbnail is served without generating it again
+	get(t, app, "/thumbnails/1/Movie%20One.png")
+
+	status, body = get(t, app, "/subtitles/1/Movie%20One.vtt")
+	if status != fiber.StatusOK || !strings.HasPrefix(body, "WEBVTT") {
+		t.Errorf("subtitles = %d %q", status, body)
+	}
+
+	status, body = get(t, app, "/transcode/1/Movie%20One.mkv")
+	if status != fiber.StatusOK || body != "Movie One.mkv" {
+		t.Errorf("transcode = %d %q", status, body)
+	}
+	status, body = get(t, app, "/stream/1/Movie%20One.mkv")
+	if status != fiber.StatusOK || body != "Movie One.mkv" {
+		t.Errorf("stream without a range = %d %q, want the whole file", status, body)
+	}
+
+	if calls := toolkit.Calls(); len(calls) != 3 {
+		t.Errorf("got toolkit calls %v, want one per route", calls)
+	}
+
+	if status, _ := get(t, app, "/thumbnails/1/Missing.png"); status != fiber.StatusNotFound {
+		t.Errorf("thumbnail of a missing video = %d, want 404", status)
+	}
+}
+
+func TestMediaRoutesWithoutMediaToolkit(t *testing.T) {
+	library := newTestLibrary(t)
+	category, err := library.categories.CreateCategory("Movies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := library.folders.CreateFolder(makeDir(t, "movie.mkv"), category.ID); err != nil {
+		t.Fatal(err)
+	}
+	app := newTestStreamApp(t, library, NewUnavailableMediaToolkit(errors.New("ffmpeg not found")))
+
+	for _, path := range []string{"/thumbnails/1/movie.png", "/subtitles/1/movie.vtt", "/transcode/1/movie.mkv"} {
+		status, body := get(t, app, path)
+		if status != fiber.StatusServiceUnavailable || !strings.Contains(body, "ffmpeg not found") {
+			t.Errorf("%s = %d %q, want 503 naming the missing ffmpeg", path, status, body)
+		}
+	}
+}
+
+func TestFileURLsKeepApiKeyParam(t *testing.T) {
+	library := newTestLibrary(t)
+	category, err := library.categories.CreateCategory("Movies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	folder, err := library.folders.CreateFolder(makeDir(t, "Movie.1080p.mkv"), category.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewScanService(context.Background(), library.db, NewFakeMediaToolkit(), logging.Discard()).ScanFolder(folder.ID); err != nil {
+		t.Fatal(err)
+	}
+	app := newTestStreamApp(t, library, NewFakeMediaToolkit())
+
+	status, body := get(t, app, "/files/"+strconv.Itoa(folder.ID)+"?api_key=lfx_a%2Bb")
+	var files []models.File
+	if err := json.Unmarshal([]byte(body), &files); err != nil || len(files) != 1 {
+		t.Fatalf("got %d %s", status, body)
+	}
+	for _, link := range []string{files[0].URL, files[0].SubtitlesURL, files[0].ThumbnailURL} {
+		if !strings.HasSuffix(link, "?api_key=lfx_a%2Bb") {
+			t.Errorf("got URL %s, want the api_key param passed on", link)
+		}
+	}
+
+	status, body = get(t, app, "/items/"+strconv.Itoa(files[0].MediaItemID)+"/play?max_height=480&api_key=lfx_a%2Bb")
+	var decision models.PlaybackDecision
+	if err := json.Unmarshal([]byte(body), &decision); err != nil {
+		t.Fatalf("got %d %s", status, body)
+	}
+	if !strings.HasSuffix(decision.URL, "/transcode/"+strconv.Itoa(folder.ID)+"/Movie.1080p.mkv?max_height=480&api_key=lfx_a%2Bb") {
+		t.Errorf("got URL %s, want the api_key param after max_height", decision.URL)
+	}
+
+	// Clients sending the key in a header get links without it
+	_, body = get(t, app, "/files/"+strconv.Itoa(folder.ID))
+	if strings.Contains(body, "api_key") {
+		t.Errorf("got %s, want no api_key param", body)
+	}
+}
+
+func TestStreamRoutesWhileShuttingDown(t *testing.T) {
+	library := newTestLibrary(t)
+	category, err := library.categories.CreateCategory("Movies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := library.folders.CreateFolder(makeDir(t, "movie.mkv"), category.ID); err != nil {
+		t.Fatal(err)
+	}
+	s := newTestStreamService(t, library, NewFakeMediaToolkit())
+	app := serveTestRoutes(s)
+
+	// StopServer drops the tracker once the app is shut down, requests still
+	// in flight then stream without being tracked
+	s.stop(nil)
+	if status, body := get(t, app, "/transcode/1/movie.mkv"); status != fiber.StatusOK || body != "movie.mkv" {
+		t.Errorf("transcode = %d %q", status, body)
+	}
+}
+
+func TestConcurrentStopsStopTheTrackerOnce(t *testing.T) {
+	s := newTestStreamService(t, newTestLibrary(t), NewFakeMediaToolkit())
+
+	// A second StopServer, while the first waits for the app to shut down,
+	// finds the tracker the first one is about to stop
+	s.tracker().stop()
+	s.stop(nil)
+	if tracker := s.tracker(); tracker != nil {
+		t.Errorf("got tracker %v after stopping, want none", tracker)
+	}
+}
+
+func TestServeWithBadCertificateStartsNothing(t *testing.T) {
+	library := newTestLibrary(t)
+	s := newTestStreamService(t, library, NewFakeMediaToolkit())
+	s.settingsService = *NewSettingsService(context.Background(), library.db, logging.Discard())
+
+	certFile, keyFile, err := NewCertificateService(t.TempDir(), logging.Discard()).SelfSignedCertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	redirectPort := freePort(t)
+	if _, err := s.settingsService.UpdateTlsSettings(models.TlsSettings{
+		Enabled:      true,
+		CertFile:     certFile,
+		KeyFile:      keyFile,
+		RedirectHTTP: true,
+		RedirectPort: redirectPort,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// The key goes bad after the settings were saved
+	if err := os.WriteFile(keyFile, []byte("not a key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Serve(); err == nil {
+		t.Fatal("Serve succeeded with a bad key")
+	}
+	if s.app != nil || s.redirectServer != nil {
+		t.Error("server left running after a failed start")
+	}
+	listener, err := net.Listen("tcp", "0.0.0.0:"+strconv.Itoa(redirectPort))
+	if err != nil {
+		t.Fatalf("redirect port still in use: %v", err)
+	}
+	listener.Close()
+}
+
+// freePort returns a port nothing listens on right now
+func freePort(t *testing.T) int {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func TestRateLimitBeforeAuth(t *testing.T) {
+	library := newTestLibrary(t)
+	s := newTestStreamService(t, library, NewFakeMediaToolkit())
+	s.apiKeysService = *NewApiKeysService(context.Background(), library.db, logging.Discard())
+	s.rateLimitService = NewRateLimitService(models.RateLimitSettings{RequestsPerMinute: 1})
+	app := fiber.New()
+	app.Use(s.rateLimitIP)
+	app.Get("/categories", s.requireScope(models.ScopeLibraryRead), s.rateLimit, s.ListCategories)
+
+	// Guessed keys use up the IP's requests even though they never pass auth
+	for i := 0; i < ipRequestFactor; i++ {
+		if status, _ := get(t, app, "/categories?api_key=lfx_guess"+strconv.Itoa(i)); status != fiber.StatusUnauthorized {
+			t.Fatalf("guess %d = %d, want 401", i, status)
+		}
+	}
+	if status, _ := get(t, app, "/categories?api_key=lfx_guess"); status != fiber.StatusTooManyRequests {
+		t.Errorf("guess over the limit = %d, want 429", status)
+	}
+}
+
+func TestTranscodesAreCapped(t *testing.T) {
+	library := newTestLibrary(t)
+	category, err := library.categories.CreateCategory("Movies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := library.folders.CreateFolder(makeDir(t, "movie.mkv"), category.ID); err != nil {
+		t.Fatal(err)
+	}
+	s := newTestStreamService(t, library, NewFakeMediaToolkit())
+	app := serveTestRoutes(s)
+	get(t, app, "/files/1")
+	waitScanned(t, s, 1)
+	_, body := get(t, app, "/files/1")
+	var files []models.File
+	if err := json.Unmarshal([]byte(body), &files); err != nil || len(files) != 1 {
+		t.Fatalf("got files %s", body)
+	}
+
+	for i := 0; i < maxTranscodes; i++ {
+		if !s.acquireTranscode() {
+			t.Fatalf("transcode %d was refused", i)
+		}
+	}
+	for _, path := range []string{"/transcode/1/movie.mkv", "/items/" + strconv.Itoa(files[0].MediaItemID) + "/transcode"} {
+		if status, _ := get(t, app, path); status != fiber.StatusServiceUnavailable {
+			t.Errorf("%s while %d transcodes run = %d, want 503", path, maxTranscodes, status)
+		}
+	}
+
+	// A finished transcode makes room for the next one, which gives its
+	// token back once done
+	s.releaseTranscode()
+	for i := 0; i < 2; i++ {
+		if status, body := get(t, app, "/transcode/1/movie.mkv"); status != fiber.StatusOK || body != "movie.mkv" {
+			t.Errorf("transcode = %d %q", status, body)
+		}
+	}
+}
+
+func TestStreamsRecordWatchProgress(t *testing.T) {
+	library := newTestLibrary(t)
+	category, err := library.categories.CreateCategory("Movies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	for _, name := range []string{"probed.mkv", "watched.mkv"} {
+		// 1000 seconds of video for the fake toolkit
+		if err := os.WriteFile(filepath.Join(dir, name), make([]byte, 1000*FakeMediaBytesPerSecond), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	folder, err := library.folders.CreateFolder(dir, category.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestStreamService(t, library, NewFakeMediaToolkit())
+	if _, err := s.scanService.ScanFolder(folder.ID); err != nil {
+		t.Fatal(err)
+	}
+	app := serveTestRoutes(s)
+
+	// A player reading the end of the file before playing it from the start
+	// hasn't watched it, a seek followed by more requests has
+	requests := []struct {
+		file  string
+		start int
+	}{
+		{"probed.mkv", 0},
+		{"probed.mkv", 990_000},
+		{"probed.mkv", 10_000},
+		{"watched.mkv", 0},
+		{"watched.mkv", 950_000},
+		{"watched.mkv", 960_000},
+	}
+	for _, request := range requests {
+		r := httptest.NewRequest("GET", "/stream/"+strconv.Itoa(folder.ID)+"/"+request.file, nil)
+		r.Header.Set("Range", "bytes="+strconv.Itoa(request.start)+"-")
+		response, err := app.Test(r, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+		if response.StatusCode != fiber.StatusPartialContent {
+			t.Fatalf("%s from %d = %d", request.file, request.start, response.StatusCode)
+		}
+	}
+	// Stopping the server saves the progress of its streams
+	s.stop(nil)
+
+	progresses, err := s.watchProgressService.ListProgress(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[int]models.WatchProgress{}
+	for _, progress := range progresses {
+		got[progress.MediaItemID] = progress
+	}
+	page, err := s.mediaItemsService.ListMediaItems(folder.ID, models.ListOptions{})
+	if err != nil || len(page.Items) != 2 {
+		t.Fatalf("ListMediaItems = %+v, %v", page, err)
+	}
+	probed, watched := got[page.Items[0].ID], got[page.Items[1].ID]
+	if probed.Watched || probed.Position != 10 || probed.Duration != 1000 {
+		t.Errorf("probed progress = %+v, want 10 of 1000 seconds", probed)
+	}
+	if !watched.Watched || watched.Position != 960 {
+		t.Errorf("watched progress = %+v, want watched at 960 seconds", watched)
+	}
+}
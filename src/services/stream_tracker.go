@@ -0,0 +1,251 @@
+package services
+
+import (
+	"fmt"
+	"localflix-server/src/models"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// streamIdleTimeout is how long a stream can go without range requests before
+// it's audited as stopped. Players pause requesting once their buffer is full,
+// so this has to be well above the buffer length.
+const streamIdleTimeout = 2 * time.Minute
+
+// streamReadAhead is how far past the playing position players request to
+// fill their buffer
+const streamReadAhead = 5 * time.Minute
+
+// streamedFile is the media item a file streams, looked up once per stream.
+// The later parts of a stack stream their stack, starting offset seconds into
+// it. mediaItemId is 0 for files that weren't scanned, their progress isn't
+// kept.
+type streamedFile struct {
+	mediaItemId int
+	offset      float64
+	duration    float64
+	total       float64
+}
+
+type activeStream struct {
+	actor     string
+	ip        string
+	userId    int
+	folderId  int
+	fileName  string
+	file      streamedFile
+	startedAt time.Time
+	lastSeen  time.Time
+	// position is how far into the file the stream was at positionAt, in
+	// seconds. A request far from it is a seek or a player probing the end of
+	// the file, kept in jump until another request follows it.
+	position   float64
+	positionAt time.Time
+	jump       float64
+	jumpAt     time.Time
+	// transcodes counts the transcodes streaming the file, the position moves
+	// with the clock while there are some
+	transcodes int
+	saved      bool
+}
+
+// streamTracker turns the requests of a player into stream start and stop
+// audit events, and into the watch progress of the user.
+type streamTracker struct {
+	mu                   sync.Mutex
+	streams              map[string]*activeStream
+	auditService         *AuditService
+	watchProgressService *WatchProgressService
+	lookup               func(folderId int, fileName string) streamedFile
+	done                 chan struct{}
+	// stopped lets concurrent StopServer calls all stop the tracker
+	stopped sync.Once
+}
+
+func newStreamTracker(auditService *AuditService, watchProgressService *WatchProgressService, lookup func(folderId int, fileName string) streamedFile) *streamTracker {
+	t := &streamTracker{
+		streams:              map[string]*activeStream{},
+		auditService:         auditService,
+		watchProgressService: watchProgressService,
+		lookup:               lookup,
+		done:                 make(chan struct{}),
+	}
+	go t.run()
+	return t
+}
+
+// touchRange records a range request starting at fraction of the file's
+// bytes. A nil tracker, one of a server shutting down, records nothing.
+func (t *streamTracker) touchRange(c *fiber.Ctx, folderId int, fileName string, fraction float64) {
+	t.touch(c, folderId, fileName, func(stream *activeStream, now time.Time) {
+		stream.seek(fraction*stream.file.duration, now)
+	})
+}
+
+// startTranscode records a transcode starting at start seconds into the file.
+// It returns the key to give endTranscode once the transcode stops.
+func (t *streamTracker) startTranscode(c *fiber.Ctx, folderId int, fileName string, start float64) string {
+	return t.touch(c, folderId, fileName, func(stream *activeStream, now time.Time) {
+		stream.moveTo(start, now)
+		stream.transcodes++
+	})
+}
+
+func (t *streamTracker) endTranscode(key string) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// The stream is gone when the tracker stopped first
+	stream, ok := t.streams[key]
+	if !ok {
+		return
+	}
+	now := time.Now()
+	stream.moveTo(stream.playing(now), now)
+	stream.transcodes--
+	stream.lastSeen = now
+}
+
+func (t *streamTracker) touch(c *fiber.Ctx, folderId int, fileName string, move func(stream *activeStream, now time.Time)) string {
+	if t == nil {
+		return ""
+	}
+
+	key := fmt.Sprintf("%s|%d|%s", clientKey(c), folderId, fileName)
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stream, ok := t.streams[key]
+	if !ok {
+		stream = &activeStream{
+			actor:      actorName(c),
+			ip:         c.IP(),
+			userId:     userID(c),
+			folderId:   folderId,
+			fileName:   fileName,
+			file:       t.lookup(folderId, fileName),
+			startedAt:  now,
+			positionAt: now,
+			jump:       -1,
+		}
+		t.streams[key] = stream
+		t.record(models.AuditStreamStart, stream)
+	}
+	stream.lastSeen = now
+	move(stream, now)
+	return key
+}
+
+// seek moves the stream to the position of a range request, when it follows
+// the current position or a previous jump
+func (s *activeStream) seek(position float64, now time.Time) {
+	if follows(s.position, s.positionAt, position, now) || s.jump >= 0 && follows(s.jump, s.jumpAt, position, now) {
+		s.moveTo(position, now)
+		return
+	}
+
+	s.jump, s.jumpAt = position, now
+}
+
+// follows is whether a player that was at from at since can request position
+// by now, buffering ahead
+func follows(from float64, since time.Time, position float64, now time.Time) bool {
+	return position >= from && position <= from+now.Sub(since).Seconds()+streamReadAhead.Seconds()
+}
+
+func (s *activeStream) moveTo(position float64, now time.Time) {
+	s.position, s.positionAt = position, now
+	s.jump = -1
+	s.saved = false
+}
+
+// playing is how far into the file the stream is at now
+func (s *activeStream) playing(now time.Time) float64 {
+	if s.transcodes > 0 {
+		return s.position + now.Sub(s.positionAt).Seconds()
+	}
+
+	return s.position
+}
+
+func (t *streamTracker) run() {
+	ticker := time.NewTicker(streamIdleTimeout / 4)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.done:
+			return
+		case now := <-ticker.C:
+			t.mu.Lock()
+			for key, stream := range t.streams {
+				t.save(stream, now)
+				// A transcode keeps streaming without new requests
+				if stream.transcodes == 0 && now.Sub(stream.lastSeen) > streamIdleTimeout {
+					delete(t.streams, key)
+					t.record(models.AuditStreamStop, stream)
+				}
+			}
+			t.mu.Unlock()
+		}
+	}
+}
+
+// stop ends every active stream, used when the server shuts down. Only the
+// first call does anything.
+func (t *streamTracker) stop() {
+	t.stopped.Do(t.stopStreams)
+}
+
+func (t *streamTracker) stopStreams() {
+	close(t.done)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	for key, stream := range t.streams {
+		delete(t.streams, key)
+		t.save(stream, now)
+		t.record(models.AuditStreamStop, stream)
+	}
+}
+
+// save stores the watch progress of the stream when it moved since it was
+// last saved
+func (t *streamTracker) save(stream *activeStream, now time.Time) {
+	if stream.file.mediaItemId == 0 || stream.saved && stream.transcodes == 0 {
+		return
+	}
+
+	stream.saved = true
+	t.watchProgressService.RecordProgress(models.WatchProgress{
+		UserID:      stream.userId,
+		MediaItemID: stream.file.mediaItemId,
+		Position:    min(stream.file.offset+stream.playing(now), stream.file.total),
+		Duration:    stream.file.total,
+	})
+}
+
+func (t *streamTracker) record(action string, stream *activeStream) {
+	details := stream.fileName
+	if action == models.AuditStreamStop {
+		details = fmt.Sprintf("%s (%s)", stream.fileName, stream.lastSeen.Sub(stream.startedAt).Round(time.Second))
+	}
+
+	t.auditService.Record(models.AuditEvent{
+		Action:     action,
+		Actor:      stream.actor,
+		IP:         stream.ip,
+		TargetType: "folder",
+		TargetID:   stream.folderId,
+		Details:    details,
+	})
+}
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"localflix-server/src/models"
+	"localflix-server/src/repositories"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+// ErrInvalidTag is returned for tag changes that can't be made, the wrapping
+// error tells why
+var ErrInvalidTag = errors.New("invalid tag")
+
+type TagsService struct {
+	ctx                  context.Context
+	db                   *sql.DB
+	tagsRepository       *repositories.TagsRepository
+	mediaItemsRepository *repositories.MediaItemsRepository
+	logger               *slog.Logger
+}
+
+// NewTagsService creates a new TagsService struct
+func NewTagsService(ctx context.Context, db *sql.DB, logger *slog.Logger) *TagsService {
+	return &TagsService{
+		ctx:                  ctx,
+		db:                   db,
+		tagsRepository:       repositories.NewTagsRepository(db),
+		mediaItemsRepository: repositories.NewMediaItemsRepository(db),
+		logger:               logger,
+	}
+}
+
+// ListTags pages the tags of the kind, or of every kind when kind is empty.
+// They sort by name.
+func (t *TagsService) ListTags(kind string, options models.ListOptions) (*models.TagPage, error) {
+	if kind != "" && !slices.Contains(models.TagKinds, kind) {
+		return nil, fmt.Errorf("%w: unknown kind %q, expected one of %s", ErrInvalidListOptions, kind, strings.Join(models.TagKinds, ", "))
+	}
+	query, err := parseListOptions(options, []string{models.SortName, models.SortAddedAt}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := t.tagsRepository.ListTags(kind)
+	if err != nil {
+		t.logger.Error("listing tags", "kind", kind, "err", err)
+		return nil, err
+	}
+
+	result := make([]models.Tag, len(tags))
+	for i, tag := range tags {
+		result[i] = *tag
+	}
+	return &models.TagPage{
+		Items: pageOf(result, query, map[string]func(a, b models.Tag) int{
+			models.SortName:    func(a, b models.Tag) int { return compareFold(a.Name, b.Name) },
+			models.SortAddedAt: func(a, b models.Tag) int { return a.CreatedAt.Compare(b.CreatedAt) },
+		}),
+		Total: len(result),
+	}, nil
+}
+
+func (t *TagsService) GetTag(id int) (*models.Tag, error) {
+	tag, err := t.tagsRepository.GetTag(id)
+	if err != nil {
+		t.logger.Error("getting tag", "id", id, "err", err)
+		return nil, err
+	}
+
+	return tag, nil
+}
+
+// CreateTag creates a tag to set by hand, genres are only created by the
+// metadata
+func (t *TagsService) CreateTag(name string) (*models.Tag, error) {
+	name, err := t.checkName(name, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	tag, err := t.tagsRepository.CreateTag(name, models.TagKindTag)
+	if err != nil {
+		t.logger.Error("creating tag", "name", name, "err", err)
+		return nil, err
+	}
+
+	t.logger.Info("tag created", "id", tag.ID, "name", tag.Name)
+	return tag, nil
+}
+
+func (t *TagsService) RenameTag(id int, name string) (*models.Tag, error) {
+	if _, err := t.editableTag(id); err != nil {
+		return nil, err
+	}
+	name, err := t.checkName(name, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.tagsRepository.RenameTag(id, name); err != nil {
+		t.logger.Error("renaming tag", "id", id, "err", err)
+		return nil, err
+	}
+
+	return t.GetTag(id)
+}
+
+// DeleteTag deletes the tag, removing it from its media items
+func (t *TagsService) DeleteTag(id int) error {
+	if _, err := t.editableTag(id); err != nil {
+		return err
+	}
+
+	if err := t.tagsRepository.DeleteTag(id); err != nil {
+		t.logger.Error("deleting tag", "id", id, "err", err)
+		return err
+	}
+
+	t.logger.Info("tag deleted", "id", id)
+	return nil
+}
+
+// ListMediaItemTags returns the tags and genres of the media item
+func (t *TagsService) ListMediaItemTags(mediaItemId int) ([]models.Tag, error) {
+	tags, err := t.tagsRepository.ListItemTags(mediaItemId)
+	if err != nil {
+		t.logger.Error("listing media item tags", "media_item_id", mediaItemId, "err", err)
+		return nil, err
+	}
+
+	result := make([]models.Tag, len(tags))
+	for i, tag := range tags {
+		result[i] = *tag
+	}
+	return result, nil
+}
+
+// SetMediaItemTags replaces the tags set by hand on the media item, creating
+// the ones that don't exist yet. Its genres are left alone. Returns
+// sql.ErrNoRows for an unknown media item.
+func (t *TagsService) SetMediaItemTags(mediaItemId int, names []string) ([]models.Tag, error) {
+	if _, err := t.mediaItemsRepository.GetMediaItem(mediaItemId); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			t.logger.Error("getting media item", "id", mediaItemId, "err", err)
+		}
+		return nil, err
+	}
+
+	tx, err := t.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := repositories.NewTagsRepository(tx).ReplaceItemTags(mediaItemId, models.TagKindTag, names); err != nil {
+		t.logger.Error("setting media item tags", "media_item_id", mediaItemId, "err", err)
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		t.logger.Error("setting media item tags", "media_item_id", mediaItemId, "err", err)
+		return nil, err
+	}
+
+	return t.ListMediaItemTags(mediaItemId)
+}
+
+// editableTag returns the tag when it can be renamed or deleted: genres
+// follow the metadata
+func (t *TagsService) editableTag(id int) (*models.Tag, error) {
+	tag, err := t.GetTag(id)
+	if err != nil {
+		return nil, err
+	}
+	if tag == nil {
+		return nil, sql.ErrNoRows
+	}
+	if tag.Kind != models.TagKindTag {
+		return nil, fmt.Errorf("%w: genres follow the metadata and can't be changed", ErrInvalidTag)
+	}
+
+	return tag, nil
+}
+
+// checkName trims the name and checks no other tag has it
+func (t *TagsService) checkName(name string, id int) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("%w: name is required", ErrInvalidTag)
+	}
+
+	existing, err := t.tagsRepository.GetTagByName(models.TagKindTag, name)
+	if err != nil {
+		t.logger.Error("getting tag by name", "err", err)
+		return "", err
+	}
+	if existing != nil && existing.ID != id {
+		return "", fmt.Errorf("%w: tag %q already exists", ErrInvalidTag, existing.Name)
+	}
+
+	return name, nil
+}
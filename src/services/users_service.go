@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"localflix-server/src/models"
+	"localflix-server/src/repositories"
+	"log/slog"
+	"strings"
+)
+
+// ErrInvalidUser is returned for users that can't be created, the wrapping
+// error tells why
+var ErrInvalidUser = errors.New("invalid user")
+
+type UsersService struct {
+	ctx             context.Context
+	db              *sql.DB
+	usersRepository *repositories.UsersRepository
+	auditService    *AuditService
+	logger          *slog.Logger
+}
+
+// NewUsersService creates a new UsersService struct
+func NewUsersService(ctx context.Context, db *sql.DB, logger *slog.Logger) *UsersService {
+	return &UsersService{
+		ctx:             ctx,
+		db:              db,
+		usersRepository: repositories.NewUsersRepository(db),
+		auditService:    NewAuditService(ctx, db, logger),
+		logger:          logger,
+	}
+}
+
+// ListUsers returns the users by name
+func (u *UsersService) ListUsers() ([]models.User, error) {
+	users, err := u.usersRepository.ListUsers()
+	if err != nil {
+		u.logger.Error("listing users", "err", err)
+		return nil, err
+	}
+
+	result := make([]models.User, len(users))
+	for i, user := range users {
+		result[i] = *user
+	}
+	return result, nil
+}
+
+func (u *UsersService) GetUser(id int) (*models.User, error) {
+	user, err := u.usersRepository.GetUser(id)
+	if err != nil {
+		u.logger.Error("getting user", "id", id, "err", err)
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (u *UsersService) GetUserByName(name string) (*models.User, error) {
+	user, err := u.usersRepository.GetUserByName(name)
+	if err != nil {
+		u.logger.Error("getting user by name", "err", err)
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// CreateUser creates the user together with its first api key, named after
+// the user. The returned key is the only time the plain text value is
+// available.
+func (u *UsersService) CreateUser(name string, scopes []string) (*models.User, *models.ApiKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, nil, fmt.Errorf("%w: name is required", ErrInvalidUser)
+	}
+	if err := validateApiKey(name, scopes); err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrInvalidUser, err)
+	}
+	existing, err := u.GetUserByName(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if existing != nil {
+		return nil, nil, fmt.Errorf("%w: user %q already exists", ErrInvalidUser, existing.Name)
+	}
+
+	tx, err := u.db.Begin()
+	if err != nil {
+		u.logger.Error("creating user", "err", err)
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	user, err := repositories.NewUsersRepository(tx).CreateUser(name)
+	if err != nil {
+		u.logger.Error("creating user", "err", err)
+		return nil, nil, err
+	}
+	apiKey, err := createApiKey(repositories.NewApiKeysRepository(tx), user.ID, name, scopes)
+	if err != nil {
+		u.logger.Error("creating user api key", "err", err)
+		return nil, nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		u.logger.Error("creating user", "err", err)
+		return nil, nil, err
+	}
+	user.ApiKeys = 1
+
+	u.logger.Info("user created", "id", user.ID, "name", user.Name)
+	u.auditService.Record(models.AuditEvent{
+		Action:     models.AuditUserCreate,
+		Actor:      models.AuditActorDesktop,
+		TargetType: "user",
+		TargetID:   user.ID,
+		Details:    user.Name,
+	})
+	return user, apiKey, nil
+}
+
+// DeleteUser deletes the user with its watch progress and revokes its api
+// keys. Returns sql.ErrNoRows for an unknown user.
+func (u *UsersService) DeleteUser(id int) error {
+	tx, err := u.db.Begin()
+	if err != nil {
+		u.logger.Error("deleting user", "id", id, "err", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := repositories.NewUsersRepository(tx).DeleteUser(id); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			u.logger.Error("deleting user", "id", id, "err", err)
+		}
+		return err
+	}
+	if err := repositories.NewWatchProgressRepository(tx).DeleteUserProgress(id); err != nil {
+		u.logger.Error("deleting user watch progress", "id", id, "err", err)
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		u.logger.Error("deleting user", "id", id, "err", err)
+		return err
+	}
+
+	u.logger.Info("user deleted", "id", id)
+	u.auditService.Record(models.AuditEvent{
+		Action:     models.AuditUserDelete,
+		Actor:      models.AuditActorDesktop,
+		TargetType: "user",
+		TargetID:   id,
+	})
+	return nil
+}
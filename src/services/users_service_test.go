@@ -0,0 +1,76 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"localflix-server/src/logging"
+	"localflix-server/src/models"
+	"testing"
+)
+
+func TestCreateUser(t *testing.T) {
+	database := newTestDatabase(t)
+	users := NewUsersService(context.Background(), database, logging.Discard())
+	apiKeys := NewApiKeysService(context.Background(), database, logging.Discard())
+
+	user, apiKey, err := users.CreateUser(" Alice ", []string{models.ScopeStream})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if user.Name != "Alice" || apiKey.UserID != user.ID || apiKey.Name != "Alice" {
+		t.Errorf("CreateUser = %+v, %+v, want Alice with a key of her own", user, apiKey)
+	}
+
+	authenticated, err := apiKeys.Authenticate(apiKey.Key, "10.0.0.2")
+	if err != nil || authenticated == nil || authenticated.UserID != user.ID {
+		t.Errorf("Authenticate = %+v, %v, want the key of user %d", authenticated, err, user.ID)
+	}
+
+	invalid := map[string][]string{
+		"alice": {models.ScopeStream},
+		"":      {models.ScopeStream},
+		"Bob":   {"everything"},
+	}
+	for name, scopes := range invalid {
+		if _, _, err := users.CreateUser(name, scopes); !errors.Is(err, ErrInvalidUser) {
+			t.Errorf("CreateUser(%q, %v) = %v, want ErrInvalidUser", name, scopes, err)
+		}
+	}
+	if listed, err := users.ListUsers(); err != nil || len(listed) != 1 || listed[0].ApiKeys != 1 {
+		t.Errorf("ListUsers = %+v, %v, want only Alice with one key", listed, err)
+	}
+}
+
+func TestDeleteUserRevokesApiKeys(t *testing.T) {
+	database := newTestDatabase(t)
+	users := NewUsersService(context.Background(), database, logging.Discard())
+	apiKeys := NewApiKeysService(context.Background(), database, logging.Discard())
+	user, apiKey, err := users.CreateUser("Alice", []string{models.ScopeStream})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := apiKeys.CreateUserApiKey(user.ID, "Alice's phone", []string{models.ScopeStream}); err != nil {
+		t.Fatal(err)
+	}
+	shared, err := apiKeys.CreateApiKey("tv", []string{models.ScopeStream})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := users.DeleteUser(user.ID); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	if authenticated, err := apiKeys.Authenticate(apiKey.Key, "10.0.0.2"); err != nil || authenticated != nil {
+		t.Errorf("Authenticate = %+v, %v, want the key of the deleted user revoked", authenticated, err)
+	}
+	if listed := apiKeys.ListApiKeys(); len(listed) != 1 || listed[0].ID != shared.ID {
+		t.Errorf("ListApiKeys = %+v, want only the shared key", listed)
+	}
+	if err := users.DeleteUser(user.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("deleting an unknown user = %v, want sql.ErrNoRows", err)
+	}
+	if _, err := apiKeys.CreateUserApiKey(user.ID, "tablet", []string{models.ScopeStream}); err == nil {
+		t.Error("creating a key for an unknown user succeeded")
+	}
+}
@@ -0,0 +1,256 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"localflix-server/src/models"
+	"localflix-server/src/repositories"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	// contentHashChunk is how much of the start and of the end of a file its
+	// content hash reads
+	contentHashChunk = 64 * 1024
+	// versionDurationTolerance is how far apart the durations of two versions
+	// can be, relative to the longest one. PAL releases run 4% faster.
+	versionDurationTolerance = 0.05
+	// minVersionDurationGap is the gap always tolerated, in seconds, for
+	// intros and credits cut differently
+	minVersionDurationGap = 60
+)
+
+// syncVersions groups the media items of every folder that are versions of
+// the same title: the same episode of a series, or the same parsed title
+// and year with matching durations. Items with the same content hash are
+// copies and always grouped. Stacked videos are grouped by their first part.
+// It returns how many items have other versions.
+func syncVersions(tx repositories.DBTX) (int, error) {
+	mediaItemsRepository := repositories.NewMediaItemsRepository(tx)
+	items, err := mediaItemsRepository.ListMediaItems()
+	if err != nil {
+		return 0, err
+	}
+	episodes, err := repositories.NewSeriesRepository(tx).ListEpisodesByMediaItem()
+	if err != nil {
+		return 0, err
+	}
+
+	parents := map[int]int{}
+	var find func(id int) int
+	find = func(id int) int {
+		parent, ok := parents[id]
+		if !ok || parent == id {
+			return id
+		}
+		root := find(parent)
+		parents[id] = root
+		return root
+	}
+	// The lowest id stays the root, it names the group
+	union := func(a, b int) {
+		a, b = find(a), find(b)
+		if a != b {
+			parents[max(a, b)] = min(a, b)
+		}
+	}
+
+	// Stacks are compared by their first part, with the duration of every
+	// part
+	durations := map[int]float64{}
+	for _, item := range items {
+		if item.StackID != 0 {
+			durations[item.StackID] += item.Duration
+		}
+	}
+
+	byKey := map[string][]*models.MediaItem{}
+	byHash := map[string]int{}
+	for _, item := range items {
+		if item.StackID != 0 {
+			if item.StackID != item.ID {
+				continue
+			}
+			item.Duration = durations[item.ID]
+		}
+		if item.ContentHash != "" {
+			if id, ok := byHash[item.ContentHash]; ok {
+				union(id, item.ID)
+			} else {
+				byHash[item.ContentHash] = item.ID
+			}
+		}
+
+		episode, isEpisode := episodes[item.ID]
+		key, certain := versionKey(item, episode, isEpisode)
+		if key == "" {
+			continue
+		}
+		for _, other := range byKey[key] {
+			if sameDuration(item.Duration, other.Duration, certain) {
+				union(item.ID, other.ID)
+			}
+		}
+		byKey[key] = append(byKey[key], item)
+	}
+
+	sizes := map[int]int{}
+	for _, item := range items {
+		if item.StackID == 0 || item.StackID == item.ID {
+			sizes[find(item.ID)]++
+		}
+	}
+	groups := map[int]int{}
+	for _, item := range items {
+		if group := find(item.ID); sizes[group] > 1 {
+			groups[item.ID] = group
+		}
+	}
+
+	return len(groups), mediaItemsRepository.ReplaceVersionGroups(groups)
+}
+
+// versionKey is the same for the versions of a title, empty when the item
+// can't be told apart from other titles. certain is false when the key is
+// only a title, durations must then be known to match.
+func versionKey(item *models.MediaItem, episode models.Episode, isEpisode bool) (string, bool) {
+	if isEpisode {
+		if episode.Number == 0 && episode.AbsoluteNumber == 0 && episode.AirDate == "" {
+			return "", false
+		}
+		return fmt.Sprintf("episode %d %d-%d %d %s", episode.SeasonID, episode.Number, episode.EndNumber, episode.AbsoluteNumber, episode.AirDate), true
+	}
+
+	if item.Release.Title == "" {
+		return "", false
+	}
+	return "movie " + titleMatchKey(item.Release.Title, item.Release.Year), item.Release.Year != 0
+}
+
+// sameDuration reports whether two durations in seconds can be the same
+// title. Unknown durations, 0, only match when the key is certain.
+func sameDuration(a float64, b float64, certain bool) bool {
+	if a == 0 || b == 0 {
+		return certain
+	}
+
+	return math.Abs(a-b) <= max(minVersionDurationGap, versionDurationTolerance*max(a, b))
+}
+
+// partialContentHash hashes the size of the file with its start and its end,
+// so large videos on slow drives are only read a little
+func partialContentHash(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	binary.Write(hash, binary.LittleEndian, info.Size())
+	if _, err := io.CopyN(hash, file, min(info.Size(), contentHashChunk)); err != nil {
+		return "", err
+	}
+	if info.Size() > contentHashChunk {
+		start := max(contentHashChunk, info.Size()-contentHashChunk)
+		if _, err := io.Copy(hash, io.NewSectionReader(file, start, info.Size()-start)); err != nil {
+			return "", err
+		}
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// chooseVersion picks the version to play for the client. Versions the
+// client plays as is come first, the highest resolution among them. When
+// every version needs a transcode, the one closest above the client's
+// maximum height is picked, it loses no detail for the least work.
+func chooseVersion(versions []*models.MediaItem, client models.PlaybackClient) (*models.MediaItem, bool) {
+	codecs := make([]string, len(client.Codecs))
+	for i, codec := range client.Codecs {
+		codecs[i] = normalizeCodec(codec)
+	}
+	playable := func(item *models.MediaItem) bool {
+		height := resolutionHeight(item.Release.Resolution)
+		if client.MaxHeight > 0 && height > client.MaxHeight {
+			return false
+		}
+		if len(codecs) == 0 || item.Release.Codec == "" {
+			return true
+		}
+		for _, codec := range codecs {
+			if strings.EqualFold(codec, item.Release.Codec) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var best *models.MediaItem
+	direct := false
+	for _, version := range versions {
+		switch ok := playable(version); {
+		case best == nil, ok && !direct:
+			best, direct = version, ok
+		case ok == direct && betterVersion(version, best, client.MaxHeight, direct):
+			best = version
+		}
+	}
+
+	return best, !direct
+}
+
+// betterVersion reports whether a plays better than b, both being played as
+// is or both transcoded down to maxHeight. Ties go to the larger file.
+func betterVersion(a *models.MediaItem, b *models.MediaItem, maxHeight int, direct bool) bool {
+	heightA, heightB := resolutionHeight(a.Release.Resolution), resolutionHeight(b.Release.Resolution)
+	if heightA == heightB {
+		return a.Size > b.Size
+	}
+	if direct || maxHeight == 0 {
+		return heightA > heightB
+	}
+
+	aboveA, aboveB := heightA >= maxHeight, heightB >= maxHeight
+	switch {
+	case aboveA && aboveB:
+		return heightA < heightB
+	case aboveA != aboveB:
+		return aboveA
+	default:
+		return heightA > heightB
+	}
+}
+
+// resolutionHeight is the height of a parsed resolution like "1080p", 0 when
+// unknown
+func resolutionHeight(resolution string) int {
+	height, err := strconv.Atoi(strings.TrimSuffix(resolution, "p"))
+	if err != nil {
+		return 0
+	}
+
+	return height
+}
+
+// normalizeCodec names a codec the way the release parser does, "hevc" and
+// "x265" are "H.265"
+func normalizeCodec(codec string) string {
+	token := strings.ReplaceAll(strings.ToLower(strings.TrimSpace(codec)), ".", "")
+	if name, ok := codecs[token]; ok {
+		return name
+	}
+
+	return codec
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"localflix-server/src/models"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// registerVersionRoutes adds the routes listing the versions of a media item
+// and picking the one to play
+func (s *StreamService) registerVersionRoutes(app *fiber.App) {
+	app.Get("/items/:itemId/versions", s.requireScope(models.ScopeLibraryRead), s.rateLimit, s.listVersions)
+	app.Get("/items/:itemId/play", s.requireScope(models.ScopeLibraryRead), s.rateLimit, s.decidePlayback)
+}
+
+// listVersions lists the versions of the media item like the /files route,
+// itself included
+func (s *StreamService) listVersions(c *fiber.Ctx) error {
+	itemId, err := strconv.Atoi(c.Params("itemId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid item ID")
+	}
+
+	versions, err := s.mediaItemsService.ListVersions(itemId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return c.Status(fiber.StatusNotFound).SendString("Item not found")
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("Error listing versions")
+	}
+
+	files, err := s.mediaItemFiles(c, versions)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("Error retrieving folder")
+	}
+	return c.JSON(files)
+}
+
+// decidePlayback picks the version of the media item to play for the client
+// described by the max_height and codecs query parameters, codecs being a
+// comma separated list like "h264,hevc". The URL returned transcodes the
+// version when the client can't play any as is.
+func (s *StreamService) decidePlayback(c *fiber.Ctx) error {
+	itemId, err := strconv.Atoi(c.Params("itemId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid item ID")
+	}
+
+	client := models.PlaybackClient{MaxHeight: c.QueryInt("max_height")}
+	if client.MaxHeight < 0 {
+		return c.Status(fiber.StatusBadRequest).SendString("Invalid max_height")
+	}
+	for _, codec := range strings.Split(c.Query("codecs"), ",") {
+		if codec = strings.TrimSpace(codec); codec != "" {
+			client.Codecs = append(client.Codecs, codec)
+		}
+	}
+
+	version, transcode, err := s.mediaItemsService.ChooseVersion(itemId, client)
+	if errors.Is(err, sql.ErrNoRows) {
+		return c.Status(fiber.StatusNotFound).SendString("Item not found")
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("Error choosing version")
+	}
+
+	files, err := s.mediaItemFiles(c, []models.MediaItem{*version})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("Error retrieving folder")
+	}
+	// Stacked versions play their parts through the playlist, or transcoded
+	// together
+	decision := models.PlaybackDecision{File: files[0], Transcode: transcode, URL: files[0].URL}
+	switch {
+	case transcode && version.StackID != 0:
+		decision.URL = fmt.Sprintf("%s/items/%d/transcode", c.BaseURL(), version.ID)
+	case transcode:
+		decision.URL = fmt.Sprintf("%s/transcode/%d/%s", c.BaseURL(), version.FolderID, url.PathEscape(version.RelPath))
+	case version.StackID != 0:
+		decision.URL = files[0].PlaylistURL
+	}
+	if transcode {
+		if client.MaxHeight > 0 {
+			decision.URL += "?max_height=" + strconv.Itoa(client.MaxHeight)
+		}
+		decision.URL = withApiKey(c, decision.URL)
+	}
+
+	return c.JSON(decision)
+}
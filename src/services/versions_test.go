@@ -0,0 +1,315 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"localflix-server/src/logging"
+	"localflix-server/src/models"
+	"localflix-server/src/repositories"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// versionNames maps the name of every media item to the sorted names of its
+// other versions
+func versionNames(t *testing.T, library *testLibrary) map[string][]string {
+	t.Helper()
+
+	items, err := repositories.NewMediaItemsRepository(library.db).ListMediaItems()
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups := map[int][]string{}
+	for _, item := range items {
+		if item.VersionGroup != 0 {
+			groups[item.VersionGroup] = append(groups[item.VersionGroup], item.Name)
+		}
+	}
+
+	names := map[string][]string{}
+	for _, item := range items {
+		var others []string
+		for _, name := range groups[item.VersionGroup] {
+			if name != item.Name {
+				others = append(others, name)
+			}
+		}
+		slices.Sort(others)
+		names[item.Name] = others
+	}
+	return names
+}
+
+func TestScanGroupsVersions(t *testing.T) {
+	library := newTestLibrary(t)
+	category, err := library.categories.CreateCategory("Movies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hd, err := library.folders.CreateFolder(makeDir(t,
+		"Dune.2021.1080p.x264.mkv",
+		"Dune.1984.1080p.mkv",
+		"Heat.1995.mkv",
+		"Untitled.mkv",
+		"Show/Season 1/Show.S01E01.720p.mkv",
+		"Show/Season 1/Show.S01E02.720p.mkv",
+	), category.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uhd, err := library.folders.CreateFolder(makeDir(t,
+		"Dune (2021)/Dune (2021) 2160p x265.mkv",
+		"Untitled.mp4",
+		"Show/Season 1/Show.S01E01.2160p.mkv",
+	), category.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scan := NewScanService(context.Background(), library.db, NewFakeMediaToolkit(), logging.Discard())
+	if _, err := scan.ScanFolder(hd.ID); err != nil {
+		t.Fatal(err)
+	}
+	result, err := scan.ScanFolder(uhd.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Versions != 6 {
+		t.Errorf("got %d items with versions, want 6", result.Versions)
+	}
+	want := map[string][]string{
+		"Dune.2021.1080p.x264.mkv":   {"Dune (2021) 2160p x265.mkv"},
+		"Dune (2021) 2160p x265.mkv": {"Dune.2021.1080p.x264.mkv"},
+		"Show.S01E01.720p.mkv":       {"Show.S01E01.2160p.mkv"},
+		"Show.S01E01.2160p.mkv":      {"Show.S01E01.720p.mkv"},
+		"Dune.1984.1080p.mkv":        nil,
+		"Heat.1995.mkv":              nil,
+		"Untitled.mkv":               {"Untitled.mp4"},
+		"Untitled.mp4":               {"Untitled.mkv"},
+		"Show.S01E02.720p.mkv":       nil,
+	}
+	if got := versionNames(t, library); !mapsEqual(got, want) {
+		t.Errorf("got versions %v, want %v", got, want)
+	}
+
+	// Yearless titles are only versions when their durations are known to
+	// match, durations far apart are different cuts
+	updates := []struct {
+		duration float64
+		name     string
+	}{
+		{0, "Untitled.mp4"},
+		{150 * 60, "Dune (2021) 2160p x265.mkv"},
+	}
+	for _, update := range updates {
+		if _, err := library.db.Exec("UPDATE media_items SET duration = ? WHERE name = ?", update.duration, update.name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := scan.ScanFolder(hd.ID); err != nil {
+		t.Fatal(err)
+	}
+	got := versionNames(t, library)
+	if got["Untitled.mkv"] != nil || got["Dune.2021.1080p.x264.mkv"] != nil || got["Show.S01E01.720p.mkv"] == nil {
+		t.Errorf("got versions %v after changing durations", got)
+	}
+}
+
+func TestScanGroupsCopiesByContentHash(t *testing.T) {
+	library := newTestLibrary(t)
+	category, err := library.categories.CreateCategory("Movies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	content := strings.Repeat("frame", 30*1024)
+	for _, name := range []string{"Holiday.mkv", "backup/VID_0001.mkv", "Other.mkv"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		data := content
+		if name == "Other.mkv" {
+			data = "x" + content[1:]
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	folder, err := library.folders.CreateFolder(dir, category.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scan := NewScanService(context.Background(), library.db, NewFakeMediaToolkit(), logging.Discard())
+	if _, err := scan.ScanFolder(folder.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := versionNames(t, library); len(got["Holiday.mkv"]) != 0 {
+		t.Errorf("got versions %v without content hashing", got)
+	}
+
+	// Files already scanned are hashed once hashing is enabled
+	if _, err := NewSettingsService(context.Background(), library.db, logging.Discard()).UpdateScanSettings(models.ScanSettings{HashContent: true}); err != nil {
+		t.Fatal(err)
+	}
+	result, err := scan.ScanFolder(folder.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Unchanged != 3 || result.Versions != 2 {
+		t.Errorf("got %+v, want 3 unchanged items and 2 with versions", result)
+	}
+	if got := versionNames(t, library); !slices.Equal(got["Holiday.mkv"], []string{"VID_0001.mkv"}) || got["Other.mkv"] != nil {
+		t.Errorf("got versions %v, want the copies grouped", got)
+	}
+}
+
+func TestScanLibraryGroupsVersionsOnce(t *testing.T) {
+	library := newTestLibrary(t)
+	category, err := library.categories.CreateCategory("Movies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"Heat.1995.1080p.mkv", "Heat.1995.2160p.mkv"} {
+		if _, err := library.folders.CreateFolder(makeDir(t, name), category.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, err := NewScanService(context.Background(), library.db, NewFakeMediaToolkit(), logging.Discard()).ScanLibrary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, result := range results {
+		if result.Added != 1 || result.Versions != 2 {
+			t.Errorf("got %+v, want the library versions in every result", result)
+		}
+	}
+	want := map[string][]string{
+		"Heat.1995.1080p.mkv": {"Heat.1995.2160p.mkv"},
+		"Heat.1995.2160p.mkv": {"Heat.1995.1080p.mkv"},
+	}
+	if got := versionNames(t, library); len(results) != 2 || !mapsEqual(got, want) {
+		t.Errorf("got versions %v in %d results, want %v", got, len(results), want)
+	}
+}
+
+func TestChooseVersion(t *testing.T) {
+	versions := []*models.MediaItem{
+		{ID: 1, Name: "720p", Release: models.ReleaseInfo{Resolution: "720p", Codec: "H.264"}},
+		{ID: 2, Name: "2160p", Release: models.ReleaseInfo{Resolution: "2160p", Codec: "H.265"}},
+		{ID: 3, Name: "1080p", Release: models.ReleaseInfo{Resolution: "1080p", Codec: "H.265"}},
+		{ID: 4, Name: "1080p remux", Size: 10, Release: models.ReleaseInfo{Resolution: "1080p", Codec: "H.265"}},
+	}
+	tests := []struct {
+		client    models.PlaybackClient
+		want      string
+		transcode bool
+	}{
+		{models.PlaybackClient{}, "2160p", false},
+		{models.PlaybackClient{MaxHeight: 1080}, "1080p remux", false},
+		{models.PlaybackClient{Codecs: []string{"avc"}}, "720p", false},
+		{models.PlaybackClient{MaxHeight: 1080, Codecs: []string{"x265", "h.264"}}, "1080p remux", false},
+		{models.PlaybackClient{MaxHeight: 480}, "720p", true},
+		{models.PlaybackClient{MaxHeight: 1080, Codecs: []string{"vp9"}}, "1080p remux", true},
+		{models.PlaybackClient{MaxHeight: 2160, Codecs: []string{"av1"}}, "2160p", true},
+	}
+	for _, test := range tests {
+		version, transcode := chooseVersion(versions, test.client)
+		if version.Name != test.want || transcode != test.transcode {
+			t.Errorf("%+v: got %s, transcode %v, want %s, transcode %v", test.client, version.Name, transcode, test.want, test.transcode)
+		}
+	}
+}
+
+func TestVersionRoutes(t *testing.T) {
+	library := newTestLibrary(t)
+	category, err := library.categories.CreateCategory("Movies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hd, err := library.folders.CreateFolder(makeDir(t, "Dune.2021.1080p.x264.mkv"), category.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uhd, err := library.folders.CreateFolder(makeDir(t, "Dune.2021.2160p.x265.mkv"), category.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scan := NewScanService(context.Background(), library.db, NewFakeMediaToolkit(), logging.Discard())
+	for _, folder := range []*models.Folder{hd, uhd} {
+		if _, err := scan.ScanFolder(folder.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	app := newTestStreamApp(t, library, NewFakeMediaToolkit())
+
+	status, body := get(t, app, "/files/"+strconv.Itoa(hd.ID))
+	var files []models.File
+	if err := json.Unmarshal([]byte(body), &files); err != nil || status != fiber.StatusOK {
+		t.Fatalf("got %d %s", status, body)
+	}
+	if len(files) != 1 || len(files[0].Versions) != 1 {
+		t.Fatalf("got %s, want the 4K version listed", body)
+	}
+	version := files[0].Versions[0]
+	if version.Name != "Dune.2021.2160p.x265.mkv" || version.FolderID != uhd.ID || !strings.HasSuffix(version.URL, "/stream/"+strconv.Itoa(uhd.ID)+"/Dune.2021.2160p.x265.mkv") {
+		t.Errorf("got version %+v", version)
+	}
+
+	item := strconv.Itoa(files[0].MediaItemID)
+	status, body = get(t, app, "/items/"+item+"/versions")
+	if err := json.Unmarshal([]byte(body), &files); err != nil || len(files) != 2 {
+		t.Errorf("got %d %s, want both versions", status, body)
+	}
+
+	tests := []struct {
+		query     string
+		want      string
+		transcode bool
+	}{
+		{"", "Dune.2021.2160p.x265.mkv", false},
+		{"?max_height=1080&codecs=h264,hevc", "Dune.2021.1080p.x264.mkv", false},
+		{"?max_height=720", "Dune.2021.1080p.x264.mkv", true},
+	}
+	for _, test := range tests {
+		status, body := get(t, app, "/items/"+item+"/play"+test.query)
+		var decision models.PlaybackDecision
+		if err := json.Unmarshal([]byte(body), &decision); err != nil {
+			t.Fatalf("got %d %s", status, body)
+		}
+		if decision.File.Name != test.want || decision.Transcode != test.transcode {
+			t.Errorf("%s: got %s", test.query, body)
+		}
+		if test.transcode && !strings.HasSuffix(decision.URL, "/transcode/"+strconv.Itoa(hd.ID)+"/Dune.2021.1080p.x264.mkv?max_height=720") {
+			t.Errorf("%s: got URL %s", test.query, decision.URL)
+		}
+	}
+
+	if status, _ := get(t, app, "/items/999/play"); status != fiber.StatusNotFound {
+		t.Errorf("got status %d for an unknown item, want 404", status)
+	}
+	if status, _ := get(t, app, "/items/"+item+"/play?max_height=-1"); status != fiber.StatusBadRequest {
+		t.Errorf("got status %d for a negative max_height, want 400", status)
+	}
+}
+
+func mapsEqual(a map[string][]string, b map[string][]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, value := range a {
+		other, ok := b[key]
+		if !ok || !slices.Equal(value, other) {
+			return false
+		}
+	}
+	return true
+}
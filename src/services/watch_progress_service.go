@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"localflix-server/src/models"
+	"localflix-server/src/repositories"
+	"log/slog"
+	"time"
+)
+
+// watchedFraction is how much of a media item has to be played for it to be
+// watched, the rest usually being credits
+const watchedFraction = 0.9
+
+type WatchProgressService struct {
+	ctx                     context.Context
+	watchProgressRepository *repositories.WatchProgressRepository
+	logger                  *slog.Logger
+}
+
+// NewWatchProgressService creates a new WatchProgressService struct
+func NewWatchProgressService(ctx context.Context, db *sql.DB, logger *slog.Logger) *WatchProgressService {
+	return &WatchProgressService{
+		ctx:                     ctx,
+		watchProgressRepository: repositories.NewWatchProgressRepository(db),
+		logger:                  logger,
+	}
+}
+
+// RecordProgress stores how far the user got into the media item, watched
+// past watchedFraction of its duration. Like audit events, failing to store
+// it never fails the stream, the error is only logged.
+func (w *WatchProgressService) RecordProgress(progress models.WatchProgress) {
+	progress.Watched = progress.Duration > 0 && progress.Position >= watchedFraction*progress.Duration
+	progress.UpdatedAt = time.Now().UTC()
+
+	if err := w.watchProgressRepository.SaveProgress(progress); err != nil {
+		w.logger.Error("recording watch progress", "media_item_id", progress.MediaItemID, "err", err)
+	}
+}
+
+// ListProgress returns the progress of the user, last watched first
+func (w *WatchProgressService) ListProgress(userId int) ([]models.WatchProgress, error) {
+	progresses, err := w.watchProgressRepository.ListProgress(userId)
+	if err != nil {
+		w.logger.Error("listing watch progress", "user_id", userId, "err", err)
+		return nil, err
+	}
+
+	result := make([]models.WatchProgress, len(progresses))
+	for i, progress := range progresses {
+		result[i] = *progress
+	}
+	return result, nil
+}